@@ -234,8 +234,8 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create client: %w", err)
 	}
 
-	// Test the connection by listing secrets
-	if _, err := client.ListSecrets([]string{}); err != nil {
+	// Test the connection; failures carry a diagnosis of their cause
+	if err := client.TestConnection(); err != nil {
 		return fmt.Errorf("failed to test KSM connection: %w", err)
 	}
 
@@ -112,16 +112,22 @@ func runProfilesList(cmd *cobra.Command, args []string) error {
 
 	// Display profiles in a table
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "PROFILE\tDEFAULT")
-	fmt.Fprintln(w, "-------\t-------")
+	fmt.Fprintln(w, "PROFILE\tDEFAULT\tHOSTNAME\tREGION\tCLIENT ID")
+	fmt.Fprintln(w, "-------\t-------\t--------\t------\t---------")
 
+	allMetadata := store.GetProfileMetadata()
 	for _, name := range profileNames {
 		isDefault := ""
 		if name == cfg.Profiles.Default {
 			isDefault = "✓"
 		}
+		metadata := allMetadata[name]
+		region := metadata.Region
+		if region == "" {
+			region = "-"
+		}
 
-		fmt.Fprintf(w, "%s\t%s\n", name, isDefault)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, isDefault, metadata.Hostname, region, metadata.ClientID)
 	}
 	_ = w.Flush()
 
@@ -3,10 +3,13 @@ package commands
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,6 +19,8 @@ import (
 	"github.com/keeper-security/ksm-mcp/internal/mcp"
 	"github.com/keeper-security/ksm-mcp/internal/recordtemplates"
 	"github.com/keeper-security/ksm-mcp/internal/storage"
+	"github.com/keeper-security/ksm-mcp/internal/ui"
+	"github.com/keeper-security/ksm-mcp/internal/validation"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 	"github.com/spf13/cobra"
 )
@@ -24,9 +29,12 @@ var (
 	serveBatch        bool
 	serveAutoApprove  bool
 	serveTimeout      time.Duration
+	serveBulkTimeout  time.Duration
 	serveLogLevel     string
 	serveConfigBase64 string // Add CLI flag for base64 config
 	serveNoLogs       bool   // Add flag to disable logging
+	serveMaskNotes    bool   // Mask record notes unless unmasked
+	serveHealthAddr   string // Address for the optional /healthz HTTP listener
 	// profile flag is defined in root.go and available here
 )
 
@@ -56,7 +64,10 @@ Examples:
   ksm-mcp serve --batch
 
   # Auto-approve all operations (use with caution!)
-  ksm-mcp serve --auto-approve --timeout 30s`,
+  ksm-mcp serve --auto-approve --timeout 30s
+
+  # Expose /healthz on local port 8080 for Docker health checks
+  ksm-mcp serve --health-addr 127.0.0.1:8080`,
 	RunE: runServe,
 }
 
@@ -70,9 +81,12 @@ func init() {
 	serveCmd.Flags().BoolVar(&serveBatch, "batch", false, "enable batch mode (no interactive prompts)")
 	serveCmd.Flags().BoolVar(&serveAutoApprove, "auto-approve", false, "auto-approve all operations (dangerous)")
 	serveCmd.Flags().DurationVar(&serveTimeout, "timeout", 30*time.Second, "operation timeout")
+	serveCmd.Flags().DurationVar(&serveBulkTimeout, "bulk-timeout", 0, "stop tool calls running longer than this and return partial results (0 disables)")
 	serveCmd.Flags().StringVar(&serveLogLevel, "log-level", "info", "logging level (debug, info, warn, error)")
 	serveCmd.Flags().StringVar(&serveConfigBase64, "config-base64", "", "base64-encoded KSM configuration (bypasses profile loading)")
 	serveCmd.Flags().BoolVar(&serveNoLogs, "no-logs", false, "disable audit logging")
+	serveCmd.Flags().BoolVar(&serveMaskNotes, "mask-notes", false, "mask record notes unless the secret is explicitly unmasked")
+	serveCmd.Flags().StringVar(&serveHealthAddr, "health-addr", "", "serve a plain HTTP /healthz endpoint on this address (e.g. 127.0.0.1:8080) for container probes")
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
@@ -92,19 +106,23 @@ func runServe(cmd *cobra.Command, args []string) error {
 	var envVarProfile *types.Profile
 	var finalProfileToUse *types.Profile
 	var store storage.ProfileStoreInterface
+	var cfg *config.Config
 
 	// Attempt to load configuration from CLI flag first, then environment variable
 	// 'profile' is the global variable bound to the --profile flag from root.go
 	profileNameFromFlag := profile
 	var configBase64 string
+	var configBase64Source string
 
 	// Priority 1: CLI flag --config-base64
 	if serveConfigBase64 != "" {
 		configBase64 = serveConfigBase64
+		configBase64Source = "--config-base64 flag"
 		// fmt.Fprintf(os.Stderr, "Using KSM configuration from --config-base64 flag\\n")
 	} else if envConfigBase64 := os.Getenv("KSM_CONFIG_BASE64"); envConfigBase64 != "" {
 		// Priority 2: Environment variable KSM_CONFIG_BASE64
 		configBase64 = envConfigBase64
+		configBase64Source = "KSM_CONFIG_BASE64 environment variable"
 		// fmt.Fprintf(os.Stderr, "Using KSM configuration from KSM_CONFIG_BASE64 environment variable\\n")
 	}
 
@@ -118,7 +136,7 @@ func runServe(cmd *cobra.Command, args []string) error {
 			envVarProfile = prof
 			// fmt.Fprintf(os.Stderr, "Loaded KSM configuration for profile '%s'\\n", envVarProfile.Name)
 		} else {
-			return fmt.Errorf("failed to load KSM configuration from base64: %w", err)
+			return fmt.Errorf("failed to load KSM configuration from %s: %w", configBase64Source, err)
 		}
 	}
 
@@ -126,6 +144,8 @@ func runServe(cmd *cobra.Command, args []string) error {
 		// Priority 1: Use profile from base64 config (CLI flag or env var) if loaded
 		store = &inMemoryProfileStore{profile: envVarProfile}
 		finalProfileToUse = envVarProfile
+		// config.yaml is optional here; server settings still apply if one exists
+		cfg = loadOptionalConfig()
 		// fmt.Fprintf(os.Stderr, "Using KSM configuration for profile '%s' (in-memory)\\n", finalProfileToUse.Name)
 	} else {
 		// Priority 2: Fall back to file-based profiles if no base64 config provided
@@ -138,7 +158,8 @@ func runServe(cmd *cobra.Command, args []string) error {
 			configDir = filepath.Join(home, ".keeper", "ksm-mcp")
 		}
 
-		cfg, err := config.LoadOrCreate(filepath.Join(configDir, "config.yaml"))
+		var err error
+		cfg, err = config.LoadOrCreate(filepath.Join(configDir, "config.yaml"))
 		if err != nil {
 			return fmt.Errorf("failed to load config.yaml: %w. Please run 'ksm-mcp init', set KSM_CONFIG_BASE64, or use --config-base64", err)
 		}
@@ -217,6 +238,24 @@ func runServe(cmd *cobra.Command, args []string) error {
 			fmt.Fprintf(os.Stderr, "Warning: failed to create log directory at %s: %v\\n", filepath.Join(logConfigDir, "logs"), err)
 		}
 
+		// Forward audit events to a remote sink as well, when configured
+		var sinks []io.WriteCloser
+		if remote := cfg.Logging.RemoteSink; remote.Protocol != "" {
+			sink, err := audit.NewRemoteSink(audit.SinkConfig{
+				Protocol:      remote.Protocol,
+				Address:       remote.Address,
+				Network:       remote.Network,
+				AuthToken:     remote.AuthToken,
+				BufferSize:    remote.BufferSize,
+				FlushInterval: remote.FlushInterval,
+				MaxRetries:    remote.MaxRetries,
+			})
+			if err != nil {
+				return fmt.Errorf("invalid logging.remote_sink: %w", err)
+			}
+			sinks = append(sinks, sink)
+		}
+
 		// Create audit logger
 		logPath := filepath.Join(logConfigDir, "logs", "audit.log")
 		var err error
@@ -224,8 +263,12 @@ func runServe(cmd *cobra.Command, args []string) error {
 			FilePath: logPath,
 			MaxSize:  10 * 1024 * 1024, // 10MB
 			MaxAge:   24 * time.Hour,
+			Sinks:    sinks,
 		})
 		if err != nil {
+			for _, sink := range sinks {
+				_ = sink.Close()
+			}
 			return fmt.Errorf("failed to create audit logger: %w", err)
 		}
 		defer logger.Close()
@@ -236,14 +279,132 @@ func runServe(cmd *cobra.Command, args []string) error {
 		serveBatch = true
 	}
 
+	redactionPatterns, err := mcp.CompileRedactionPatterns(cfg.Security.RedactionPatterns)
+	if err != nil {
+		return fmt.Errorf("invalid security configuration: %w", err)
+	}
+
+	confirmationBackend, err := ui.NewBackend(cfg.Security.ConfirmationBackend, types.Confirmation{
+		BatchMode:   serveBatch,
+		AutoApprove: serveAutoApprove,
+		Timeout:     cfg.Security.ConfirmationTimeout,
+	}, ui.WebhookConfig{
+		URL:       cfg.Security.ConfirmationWebhook.URL,
+		AuthToken: cfg.Security.ConfirmationWebhook.AuthToken,
+		Timeout:   cfg.Security.ConfirmationWebhook.Timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid security.confirmation_backend: %w", err)
+	}
+
+	switch cfg.MCP.SearchEmptyResult {
+	case "", mcp.SearchEmptyResultList, mcp.SearchEmptyResultNotFound:
+	default:
+		return fmt.Errorf("invalid mcp.search_empty_result %q: expected %q or %q", cfg.MCP.SearchEmptyResult, mcp.SearchEmptyResultList, mcp.SearchEmptyResultNotFound)
+	}
+	dateFormat, err := mcp.ParseDateFormat(cfg.MCP.DateFormat, cfg.MCP.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid mcp date settings: %w", err)
+	}
+	if cfg.MCP.NotationIndexBase != 0 && cfg.MCP.NotationIndexBase != 1 {
+		return fmt.Errorf("invalid mcp.notation_index_base %d: expected 0 or 1", cfg.MCP.NotationIndexBase)
+	}
+	if length := cfg.Security.DefaultPasswordLength; length != 0 {
+		if length < ksm.MinPasswordLength || length > ksm.MaxPasswordLength {
+			return fmt.Errorf("invalid security.default_password_length %d: expected %d to %d", length, ksm.MinPasswordLength, ksm.MaxPasswordLength)
+		}
+		minLength := validation.DefaultPasswordPolicy().MinLength
+		if cfg.Security.PasswordPolicy != nil {
+			minLength = cfg.Security.PasswordPolicy.MinLength
+		}
+		if length < minLength {
+			return fmt.Errorf("invalid security.default_password_length %d: below the password policy minimum of %d", length, minLength)
+		}
+	}
+	if cfg.Security.MaxURLUploadBytes < 0 {
+		return fmt.Errorf("invalid security.max_url_upload_bytes %d: must not be negative", cfg.Security.MaxURLUploadBytes)
+	}
+	if cfg.MCP.MaxNotesLength < 0 {
+		return fmt.Errorf("invalid mcp.max_notes_length %d: must not be negative", cfg.MCP.MaxNotesLength)
+	}
+	for _, folderUID := range cfg.Security.ConfirmationBypassFolders {
+		if strings.TrimSpace(folderUID) == "" {
+			return fmt.Errorf("invalid security.confirmation_bypass_folders: folder UIDs must not be empty")
+		}
+	}
+	if err := validation.SetUIDLengthBounds(cfg.Security.UIDMinLength, cfg.Security.UIDMaxLength); err != nil {
+		return fmt.Errorf("invalid security.uid_min_length/uid_max_length: %w", err)
+	}
+	switch cfg.MCP.ConfirmationVerbosity {
+	case "", mcp.VerbosityVerbose, mcp.VerbosityConcise:
+	default:
+		return fmt.Errorf("invalid mcp.confirmation_verbosity %q: expected %q or %q", cfg.MCP.ConfirmationVerbosity, mcp.VerbosityVerbose, mcp.VerbosityConcise)
+	}
+	switch cfg.MCP.ResponseEnvelope {
+	case "", mcp.EnvelopeWrapped, mcp.EnvelopeRaw:
+	default:
+		return fmt.Errorf("invalid mcp.response_envelope %q: expected %q or %q", cfg.MCP.ResponseEnvelope, mcp.EnvelopeWrapped, mcp.EnvelopeRaw)
+	}
+	switch cfg.MCP.UnknownTypeSchema {
+	case "", mcp.UnknownTypeSynthesize, mcp.UnknownTypeError:
+	default:
+		return fmt.Errorf("invalid mcp.unknown_type_schema %q: expected %q or %q", cfg.MCP.UnknownTypeSchema, mcp.UnknownTypeSynthesize, mcp.UnknownTypeError)
+	}
+	switch cfg.MCP.FilesArray {
+	case "", mcp.FilesArrayPresent, mcp.FilesArrayOmit, mcp.FilesArrayAlways:
+	default:
+		return fmt.Errorf("invalid mcp.files_array %q: expected %q, %q or %q", cfg.MCP.FilesArray, mcp.FilesArrayPresent, mcp.FilesArrayOmit, mcp.FilesArrayAlways)
+	}
+	if secret := cfg.Security.ApprovalTokenSecret; secret != "" && len(secret) < mcp.MinApprovalTokenSecretLength {
+		return fmt.Errorf("invalid security.approval_token_secret: must be at least %d characters", mcp.MinApprovalTokenSecretLength)
+	}
+	if key := cfg.Security.FingerprintKey; key != "" && len(key) < ksm.MinFingerprintKeyLength {
+		return fmt.Errorf("invalid security.fingerprint_key: must be at least %d characters", ksm.MinFingerprintKeyLength)
+	}
+	endpointPin, err := ksm.ParseEndpointPin(cfg.Security.KSMEndpointPin.Hostnames, cfg.Security.KSMEndpointPin.PublicKeySHA256)
+	if err != nil {
+		return fmt.Errorf("invalid security.ksm_endpoint_pin: %w", err)
+	}
+
 	// Create MCP server with options
 	serverOpts := &mcp.ServerOptions{
 		BatchMode:   serveBatch,
 		AutoApprove: serveAutoApprove,
 		Timeout:     serveTimeout,
+		BulkTimeout: serveBulkTimeout,
 		ProfileName: finalProfileToUse.Name, // Use the name from the actually loaded/used profile
 		RateLimit:   100,                    // requests per minute
 		Version:     version,                // Use the package-level version variable
+		MaskNotes:   serveMaskNotes || cfg.Security.MaskNotes,
+
+		MaskBankOtherType:          cfg.Security.MaskBankOtherType,
+		PasswordPolicy:             cfg.Security.PasswordPolicy,
+		PasswordGenerationAttempts: cfg.Security.PasswordGenerationAttempts,
+		DefaultPasswordLength:      cfg.Security.DefaultPasswordLength,
+		RedactionPatterns:          redactionPatterns,
+		SearchEmptyResult:          cfg.MCP.SearchEmptyResult,
+		DefaultFolderDeleteForce:   cfg.Security.DefaultFolderDeleteForce,
+		MultiValueFieldTypes:       cfg.MCP.MultiValueFieldTypes,
+		TrimFieldValues:            cfg.MCP.TrimFieldValues,
+		NormalizeLineEndings:       cfg.MCP.NormalizeLineEndings,
+		DefaultFolderName:          strings.TrimSpace(cfg.MCP.DefaultFolderName),
+		TrimSensitiveFields:        cfg.MCP.TrimSensitiveFields,
+		MaxNotesLength:             cfg.MCP.MaxNotesLength,
+		ConfirmationVerbosity:      cfg.MCP.ConfirmationVerbosity,
+		ResponseEnvelope:           cfg.MCP.ResponseEnvelope,
+		UnknownRecordTypeSchema:    cfg.MCP.UnknownTypeSchema,
+		FilesArray:                 cfg.MCP.FilesArray,
+		NotationIndexBase:          cfg.MCP.NotationIndexBase,
+		DateFormat:                 dateFormat,
+		MaxUnmaskedRecords:         cfg.Security.MaxUnmaskedRecords,
+		MaxURLUploadBytes:          cfg.Security.MaxURLUploadBytes,
+		ConfirmationBackend:        confirmationBackend,
+		RequireExplicitUnmaskLog:   cfg.Security.RequireExplicitUnmaskLog,
+		UnmaskLogSummary:           cfg.Security.UnmaskLogSummary,
+		ConfirmationBypassFolders:  cfg.Security.ConfirmationBypassFolders,
+		ApprovalTokenSecret:        []byte(cfg.Security.ApprovalTokenSecret),
+		FingerprintKey:             []byte(cfg.Security.FingerprintKey),
+		EndpointPin:                endpointPin,
 	}
 
 	server := mcp.NewServer(store, logger, serverOpts)
@@ -261,6 +422,12 @@ func runServe(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	if serveHealthAddr != "" {
+		if err := server.StartHealthServer(ctx, serveHealthAddr); err != nil {
+			return err
+		}
+	}
+
 	// Start the server
 	// fmt.Fprintf(os.Stderr, "Server ready. Starting MCP server...\n")
 
@@ -272,6 +439,19 @@ func runServe(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// loadOptionalConfig loads config.yaml from the config directory if it exists,
+// falling back to defaults so base64-configured deployments need no file
+func loadOptionalConfig() *config.Config {
+	cfg, err := config.Load(filepath.Join(config.GetConfigDir(), "config.yaml"))
+	if err != nil {
+		if !errors.Is(err, config.ErrConfigNotFound) {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable config.yaml: %v\n", err)
+		}
+		return config.DefaultConfig()
+	}
+	return cfg
+}
+
 // dockerProfileStore is a simple in-memory profile store for Docker direct config
 // Rename to inMemoryProfileStore to reflect its broader use
 type inMemoryProfileStore struct {
@@ -319,16 +499,16 @@ func (d *inMemoryProfileStore) ProfileExists(name string) bool {
 // loadProfileFromBase64 loads a profile from base64-encoded KSM config
 // Modified to take the desired profileName as an argument
 func loadProfileFromBase64(profileName string, configBase64 string) (*types.Profile, error) {
-	// Decode base64
-	configData, err := base64.StdEncoding.DecodeString(configBase64)
+	// Decode base64, tolerating the surrounding whitespace env files and secrets often add
+	configData, err := base64.StdEncoding.DecodeString(strings.TrimSpace(configBase64))
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 config: %w", err)
+		return nil, fmt.Errorf("value is not valid base64 (encode the KSM config JSON with standard base64): %w", err)
 	}
 
-	// Initialize KSM config
+	// Initialize KSM config; this validates the JSON and its required fields
 	ksmConfig, err := ksm.InitializeWithConfig(configData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize KSM config: %w", err)
+		return nil, fmt.Errorf("invalid KSM config (expected JSON with clientId, privateKey and appKey): %w", err)
 	}
 
 	// Create profile
@@ -191,7 +191,7 @@ func runTest(cmd *cobra.Command, args []string) error {
 		// Test searching
 		fmt.Print("\n5. Testing search functionality... ")
 		searchStart := time.Now()
-		searchResults, err := client.SearchSecrets("")
+		searchResults, err := client.SearchSecrets("", nil)
 		searchElapsed := time.Since(searchStart)
 
 		if err != nil {
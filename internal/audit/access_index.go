@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordAccess is the most recent recorded use of a record through the server
+type RecordAccess struct {
+	LastAccessed time.Time `json:"last_accessed"`
+	LastAction   string    `json:"last_action"`  // e.g. SECRET_ACCESS, SECRET_UPDATE, get, download
+	AccessCount  int       `json:"access_count"` // successful operations on the record seen in the log
+}
+
+// AccessIndex maps record UIDs to their last successful access in the audit log. It
+// is built incrementally: each Refresh reads only the lines appended to the log file
+// since the previous one. When the log is rotated, the new file is indexed from its
+// start and the accesses already indexed are kept.
+type AccessIndex struct {
+	mu       sync.Mutex
+	logger   *Logger
+	offset   int64       // bytes of the log file already indexed
+	file     os.FileInfo // identity of the indexed log file
+	accesses map[string]*RecordAccess
+}
+
+// NewAccessIndex creates an empty access index over the logger's audit file
+func NewAccessIndex(logger *Logger) *AccessIndex {
+	return &AccessIndex{logger: logger, accesses: make(map[string]*RecordAccess)}
+}
+
+// accessQuery selects the successful events that can name a record
+func accessQuery() Query {
+	succeeded := true
+	return Query{
+		EventTypes: []EventType{EventSecretAccess, EventSecretUpdate, EventAccess},
+		Success:    &succeeded,
+	}
+}
+
+// Refresh indexes the events logged since the previous refresh
+func (x *AccessIndex) Refresh() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.logger.mu.Lock()
+	defer x.logger.mu.Unlock()
+
+	file, err := os.Open(x.logger.filepath)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	if x.file != nil && !os.SameFile(x.file, info) {
+		x.offset = 0 // rotated: index the new file from its start
+	}
+	if info.Size() < x.offset {
+		x.offset = 0 // the file was truncated or replaced in place
+	}
+	x.file = info
+
+	if _, err := file.Seek(x.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek audit log: %w", err)
+	}
+
+	query := accessQuery()
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A line without its newline is still being written; read it next time
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+		x.offset += int64(len(line))
+
+		var event AuditEvent
+		if json.Unmarshal(line, &event) != nil || !query.matches(&event) {
+			continue
+		}
+		for _, uid := range eventRecordUIDs(&event) {
+			x.record(uid, &event)
+		}
+	}
+}
+
+// Lookup returns the last access to a record, if one was indexed
+func (x *AccessIndex) Lookup(uid string) (RecordAccess, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	access, ok := x.accesses[uid]
+	if !ok {
+		return RecordAccess{}, false
+	}
+	return *access, true
+}
+
+// record notes an event on a record, keeping the most recent one
+func (x *AccessIndex) record(uid string, event *AuditEvent) {
+	access, ok := x.accesses[uid]
+	if !ok {
+		access = &RecordAccess{}
+		x.accesses[uid] = access
+	}
+	access.AccessCount++
+	if !event.Timestamp.Before(access.LastAccessed) {
+		access.LastAccessed = event.Timestamp
+		access.LastAction = event.Action
+	}
+}
+
+// eventRecordUIDs returns the UIDs of the records an event operated on
+func eventRecordUIDs(event *AuditEvent) []string {
+	switch {
+	case event.Type == EventSecretAccess || event.Type == EventSecretUpdate:
+		if event.Resource != "" {
+			return []string{event.Resource}
+		}
+	case event.Resource == "field" && event.Action == "get":
+		// The notation is logged as the user, e.g. "keeper://UID/field/password"
+		notation := strings.TrimPrefix(event.User, "keeper://")
+		if uid, _, _ := strings.Cut(notation, "/"); uid != "" {
+			return []string{uid}
+		}
+	case event.Resource == "file":
+		if event.User != "" {
+			return []string{event.User}
+		}
+	case event.Resource == "secrets" && event.Action == "get_batch":
+		uids, _ := event.Details["uids"].([]interface{})
+		result := make([]string, 0, len(uids))
+		for _, uid := range uids {
+			if s, ok := uid.(string); ok && s != "" {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
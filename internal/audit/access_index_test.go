@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAccessIndex(t *testing.T) {
+	logger := setupTestLogger(t)
+	defer logger.Close()
+
+	logger.LogSecretOperation(EventSecretAccess, "uid-read", "", "prod", true, nil)
+	logger.LogSecretOperation(EventSecretUpdate, "uid-read", "", "prod", true, nil)
+	logger.LogSecretOperation(EventSecretAccess, "uid-failed", "", "prod", false, nil)
+	logger.LogAccess("field", "get", "keeper://uid-field/field/password", "prod", true, nil)
+	logger.LogAccess("file", "download", "uid-file", "prod", true, nil)
+	logger.LogAccess("secrets", "get_batch", "", "prod", true, map[string]interface{}{"uids": []string{"uid-batch-1", "uid-batch-2"}})
+	logger.LogAccess("secrets", "list", "", "prod", true, nil)
+	time.Sleep(200 * time.Millisecond)
+
+	index := NewAccessIndex(logger)
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	access, ok := index.Lookup("uid-read")
+	if !ok {
+		t.Fatal("expected an access for uid-read")
+	}
+	if access.AccessCount != 2 || access.LastAction != string(EventSecretUpdate) || access.LastAccessed.IsZero() {
+		t.Errorf("unexpected access for uid-read: %+v", access)
+	}
+	for _, uid := range []string{"uid-field", "uid-file", "uid-batch-1", "uid-batch-2"} {
+		if _, ok := index.Lookup(uid); !ok {
+			t.Errorf("expected an access for %s", uid)
+		}
+	}
+	if _, ok := index.Lookup("uid-failed"); ok {
+		t.Error("failed operations should not count as accesses")
+	}
+
+	// A later refresh only reads the new events
+	logger.LogSecretOperation(EventSecretAccess, "uid-read", "", "prod", true, nil)
+	time.Sleep(200 * time.Millisecond)
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if access, _ := index.Lookup("uid-read"); access.AccessCount != 3 || access.LastAction != string(EventSecretAccess) {
+		t.Errorf("expected 3 accesses ending with SECRET_ACCESS after refresh, got %+v", access)
+	}
+
+	// After rotation the new file is indexed from its start and earlier accesses are kept
+	logger.mu.Lock()
+	logger.rotate()
+	logger.mu.Unlock()
+	logger.LogSecretOperation(EventSecretAccess, "uid-new", "", "prod", true, nil)
+	time.Sleep(200 * time.Millisecond)
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, ok := index.Lookup("uid-new"); !ok {
+		t.Error("expected an access for uid-new logged after rotation")
+	}
+	if access, _ := index.Lookup("uid-read"); access.AccessCount != 3 {
+		t.Errorf("expected accesses indexed before rotation to be kept, got %+v", access)
+	}
+}
+
+func TestAccessIndexLargeLog(t *testing.T) {
+	logger := setupTestLogger(t)
+	defer logger.Close()
+
+	const events = 1050
+	for i := 0; i < events; i++ {
+		logger.LogSecretOperation(EventSecretAccess, "uid-busy", "", "prod", true, nil)
+	}
+	time.Sleep(time.Second)
+
+	index := NewAccessIndex(logger)
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if access, _ := index.Lookup("uid-busy"); access.AccessCount != events {
+		t.Errorf("expected %d accesses, got %d", events, access.AccessCount)
+	}
+}
+
+func TestAccessIndexReadsAppendedLines(t *testing.T) {
+	logger := setupTestLogger(t)
+	defer logger.Close()
+
+	logger.LogSecretOperation(EventSecretAccess, "uid-read", "", "prod", true, nil)
+	time.Sleep(200 * time.Millisecond)
+
+	index := NewAccessIndex(logger)
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	info, err := os.Stat(logger.filepath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if index.offset != info.Size() {
+		t.Errorf("expected offset %d after refresh, got %d", info.Size(), index.offset)
+	}
+
+	// A partially written line is left for the next refresh
+	f, err := os.OpenFile(logger.filepath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	line := `{"timestamp":"2026-01-02T03:04:05Z","type":"SECRET_ACCESS","resource":"uid-partial","result":"SUCCESS"}`
+	if _, err := f.WriteString(line[:20]); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, ok := index.Lookup("uid-partial"); ok {
+		t.Error("a partial line should not be indexed")
+	}
+	if _, err := f.WriteString(line[20:] + "\n"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	f.Close()
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, ok := index.Lookup("uid-partial"); !ok {
+		t.Error("expected the completed line to be indexed")
+	}
+
+	// After truncation the file is indexed from its start
+	if err := os.Truncate(logger.filepath, 0); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	logger.LogSecretOperation(EventSecretAccess, "uid-after-truncate", "", "prod", true, nil)
+	time.Sleep(200 * time.Millisecond)
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, ok := index.Lookup("uid-after-truncate"); !ok {
+		t.Error("expected an access logged after truncation")
+	}
+}
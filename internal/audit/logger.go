@@ -3,8 +3,10 @@ package audit
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -73,6 +75,7 @@ type Logger struct {
 	maxSize   int64
 	maxAge    time.Duration
 	encoder   *json.Encoder
+	sinks     []io.WriteCloser
 	eventChan chan *AuditEvent
 	stopChan  chan struct{}
 	wg        sync.WaitGroup
@@ -81,8 +84,9 @@ type Logger struct {
 // Config represents logger configuration
 type Config struct {
 	FilePath string
-	MaxSize  int64         // Maximum file size in bytes
-	MaxAge   time.Duration // Maximum age of log files
+	MaxSize  int64            // Maximum file size in bytes
+	MaxAge   time.Duration    // Maximum age of log files
+	Sinks    []io.WriteCloser // Receive each event as a line of JSON, in addition to the file
 }
 
 // NewLogger creates a new audit logger
@@ -105,6 +109,7 @@ func NewLogger(config Config) (*Logger, error) {
 		maxSize:   config.MaxSize,
 		maxAge:    config.MaxAge,
 		encoder:   json.NewEncoder(file),
+		sinks:     config.Sinks,
 		eventChan: make(chan *AuditEvent, 100),
 		stopChan:  make(chan struct{}),
 	}
@@ -288,6 +293,17 @@ func (l *Logger) writeEvent(event *AuditEvent) {
 		fmt.Fprintf(os.Stderr, "Failed to write audit event: %v\n", err)
 	}
 
+	if len(l.sinks) > 0 {
+		if data, err := json.Marshal(event); err == nil {
+			data = append(data, '\n')
+			for _, sink := range l.sinks {
+				if _, err := sink.Write(data); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to forward audit event: %v\n", err)
+				}
+			}
+		}
+	}
+
 	// Check if rotation is needed
 	if l.maxSize > 0 {
 		if info, err := l.file.Stat(); err == nil && info.Size() > l.maxSize {
@@ -363,11 +379,20 @@ func (l *Logger) Close() error {
 	close(l.stopChan)
 	l.wg.Wait()
 
-	// Close file
+	// Detach the sinks and close the file, then close the sinks without holding the
+	// lock, since they flush buffered events over the network
 	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	return l.file.Close()
+	sinks := l.sinks
+	l.sinks = nil
+	err := l.file.Close()
+	l.mu.Unlock()
+
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to close audit sink: %v\n", err)
+		}
+	}
+	return err
 }
 
 // generateEventID generates a unique event ID
@@ -391,23 +416,60 @@ func isSensitiveKey(key string) bool {
 	return false
 }
 
-// Query represents an audit log query
+// Query represents an audit log query. Every filter that is set must match.
 type Query struct {
 	StartTime     time.Time
 	EndTime       time.Time
 	EventTypes    []EventType
 	Severities    []Severity
 	Users         []string
+	Profiles      []string
 	Resources     []string
 	CorrelationID string
+	Success       *bool  // true matches successful events; false matches failed, denied and error events
+	Offset        int    // matching events to skip before the first returned event
+	Cursor        string // NextCursor of a previous page; takes precedence over Offset
 	Limit         int
 }
 
+// SearchResult is one page of events matching a query
+type SearchResult struct {
+	Events     []*AuditEvent
+	Total      int    // matching events across all pages
+	NextCursor string // pass as Query.Cursor to read the next page; empty on the last page
+}
+
+// failedResults are the event results that count as unsuccessful
+var failedResults = map[string]bool{"FAILED": true, "DENIED": true, "ERROR": true}
+
 // Search searches the audit log (basic implementation)
 func (l *Logger) Search(query Query) ([]*AuditEvent, error) {
+	result, err := l.SearchPage(query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Events, nil
+}
+
+// SearchPage returns one page of matching events with the total match count and a
+// cursor for the next page. Pages follow log order, so appending events does not move
+// the events an existing cursor points past.
+func (l *Logger) SearchPage(query Query) (*SearchResult, error) {
 	// This is a basic implementation that reads the entire file
 	// For production, consider using a database or indexed storage
 
+	offset := query.Offset
+	if query.Cursor != "" {
+		parsed, err := strconv.Atoi(query.Cursor)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid audit log cursor %q", query.Cursor)
+		}
+		offset = parsed
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("audit log offset must not be negative: %d", offset)
+	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -418,7 +480,7 @@ func (l *Logger) Search(query Query) ([]*AuditEvent, error) {
 	}
 	defer file.Close()
 
-	var events []*AuditEvent
+	result := &SearchResult{}
 	decoder := json.NewDecoder(file)
 
 	for {
@@ -426,38 +488,56 @@ func (l *Logger) Search(query Query) ([]*AuditEvent, error) {
 		if err := decoder.Decode(&event); err != nil {
 			break // EOF or error
 		}
-
-		// Apply filters
-		if !query.StartTime.IsZero() && event.Timestamp.Before(query.StartTime) {
-			continue
-		}
-		if !query.EndTime.IsZero() && event.Timestamp.After(query.EndTime) {
-			continue
-		}
-		if len(query.EventTypes) > 0 && !contains(query.EventTypes, event.Type) {
+		if !query.matches(&event) {
 			continue
 		}
-		if len(query.Severities) > 0 && !containsSeverity(query.Severities, event.Severity) {
-			continue
-		}
-		if len(query.Users) > 0 && !containsString(query.Users, event.User) {
-			continue
-		}
-		if len(query.Resources) > 0 && !containsString(query.Resources, event.Resource) {
+
+		result.Total++
+		if result.Total <= offset {
 			continue
 		}
-		if query.CorrelationID != "" && event.CorrelationID != query.CorrelationID {
-			continue
+		if query.Limit > 0 && len(result.Events) >= query.Limit {
+			continue // keep counting matches beyond this page
 		}
+		result.Events = append(result.Events, &event)
+	}
 
-		events = append(events, &event)
-
-		if query.Limit > 0 && len(events) >= query.Limit {
-			break
-		}
+	if next := offset + len(result.Events); next < result.Total {
+		result.NextCursor = strconv.Itoa(next)
 	}
+	return result, nil
+}
 
-	return events, nil
+// matches reports whether an event satisfies every filter in the query
+func (q Query) matches(event *AuditEvent) bool {
+	if !q.StartTime.IsZero() && event.Timestamp.Before(q.StartTime) {
+		return false
+	}
+	if !q.EndTime.IsZero() && event.Timestamp.After(q.EndTime) {
+		return false
+	}
+	if len(q.EventTypes) > 0 && !contains(q.EventTypes, event.Type) {
+		return false
+	}
+	if len(q.Severities) > 0 && !containsSeverity(q.Severities, event.Severity) {
+		return false
+	}
+	if len(q.Users) > 0 && !containsString(q.Users, event.User) {
+		return false
+	}
+	if len(q.Profiles) > 0 && !containsString(q.Profiles, event.Profile) {
+		return false
+	}
+	if len(q.Resources) > 0 && !containsString(q.Resources, event.Resource) {
+		return false
+	}
+	if q.CorrelationID != "" && event.CorrelationID != q.CorrelationID {
+		return false
+	}
+	if q.Success != nil && *q.Success == failedResults[event.Result] {
+		return false
+	}
+	return true
 }
 
 // Helper functions
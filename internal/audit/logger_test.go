@@ -332,6 +332,85 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestSearchPage(t *testing.T) {
+	logger := setupTestLogger(t)
+	defer logger.Close()
+
+	for i := 0; i < 5; i++ {
+		logger.LogAccess(fmt.Sprintf("denied-%d", i), "read", "user1", "prod", false, nil)
+	}
+	logger.LogAccess("allowed-prod", "read", "user1", "prod", true, nil)
+	logger.LogAccess("denied-dev", "read", "user1", "dev", false, nil)
+	logger.LogAuth(false, "user2", "prod", nil)
+
+	time.Sleep(200 * time.Millisecond)
+
+	failed := false
+	query := Query{
+		EventTypes: []EventType{EventAccessDenied},
+		Profiles:   []string{"prod"},
+		Success:    &failed,
+		Limit:      2,
+	}
+
+	var resources []string
+	pages := 0
+	for {
+		page, err := logger.SearchPage(query)
+		if err != nil {
+			t.Fatalf("SearchPage failed: %v", err)
+		}
+		pages++
+		if page.Total != 5 {
+			t.Errorf("page %d: expected total of 5 matches, got %d", pages, page.Total)
+		}
+		if len(page.Events) > 2 {
+			t.Errorf("page %d: expected at most 2 events, got %d", pages, len(page.Events))
+		}
+		for _, event := range page.Events {
+			resources = append(resources, event.Resource)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+	expected := "denied-0,denied-1,denied-2,denied-3,denied-4"
+	if got := strings.Join(resources, ","); got != expected {
+		t.Errorf("expected resources %s, got %s", expected, got)
+	}
+
+	// Successful events only
+	succeeded := true
+	page, err := logger.SearchPage(Query{Profiles: []string{"prod"}, Success: &succeeded})
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	if page.Total != 1 || page.Events[0].Resource != "allowed-prod" {
+		t.Errorf("expected only the allowed prod access, got %d events", page.Total)
+	}
+
+	// Offset past the end returns no events but still counts matches
+	page, err = logger.SearchPage(Query{Profiles: []string{"prod"}, Offset: 10})
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	if len(page.Events) != 0 || page.Total != 7 || page.NextCursor != "" {
+		t.Errorf("expected empty last page with total 7, got %d events, total %d, cursor %q", len(page.Events), page.Total, page.NextCursor)
+	}
+
+	if _, err := logger.SearchPage(Query{Cursor: "not-a-cursor"}); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+	if _, err := logger.SearchPage(Query{Offset: -1}); err == nil {
+		t.Error("expected error for negative offset")
+	}
+}
+
 func TestConcurrentLogging(t *testing.T) {
 	logger := setupTestLogger(t)
 	defer logger.Close()
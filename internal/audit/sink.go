@@ -0,0 +1,378 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Remote sink protocols accepted for logging.remote_sink.protocol
+const (
+	SinkSyslog = "syslog" // RFC 5424 messages over TCP (one per line), TLS (RFC 5425) or UDP
+	SinkHTTP   = "http"   // JSON arrays of events POSTed to a collector
+)
+
+// Defaults applied when a SinkConfig field is zero
+const (
+	defaultSinkBufferSize    = 1000
+	defaultSinkBatchSize     = 50
+	defaultSinkFlushInterval = 5 * time.Second
+	defaultSinkMaxRetries    = 3
+	defaultSinkRetryBackoff  = time.Second
+	defaultSinkTimeout       = 10 * time.Second
+)
+
+// syslogFacility is the "log audit" facility of RFC 5424
+const syslogFacility = 13
+
+// SinkConfig configures a remote audit sink
+type SinkConfig struct {
+	Protocol      string        // SinkSyslog or SinkHTTP
+	Address       string        // host:port for syslog, http(s) URL for HTTP
+	Network       string        // "tcp" (default), "tls" or "udp"; syslog only
+	TLSConfig     *tls.Config   // client settings for syslog over TLS and https; nil verifies against the system roots
+	AuthToken     string        // sent as "Authorization: Bearer <token>"; HTTP only, and https URLs only
+	BufferSize    int           // events kept while the endpoint is unreachable; the oldest are dropped beyond it
+	BatchSize     int           // events sent together
+	FlushInterval time.Duration // how often buffered events are sent
+	MaxRetries    int           // extra attempts for a failed batch before it waits for the next flush; negative disables retries
+	RetryBackoff  time.Duration // delay before the first retry, doubled for each further one
+	Timeout       time.Duration // bound on each connection or request
+}
+
+// RemoteSink forwards audit events to a syslog or HTTP endpoint so they reach a
+// SIEM without scraping the local file. Each Write queues one JSON-encoded event;
+// a background worker sends queued events in batches, retrying failed batches and
+// keeping them buffered until the endpoint is reachable again.
+type RemoteSink struct {
+	config   SinkConfig
+	hostname string
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending [][]byte
+	dropped int
+	conn    net.Conn
+
+	notify   chan struct{}
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*RemoteSink)(nil)
+
+// NewRemoteSink validates config and starts the sink's background worker
+func NewRemoteSink(config SinkConfig) (*RemoteSink, error) {
+	switch config.Protocol {
+	case SinkSyslog:
+		if config.Network == "" {
+			config.Network = "tcp"
+		}
+		if config.Network != "tcp" && config.Network != "tls" && config.Network != "udp" {
+			return nil, fmt.Errorf("unknown syslog network %q: expected \"tcp\", \"tls\" or \"udp\"", config.Network)
+		}
+		if _, _, err := net.SplitHostPort(config.Address); err != nil {
+			return nil, fmt.Errorf("syslog address must be host:port: %w", err)
+		}
+	case SinkHTTP:
+		parsed, err := url.Parse(config.Address)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("HTTP sink address must be an http or https URL: %s", config.Address)
+		}
+		if config.AuthToken != "" && parsed.Scheme != "https" {
+			return nil, errors.New("HTTP sink with an auth token must use an https URL so the token is not sent in the clear")
+		}
+	default:
+		return nil, fmt.Errorf("unknown audit sink protocol %q: expected %q or %q", config.Protocol, SinkSyslog, SinkHTTP)
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultSinkBufferSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultSinkBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultSinkFlushInterval
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultSinkMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultSinkRetryBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultSinkTimeout
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	client := &http.Client{Timeout: config.Timeout}
+	if config.TLSConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config.TLSConfig
+		client.Transport = transport
+	}
+
+	sink := &RemoteSink{
+		config:   config,
+		hostname: hostname,
+		client:   client,
+		notify:   make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+	sink.wg.Add(1)
+	go sink.worker()
+	return sink, nil
+}
+
+// Write queues one JSON-encoded audit event. It never blocks on the network; when
+// the buffer is full the oldest queued event is dropped.
+func (s *RemoteSink) Write(p []byte) (int, error) {
+	event := bytes.TrimSpace(p)
+	if len(event) == 0 {
+		return len(p), nil
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, append([]byte(nil), event...))
+	if overflow := len(s.pending) - s.config.BufferSize; overflow > 0 {
+		s.pending = s.pending[overflow:]
+		s.dropped += overflow
+	}
+	full := len(s.pending) >= s.config.BatchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Close stops the worker after one last attempt to send the buffered events, and
+// reports any events that could not be delivered
+func (s *RemoteSink) Close() error {
+	close(s.stopChan)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if len(s.pending) > 0 {
+		err = fmt.Errorf("audit sink closed with %d undelivered event(s)", len(s.pending))
+	}
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// worker sends buffered events on every flush interval, and sooner once a batch is full
+func (s *RemoteSink) worker() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.notify:
+			s.flush()
+		case <-s.stopChan:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush sends the buffered events batch by batch. A batch that still fails after
+// its retries is put back at the front of the buffer for the next flush.
+func (s *RemoteSink) flush() {
+	for {
+		s.mu.Lock()
+		if s.dropped > 0 {
+			fmt.Fprintf(os.Stderr, "Audit sink buffer full: %d event(s) dropped\n", s.dropped)
+			s.dropped = 0
+		}
+		n := len(s.pending)
+		if n > s.config.BatchSize {
+			n = s.config.BatchSize
+		}
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := s.sendWithRetry(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to forward audit events: %v\n", err)
+			s.mu.Lock()
+			s.pending = append(batch, s.pending...)
+			if overflow := len(s.pending) - s.config.BufferSize; overflow > 0 {
+				s.pending = s.pending[overflow:]
+				s.dropped += overflow
+			}
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// sendWithRetry sends a batch, retrying with exponential backoff. Retries stop early
+// when the sink is closing.
+func (s *RemoteSink) sendWithRetry(batch [][]byte) error {
+	backoff := s.config.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = s.send(batch); err == nil || attempt >= s.config.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.stopChan:
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// send delivers a batch once over the configured protocol
+func (s *RemoteSink) send(batch [][]byte) error {
+	if s.config.Protocol == SinkHTTP {
+		return s.sendHTTP(batch)
+	}
+	return s.sendSyslog(batch)
+}
+
+// sendHTTP POSTs the batch as a JSON array of events
+func (s *RemoteSink) sendHTTP(batch [][]byte) error {
+	body := append([]byte{'['}, bytes.Join(batch, []byte{','})...)
+	body = append(body, ']')
+
+	request, err := http.NewRequest(http.MethodPost, s.config.Address, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build audit sink request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.config.AuthToken != "" {
+		request.Header.Set("Authorization", "Bearer "+s.config.AuthToken)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("audit sink request failed: %w", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("audit sink returned status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// sendSyslog writes one RFC 5424 message per event. The connection is opened on
+// first use and reopened after a failure. Over TLS each message is prefixed with its
+// length as RFC 5425 requires; over TCP messages end with a newline.
+func (s *RemoteSink) sendSyslog(batch [][]byte) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		var err error
+		conn, err = s.dialSyslog()
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog server: %w", err)
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+	}
+
+	for _, event := range batch {
+		message := s.syslogMessage(event)
+		switch s.config.Network {
+		case "tcp":
+			message = append(message, '\n')
+		case "tls":
+			message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+		if _, err := conn.Write(message); err != nil {
+			_ = conn.Close()
+			s.mu.Lock()
+			s.conn = nil
+			s.mu.Unlock()
+			return fmt.Errorf("failed to write to syslog server: %w", err)
+		}
+	}
+	return nil
+}
+
+// dialSyslog connects to the syslog server, over TLS when configured
+func (s *RemoteSink) dialSyslog() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.Timeout}
+	if s.config.Network != "tls" {
+		return dialer.Dial(s.config.Network, s.config.Address)
+	}
+	config := s.config.TLSConfig
+	if config == nil {
+		config = &tls.Config{}
+	}
+	return tls.DialWithDialer(dialer, "tcp", s.config.Address, config)
+}
+
+// syslogMessage formats an event as an RFC 5424 message whose body is the JSON event
+func (s *RemoteSink) syslogMessage(event []byte) []byte {
+	var header struct {
+		Timestamp time.Time `json:"timestamp"`
+		Severity  Severity  `json:"severity"`
+	}
+	_ = json.Unmarshal(event, &header)
+	if header.Timestamp.IsZero() {
+		header.Timestamp = time.Now().UTC()
+	}
+
+	priority := syslogFacility*8 + syslogSeverity(header.Severity)
+	prefix := fmt.Sprintf("<%d>1 %s %s ksm-mcp %d - - ", priority, header.Timestamp.Format(time.RFC3339Nano), s.hostname, os.Getpid())
+	return append([]byte(prefix), event...)
+}
+
+// syslogSeverity maps an audit severity to its RFC 5424 severity code
+func syslogSeverity(severity Severity) int {
+	switch severity {
+	case SeverityCritical:
+		return 2
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 4
+	case SeverityDebug:
+		return 7
+	default:
+		return 6
+	}
+}
@@ -0,0 +1,337 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a local HTTP endpoint recording the events POSTed to it. It answers
+// failures with 503 before accepting requests.
+type collector struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	auth     string
+	events   []AuditEvent
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	c.auth = r.Header.Get("Authorization")
+	var batch []AuditEvent
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.events = append(c.events, batch...)
+}
+
+func (c *collector) received() []AuditEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]AuditEvent(nil), c.events...)
+}
+
+// waitFor polls until cond holds or the deadline passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the sink")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func encodeEvent(t *testing.T, event *AuditEvent) []byte {
+	t.Helper()
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("failed to encode event: %v", err)
+	}
+	return append(data, '\n')
+}
+
+func TestRemoteSinkHTTPWithLogger(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewTLSServer(c)
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	sink, err := NewRemoteSink(SinkConfig{
+		Protocol:      SinkHTTP,
+		Address:       server.URL,
+		AuthToken:     "token",
+		TLSConfig:     &tls.Config{RootCAs: roots},
+		FlushInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewRemoteSink() unexpected error: %v", err)
+	}
+	logger, err := NewLogger(Config{FilePath: filepath.Join(t.TempDir(), "audit.log"), Sinks: []io.WriteCloser{sink}})
+	if err != nil {
+		t.Fatalf("NewLogger() unexpected error: %v", err)
+	}
+
+	logger.LogAccess("secret", "get", "", "prod", true, map[string]interface{}{"uid": "rec-uid"})
+	waitFor(t, func() bool { return len(c.received()) >= 2 })
+	logger.Close()
+
+	events := c.received()
+	if events[0].Type != EventStartup {
+		t.Errorf("first forwarded event = %s, want %s", events[0].Type, EventStartup)
+	}
+	if events[1].Type != EventAccess || events[1].Profile != "prod" {
+		t.Errorf("second forwarded event = %+v, want the access event", events[1])
+	}
+	if got := events[len(events)-1].Type; got != EventShutdown {
+		t.Errorf("last forwarded event = %s, want %s (flushed on close)", got, EventShutdown)
+	}
+	if c.auth != "Bearer token" {
+		t.Errorf("Authorization = %q, want %q", c.auth, "Bearer token")
+	}
+}
+
+func TestRemoteSinkHTTPRetry(t *testing.T) {
+	c := &collector{failures: 2}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	sink, err := NewRemoteSink(SinkConfig{Protocol: SinkHTTP, Address: server.URL, BatchSize: 1, MaxRetries: 2, RetryBackoff: 5 * time.Millisecond, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRemoteSink() unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: "e1", Type: EventAccess}))
+	waitFor(t, func() bool { return len(c.received()) == 1 })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.requests != 3 {
+		t.Errorf("requests = %d, want 3 (two failures retried)", c.requests)
+	}
+}
+
+func TestRemoteSinkBuffersWhileUnreachable(t *testing.T) {
+	c := &collector{failures: 1}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	sink, err := NewRemoteSink(SinkConfig{Protocol: SinkHTTP, Address: server.URL, MaxRetries: -1, FlushInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewRemoteSink() unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: "e1"}))
+	_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: "e2"}))
+	waitFor(t, func() bool { return len(c.received()) == 2 })
+
+	events := c.received()
+	if events[0].ID != "e1" || events[1].ID != "e2" {
+		t.Errorf("events = %+v, want e1 then e2 after the failed flush", events)
+	}
+}
+
+func TestRemoteSinkDropsOldestWhenFull(t *testing.T) {
+	sink, err := NewRemoteSink(SinkConfig{Protocol: SinkHTTP, Address: "http://127.0.0.1:1", BufferSize: 2, MaxRetries: -1, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRemoteSink() unexpected error: %v", err)
+	}
+	for _, id := range []string{"e1", "e2", "e3"} {
+		_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: id}))
+	}
+
+	sink.mu.Lock()
+	var ids []string
+	for _, raw := range sink.pending {
+		var event AuditEvent
+		_ = json.Unmarshal(raw, &event)
+		ids = append(ids, event.ID)
+	}
+	dropped := sink.dropped
+	sink.mu.Unlock()
+
+	if strings.Join(ids, ",") != "e2,e3" || dropped != 1 {
+		t.Errorf("pending = %v (dropped %d), want [e2 e3] with 1 dropped", ids, dropped)
+	}
+	if err := sink.Close(); err == nil {
+		t.Error("Close() expected an error for undelivered events")
+	}
+}
+
+func TestRemoteSinkSyslogTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	sink, err := NewRemoteSink(SinkConfig{Protocol: SinkSyslog, Address: listener.Addr().String(), FlushInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewRemoteSink() unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: "e1", Severity: SeverityInfo, Type: EventAccess}))
+	_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: "e2", Severity: SeverityError, Type: EventError}))
+
+	for _, want := range []struct {
+		prefix string
+		id     string
+	}{
+		{"<110>1 ", `"id":"e1"`}, // facility 13 (log audit), severity 6 (info)
+		{"<107>1 ", `"id":"e2"`}, // severity 3 (error)
+	} {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, want.prefix) || !strings.Contains(line, " ksm-mcp ") || !strings.Contains(line, want.id) {
+				t.Errorf("syslog line = %q, want prefix %q and event %s", line, want.prefix, want.id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a syslog line")
+		}
+	}
+}
+
+func TestRemoteSinkSyslogTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	frames := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// RFC 5425 frames each message as its length, a space and the message
+		reader := bufio.NewReader(conn)
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			return
+		}
+		message := make([]byte, n)
+		if _, err := io.ReadFull(reader, message); err != nil {
+			return
+		}
+		frames <- string(message)
+	}()
+
+	sink, err := NewRemoteSink(SinkConfig{
+		Protocol:      SinkSyslog,
+		Network:       "tls",
+		Address:       listener.Addr().String(),
+		TLSConfig:     &tls.Config{RootCAs: roots},
+		FlushInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewRemoteSink() unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: "e1", Severity: SeverityInfo}))
+
+	select {
+	case message := <-frames:
+		if !strings.HasPrefix(message, "<110>1 ") || !strings.HasSuffix(message, "}") || !strings.Contains(message, `"id":"e1"`) {
+			t.Errorf("syslog message = %q, want one framed info message", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a syslog message")
+	}
+}
+
+func TestRemoteSinkSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := NewRemoteSink(SinkConfig{Protocol: SinkSyslog, Network: "udp", Address: conn.LocalAddr().String(), FlushInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewRemoteSink() unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	_, _ = sink.Write(encodeEvent(t, &AuditEvent{ID: "e1", Severity: SeverityWarning}))
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("failed to read syslog datagram: %v", err)
+	}
+	message := string(buf[:n])
+	if !strings.HasPrefix(message, "<108>1 ") || strings.HasSuffix(message, "\n") || !strings.Contains(message, `"id":"e1"`) {
+		t.Errorf("syslog datagram = %q, want one unterminated warning message", message)
+	}
+}
+
+func TestNewRemoteSinkInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SinkConfig
+	}{
+		{"unknown protocol", SinkConfig{Protocol: "kafka", Address: "localhost:9092"}},
+		{"syslog without port", SinkConfig{Protocol: SinkSyslog, Address: "localhost"}},
+		{"unknown syslog network", SinkConfig{Protocol: SinkSyslog, Network: "unix", Address: "localhost:514"}},
+		{"HTTP without scheme", SinkConfig{Protocol: SinkHTTP, Address: "collector.example.com/events"}},
+		{"auth token over plain HTTP", SinkConfig{Protocol: SinkHTTP, Address: "http://collector.example.com/events", AuthToken: "token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRemoteSink(tt.config); err == nil {
+				t.Error("NewRemoteSink() expected an error")
+			}
+		})
+	}
+}
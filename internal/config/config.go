@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/keeper-security/ksm-mcp/internal/validation"
 	"github.com/spf13/viper"
 )
 
@@ -23,8 +24,22 @@ type Config struct {
 
 // MCPConfig represents MCP protocol settings
 type MCPConfig struct {
-	Timeout   time.Duration `mapstructure:"timeout"`
-	RateLimit RateLimit     `mapstructure:"rate_limit"`
+	Timeout               time.Duration `mapstructure:"timeout"`
+	RateLimit             RateLimit     `mapstructure:"rate_limit"`
+	SearchEmptyResult     string        `mapstructure:"search_empty_result"`     // "empty_list" or "not_found"
+	MultiValueFieldTypes  []string      `mapstructure:"multi_value_field_types"` // field types allowed to keep multiple values
+	NotationIndexBase     int           `mapstructure:"notation_index_base"`     // first array index in notations: 0 (KSM) or 1
+	DateFormat            string        `mapstructure:"date_format"`             // preset or Go layout for date fields; empty keeps epoch ms
+	Timezone              string        `mapstructure:"timezone"`                // IANA zone dates are shown in (default UTC)
+	TrimFieldValues       bool          `mapstructure:"trim_field_values"`       // trim whitespace from string field values
+	TrimSensitiveFields   bool          `mapstructure:"trim_sensitive_fields"`   // also trim password/secret fields
+	MaxNotesLength        int           `mapstructure:"max_notes_length"`        // longest notes accepted on create/update, in characters; 0 means no limit
+	ConfirmationVerbosity string        `mapstructure:"confirmation_verbosity"`  // "verbose" or "concise" confirmation warnings
+	NormalizeLineEndings  bool          `mapstructure:"normalize_line_endings"`  // return multiline values with LF line endings
+	DefaultFolderName     string        `mapstructure:"default_folder_name"`     // opt-in startup check for a folder to create records in
+	ResponseEnvelope      string        `mapstructure:"response_envelope"`       // "wrapped" or "raw" read tool results
+	UnknownTypeSchema     string        `mapstructure:"unknown_type_schema"`     // "synthesize" or "error" for record types without a template
+	FilesArray            string        `mapstructure:"files_array"`             // "present", "omit" or "always" list attachments in secret results
 }
 
 // RateLimit represents rate limiting configuration
@@ -35,18 +50,62 @@ type RateLimit struct {
 
 // SecurityConfig represents security settings
 type SecurityConfig struct {
-	BatchMode              bool          `mapstructure:"batch_mode"`
-	AutoApprove            bool          `mapstructure:"auto_approve"`
-	MaskByDefault          bool          `mapstructure:"mask_by_default"`
-	SessionTimeout         time.Duration `mapstructure:"session_timeout"`
-	ConfirmationTimeout    time.Duration `mapstructure:"confirmation_timeout"`
-	ProtectionPasswordHash string        `mapstructure:"protection_password_hash"`
+	BatchMode                  bool                       `mapstructure:"batch_mode"`
+	AutoApprove                bool                       `mapstructure:"auto_approve"`
+	MaskByDefault              bool                       `mapstructure:"mask_by_default"`
+	MaskNotes                  bool                       `mapstructure:"mask_notes"`
+	MaskBankOtherType          bool                       `mapstructure:"mask_bank_other_type"` // mask a populated bankAccount otherType
+	SessionTimeout             time.Duration              `mapstructure:"session_timeout"`
+	ConfirmationTimeout        time.Duration              `mapstructure:"confirmation_timeout"`
+	ProtectionPasswordHash     string                     `mapstructure:"protection_password_hash"`
+	PasswordPolicy             *validation.PasswordPolicy `mapstructure:"password_policy"` // nil uses the validator default
+	PasswordGenerationAttempts int                        `mapstructure:"password_generation_attempts"`
+	DefaultPasswordLength      int                        `mapstructure:"default_password_length"`
+	RedactionPatterns          []string                   `mapstructure:"redaction_patterns"` // regexes masked in any value
+	DefaultFolderDeleteForce   bool                       `mapstructure:"default_folder_delete_force"`
+	MaxUnmaskedRecords         int                        `mapstructure:"max_unmasked_records"` // cap on get_all_secrets_unmasked
+	MaxURLUploadBytes          int64                      `mapstructure:"max_url_upload_bytes"` // cap on upload_file_from_url
+	ConfirmationBackend        string                     `mapstructure:"confirmation_backend"` // "prompt", "interactive" or "webhook"
+	ConfirmationWebhook        ConfirmationWebhookConfig  `mapstructure:"confirmation_webhook"`
+	RequireExplicitUnmaskLog   bool                       `mapstructure:"require_explicit_unmask_log"` // audit every unmask in batch/auto-approve mode
+	UnmaskLogSummary           bool                       `mapstructure:"unmask_log_summary"`          // include an unmask summary in responses
+	ConfirmationBypassFolders  []string                   `mapstructure:"confirmation_bypass_folders"` // folder UIDs read/unmasked without confirmation
+	ApprovalTokenSecret        string                     `mapstructure:"approval_token_secret"`       // HMAC secret for signed approval tokens; empty disables them
+	FingerprintKey             string                     `mapstructure:"fingerprint_key"`             // HMAC key for secret_fingerprint; empty uses a random key per run
+	KSMEndpointPin             EndpointPinConfig          `mapstructure:"ksm_endpoint_pin"`            // reject an unexpected KSM hostname or certificate
+	UIDMinLength               int                        `mapstructure:"uid_min_length"`              // shortest accepted record/folder UID
+	UIDMaxLength               int                        `mapstructure:"uid_max_length"`              // longest accepted record/folder UID
+}
+
+// EndpointPinConfig pins the KSM endpoint the server connects to
+type EndpointPinConfig struct {
+	Hostnames       []string `mapstructure:"hostnames"`         // accepted KSM hostnames
+	PublicKeySHA256 []string `mapstructure:"public_key_sha256"` // base64 SHA-256 hashes of accepted certificate public keys
+}
+
+// ConfirmationWebhookConfig configures the webhook confirmation backend
+type ConfirmationWebhookConfig struct {
+	URL       string        `mapstructure:"url"`
+	AuthToken string        `mapstructure:"auth_token"`
+	Timeout   time.Duration `mapstructure:"timeout"`
 }
 
 // LoggingConfig represents logging configuration
 type LoggingConfig struct {
-	Level string `mapstructure:"level"`
-	File  string `mapstructure:"file"`
+	Level      string           `mapstructure:"level"`
+	File       string           `mapstructure:"file"`
+	RemoteSink RemoteSinkConfig `mapstructure:"remote_sink"` // forward audit events to syslog or HTTP
+}
+
+// RemoteSinkConfig configures forwarding of audit events to a remote endpoint
+type RemoteSinkConfig struct {
+	Protocol      string        `mapstructure:"protocol"`       // "syslog" or "http"; empty disables forwarding
+	Address       string        `mapstructure:"address"`        // syslog host:port or HTTP(S) URL
+	Network       string        `mapstructure:"network"`        // "tcp", "tls" or "udp" for syslog
+	AuthToken     string        `mapstructure:"auth_token"`     // bearer token for HTTPS
+	BufferSize    int           `mapstructure:"buffer_size"`    // events kept while the endpoint is unreachable
+	FlushInterval time.Duration `mapstructure:"flush_interval"` // how often buffered events are sent
+	MaxRetries    int           `mapstructure:"max_retries"`    // retries of a failed batch per flush
 }
 
 // ProfilesConfig represents profile settings
@@ -63,13 +122,26 @@ func DefaultConfig() *Config {
 				RequestsPerMinute: 60,
 				RequestsPerHour:   1000,
 			},
+			SearchEmptyResult:     "empty_list",
+			ConfirmationVerbosity: "verbose",
+			ResponseEnvelope:      "wrapped",
+			UnknownTypeSchema:     "synthesize",
+			FilesArray:            "present",
 		},
 		Security: SecurityConfig{
-			BatchMode:           false,
-			AutoApprove:         false,
-			MaskByDefault:       true,
-			SessionTimeout:      15 * time.Minute,
-			ConfirmationTimeout: 30 * time.Second,
+			BatchMode:                  false,
+			AutoApprove:                false,
+			MaskByDefault:              true,
+			MaskNotes:                  false,
+			MaskBankOtherType:          false,
+			SessionTimeout:             15 * time.Minute,
+			ConfirmationTimeout:        30 * time.Second,
+			PasswordGenerationAttempts: 5,
+			DefaultFolderDeleteForce:   false,
+			MaxUnmaskedRecords:         100,
+			ConfirmationBackend:        "prompt",
+			UIDMinLength:               validation.DefaultUIDMinLength,
+			UIDMaxLength:               validation.DefaultUIDMaxLength,
 		},
 		Logging: LoggingConfig{
 			Level: "info",
@@ -168,14 +240,73 @@ func (c *Config) Save(configFile string) error {
 	v.Set("mcp.timeout", c.MCP.Timeout)
 	v.Set("mcp.rate_limit.requests_per_minute", c.MCP.RateLimit.RequestsPerMinute)
 	v.Set("mcp.rate_limit.requests_per_hour", c.MCP.RateLimit.RequestsPerHour)
+	v.Set("mcp.search_empty_result", c.MCP.SearchEmptyResult)
+	v.Set("mcp.multi_value_field_types", c.MCP.MultiValueFieldTypes)
+	v.Set("mcp.notation_index_base", c.MCP.NotationIndexBase)
+	v.Set("mcp.date_format", c.MCP.DateFormat)
+	v.Set("mcp.timezone", c.MCP.Timezone)
+	v.Set("mcp.trim_field_values", c.MCP.TrimFieldValues)
+	v.Set("mcp.trim_sensitive_fields", c.MCP.TrimSensitiveFields)
+	v.Set("mcp.normalize_line_endings", c.MCP.NormalizeLineEndings)
+	v.Set("mcp.default_folder_name", c.MCP.DefaultFolderName)
+	v.Set("mcp.response_envelope", c.MCP.ResponseEnvelope)
+	v.Set("mcp.unknown_type_schema", c.MCP.UnknownTypeSchema)
+	v.Set("mcp.files_array", c.MCP.FilesArray)
+	v.Set("mcp.max_notes_length", c.MCP.MaxNotesLength)
+	v.Set("mcp.confirmation_verbosity", c.MCP.ConfirmationVerbosity)
 	v.Set("security.batch_mode", c.Security.BatchMode)
 	v.Set("security.auto_approve", c.Security.AutoApprove)
 	v.Set("security.mask_by_default", c.Security.MaskByDefault)
+	v.Set("security.mask_notes", c.Security.MaskNotes)
+	v.Set("security.mask_bank_other_type", c.Security.MaskBankOtherType)
+	if c.Security.PasswordPolicy != nil {
+		v.Set("security.password_policy.min_length", c.Security.PasswordPolicy.MinLength)
+		v.Set("security.password_policy.require_upper", c.Security.PasswordPolicy.RequireUpper)
+		v.Set("security.password_policy.require_lower", c.Security.PasswordPolicy.RequireLower)
+		v.Set("security.password_policy.require_digit", c.Security.PasswordPolicy.RequireDigit)
+		v.Set("security.password_policy.require_special", c.Security.PasswordPolicy.RequireSpecial)
+	}
+	v.Set("security.password_generation_attempts", c.Security.PasswordGenerationAttempts)
+	v.Set("security.default_password_length", c.Security.DefaultPasswordLength)
+	v.Set("security.redaction_patterns", c.Security.RedactionPatterns)
+	v.Set("security.default_folder_delete_force", c.Security.DefaultFolderDeleteForce)
+	v.Set("security.max_unmasked_records", c.Security.MaxUnmaskedRecords)
+	v.Set("security.max_url_upload_bytes", c.Security.MaxURLUploadBytes)
+	v.Set("security.confirmation_backend", c.Security.ConfirmationBackend)
+	v.Set("security.require_explicit_unmask_log", c.Security.RequireExplicitUnmaskLog)
+	v.Set("security.unmask_log_summary", c.Security.UnmaskLogSummary)
+	v.Set("security.confirmation_bypass_folders", c.Security.ConfirmationBypassFolders)
+	v.Set("security.uid_min_length", c.Security.UIDMinLength)
+	v.Set("security.uid_max_length", c.Security.UIDMaxLength)
+	if c.Security.ApprovalTokenSecret != "" {
+		v.Set("security.approval_token_secret", c.Security.ApprovalTokenSecret)
+	}
+	if c.Security.FingerprintKey != "" {
+		v.Set("security.fingerprint_key", c.Security.FingerprintKey)
+	}
+	if len(c.Security.KSMEndpointPin.Hostnames) > 0 || len(c.Security.KSMEndpointPin.PublicKeySHA256) > 0 {
+		v.Set("security.ksm_endpoint_pin.hostnames", c.Security.KSMEndpointPin.Hostnames)
+		v.Set("security.ksm_endpoint_pin.public_key_sha256", c.Security.KSMEndpointPin.PublicKeySHA256)
+	}
+	if c.Security.ConfirmationWebhook.URL != "" {
+		v.Set("security.confirmation_webhook.url", c.Security.ConfirmationWebhook.URL)
+		v.Set("security.confirmation_webhook.auth_token", c.Security.ConfirmationWebhook.AuthToken)
+		v.Set("security.confirmation_webhook.timeout", c.Security.ConfirmationWebhook.Timeout)
+	}
 	v.Set("security.session_timeout", c.Security.SessionTimeout)
 	v.Set("security.confirmation_timeout", c.Security.ConfirmationTimeout)
 	v.Set("security.protection_password_hash", c.Security.ProtectionPasswordHash)
 	v.Set("logging.level", c.Logging.Level)
 	v.Set("logging.file", c.Logging.File)
+	if c.Logging.RemoteSink.Protocol != "" {
+		v.Set("logging.remote_sink.protocol", c.Logging.RemoteSink.Protocol)
+		v.Set("logging.remote_sink.address", c.Logging.RemoteSink.Address)
+		v.Set("logging.remote_sink.network", c.Logging.RemoteSink.Network)
+		v.Set("logging.remote_sink.auth_token", c.Logging.RemoteSink.AuthToken)
+		v.Set("logging.remote_sink.buffer_size", c.Logging.RemoteSink.BufferSize)
+		v.Set("logging.remote_sink.flush_interval", c.Logging.RemoteSink.FlushInterval)
+		v.Set("logging.remote_sink.max_retries", c.Logging.RemoteSink.MaxRetries)
+	}
 	v.Set("profiles.default", c.Profiles.Default)
 
 	return v.WriteConfig()
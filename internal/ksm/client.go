@@ -1,25 +1,62 @@
 package ksm
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"strings"
+	"time"
 
 	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/internal/recordtemplates"
 	"github.com/keeper-security/ksm-mcp/internal/validation"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 	sm "github.com/keeper-security/secrets-manager-go/core"
 )
 
+var (
+	// ErrSecretNotFound is returned when no record with the requested UID is shared with the application
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrAccessDenied is returned when Keeper rejects a request because the application lacks access
+	ErrAccessDenied = errors.New("access denied")
+	// ErrNoTOTP is returned when clearing or verifying TOTP on a record that has no one-time code field
+	ErrNoTOTP = errors.New("record has no TOTP field")
+	// ErrNoPassword is returned when rotating the password of a record that has no password field
+	ErrNoPassword = errors.New("record has no password field")
+	// ErrFolderNotFound is returned when no folder with the requested UID is accessible to the application
+	ErrFolderNotFound = errors.New("folder not found")
+)
+
+// recordStore reads and saves whole records. The SDK client implements it; the
+// read-modify-write operations use it so their locking can be tested without a vault.
+type recordStore interface {
+	GetSecrets(uids []string) ([]*sm.Record, error)
+	Save(record *sm.Record) error
+}
+
 // Client wraps the KSM SDK client
 type Client struct {
 	sm        *sm.SecretsManager
+	store     recordStore                                              // c.sm outside tests
+	schemas   func(recordType string) (*types.RecordTypeSchema, error) // recordtemplates.GetSchema outside tests
 	profile   string
 	validator *validation.Validator
 	logger    *audit.Logger
+	records   recordLocks // serializes read-modify-write operations per record UID
+
+	maskOtherType  bool   // mask a populated bankAccount otherType in masked results
+	fingerprintKey []byte // HMAC key for record fingerprints
 }
 
+// MinFingerprintKeyLength is the shortest accepted record fingerprint key
+const MinFingerprintKeyLength = 32
+
 // NewClient creates a new KSM client with the provided configuration
 func NewClient(profile *types.Profile, logger *audit.Logger) (*Client, error) {
 	if profile == nil {
@@ -37,11 +74,18 @@ func NewClient(profile *types.Profile, logger *audit.Logger) (*Client, error) {
 	// Create secrets manager client
 	smClient := sm.NewSecretsManager(options)
 	if smClient == nil {
-		return nil, errors.New("failed to create secrets manager client")
+		// The SDK only logs why it gave up; work out what is wrong with the configuration
+		diag := diagnoseConfig(storage)
+		if diag == nil {
+			diag = &InitError{Failure: InitUnknown, Detail: "the Secrets Manager SDK rejected the configuration"}
+		}
+		return nil, fmt.Errorf("failed to create secrets manager client: %w", diag)
 	}
 
 	return &Client{
 		sm:        smClient,
+		store:     smClient,
+		schemas:   recordtemplates.GetSchema,
 		profile:   profile.Name,
 		validator: validation.NewValidator(),
 		logger:    logger,
@@ -103,7 +147,7 @@ func InitializeWithConfig(configData []byte) (map[string]string, error) {
 	// Validate required fields
 	requiredFields := []string{"clientId", "privateKey", "appKey"}
 	for _, field := range requiredFields {
-		if _, exists := config[field]; !exists {
+		if value, exists := config[field]; !exists || strings.TrimSpace(value) == "" {
 			return nil, fmt.Errorf("missing required field: %s", field)
 		}
 	}
@@ -166,12 +210,28 @@ func (c *Client) ListSecrets(folderUIDs []string) ([]*types.SecretMetadata, erro
 			Type:   record.Type(),
 			Folder: record.FolderUid(),
 		}
+		hasTOTP := recordTOTPURL(record) != ""
+		secretMeta.HasTOTP = &hasTOTP
 		metadata = append(metadata, secretMeta)
 	}
 
 	return metadata, nil
 }
 
+// isAccessDeniedError reports whether an SDK error carries Keeper's access_denied result code
+func isAccessDeniedError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "access_denied")
+}
+
+// classifySDKError wraps an SDK error in ErrAccessDenied when Keeper refused access,
+// so callers can tell a denial from other fetch failures
+func classifySDKError(err error) error {
+	if isAccessDeniedError(err) {
+		return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+	}
+	return err
+}
+
 // GetSecret retrieves a secret by UID
 func (c *Client) GetSecret(uid string, fields []string, unmask bool) (map[string]interface{}, error) {
 	// Validate UID
@@ -194,15 +254,19 @@ func (c *Client) GetSecret(uid string, fields []string, unmask bool) (map[string
 			"operation": "get_secret",
 			"uid":       uid,
 		})
-		return nil, fmt.Errorf("failed to get secret: %w", err)
+		return nil, fmt.Errorf("failed to get secret: %w", classifySDKError(err))
 	}
 
 	if len(records) == 0 {
-		return nil, errors.New("secret not found")
+		return nil, ErrSecretNotFound
 	}
 
 	// Handle duplicates - just use the first one since they're the same record
-	record := records[0]
+	return c.secretResult(records[0], fields, unmask)
+}
+
+// secretResult builds the get_secret response for a record: all fields, or only the requested ones
+func (c *Client) secretResult(record *sm.Record, fields []string, unmask bool) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
 	// Add basic metadata
@@ -225,6 +289,96 @@ func (c *Client) GetSecret(uid string, fields []string, unmask bool) (map[string
 	return result, nil
 }
 
+// GetSecrets retrieves several secrets in one request. Records that cannot be read
+// are reported per UID in the result's errors instead of failing the whole batch.
+func (c *Client) GetSecrets(uids []string, fields []string, unmask bool) (*types.BatchResult, error) {
+	c.logAccess("secrets", "get_batch", "", c.profile, true, map[string]interface{}{
+		"uids":   uids,
+		"fields": fields,
+		"masked": !unmask,
+	})
+
+	return c.batchResult(uids, c.sm.GetSecrets, fields, unmask), nil
+}
+
+// batchResult fetches the valid UIDs with one call to fetch and reports invalid and
+// missing UIDs as errors. When the combined request fails, as it does when one of the
+// records is unreadable, each UID is fetched on its own so the others are still returned.
+func (c *Client) batchResult(uids []string, fetch func([]string) ([]*sm.Record, error), fields []string, unmask bool) *types.BatchResult {
+	result := &types.BatchResult{
+		Results: []map[string]interface{}{},
+		Errors:  []types.BatchError{},
+	}
+	addError := func(uid string, err error) {
+		result.Errors = append(result.Errors, types.BatchError{UID: uid, Error: err.Error()})
+	}
+
+	var valid []string
+	seen := make(map[string]bool)
+	for _, uid := range uids {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		if err := c.validator.ValidateUID(uid); err != nil {
+			addError(uid, fmt.Errorf("invalid UID: %w", err))
+			continue
+		}
+		valid = append(valid, uid)
+	}
+	if len(valid) == 0 {
+		return result
+	}
+
+	records, err := fetch(valid)
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "get_secrets",
+			"count":     len(valid),
+		})
+		records = nil
+		for _, uid := range valid {
+			single, err := fetch([]string{uid})
+			switch {
+			case err != nil:
+				addError(uid, classifySDKError(err))
+			default:
+				records = append(records, single...)
+			}
+		}
+	}
+
+	byUID := make(map[string]*sm.Record, len(records))
+	for _, record := range records {
+		if _, exists := byUID[record.Uid]; !exists {
+			byUID[record.Uid] = record
+		}
+	}
+
+	// Results and not-found errors keep the order the UIDs were requested in
+	failed := make(map[string]bool, len(result.Errors))
+	for _, batchErr := range result.Errors {
+		failed[batchErr.UID] = true
+	}
+	for _, uid := range valid {
+		if failed[uid] {
+			continue
+		}
+		record, ok := byUID[uid]
+		if !ok {
+			addError(uid, ErrSecretNotFound)
+			continue
+		}
+		secret, err := c.secretResult(record, fields, unmask)
+		if err != nil {
+			addError(uid, err)
+			continue
+		}
+		result.Results = append(result.Results, secret)
+	}
+	return result
+}
+
 // extractAllFields extracts all available fields from a record based on its type
 func (c *Client) extractAllFields(record *sm.Record, unmask bool) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
@@ -240,9 +394,7 @@ func (c *Client) extractAllFields(record *sm.Record, unmask bool) (map[string]in
 	}
 
 	// Extract all standard fields using SDK methods
-	allFieldTypes := c.getFieldTypesForRecordType(record.Type())
-
-	for _, fieldType := range allFieldTypes {
+	for _, fieldType := range recordFieldTypes(record) {
 		if value, found := c.extractField(record, fieldType, unmask); found {
 			result[fieldType] = value
 		}
@@ -254,112 +406,220 @@ func (c *Client) extractAllFields(record *sm.Record, unmask bool) (map[string]in
 	}
 
 	// Extract file information if present
-	if len(record.Files) > 0 {
-		files := make([]map[string]interface{}, len(record.Files))
-		for i, file := range record.Files {
-			files[i] = map[string]interface{}{
-				"name":  file.Name,
-				"title": file.Title,
-				"size":  file.Size,
-				"type":  file.Type,
-			}
-		}
+	if files := fileInfos(record); len(files) > 0 {
 		result["files"] = files
 	}
 
 	return result, nil
 }
 
-// getFieldTypesForRecordType returns the expected field types for a given record type
-func (c *Client) getFieldTypesForRecordType(recordType string) []string {
+// recordFieldTypes returns the standard field types to extract from a record. Record
+// types this client does not know (such as types Keeper adds later) use the field
+// types the record stores.
+func recordFieldTypes(record *sm.Record) []string {
+	if fieldTypes, known := recordTypeFieldTypes(record.Type()); known {
+		return fieldTypes
+	}
+	return discoverFieldTypes(record)
+}
+
+// fileInfos describes a record's file attachments without their contents
+func fileInfos(record *sm.Record) []map[string]interface{} {
+	files := make([]map[string]interface{}, len(record.Files))
+	for i, file := range record.Files {
+		files[i] = map[string]interface{}{
+			"uid":   file.Uid,
+			"name":  file.Name,
+			"title": file.Title,
+			"size":  file.Size,
+			"type":  file.Type,
+		}
+	}
+	return files
+}
+
+// GetSecretSafe retrieves a secret with its sensitive fields omitted entirely, leaving
+// only metadata and non-sensitive fields. The names of omitted fields are listed
+// under omitted_fields; their values are never read into the result.
+func (c *Client) GetSecretSafe(uid string) (map[string]interface{}, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID: %w", err)
+	}
+
+	if c.logger != nil {
+		c.logSecretOperation(audit.EventSecretAccess, uid, "", c.profile, true, map[string]interface{}{
+			"masked": true,
+			"safe":   true,
+		})
+	}
+
+	records, err := c.sm.GetSecrets([]string{uid})
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "get_secret_safe",
+			"uid":       uid,
+		})
+		return nil, fmt.Errorf("failed to get secret: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return nil, ErrSecretNotFound
+	}
+
+	return c.extractSafeFields(records[0]), nil
+}
+
+// extractSafeFields is extractAllFields without the sensitive fields. Standard fields
+// are dropped by type and custom fields by label or type.
+func (c *Client) extractSafeFields(record *sm.Record) map[string]interface{} {
+	result := map[string]interface{}{
+		"uid":   record.Uid,
+		"title": record.Title(),
+		"type":  record.Type(),
+	}
+	omitted := []string{}
+
+	if notes := record.Notes(); notes != "" {
+		result["notes"] = notes
+	}
+
+	for _, fieldType := range recordFieldTypes(record) {
+		// extractField falls back to custom fields, which are filtered by label below
+		if len(record.GetFieldsByType(fieldType)) == 0 {
+			continue
+		}
+		if IsSensitiveField(fieldType) {
+			omitted = append(omitted, fieldType)
+			continue
+		}
+		if value, found := c.extractField(record, fieldType, false); found {
+			result[fieldType] = value
+		}
+	}
+
+	customFields := make(map[string]interface{})
+	forEachCustomField(record, func(label, fieldType string, value interface{}) {
+		if IsSensitiveField(label) || IsSensitiveField(fieldType) {
+			omitted = append(omitted, label)
+			return
+		}
+		customFields[label] = value
+	})
+	if len(customFields) > 0 {
+		result["custom_fields"] = customFields
+	}
+
+	if files := fileInfos(record); len(files) > 0 {
+		result["files"] = files
+	}
+
+	result["omitted_fields"] = omitted
+	return result
+}
+
+// discoverFieldTypes returns the distinct field types stored in a record's fields and
+// custom entries, in record order
+func discoverFieldTypes(record *sm.Record) []string {
+	var fieldTypes []string
+	seen := make(map[string]bool)
+	for _, section := range []string{"fields", "custom"} {
+		entries, _ := record.RecordDict[section].([]interface{})
+		for _, entry := range entries {
+			fieldMap, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if fieldType, ok := fieldMap["type"].(string); ok && fieldType != "" && !seen[fieldType] {
+				seen[fieldType] = true
+				fieldTypes = append(fieldTypes, fieldType)
+			}
+		}
+	}
+	return fieldTypes
+}
+
+// recordTypeFieldTypes returns the field types of a known record type, and false
+// when the record type is unknown
+func recordTypeFieldTypes(recordType string) ([]string, bool) {
 	switch recordType {
 	case "login":
-		return []string{"login", "password", "url", "oneTimeCode", "otp"}
+		return []string{"login", "password", "url", "oneTimeCode", "otp"}, true
 	case "bankCard":
-		return []string{"paymentCard", "text", "pinCode", "addressRef", "cardRef"}
+		return []string{"paymentCard", "text", "pinCode", "addressRef", "cardRef"}, true
 	case "databaseCredentials":
-		return []string{"host", "login", "password", "databaseType", "text"}
+		return []string{"host", "login", "password", "databaseType", "text"}, true
 	case "sshKeys":
-		return []string{"login", "host", "keyPair", "passphrase", "password"}
+		return []string{"login", "host", "keyPair", "passphrase", "password"}, true
 	case "serverCredentials":
-		return []string{"host", "login", "password", "text"}
+		return []string{"host", "login", "password", "text"}, true
 	case "sslCertificate":
-		return []string{"text", "multiline", "keyPair", "password"}
+		return []string{"text", "multiline", "keyPair", "password"}, true
 	case "file":
-		return []string{"text", "multiline", "fileRef"}
+		return []string{"text", "multiline", "fileRef"}, true
 	case "address":
-		return []string{"address", "name", "phone", "email"}
+		return []string{"address", "name", "phone", "email"}, true
 	case "bankAccount":
-		return []string{"bankAccount", "name", "login", "password", "accountNumber"}
+		return []string{"bankAccount", "name", "login", "password", "accountNumber"}, true
 	case "driverLicense":
-		return []string{"licenseNumber", "name", "address", "birthDate", "expirationDate", "text"}
+		return []string{"licenseNumber", "name", "address", "birthDate", "expirationDate", "text"}, true
 	case "passport":
-		return []string{"text", "name", "birthDate", "expirationDate", "address", "licenseNumber"}
+		return []string{"text", "name", "birthDate", "expirationDate", "address", "licenseNumber"}, true
 	case "softwareLicense":
-		return []string{"licenseNumber", "text", "date", "multiline", "login", "password"}
+		return []string{"licenseNumber", "text", "date", "multiline", "login", "password"}, true
 	case "contact":
-		return []string{"name", "email", "phone", "address", "text"}
+		return []string{"name", "email", "phone", "address", "text"}, true
 	case "encryptedNotes":
-		return []string{"note", "text", "multiline"}
+		return []string{"note", "text", "multiline"}, true
 	case "membership":
-		return []string{"accountNumber", "name", "password", "login", "text"}
+		return []string{"accountNumber", "name", "password", "login", "text"}, true
 	case "outdoorLicense":
-		return []string{"licenseNumber", "name", "address", "birthDate", "expirationDate"}
+		return []string{"licenseNumber", "name", "address", "birthDate", "expirationDate"}, true
 	case "healthInsurance":
-		return []string{"accountNumber", "name", "login", "password", "text"}
+		return []string{"accountNumber", "name", "login", "password", "text"}, true
 	case "document":
-		return []string{"text", "multiline", "date", "fileRef"}
+		return []string{"text", "multiline", "date", "fileRef"}, true
 	// PAM (Privileged Access Management) record types
 	case "pamUser":
-		return []string{"login", "password", "host", "pamHostname", "pamResources", "pamSettings"}
+		return []string{"login", "password", "host", "pamHostname", "pamResources", "pamSettings"}, true
 	case "pamMachine":
-		return []string{"pamHostname", "host", "login", "password", "pamResources", "pamSettings", "keyPair"}
+		return []string{"pamHostname", "host", "login", "password", "pamResources", "pamSettings", "keyPair"}, true
 	case "pamDatabase":
-		return []string{"host", "login", "password", "databaseType", "pamResources", "pamSettings"}
+		return []string{"host", "login", "password", "databaseType", "pamResources", "pamSettings"}, true
 	case "pamDirectory":
-		return []string{"host", "login", "password", "directoryType", "pamResources", "pamSettings"}
+		return []string{"host", "login", "password", "directoryType", "pamResources", "pamSettings"}, true
 	case "pamRemoteBrowser":
-		return []string{"url", "login", "password", "pamRemoteBrowserSettings", "rbiUrl"}
+		return []string{"url", "login", "password", "pamRemoteBrowserSettings", "rbiUrl"}, true
 	// Network and infrastructure types
 	case "router":
-		return []string{"host", "login", "password", "text", "url"}
+		return []string{"host", "login", "password", "text", "url"}, true
 	case "wireless":
-		return []string{"text", "password", "wifiEncryption", "isSSIDHidden"}
+		return []string{"text", "password", "wifiEncryption", "isSSIDHidden"}, true
 	case "server":
-		return []string{"host", "login", "password", "text", "url"}
+		return []string{"host", "login", "password", "text", "url"}, true
 	// Security and authentication types
 	case "passkey":
-		return []string{"passkey", "login", "url", "text"}
+		return []string{"passkey", "login", "url", "text"}, true
 	case "apiCredentials":
-		return []string{"login", "password", "secret", "text", "url"}
+		return []string{"login", "password", "secret", "text", "url"}, true
 	// Application and service types
 	case "application":
-		return []string{"login", "password", "url", "text", "appFiller"}
+		return []string{"login", "password", "url", "text", "appFiller"}, true
 	case "webService":
-		return []string{"url", "login", "password", "secret", "text"}
+		return []string{"url", "login", "password", "secret", "text"}, true
 	// Financial types
 	case "creditCard":
-		return []string{"paymentCard", "pinCode", "addressRef", "text"}
+		return []string{"paymentCard", "pinCode", "addressRef", "text"}, true
 	case "investment":
-		return []string{"accountNumber", "login", "password", "text", "url"}
+		return []string{"accountNumber", "login", "password", "text", "url"}, true
 	// Personal types
 	case "socialSecurityNumber":
-		return []string{"text", "name", "birthDate"}
+		return []string{"text", "name", "birthDate"}, true
 	case "taxNumber":
-		return []string{"text", "name", "address"}
+		return []string{"text", "name", "address"}, true
 	// Infrastructure scripts and automation
 	case "script":
-		return []string{"script", "text", "multiline", "fileRef"}
+		return []string{"script", "text", "multiline", "fileRef"}, true
 	default:
-		// For unknown types, try comprehensive field list
-		return []string{
-			"login", "password", "url", "text", "multiline", "host", "name", "email", "phone", "address",
-			"oneTimeCode", "otp", "keyPair", "paymentCard", "bankAccount", "accountNumber", "licenseNumber",
-			"secret", "note", "date", "birthDate", "expirationDate", "pinCode", "fileRef", "addressRef",
-			"cardRef", "pamHostname", "pamResources", "pamSettings", "pamRemoteBrowserSettings",
-			"databaseType", "directoryType", "wifiEncryption", "isSSIDHidden", "passkey", "appFiller",
-			"script", "rbiUrl", "dropdown", "checkbox", "recordRef", "schedule", "trafficEncryptionSeed",
-		}
+		return nil, false
 	}
 }
 
@@ -394,7 +654,7 @@ func (c *Client) extractField(record *sm.Record, fieldType string, unmask bool)
 		value := values[0] // Take first value (string)
 
 		// Apply masking for sensitive fields
-		if !unmask && isSensitiveField(fieldType) {
+		if !unmask && IsSensitiveField(fieldType) {
 			return maskValue(value), true
 		}
 
@@ -504,6 +764,11 @@ func (c *Client) processPaymentCardField(value interface{}, unmask bool) (interf
 				result["cardExpirationDate"] = expDate
 			}
 
+			// The cardholder name is printed on the card, so it is never masked
+			if cardholder, ok := cardData["cardholderName"].(string); ok {
+				result["cardholderName"] = cardholder
+			}
+
 			if secCode, ok := cardData["cardSecurityCode"].(string); ok {
 				if unmask {
 					result["cardSecurityCode"] = secCode
@@ -574,6 +839,13 @@ func (c *Client) processPhoneField(value interface{}, unmask bool) (interface{},
 	return nil, false
 }
 
+// SetMaskBankOtherType makes masked results also mask the otherType of bank account
+// fields when it is populated. It is off by default; the description of an "Other"
+// account type can hold routing details in some vaults.
+func (c *Client) SetMaskBankOtherType(mask bool) {
+	c.maskOtherType = mask
+}
+
 // processBankAccountField handles bank account field structures
 func (c *Client) processBankAccountField(value interface{}, unmask bool) (interface{}, bool) {
 	if valueArray, ok := value.([]interface{}); ok && len(valueArray) > 0 {
@@ -598,7 +870,11 @@ func (c *Client) processBankAccountField(value interface{}, unmask bool) (interf
 				}
 			}
 			if otherType, ok := bankData["otherType"].(string); ok {
-				result["otherType"] = otherType
+				if c.maskOtherType && !unmask && otherType != "" {
+					result["otherType"] = maskValue(otherType)
+				} else {
+					result["otherType"] = otherType
+				}
 			}
 
 			return result, true
@@ -671,27 +947,45 @@ func (c *Client) processNameField(value interface{}, unmask bool) (interface{},
 	return nil, false
 }
 
-// processSecurityQuestionField handles security question field structures
+// processSecurityQuestionField handles security question field structures. A record
+// with one question returns a single {question, answer} object; with several, a list
+// of them in record order, so securityQuestion[1] addresses the second pair.
 func (c *Client) processSecurityQuestionField(value interface{}, unmask bool) (interface{}, bool) {
-	if valueArray, ok := value.([]interface{}); ok && len(valueArray) > 0 {
-		if sqData, ok := valueArray[0].(map[string]interface{}); ok {
-			result := make(map[string]interface{})
+	valueArray, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
 
-			if question, ok := sqData["question"].(string); ok {
-				result["question"] = question
-			}
-			if answer, ok := sqData["answer"].(string); ok {
-				if unmask {
-					result["answer"] = answer
-				} else {
-					result["answer"] = maskValue(answer)
-				}
-			}
+	pairs := make([]interface{}, 0, len(valueArray))
+	for _, item := range valueArray {
+		sqData, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		result := make(map[string]interface{})
 
-			return result, true
+		if question, ok := sqData["question"].(string); ok {
+			result["question"] = question
 		}
+		if answer, ok := sqData["answer"].(string); ok {
+			if unmask {
+				result["answer"] = answer
+			} else {
+				result["answer"] = maskValue(answer)
+			}
+		}
+
+		pairs = append(pairs, result)
+	}
+
+	switch len(pairs) {
+	case 0:
+		return nil, false
+	case 1:
+		return pairs[0], true
+	default:
+		return pairs, true
 	}
-	return nil, false
 }
 
 // processSimpleField handles simple field types (strings, arrays, etc.)
@@ -715,7 +1009,7 @@ func (c *Client) processSimpleField(value interface{}, fieldType string, unmask
 		}
 
 		// Apply masking for sensitive fields
-		if !unmask && isSensitiveField(fieldType) {
+		if !unmask && IsSensitiveField(fieldType) {
 			return maskValue(stringValue), true
 		}
 
@@ -923,6 +1217,19 @@ func (c *Client) processAppFillerField(value interface{}, unmask bool) (interfac
 					} else {
 						result["macroSequence"] = maskValue(macroSequence)
 					}
+					// A structured macro is also returned as its ordered steps. Known key
+					// steps such as {TAB} carry no secret; typed text, and any braced step
+					// that is not a known key, stays masked.
+					if steps, structured := ParseMacroSequence(macroSequence); structured {
+						if !unmask {
+							for i, step := range steps {
+								if !knownMacroKeys[step] {
+									steps[i] = maskValue(step)
+								}
+							}
+						}
+						result["macroSteps"] = steps
+					}
 				}
 
 				appFillers = append(appFillers, result)
@@ -936,6 +1243,52 @@ func (c *Client) processAppFillerField(value interface{}, unmask bool) (interfac
 	return nil, false
 }
 
+// ParseMacroSequence splits an appFiller macroSequence such as
+// "{USERNAME}{TAB}{PASSWORD}{ENTER}" into its ordered steps: each {KEY} is one step
+// and the text typed between keys is another. Joining the steps gives the sequence
+// back. It returns false when the sequence has no {KEY} steps, so a free-form macro
+// is kept as a plain string.
+func ParseMacroSequence(sequence string) ([]string, bool) {
+	var steps []string
+	structured := false
+	rest := sequence
+	for rest != "" {
+		open := strings.Index(rest, "{")
+		closing := -1
+		if open >= 0 {
+			closing = strings.Index(rest[open:], "}")
+		}
+		if open < 0 || closing < 0 {
+			steps = append(steps, rest)
+			break
+		}
+		if open > 0 {
+			steps = append(steps, rest[:open])
+		}
+		key := rest[open : open+closing+1]
+		if isMacroKey(key) {
+			structured = true
+		}
+		steps = append(steps, key)
+		rest = rest[open+closing+1:]
+	}
+	return steps, structured
+}
+
+// knownMacroKeys are the macro steps shown unmasked: placeholders for the record's
+// own fields and keys pressed while filling. Other braced steps may be typed text.
+var knownMacroKeys = map[string]bool{
+	"{USERNAME}": true, "{LOGIN}": true, "{PASSWORD}": true, "{TOTP}": true,
+	"{TAB}": true, "{ENTER}": true, "{SPACE}": true, "{ESC}": true, "{BACKSPACE}": true,
+	"{DELETE}": true, "{UP}": true, "{DOWN}": true, "{LEFT}": true, "{RIGHT}": true,
+	"{HOME}": true, "{END}": true, "{DELAY}": true,
+}
+
+// isMacroKey reports whether a macro step has the {KEY} form rather than being text
+func isMacroKey(step string) bool {
+	return len(step) > 2 && strings.HasPrefix(step, "{") && strings.HasSuffix(step, "}") && !strings.ContainsAny(step[1:len(step)-1], "{}")
+}
+
 // processScheduleField handles schedule field structures
 func (c *Client) processScheduleField(value interface{}, unmask bool) (interface{}, bool) {
 	if valueArray, ok := value.([]interface{}); ok && len(valueArray) > 0 {
@@ -978,181 +1331,418 @@ func (c *Client) processScheduleField(value interface{}, unmask bool) (interface
 // extractCustomFields extracts all custom fields from a record
 func (c *Client) extractCustomFields(record *sm.Record, unmask bool) map[string]interface{} {
 	customFields := make(map[string]interface{})
-
-	// Try to get custom fields from the raw record dictionary
-	if record.RecordDict != nil {
-		if customFieldsData, exists := record.RecordDict["custom"]; exists {
-			if customFieldsList, ok := customFieldsData.([]interface{}); ok {
-				for _, field := range customFieldsList {
-					if fieldMap, ok := field.(map[string]interface{}); ok {
-						if label, hasLabel := fieldMap["label"].(string); hasLabel {
-							if value, hasValue := fieldMap["value"]; hasValue {
-								// Apply masking for sensitive custom fields
-								if !unmask && isSensitiveField(label) {
-									if str, ok := value.(string); ok {
-										customFields[label] = maskValue(str)
-									} else {
-										customFields[label] = value
-									}
-								} else {
-									customFields[label] = value
-								}
-							}
-						}
-					}
-				}
-			}
+	forEachCustomField(record, func(label, _ string, value interface{}) {
+		// Apply masking for sensitive custom fields
+		if str, ok := value.(string); ok && !unmask && IsSensitiveField(label) {
+			value = maskValue(str)
 		}
-	}
-
+		customFields[label] = value
+	})
 	return customFields
 }
 
-// SearchSecrets searches for secrets by query
-func (c *Client) SearchSecrets(query string) ([]*types.SecretMetadata, error) {
-	// Validate query
-	if err := c.validator.ValidateSearchQuery(query); err != nil {
-		return nil, fmt.Errorf("invalid search query: %w", err)
+// forEachCustomField calls visit with the label, type and value of each labelled
+// custom field of a record
+func forEachCustomField(record *sm.Record, visit func(label, fieldType string, value interface{})) {
+	entries, _ := record.RecordDict["custom"].([]interface{})
+	for _, entry := range entries {
+		fieldMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		label, hasLabel := fieldMap["label"].(string)
+		value, hasValue := fieldMap["value"]
+		if !hasLabel || !hasValue {
+			continue
+		}
+		fieldType, _ := fieldMap["type"].(string)
+		visit(label, fieldType, value)
 	}
+}
 
-	// Log search
-	if c.logger != nil {
-		c.logAccess("secrets", "search", "", c.profile, true, map[string]interface{}{
-			"query_length": len(query),
-		})
+// RecordLayout returns the record type and the type and label of each standard and
+// custom field of a record, in the shape of a record template. Values are never read
+// into the result.
+func (c *Client) RecordLayout(uid string) (*types.FullRecordTemplate, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID: %w", err)
 	}
 
-	// Get all secrets and filter
-	records, err := c.sm.GetSecrets([]string{})
+	c.logAccess("secret", "record_layout", "", c.profile, true, map[string]interface{}{
+		"uid": uid,
+	})
+
+	records, err := c.sm.GetSecrets([]string{uid})
 	if err != nil {
-		return nil, fmt.Errorf("failed to search secrets: %w", err)
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "record_layout",
+			"uid":       uid,
+		})
+		return nil, fmt.Errorf("failed to read record layout: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return nil, ErrSecretNotFound
 	}
 
-	queryLower := strings.ToLower(query)
-	var results []*types.SecretMetadata
-
-	for _, record := range records {
-		found := false
-
-		// Search in title
-		if strings.Contains(strings.ToLower(record.Title()), queryLower) {
-			found = true
-		}
+	return recordLayout(records[0]), nil
+}
 
-		// Search in notes if not already found
-		if !found && record.Notes() != "" {
-			if strings.Contains(strings.ToLower(record.Notes()), queryLower) {
-				found = true
+// recordLayout describes a record's fields as template fields, in record order
+func recordLayout(record *sm.Record) *types.FullRecordTemplate {
+	layout := &types.FullRecordTemplate{ID: record.Type()}
+	for _, section := range []string{"fields", "custom"} {
+		entries, _ := record.RecordDict[section].([]interface{})
+		for _, entry := range entries {
+			fieldMap, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
 			}
-		}
-
-		// Search in record type if not already found
-		if !found && strings.Contains(strings.ToLower(record.Type()), queryLower) {
-			found = true
-		}
-
-		// Search in field values if not already found
-		if !found {
-			// Check standard fields
-			fieldTypes := []string{"login", "url", "hostname", "address"}
-			for _, fieldType := range fieldTypes {
-				if fieldValue := record.GetFieldValueByType(fieldType); fieldValue != "" {
-					if strings.Contains(strings.ToLower(fieldValue), queryLower) {
-						found = true
-						break
-					}
-				}
+			fieldType, _ := fieldMap["type"].(string)
+			if fieldType == "" {
+				continue
 			}
-
-			// Check file attachments
-			if !found && record.Files != nil {
-				for _, file := range record.Files {
-					if file.Name != "" && strings.Contains(strings.ToLower(file.Name), queryLower) {
-						found = true
-						break
-					}
-					if file.Title != "" && strings.Contains(strings.ToLower(file.Title), queryLower) {
-						found = true
-						break
-					}
-				}
+			label, _ := fieldMap["label"].(string)
+			field := types.RecordTemplateField{Ref: fieldType, Label: label}
+			if section == "fields" {
+				layout.Fields = append(layout.Fields, field)
+			} else {
+				layout.Custom = append(layout.Custom, field)
 			}
-
-			// TODO: Add custom field search when SDK provides access
-			// Currently the SDK doesn't expose a method to iterate custom fields
 		}
+	}
+	return layout
+}
 
-		if found {
-			results = append(results, &types.SecretMetadata{
-				UID:    record.Uid,
-				Title:  record.Title(),
-				Type:   record.Type(),
-				Folder: record.FolderUid(),
-			})
-		}
+// ListCustomFields returns the label, type and sensitivity of each custom field of a
+// record. Values are never read into the result.
+func (c *Client) ListCustomFields(uid string) ([]types.CustomFieldInfo, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID: %w", err)
 	}
 
-	return results, nil
-}
+	c.logAccess("secret", "list_custom_fields", "", c.profile, true, map[string]interface{}{
+		"uid": uid,
+	})
 
-// GetField retrieves a specific field using KSM notation
-func (c *Client) GetField(notation string, unmask bool) (interface{}, error) {
-	// Validate notation
-	if err := c.validator.ValidateKSMNotation(notation); err != nil {
-		return nil, fmt.Errorf("invalid notation: %w", err)
+	records, err := c.sm.GetSecrets([]string{uid})
+	if err != nil || len(records) == 0 {
+		return nil, ErrSecretNotFound
 	}
 
-	// Parse notation
-	parsedNotation, err := ParseNotation(notation)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse notation: %w", err)
+	return customFieldInfos(records[0]), nil
+}
+
+// customFieldInfos describes the custom fields of a record in their stored order.
+// Unlabelled fields are addressed by their type, as KSM notation does.
+func customFieldInfos(record *sm.Record) []types.CustomFieldInfo {
+	infos := []types.CustomFieldInfo{}
+	customFields, _ := record.RecordDict["custom"].([]interface{})
+	for _, field := range customFields {
+		fieldMap, ok := field.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		label, _ := fieldMap["label"].(string)
+		fieldType, _ := fieldMap["type"].(string)
+
+		valueCount := 0
+		if values, ok := fieldMap["value"].([]interface{}); ok {
+			valueCount = len(values)
+		}
+
+		name := label
+		if name == "" {
+			name = fieldType
+		}
+		infos = append(infos, types.CustomFieldInfo{
+			Label:      label,
+			Type:       fieldType,
+			Sensitive:  IsSensitiveField(label) || IsSensitiveField(fieldType),
+			ValueCount: valueCount,
+			Notation: BuildNotation(&types.NotationResult{
+				UID:    record.Uid,
+				Field:  name,
+				Custom: true,
+				Index:  -1,
+			}),
+		})
 	}
+	return infos
+}
 
-	// Log access
+// FindByCustomField returns the records with a custom field labelled label (case
+// insensitive) holding value. Integrations use it to look records up by the
+// identifiers of external systems stored in a custom field.
+func (c *Client) FindByCustomField(label, value string) ([]*types.SecretMetadata, error) {
 	if c.logger != nil {
-		c.logAccess("field", "get", notation, c.profile, true, map[string]interface{}{
-			"masked": !unmask,
+		c.logAccess("secrets", "find_by_custom_field", "", c.profile, true, map[string]interface{}{
+			"field_label": label,
 		})
 	}
 
-	// Try to use SDK's notation support first
-	results, err := c.sm.GetNotation(notation)
+	records, err := c.sm.GetSecrets([]string{})
 	if err != nil {
-		// Check if it's a duplicate record error
-		if strings.Contains(err.Error(), "multiple records") || strings.Contains(err.Error(), "found multiple records") {
-			// Handle duplicates by getting records manually
-			return c.getFieldFromDuplicates(parsedNotation, unmask)
-		}
-
 		if c.logger != nil {
 			c.logError("ksm", err, map[string]interface{}{
-				"operation": "get_field",
-				"notation":  notation,
+				"operation":   "find_by_custom_field",
+				"field_label": label,
 			})
 		}
-		return nil, fmt.Errorf("failed to get field: %w", err)
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
 	}
 
-	// Process results based on type
-	if len(results) > 0 {
-		// For single values, return the first result
-		if len(results) == 1 {
-			value := results[0]
-			if str, ok := value.(string); ok && !unmask && isSensitiveField(parsedNotation.Field) {
-				return maskValue(str), nil
+	matches := []*types.SecretMetadata{}
+	for _, record := range records {
+		if !customFieldHasValue(record, label, value) {
+			continue
+		}
+		matches = append(matches, &types.SecretMetadata{
+			UID:    record.Uid,
+			Title:  record.Title(),
+			Type:   record.Type(),
+			Folder: record.FolderUid(),
+		})
+	}
+	return matches, nil
+}
+
+// customFieldHasValue reports whether a custom field of the record labelled label
+// holds value. Labels are compared case-insensitively; values must match exactly,
+// ignoring surrounding whitespace, since external IDs are often case-sensitive.
+// Sensitive fields, by type or label, never match so a lookup can't probe their values.
+func customFieldHasValue(record *sm.Record, label, value string) bool {
+	label = strings.TrimSpace(label)
+	value = strings.TrimSpace(value)
+	customFields, _ := record.RecordDict["custom"].([]interface{})
+	for _, field := range customFields {
+		fieldMap, ok := field.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fieldLabel, _ := fieldMap["label"].(string)
+		if !strings.EqualFold(strings.TrimSpace(fieldLabel), label) {
+			continue
+		}
+		fieldType, _ := fieldMap["type"].(string)
+		if IsSensitiveField(fieldType) || IsSensitiveField(fieldLabel) {
+			continue
+		}
+		values, _ := fieldMap["value"].([]interface{})
+		for _, v := range values {
+			if strings.TrimSpace(fmt.Sprint(v)) == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetFingerprintKey sets the key record fingerprints are computed with. Fingerprints
+// are only comparable between clients using the same key.
+func (c *Client) SetFingerprintKey(key []byte) {
+	c.fingerprintKey = key
+}
+
+// Fingerprint returns a keyed hash of a record's title, type, fields, custom fields
+// and notes. It changes whenever any of them changes, without revealing the values;
+// the key keeps the hash from being brute-forced offline.
+func (c *Client) Fingerprint(uid string) (string, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return "", fmt.Errorf("invalid UID: %w", err)
+	}
+	if len(c.fingerprintKey) == 0 {
+		return "", errors.New("fingerprint key is not configured")
+	}
+
+	c.logAccess("secret", "fingerprint", "", c.profile, true, map[string]interface{}{
+		"uid": uid,
+	})
+
+	records, err := c.sm.GetSecrets([]string{uid})
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "fingerprint",
+			"uid":       uid,
+		})
+		return "", fmt.Errorf("failed to get secret: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return "", ErrSecretNotFound
+	}
+	return recordFingerprint(c.fingerprintKey, records[0])
+}
+
+// recordFingerprint is the hex HMAC-SHA256 of the record's content encoded as JSON.
+// Map keys are encoded sorted, so equal content always hashes the same.
+func recordFingerprint(key []byte, record *sm.Record) (string, error) {
+	content := make(map[string]interface{})
+	for _, name := range []string{"title", "type", "fields", "custom", "notes"} {
+		if value, ok := record.RecordDict[name]; ok {
+			content[name] = value
+		}
+	}
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode record %s: %w", record.Uid, err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SearchSecrets searches for secrets by query. When folder UIDs are given, only the
+// records in those folders are fetched from Keeper and searched.
+func (c *Client) SearchSecrets(query string, folderUIDs []string) ([]*types.SecretMetadata, error) {
+	// Validate query
+	if err := c.validator.ValidateSearchQuery(query); err != nil {
+		return nil, fmt.Errorf("invalid search query: %w", err)
+	}
+	for _, folderUID := range folderUIDs {
+		if err := c.validator.ValidateUID(folderUID); err != nil {
+			return nil, fmt.Errorf("invalid folder UID %q: %w", folderUID, err)
+		}
+	}
+
+	// Log search
+	if c.logger != nil {
+		c.logAccess("secrets", "search", "", c.profile, true, map[string]interface{}{
+			"query_length": len(query),
+			"folders":      folderUIDs,
+		})
+	}
+
+	// Get the secrets in scope and filter
+	var records []*sm.Record
+	var err error
+	if len(folderUIDs) == 0 {
+		records, err = c.sm.GetSecrets([]string{})
+	} else {
+		records, err = c.sm.GetSecretsWithOptions(sm.QueryOptions{FoldersFilter: folderUIDs})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search secrets: %w", err)
+	}
+
+	return searchRecords(records, query), nil
+}
+
+// searchRecords returns the metadata of the records matching the query
+func searchRecords(records []*sm.Record, query string) []*types.SecretMetadata {
+	queryLower := strings.ToLower(query)
+	var results []*types.SecretMetadata
+
+	for _, record := range records {
+		if recordMatchesQuery(record, queryLower) {
+			results = append(results, &types.SecretMetadata{
+				UID:    record.Uid,
+				Title:  record.Title(),
+				Type:   record.Type(),
+				Folder: record.FolderUid(),
+			})
+		}
+	}
+
+	return results
+}
+
+// recordMatchesQuery reports whether a record's title, notes, type, searchable fields
+// or attachment names contain the lowercase query
+func recordMatchesQuery(record *sm.Record, queryLower string) bool {
+	// Search in title
+	if strings.Contains(strings.ToLower(record.Title()), queryLower) {
+		return true
+	}
+
+	// Search in notes
+	if record.Notes() != "" && strings.Contains(strings.ToLower(record.Notes()), queryLower) {
+		return true
+	}
+
+	// Search in record type
+	if strings.Contains(strings.ToLower(record.Type()), queryLower) {
+		return true
+	}
+
+	// Check standard fields
+	fieldTypes := []string{"login", "url", "hostname", "address"}
+	for _, fieldType := range fieldTypes {
+		if fieldValue := record.GetFieldValueByType(fieldType); fieldValue != "" {
+			if strings.Contains(strings.ToLower(fieldValue), queryLower) {
+				return true
 			}
-			return value, nil
+		}
+	}
+
+	// Check file attachments
+	for _, file := range record.Files {
+		if file.Name != "" && strings.Contains(strings.ToLower(file.Name), queryLower) {
+			return true
+		}
+		if file.Title != "" && strings.Contains(strings.ToLower(file.Title), queryLower) {
+			return true
+		}
+	}
+
+	// TODO: Add custom field search when SDK provides access
+	// Currently the SDK doesn't expose a method to iterate custom fields
+	return false
+}
+
+// GetField retrieves a specific field using KSM notation
+func (c *Client) GetField(notation string, unmask bool) (interface{}, error) {
+	// Validate notation
+	if err := c.validator.ValidateKSMNotation(notation); err != nil {
+		return nil, fmt.Errorf("invalid notation: %w", err)
+	}
+
+	// Parse notation
+	parsedNotation, err := ParseNotation(notation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notation: %w", err)
+	}
+
+	// Log access
+	if c.logger != nil {
+		c.logAccess("field", "get", notation, c.profile, true, map[string]interface{}{
+			"masked": !unmask,
+		})
+	}
+
+	// Try to use SDK's notation support first
+	results, err := c.sm.GetNotation(notation)
+	if err != nil {
+		// Check if it's a duplicate record error
+		if strings.Contains(err.Error(), "multiple records") || strings.Contains(err.Error(), "found multiple records") {
+			// Handle duplicates by getting records manually
+			return c.getFieldFromDuplicates(parsedNotation, unmask)
+		}
+
+		if c.logger != nil {
+			c.logError("ksm", err, map[string]interface{}{
+				"operation": "get_field",
+				"notation":  notation,
+			})
+		}
+		return nil, fmt.Errorf("failed to get field: %w", err)
+	}
+
+	// Process results based on type. A property such as securityQuestion[1][answer]
+	// is sensitive even when the field name itself is not.
+	sensitive := IsSensitiveField(parsedNotation.Field) || IsSensitiveField(parsedNotation.Property)
+	if len(results) > 0 {
+		// For single values, return the first result
+		if len(results) == 1 {
+			if unmask {
+				return results[0], nil
+			}
+			return maskNotationValue(results[0], sensitive), nil
 		}
 
 		// For multiple values, mask if needed
-		if !unmask && isSensitiveField(parsedNotation.Field) {
+		if !unmask {
 			maskedResults := make([]interface{}, len(results))
 			for i, result := range results {
-				if str, ok := result.(string); ok {
-					maskedResults[i] = maskValue(str)
-				} else {
-					maskedResults[i] = result
-				}
+				maskedResults[i] = maskNotationValue(result, sensitive)
 			}
 			return maskedResults, nil
 		}
@@ -1163,6 +1753,29 @@ func (c *Client) GetField(notation string, unmask bool) (interface{}, error) {
 	return nil, errors.New("field not found")
 }
 
+// maskNotationValue masks a value returned for a notation. Strings are masked when
+// the field is sensitive; objects (such as a securityQuestion pair) have their
+// sensitive properties masked, matching what get_secret shows for the field.
+func maskNotationValue(value interface{}, sensitive bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		if sensitive {
+			return maskValue(v)
+		}
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if str, ok := item.(string); ok && IsSensitiveField(key) {
+				masked[key] = maskValue(str)
+				continue
+			}
+			masked[key] = item
+		}
+		return masked
+	}
+	return value
+}
+
 // getFieldFromDuplicates handles getting field from duplicate records
 func (c *Client) getFieldFromDuplicates(parsedNotation *types.NotationResult, unmask bool) (interface{}, error) {
 	// Get all records
@@ -1188,9 +1801,9 @@ func (c *Client) getFieldFromDuplicates(parsedNotation *types.NotationResult, un
 	// Use the first matching record (they're all the same)
 	record := matchingRecords[0]
 
-	// Extract the field value
+	// Extract the field value; indices are 0-based, matching the SDK notation path
 	var indexPtr *int
-	if parsedNotation.Index > 0 {
+	if parsedNotation.Index >= 0 {
 		indexPtr = &parsedNotation.Index
 	}
 	fieldValue, err := c.extractFieldValue(record, parsedNotation.Field, indexPtr)
@@ -1199,7 +1812,7 @@ func (c *Client) getFieldFromDuplicates(parsedNotation *types.NotationResult, un
 	}
 
 	// Handle masking
-	if !unmask && isSensitiveField(parsedNotation.Field) {
+	if !unmask && IsSensitiveField(parsedNotation.Field) {
 		if str, ok := fieldValue.(string); ok {
 			return maskValue(str), nil
 		}
@@ -1213,33 +1826,27 @@ func (c *Client) extractFieldValue(record *sm.Record, field string, index *int)
 	switch field {
 	case "password":
 		// First try the standard Password() method
-		if password := record.Password(); password != "" {
+		if password := record.Password(); password != "" && (index == nil || *index == 0) {
 			return password, nil
 		}
 		// For database credentials and other types, check field values
 		values := record.GetFieldValuesByType("password")
 		if len(values) > 0 {
-			if index != nil && *index < len(values) {
-				return values[*index], nil
+			position, err := selectIndex(field, len(values), index)
+			if err != nil {
+				return nil, err
 			}
-			return values[0], nil
+			return values[position], nil
 		}
 		return "", nil
-	case "login":
-		values := record.GetFieldValuesByType("login")
-		if len(values) > 0 {
-			if index != nil && *index < len(values) {
-				return values[*index], nil
-			}
-			return values[0], nil
-		}
-	case "url":
-		values := record.GetFieldValuesByType("url")
+	case "login", "url":
+		values := record.GetFieldValuesByType(field)
 		if len(values) > 0 {
-			if index != nil && *index < len(values) {
-				return values[*index], nil
+			position, err := selectIndex(field, len(values), index)
+			if err != nil {
+				return nil, err
 			}
-			return values[0], nil
+			return values[position], nil
 		}
 	case "notes":
 		return record.Notes(), nil
@@ -1252,6 +1859,13 @@ func (c *Client) extractFieldValue(record *sm.Record, field string, index *int)
 						if fieldMap, ok := fieldData.(map[string]interface{}); ok {
 							if label, hasLabel := fieldMap["label"].(string); hasLabel && label == field {
 								if value, hasValue := fieldMap["value"]; hasValue {
+									if values, isList := value.([]interface{}); isList && index != nil {
+										position, err := selectIndex(field, len(values), index)
+										if err != nil {
+											return nil, err
+										}
+										return values[position], nil
+									}
 									return value, nil
 								}
 							}
@@ -1265,11 +1879,31 @@ func (c *Client) extractFieldValue(record *sm.Record, field string, index *int)
 	return nil, fmt.Errorf("field '%s' not found", field)
 }
 
+// selectIndex returns the position of the 0-based index among count values, or the
+// first position when no index was given. An index past the end is an error rather
+// than a silent fallback to the first value, so url[1] never returns url[0].
+func selectIndex(field string, count int, index *int) (int, error) {
+	if index == nil {
+		return 0, nil
+	}
+	if *index < 0 || *index >= count {
+		return 0, fmt.Errorf("index %d out of range for field '%s' with %d values (indices are 0-based)", *index, field, count)
+	}
+	return *index, nil
+}
+
+// Password lengths accepted by generate_password
+const (
+	DefaultPasswordLength = 32 // used when no length is requested
+	MinPasswordLength     = 8
+	MaxPasswordLength     = 100
+)
+
 // GeneratePassword generates a secure password using KSM
 func (c *Client) GeneratePassword(params types.GeneratePasswordParams) (string, error) {
 	// Set defaults
 	if params.Length == 0 {
-		params.Length = 32
+		params.Length = DefaultPasswordLength
 	}
 
 	// Log password generation
@@ -1301,13 +1935,25 @@ func (c *Client) GeneratePassword(params types.GeneratePasswordParams) (string,
 		special = "0"
 	}
 
+	excluded := excludedPasswordCharacters(params)
+	specialSet := params.SpecialSet
+	if excluded != "" {
+		if specialSet == "" {
+			specialSet = sm.AsciiSpecialCharacters
+		}
+		specialSet = removeCharacters(specialSet, excluded)
+		if specialSet == "" && params.Special > 0 {
+			return "", errors.New("failed to generate password: no special characters remain after exclusions")
+		}
+	}
+
 	password, err := sm.GeneratePassword(
 		params.Length,
 		lowercase,
 		uppercase,
 		digits,
 		special,
-		params.SpecialSet, // Use custom special character set if provided
+		specialSet, // Use custom special character set if provided
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate password: %w", err)
@@ -1317,9 +1963,182 @@ func (c *Client) GeneratePassword(params types.GeneratePasswordParams) (string,
 		return "", errors.New("failed to generate password")
 	}
 
+	if excluded != "" {
+		if password, err = replaceExcludedCharacters(password, excluded, specialSet); err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+	}
+
 	return password, nil
 }
 
+const (
+	// similarPasswordCharacters look alike in many fonts
+	similarPasswordCharacters = "il1Lo0O"
+	// ambiguousPasswordCharacters are hard to read aloud or type reliably
+	ambiguousPasswordCharacters = "{}[]()/\\'\"`~,;:.<>"
+)
+
+// excludedPasswordCharacters returns the characters a generated password must not contain
+func excludedPasswordCharacters(params types.GeneratePasswordParams) string {
+	excluded := ""
+	if params.ExcludeSimilar {
+		excluded += similarPasswordCharacters
+	}
+	if params.ExcludeAmbiguous {
+		excluded += ambiguousPasswordCharacters
+	}
+	return excluded
+}
+
+// removeCharacters returns set without any of the characters in excluded
+func removeCharacters(set, excluded string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(excluded, r) {
+			return -1
+		}
+		return r
+	}, set)
+}
+
+// PasswordComposition counts the character classes of a password generated with
+// params and estimates its entropy as length × log2(pool), where the pool is the
+// characters of every class the password uses, less any excluded characters
+func PasswordComposition(password string, params types.GeneratePasswordParams) types.PasswordComposition {
+	excluded := excludedPasswordCharacters(params)
+	specialSet := params.SpecialSet
+	if specialSet == "" {
+		specialSet = sm.AsciiSpecialCharacters
+	}
+
+	composition := types.PasswordComposition{}
+	for _, r := range password {
+		composition.Length++
+		switch {
+		case strings.ContainsRune(sm.AsciiLowercase, r):
+			composition.Lowercase++
+		case strings.ContainsRune(sm.AsciiUppercase, r):
+			composition.Uppercase++
+		case strings.ContainsRune(sm.AsciiDigits, r):
+			composition.Digits++
+		default:
+			composition.Special++
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		count int
+		set   string
+	}{
+		{composition.Lowercase, sm.AsciiLowercase},
+		{composition.Uppercase, sm.AsciiUppercase},
+		{composition.Digits, sm.AsciiDigits},
+		{composition.Special, specialSet},
+	} {
+		if class.count > 0 {
+			pool += len(removeCharacters(class.set, excluded))
+		}
+	}
+	if pool > 1 {
+		bits := float64(composition.Length) * math.Log2(float64(pool))
+		composition.EntropyBits = math.Round(bits*10) / 10
+	}
+	return composition
+}
+
+// replaceExcludedCharacters swaps each excluded character for a random character of the
+// same class (lowercase, uppercase, digit or special), so the SDK's composition
+// guarantees still hold
+func replaceExcludedCharacters(password, excluded, specialSet string) (string, error) {
+	classes := []string{sm.AsciiLowercase, sm.AsciiUppercase, sm.AsciiDigits, specialSet}
+	runes := []rune(password)
+	for i, r := range runes {
+		if !strings.ContainsRune(excluded, r) {
+			continue
+		}
+		alphabet := ""
+		for _, class := range classes {
+			if strings.ContainsRune(class, r) {
+				alphabet = removeCharacters(class, excluded)
+				break
+			}
+		}
+		if alphabet == "" {
+			return "", fmt.Errorf("no replacement available for excluded character class of %q", r)
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		runes[i] = rune(alphabet[n.Int64()])
+	}
+	return string(runes), nil
+}
+
+// totpFieldTypes lists the field types that hold an otpauth:// URI
+var totpFieldTypes = map[string]bool{
+	"oneTimeCode": true,
+	"otp":         true,
+}
+
+// recordTOTPURL returns the first TOTP URI configured on a record, checking an
+// otpauth:// password and any standard or custom one-time code field. It returns
+// an empty string when the record has no TOTP configured.
+func recordTOTPURL(record *sm.Record) string {
+	if record == nil || record.RecordDict == nil {
+		return ""
+	}
+
+	if password := record.GetFieldValueByType("password"); strings.HasPrefix(password, "otpauth://") {
+		return password
+	}
+
+	for _, section := range []string{"fields", "custom"} {
+		fieldsList, ok := record.RecordDict[section].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range fieldsList {
+			fieldMap, ok := field.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if fieldType, _ := fieldMap["type"].(string); !totpFieldTypes[fieldType] {
+				continue
+			}
+			if values, ok := fieldMap["value"].([]interface{}); ok && len(values) > 0 {
+				if url, ok := values[0].(string); ok && url != "" {
+					return url
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// removeTOTPFields deletes every standard or custom one-time code field from a record
+// and returns how many were removed. An otpauth:// URI stored as the password is left
+// alone, since removing it would also remove the password.
+func removeTOTPFields(record *sm.Record) int {
+	if record == nil || record.RecordDict == nil {
+		return 0
+	}
+
+	removed := 0
+	for _, section := range []string{"fields", "custom"} {
+		for fieldType := range totpFieldTypes {
+			removed += record.RemoveField(section, fieldType, true)
+		}
+	}
+	if removed > 0 {
+		// RemoveField only edits RecordDict; Save sends RawJson
+		record.RawJson = sm.DictToJson(record.RecordDict)
+	}
+	return removed
+}
+
 // GetTOTPCode generates a TOTP code for a secret
 func (c *Client) GetTOTPCode(uid string) (*types.TOTPResponse, error) {
 	// Validate UID
@@ -1332,45 +2151,78 @@ func (c *Client) GetTOTPCode(uid string) (*types.TOTPResponse, error) {
 		"field": "totp",
 	})
 
-	// Get secret
-	records, err := c.sm.GetSecrets([]string{uid})
-	if err != nil || len(records) == 0 {
-		return nil, errors.New("secret not found")
+	totpURL, err := c.lookupTOTPURL(uid)
+	if err != nil {
+		return nil, err
+	}
+	if totpURL == "" {
+		return nil, errors.New("no TOTP field found in secret")
 	}
 
-	record := records[0]
+	// Generate TOTP code
+	totpCode, err := sm.GetTotpCode(totpURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP: %w", err)
+	}
 
-	// Look for TOTP field
-	// Try different field names where TOTP might be stored
-	var totpURL string
+	return &types.TOTPResponse{
+		Code:     totpCode.Code,
+		TimeLeft: totpCode.TimeLeft,
+	}, nil
+}
 
-	// Check standard fields
-	if record.Password() != "" && strings.HasPrefix(record.Password(), "otpauth://") {
-		totpURL = record.Password()
-	}
-
-	// Check custom fields for TOTP using the raw record dictionary
-	if totpURL == "" && record.RecordDict != nil {
-		if customFieldsData, exists := record.RecordDict["custom"]; exists {
-			if customFieldsList, ok := customFieldsData.([]interface{}); ok {
-				for _, field := range customFieldsList {
-					if fieldMap, ok := field.(map[string]interface{}); ok {
-						if fieldType, hasType := fieldMap["type"].(string); hasType && fieldType == "oneTimeCode" {
-							if value, hasValue := fieldMap["value"]; hasValue {
-								if values, ok := value.([]interface{}); ok && len(values) > 0 {
-									if url, ok := values[0].(string); ok {
-										totpURL = url
-										break
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-		}
+// VerifyTOTPCode checks a code against the current and adjacent TOTP windows of a
+// secret's seed. Only whether it matched is returned, never the expected code.
+func (c *Client) VerifyTOTPCode(uid, code string) (*types.TOTPVerification, error) {
+	// Validate UID
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID: %w", err)
+	}
+
+	totpURL, err := c.lookupTOTPURL(uid)
+	if err != nil {
+		return nil, err
+	}
+	if totpURL == "" {
+		return nil, ErrNoTOTP
+	}
+
+	valid, drift, err := VerifyTOTPCode(totpURL, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed verification is logged as an unsuccessful access, so repeated guessing shows up in the audit log
+	c.logSecretOperation(audit.EventSecretAccess, uid, "", c.profile, valid, map[string]interface{}{
+		"field":  "totp",
+		"action": "verify",
+	})
+
+	result := &types.TOTPVerification{UID: uid, Valid: valid}
+	if valid {
+		result.Drift = drift
+	}
+	return result, nil
+}
+
+// lookupTOTPURL returns the TOTP URI configured on a secret, or an empty string when
+// the secret has none
+func (c *Client) lookupTOTPURL(uid string) (string, error) {
+	records, err := c.store.GetSecrets([]string{uid})
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "get_totp",
+			"uid":       uid,
+		})
+		return "", fmt.Errorf("failed to get secret: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return "", ErrSecretNotFound
 	}
 
+	// Look for TOTP field in the password and one-time code fields
+	totpURL := recordTOTPURL(records[0])
+
 	if totpURL == "" {
 		// Try using notation to get TOTP field
 		totpNotation := fmt.Sprintf("%s/custom_field/oneTimeCode", uid)
@@ -1381,20 +2233,7 @@ func (c *Client) GetTOTPCode(uid string) (*types.TOTPResponse, error) {
 		}
 	}
 
-	if totpURL == "" {
-		return nil, errors.New("no TOTP field found in secret")
-	}
-
-	// Generate TOTP code
-	totpCode, err := sm.GetTotpCode(totpURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate TOTP: %w", err)
-	}
-
-	return &types.TOTPResponse{
-		Code:     totpCode.Code,
-		TimeLeft: totpCode.TimeLeft,
-	}, nil
+	return totpURL, nil
 }
 
 // CreateSecret creates a new secret
@@ -1441,29 +2280,15 @@ func (c *Client) CreateSecret(params types.CreateSecretParams) (string, error) {
 	}
 
 	// Determine SDK CreateOptions based on the target params.FolderUID
-	sdkCreateOptions := sm.CreateOptions{}
-	foundTargetFolder := false
-
-	for _, kf := range allKeeperFolders {
-		if kf.FolderUid == params.FolderUID {
-			foundTargetFolder = true
-			if kf.ParentUid != "" { // Our target folder has a parent
-				sdkCreateOptions.FolderUid = kf.ParentUid        // The direct parent becomes the main FolderUid for CreateOptions
-				sdkCreateOptions.SubFolderUid = params.FolderUID // Our target is the SubFolderUid
-			} else { // Our target folder is a root folder (no parent UID)
-				sdkCreateOptions.FolderUid = params.FolderUID // Target itself is the main FolderUid
-				sdkCreateOptions.SubFolderUid = ""            // No sub-folder in this context for the SDK call
-			}
-			break
-		}
+	sdkCreateOptions, foundTargetFolder, err := createOptionsForFolder(allKeeperFolders, params.FolderUID)
+	if err != nil {
+		return "", fmt.Errorf("cannot create secret '%s': %w", params.Title, err)
 	}
 
 	// If targetFolderUID was not found in allKeeperFolders, it implies it might be a shared folder itself that wasn't listed as a sub-folder of anything.
 	// Or it's an invalid FolderUID. The SDK call will ultimately determine validity.
 	if !foundTargetFolder {
 		c.logSystem(audit.EventAccess, fmt.Sprintf("Target folder %s not found in GetFolders list; assuming it is the main shared folder for SDK CreateOptions or will be handled by SDK.", params.FolderUID), map[string]interface{}{"profile": c.profile, "target_folder_uid": params.FolderUID})
-		sdkCreateOptions.FolderUid = params.FolderUID // Assume user-provided UID is the main shared folder context
-		sdkCreateOptions.SubFolderUid = ""            // If it's the main shared folder, SubFolderUid is empty for the SDK.
 	}
 
 	c.logSystem(audit.EventAccess, "Attempting CreateSecretWithRecordDataAndOptions", map[string]interface{}{
@@ -1490,6 +2315,44 @@ func (c *Client) CreateSecret(params types.CreateSecretParams) (string, error) {
 	return uid, nil
 }
 
+// createOptionsForFolder returns the SDK CreateOptions for creating in targetUID. The
+// SDK encrypts with the key of CreateOptions.FolderUid, which must be the shared folder
+// at the top of the target's ParentUid chain however deeply the target is nested; the
+// target itself becomes SubFolderUid unless it is that shared folder. found is false
+// when targetUID is not among folders, in which case it is assumed to be a shared folder.
+// A chain that loops or leaves the accessible folders has no shared folder to encrypt
+// with and is an error.
+func createOptionsForFolder(folders []*sm.KeeperFolder, targetUID string) (options sm.CreateOptions, found bool, err error) {
+	byUID := make(map[string]*sm.KeeperFolder, len(folders))
+	for _, folder := range folders {
+		byUID[folder.FolderUid] = folder
+	}
+
+	target, found := byUID[targetUID]
+	if !found {
+		return sm.CreateOptions{FolderUid: targetUID}, false, nil
+	}
+
+	root := target
+	visited := map[string]bool{root.FolderUid: true}
+	for root.ParentUid != "" {
+		parent, ok := byUID[root.ParentUid]
+		if !ok {
+			return sm.CreateOptions{}, true, fmt.Errorf("folder %s is in a folder that is not accessible to this application", targetUID)
+		}
+		if visited[parent.FolderUid] {
+			return sm.CreateOptions{}, true, fmt.Errorf("folder %s has a parent folder cycle", targetUID)
+		}
+		visited[parent.FolderUid] = true
+		root = parent
+	}
+
+	if root.FolderUid == targetUID {
+		return sm.CreateOptions{FolderUid: targetUID}, true, nil
+	}
+	return sm.CreateOptions{FolderUid: root.FolderUid, SubFolderUid: targetUID}, true, nil
+}
+
 // UpdateSecret updates an existing secret
 func (c *Client) UpdateSecret(params types.UpdateSecretParams) error {
 	// Validate UID
@@ -1500,10 +2363,14 @@ func (c *Client) UpdateSecret(params types.UpdateSecretParams) error {
 	// Log update attempt
 	c.logSecretOperation(audit.EventSecretUpdate, params.UID, "", c.profile, true, nil)
 
+	// Hold the record's lock from read to save so concurrent updates don't overwrite each other
+	unlock := c.records.lock(params.UID)
+	defer unlock()
+
 	// Get existing record
-	records, err := c.sm.GetSecrets([]string{params.UID})
+	records, err := c.store.GetSecrets([]string{params.UID})
 	if err != nil || len(records) == 0 {
-		return errors.New("secret not found")
+		return ErrSecretNotFound
 	}
 
 	record := records[0]
@@ -1513,22 +2380,23 @@ func (c *Client) UpdateSecret(params types.UpdateSecretParams) error {
 		record.SetTitle(params.Title)
 	}
 	for _, field := range params.Fields {
-		if field.Type == "password" && len(field.Value) > 0 {
-			if password, ok := field.Value[0].(string); ok {
-				record.SetPassword(password)
-			}
-		} else if len(field.Value) > 0 {
-			if value, ok := field.Value[0].(string); ok {
-				record.SetFieldValueSingle(field.Type, value)
+		if len(field.Value) > 0 {
+			if err := applyFieldUpdate(record, field, c.schemas); err != nil {
+				return err
 			}
 		}
 	}
 	if params.Notes != "" {
 		record.SetNotes(params.Notes)
 	}
+	// applyFieldUpdate only edits RecordDict; Save sends RawJson
+	record.RawJson = sm.DictToJson(record.RecordDict)
+	if params.ClearTOTP && removeTOTPFields(record) == 0 {
+		return ErrNoTOTP
+	}
 
 	// Save the record
-	if err := c.sm.Save(record); err != nil {
+	if err := c.store.Save(record); err != nil {
 		c.logError("ksm", err, map[string]interface{}{
 			"operation": "update_secret",
 			"uid":       params.UID,
@@ -1539,6 +2407,256 @@ func (c *Client) UpdateSecret(params types.UpdateSecretParams) error {
 	return nil
 }
 
+// applyFieldUpdate writes an updated field into the record's standard fields, adding
+// the field when the record has none of its type and the record type's schema from
+// getSchema defines it. Text values replace all of the field's values. Object values,
+// such as a name or securityQuestion pair, are merged into the existing value at the
+// same position, so sub-fields not given are kept. A nil value leaves its position
+// unchanged, and positions past the existing values are appended.
+func applyFieldUpdate(record *sm.Record, update types.SecretField, getSchema func(string) (*types.RecordTypeSchema, error)) error {
+	if record.RecordDict == nil {
+		record.RecordDict = map[string]interface{}{}
+	}
+	fields, _ := record.RecordDict["fields"].([]interface{})
+	var existing map[string]interface{}
+	for _, entry := range fields {
+		if field, ok := entry.(map[string]interface{}); ok && field["type"] == update.Type {
+			existing = field
+			break
+		}
+	}
+
+	if !objectValues(update.Value) {
+		values := make([]interface{}, 0, len(update.Value))
+		for _, value := range update.Value {
+			if value != nil {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		if existing != nil {
+			existing["value"] = values
+			return nil
+		}
+		if err := checkFieldInSchema(record.Type(), update.Type, getSchema); err != nil {
+			return err
+		}
+		record.RecordDict["fields"] = append(fields, map[string]interface{}{"type": update.Type, "value": values})
+		return nil
+	}
+
+	if existing == nil {
+		if err := checkFieldInSchema(record.Type(), update.Type, getSchema); err != nil {
+			return err
+		}
+		existing = map[string]interface{}{"type": update.Type, "value": []interface{}{}}
+		record.RecordDict["fields"] = append(fields, existing)
+	}
+	current, _ := existing["value"].([]interface{})
+	merged := append([]interface{}{}, current...)
+	for i, value := range update.Value {
+		if value == nil {
+			continue
+		}
+		object := value.(map[string]interface{})
+		if i >= len(merged) {
+			merged = append(merged, object)
+			continue
+		}
+		previous, ok := merged[i].(map[string]interface{})
+		if !ok {
+			merged[i] = object
+			continue
+		}
+		combined := make(map[string]interface{}, len(previous)+len(object))
+		for key, subValue := range previous {
+			combined[key] = subValue
+		}
+		for key, subValue := range object {
+			combined[key] = subValue
+		}
+		merged[i] = combined
+	}
+	existing["value"] = merged
+	return nil
+}
+
+// checkFieldInSchema returns an error unless the schema of recordType defines a
+// standard field of fieldType, so an update cannot add fields the type does not have
+func checkFieldInSchema(recordType, fieldType string, getSchema func(string) (*types.RecordTypeSchema, error)) error {
+	var schema *types.RecordTypeSchema
+	if getSchema != nil {
+		schema, _ = getSchema(recordType)
+	}
+	if schema == nil {
+		return fmt.Errorf("record has no '%s' field and the fields of record type '%s' are unknown, so it cannot be added", fieldType, recordType)
+	}
+	for _, field := range schema.Fields {
+		if strings.HasPrefix(field.Name, "custom.") {
+			continue
+		}
+		base, _, _ := strings.Cut(field.Name, ".")
+		if field.Ref == fieldType || (field.Ref == "" && base == fieldType) {
+			return nil
+		}
+	}
+	return fmt.Errorf("record has no '%s' field and record type '%s' does not define one", fieldType, recordType)
+}
+
+// objectValues reports whether values holds at least one object and otherwise only nils
+func objectValues(values []interface{}) bool {
+	found := false
+	for _, value := range values {
+		switch value.(type) {
+		case nil:
+		case map[string]interface{}:
+			found = true
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// RenameSecret changes the title of an existing secret without touching its fields
+func (c *Client) RenameSecret(uid, newTitle string) error {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return fmt.Errorf("invalid UID: %w", err)
+	}
+	if err := c.validator.ValidateTitle(newTitle); err != nil {
+		return fmt.Errorf("invalid title: %w", err)
+	}
+
+	c.logSecretOperation(audit.EventSecretUpdate, uid, "", c.profile, true, map[string]interface{}{
+		"operation": "rename",
+	})
+
+	unlock := c.records.lock(uid)
+	defer unlock()
+
+	records, err := c.store.GetSecrets([]string{uid})
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "rename_secret",
+			"uid":       uid,
+		})
+		return fmt.Errorf("failed to get secret: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return ErrSecretNotFound
+	}
+
+	record := records[0]
+	record.SetTitle(newTitle)
+
+	if err := c.store.Save(record); err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "rename_secret",
+			"uid":       uid,
+		})
+		return fmt.Errorf("failed to rename secret: %w", err)
+	}
+
+	return nil
+}
+
+// AppendNotes adds text to the end of a secret's notes, on a new line when the
+// record already has notes, so existing notes are never overwritten
+func (c *Client) AppendNotes(uid, text string) error {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return fmt.Errorf("invalid UID: %w", err)
+	}
+
+	c.logSecretOperation(audit.EventSecretUpdate, uid, "", c.profile, true, map[string]interface{}{
+		"operation": "append_notes",
+	})
+
+	unlock := c.records.lock(uid)
+	defer unlock()
+
+	records, err := c.store.GetSecrets([]string{uid})
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "append_notes",
+			"uid":       uid,
+		})
+		return fmt.Errorf("failed to get secret: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return ErrSecretNotFound
+	}
+
+	record := records[0]
+	notes := record.Notes()
+	if notes != "" && !strings.HasSuffix(notes, "\n") {
+		notes += "\n"
+	}
+	record.SetNotes(notes + text)
+
+	if err := c.store.Save(record); err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "append_notes",
+			"uid":       uid,
+		})
+		return fmt.Errorf("failed to append notes: %w", err)
+	}
+
+	return nil
+}
+
+// RotatePassword replaces the value of a record's password field with password.
+// Records without a password field return ErrNoPassword.
+func (c *Client) RotatePassword(uid, password string) error {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return fmt.Errorf("invalid UID: %w", err)
+	}
+
+	c.logSecretOperation(audit.EventSecretUpdate, uid, "", c.profile, true, map[string]interface{}{
+		"operation": "rotate_password",
+	})
+
+	unlock := c.records.lock(uid)
+	defer unlock()
+
+	records, err := c.store.GetSecrets([]string{uid})
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "rotate_password",
+			"uid":       uid,
+		})
+		return fmt.Errorf("failed to get secret: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return ErrSecretNotFound
+	}
+
+	record := records[0]
+	if err := setRecordPassword(record, password); err != nil {
+		return err
+	}
+
+	if err := c.store.Save(record); err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "rotate_password",
+			"uid":       uid,
+		})
+		return fmt.Errorf("failed to rotate password: %w", err)
+	}
+
+	return nil
+}
+
+// setRecordPassword sets the first password field of a record
+func setRecordPassword(record *sm.Record, password string) error {
+	if len(record.GetFieldsByType("password")) == 0 {
+		return ErrNoPassword
+	}
+	record.SetPassword(password)
+	return nil
+}
+
 // DeleteSecret deletes a secret
 func (c *Client) DeleteSecret(uid string, permanent bool) error { // KSM SDK permanent is 'force'
 	// Note: The 'permanent' flag is for MCP layer consistency.
@@ -1561,6 +2679,12 @@ func (c *Client) DeleteSecret(uid string, permanent bool) error { // KSM SDK per
 	// Delete the record
 	statuses, err := c.sm.DeleteSecrets([]string{uid}) // Basic call, assuming no direct force flag here or handled by SDK default
 	if err != nil {
+		if isRecordNotFoundStatus(err.Error()) {
+			c.logSystem(audit.EventAccess, fmt.Sprintf("DeleteSecret: UID %s was already deleted", uid), map[string]interface{}{
+				"uid": uid,
+			})
+			return nil
+		}
 		c.logError("ksm", err, map[string]interface{}{
 			"operation": "delete_secret",
 			"uid":       uid,
@@ -1568,29 +2692,65 @@ func (c *Client) DeleteSecret(uid string, permanent bool) error { // KSM SDK per
 		return fmt.Errorf("failed to delete secret during SDK call for UID %s: %w", uid, err)
 	}
 
-	// Check if deletion was successful for the specific UID
-	status, exists := statuses[uid]
-	if !exists {
-		c.logSystem(audit.EventAccess, fmt.Sprintf("DeleteSecret status for UID %s not found in SDK response, though SDK call had no error.", uid), map[string]interface{}{
+	recordExists := func() bool {
+		records, err := c.sm.GetSecrets([]string{uid})
+		return err != nil || len(records) > 0 // assume it still exists when unsure
+	}
+	alreadyDeleted, err := deleteSecretOutcome(uid, statuses, recordExists)
+	if err != nil {
+		c.logSystem(audit.EventError, fmt.Sprintf("DeleteSecret failed for UID %s", uid), map[string]interface{}{
 			"uid": uid, "statuses_map": statuses,
 		})
-		return fmt.Errorf("failed to confirm delete secret status for UID %s (not found in status map: %v)", uid, statuses)
+		return err
 	}
-
-	// Treat "success" and "ok" as successful deletion statuses.
-	if status != "success" && status != "ok" {
-		c.logSystem(audit.EventError, fmt.Sprintf("DeleteSecret status for UID %s was '%s', not 'success' or 'ok'.", uid, status), map[string]interface{}{
-			"uid": uid, "status": status,
+	if alreadyDeleted {
+		c.logSystem(audit.EventAccess, fmt.Sprintf("DeleteSecret: UID %s was already deleted", uid), map[string]interface{}{
+			"uid": uid, "statuses_map": statuses,
 		})
-		return fmt.Errorf("failed to delete secret: KSM reported status '%s' for UID %s", status, uid)
+		return nil
 	}
 
-	c.logSystem(audit.EventAccess, fmt.Sprintf("DeleteSecret successful for UID %s with KSM status '%s'", uid, status), map[string]interface{}{
-		"uid": uid, "status": status,
+	c.logSystem(audit.EventAccess, fmt.Sprintf("DeleteSecret successful for UID %s with KSM status '%s'", uid, statuses[uid]), map[string]interface{}{
+		"uid": uid, "status": statuses[uid],
 	})
 	return nil // Success
 }
 
+// deleteSecretOutcome interprets the delete status the SDK returned for uid. A record
+// Keeper reports as not found was already deleted, typically by an earlier attempt
+// whose response was lost, so a retried delete succeeds. When uid is missing from the
+// status map, recordExists decides: a record that is gone counts as deleted.
+func deleteSecretOutcome(uid string, statuses map[string]string, recordExists func() bool) (bool, error) {
+	status, exists := statuses[uid]
+	if !exists {
+		if !recordExists() {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to confirm delete secret status for UID %s (not found in status map: %v)", uid, statuses)
+	}
+
+	// Treat "success" and "ok" as successful deletion statuses.
+	if status == "success" || status == "ok" {
+		return false, nil
+	}
+	if isRecordNotFoundStatus(status) {
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to delete secret: KSM reported status '%s' for UID %s", status, uid)
+}
+
+// isRecordNotFoundStatus reports whether a Keeper status or error message says the
+// record does not exist
+func isRecordNotFoundStatus(status string) bool {
+	status = strings.ToLower(status)
+	for _, marker := range []string{"not_found", "not found", "does not exist", "doesn't exist"} {
+		if strings.Contains(status, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // UploadFile uploads a file to a secret
 func (c *Client) UploadFile(uid, filePath, title string) error {
 	// Validate inputs
@@ -1606,10 +2766,14 @@ func (c *Client) UploadFile(uid, filePath, title string) error {
 		"file": filePath,
 	})
 
+	// Uploading adds the file to the record and saves it, so it is serialized like an update
+	unlock := c.records.lock(uid)
+	defer unlock()
+
 	// Get the record
 	records, err := c.sm.GetSecrets([]string{uid})
 	if err != nil || len(records) == 0 {
-		return errors.New("secret not found")
+		return ErrSecretNotFound
 	}
 
 	record := records[0]
@@ -1650,7 +2814,7 @@ func (c *Client) DownloadFile(uid, fileUID, savePath string) error {
 	// Get the record
 	records, err := c.sm.GetSecrets([]string{uid})
 	if err != nil || len(records) == 0 {
-		return errors.New("secret not found")
+		return ErrSecretNotFound
 	}
 
 	record := records[0]
@@ -1704,6 +2868,130 @@ func (c *Client) ListFolders() (*types.ListFoldersResponse, error) {
 	}, nil
 }
 
+// IsSharedFolder reports whether a folder is one of the shared folders shared with the
+// application, which records can be created in, rather than a subfolder inside one.
+// Folders the application cannot see return ErrFolderNotFound.
+func (c *Client) IsSharedFolder(uid string) (bool, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return false, fmt.Errorf("invalid folder UID: %w", err)
+	}
+
+	c.logAccess("folder", "is_shared", "", c.profile, true, map[string]interface{}{
+		"uid": uid,
+	})
+
+	folders, err := c.sm.GetFolders()
+	if err != nil {
+		return false, fmt.Errorf("failed to list folders: %w", err)
+	}
+
+	shared, found := sharedFolderStatus(uid, folders)
+	if !found {
+		return false, ErrFolderNotFound
+	}
+	return shared, nil
+}
+
+// sharedFolderStatus looks a folder up in GetFolders metadata. The SDK reports the
+// shared folders given to the application without a parent; their subfolders carry
+// the UID of the folder they are in.
+func sharedFolderStatus(uid string, folders []*sm.KeeperFolder) (shared, found bool) {
+	for _, folder := range folders {
+		if folder != nil && folder.FolderUid == uid {
+			return folder.ParentUid == "", true
+		}
+	}
+	return false, false
+}
+
+// GetSecretFolderUIDs returns the folders a record is in: the shared folder and, when
+// the record sits in one of its subfolders, that subfolder. Records outside any
+// accessible folder return none.
+func (c *Client) GetSecretFolderUIDs(uid string) ([]string, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID: %w", err)
+	}
+
+	records, err := c.sm.GetSecrets([]string{uid})
+	if err != nil || len(records) == 0 {
+		return nil, ErrSecretNotFound
+	}
+
+	var folderUIDs []string
+	if folderUID := records[0].FolderUid(); folderUID != "" {
+		folderUIDs = append(folderUIDs, folderUID)
+	}
+	if inner := records[0].InnerFolderUid(); inner != "" && inner != records[0].FolderUid() {
+		folderUIDs = append(folderUIDs, inner)
+	}
+	return folderUIDs, nil
+}
+
+// GetSecretPath returns the folder breadcrumb of a record, e.g. "Engineering / Prod / DB".
+// Records outside any accessible folder (vault root or shared directly) have an empty path.
+func (c *Client) GetSecretPath(uid string) (string, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return "", fmt.Errorf("invalid UID: %w", err)
+	}
+
+	c.logAccess("secret", "path", "", c.profile, true, map[string]interface{}{
+		"uid": uid,
+	})
+
+	records, err := c.sm.GetSecrets([]string{uid})
+	if err != nil || len(records) == 0 {
+		return "", ErrSecretNotFound
+	}
+
+	// Records in a shared folder's subfolder report the subfolder as their inner folder
+	record := records[0]
+	folderUID := record.InnerFolderUid()
+	if folderUID == "" {
+		folderUID = record.FolderUid()
+	}
+	if folderUID == "" {
+		return "", nil
+	}
+
+	folders, err := c.sm.GetFolders()
+	if err != nil {
+		return "", fmt.Errorf("failed to list folders: %w", err)
+	}
+
+	folderInfos := make([]types.FolderInfo, 0, len(folders))
+	for _, folder := range folders {
+		folderInfos = append(folderInfos, types.FolderInfo{
+			UID:       folder.FolderUid,
+			Name:      folder.Name,
+			ParentUID: folder.ParentUid,
+		})
+	}
+
+	return FolderPath(folderUID, folderInfos), nil
+}
+
+// FolderPath builds the " / " separated path from the top-most accessible folder down to folderUID
+func FolderPath(folderUID string, folders []types.FolderInfo) string {
+	byUID := make(map[string]types.FolderInfo, len(folders))
+	for _, folder := range folders {
+		byUID[folder.UID] = folder
+	}
+
+	var names []string
+	visited := make(map[string]bool)
+	for current := folderUID; current != "" && !visited[current]; {
+		folder, ok := byUID[current]
+		if !ok {
+			break
+		}
+		visited[current] = true
+		names = append([]string{folder.Name}, names...)
+		current = folder.ParentUID
+	}
+
+	return strings.Join(names, " / ")
+}
+
 // CreateFolder creates a new folder
 func (c *Client) CreateFolder(name, parentUID string) (string, error) {
 	c.logAccess("folder", "create", "", c.profile, true, map[string]interface{}{
@@ -1818,12 +3106,13 @@ func (c *Client) DeleteFolder(uid string, force bool) error {
 	return nil
 }
 
-// TestConnection tests the KSM connection
+// TestConnection tests the KSM connection. A failure wraps an *InitError describing
+// its cause.
 func (c *Client) TestConnection() error {
 	// Try to get secrets to test connection
 	_, err := c.sm.GetSecrets([]string{})
 	if err != nil {
-		return fmt.Errorf("connection test failed: %w", err)
+		return fmt.Errorf("connection test failed: %w", c.diagnoseConnectionError(err))
 	}
 
 	return nil
@@ -1831,16 +3120,17 @@ func (c *Client) TestConnection() error {
 
 // Helper functions
 
-// maskValue masks sensitive values
+// maskValue masks sensitive values. Multiline values such as PEM keys are masked
+// entirely so that no part of their first or last line is shown.
 func maskValue(value string) string {
-	if len(value) <= 6 {
+	if len(value) <= 6 || strings.ContainsAny(value, "\r\n") {
 		return "******"
 	}
 	return value[:3] + "***" + value[len(value)-3:]
 }
 
-// isSensitiveField checks if a field name is sensitive
-func isSensitiveField(field string) bool {
+// IsSensitiveField checks if a field name is sensitive
+func IsSensitiveField(field string) bool {
 	sensitiveFields := []string{
 		"password", "secret", "key", "token", "privateKey",
 		"cardNumber", "cardSecurityCode", "accountNumber",
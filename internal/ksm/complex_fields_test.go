@@ -1,14 +1,13 @@
 package ksm
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetFieldTypesForRecordType(t *testing.T) {
-	client := &Client{}
-
+func TestRecordTypeFieldTypes(t *testing.T) {
 	tests := []struct {
 		name           string
 		recordType     string
@@ -74,26 +73,18 @@ func TestGetFieldTypesForRecordType(t *testing.T) {
 			recordType:     "script",
 			expectedFields: []string{"script", "text", "multiline", "fileRef"},
 		},
-		{
-			name:       "unknown record type",
-			recordType: "unknownType",
-			expectedFields: []string{
-				"login", "password", "url", "text", "multiline", "host", "name", "email", "phone", "address",
-				"oneTimeCode", "otp", "keyPair", "paymentCard", "bankAccount", "accountNumber", "licenseNumber",
-				"secret", "note", "date", "birthDate", "expirationDate", "pinCode", "fileRef", "addressRef",
-				"cardRef", "pamHostname", "pamResources", "pamSettings", "pamRemoteBrowserSettings",
-				"databaseType", "directoryType", "wifiEncryption", "isSSIDHidden", "passkey", "appFiller",
-				"script", "rbiUrl", "dropdown", "checkbox", "recordRef", "schedule", "trafficEncryptionSeed",
-			},
-		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := client.getFieldTypesForRecordType(tt.recordType)
+			result, known := recordTypeFieldTypes(tt.recordType)
+			assert.True(t, known, "record type %s should be known", tt.recordType)
 			assert.ElementsMatch(t, tt.expectedFields, result, "Field types should match for record type %s", tt.recordType)
 		})
 	}
+
+	_, known := recordTypeFieldTypes("unknownType")
+	assert.False(t, known, "unknown record types should not be known")
 }
 
 func TestProcessPaymentCardField(t *testing.T) {
@@ -140,6 +131,25 @@ func TestProcessPaymentCardField(t *testing.T) {
 			},
 			found: true,
 		},
+		{
+			name: "payment card with cardholder name - masked",
+			value: []interface{}{
+				map[string]interface{}{
+					"cardNumber":         "4111111111111111",
+					"cardExpirationDate": "12/25",
+					"cardSecurityCode":   "123",
+					"cardholderName":     "Jane Q Public",
+				},
+			},
+			unmask: false,
+			expected: map[string]interface{}{
+				"cardNumber":         "411***111",
+				"cardExpirationDate": "12/25",
+				"cardSecurityCode":   "******",
+				"cardholderName":     "Jane Q Public",
+			},
+			found: true,
+		},
 		{
 			name:     "empty value",
 			value:    []interface{}{},
@@ -320,7 +330,7 @@ func TestProcessKeyPairField(t *testing.T) {
 			unmask: false,
 			expected: map[string]interface{}{
 				"publicKey":  "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQ...",
-				"privateKey": "---***...",
+				"privateKey": "******",
 			},
 			found: true,
 		},
@@ -425,6 +435,51 @@ func TestProcessBankAccountField(t *testing.T) {
 	}
 }
 
+func TestProcessBankAccountFieldMaskOtherType(t *testing.T) {
+	value := []interface{}{
+		map[string]interface{}{
+			"accountType":   "Other",
+			"routingNumber": "123456789",
+			"accountNumber": "987654321",
+			"otherType":     "SWIFT BOFAUS3N via 026009593",
+		},
+	}
+
+	t.Run("shown as stored by default", func(t *testing.T) {
+		client := &Client{}
+		result, found := client.processBankAccountField(value, false)
+		assert.True(t, found)
+		assert.Equal(t, "SWIFT BOFAUS3N via 026009593", result.(map[string]interface{})["otherType"])
+	})
+
+	t.Run("masked when configured", func(t *testing.T) {
+		client := &Client{}
+		client.SetMaskBankOtherType(true)
+		result, found := client.processBankAccountField(value, false)
+		assert.True(t, found)
+		bank := result.(map[string]interface{})
+		assert.Equal(t, maskValue("SWIFT BOFAUS3N via 026009593"), bank["otherType"])
+		assert.NotEqual(t, "SWIFT BOFAUS3N via 026009593", bank["otherType"])
+		assert.Equal(t, "Other", bank["accountType"], "accountType stays readable")
+	})
+
+	t.Run("unmasked results keep the value", func(t *testing.T) {
+		client := &Client{}
+		client.SetMaskBankOtherType(true)
+		result, _ := client.processBankAccountField(value, true)
+		assert.Equal(t, "SWIFT BOFAUS3N via 026009593", result.(map[string]interface{})["otherType"])
+	})
+
+	t.Run("empty otherType stays empty", func(t *testing.T) {
+		client := &Client{}
+		client.SetMaskBankOtherType(true)
+		result, _ := client.processBankAccountField([]interface{}{
+			map[string]interface{}{"accountType": "Checking", "otherType": ""},
+		}, false)
+		assert.Equal(t, "", result.(map[string]interface{})["otherType"])
+	})
+}
+
 func TestProcessHostField(t *testing.T) {
 	client := &Client{}
 
@@ -585,6 +640,31 @@ func TestProcessSecurityQuestionField(t *testing.T) {
 			},
 			found: true,
 		},
+		{
+			name: "two security questions - masked",
+			value: []interface{}{
+				map[string]interface{}{
+					"question": "What is your mother's maiden name?",
+					"answer":   "Johnson",
+				},
+				map[string]interface{}{
+					"question": "What was your first pet's name?",
+					"answer":   "Fluffy",
+				},
+			},
+			unmask: false,
+			expected: []interface{}{
+				map[string]interface{}{
+					"question": "What is your mother's maiden name?",
+					"answer":   "Joh***son",
+				},
+				map[string]interface{}{
+					"question": "What was your first pet's name?",
+					"answer":   "******",
+				},
+			},
+			found: true,
+		},
 		{
 			name:     "empty value",
 			value:    []interface{}{},
@@ -892,6 +972,116 @@ func TestProcessScriptField(t *testing.T) {
 	}
 }
 
+func TestProcessAppFillerField(t *testing.T) {
+	client := &Client{}
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		unmask   bool
+		expected interface{}
+		found    bool
+	}{
+		{
+			name: "multi-step macro - unmasked",
+			value: []interface{}{
+				map[string]interface{}{
+					"applicationTitle": "VPN Client",
+					"macroSequence":    "{USERNAME}{TAB}{PASSWORD}{ENTER}",
+				},
+			},
+			unmask: true,
+			expected: []map[string]interface{}{
+				{
+					"applicationTitle": "VPN Client",
+					"macroSequence":    "{USERNAME}{TAB}{PASSWORD}{ENTER}",
+					"macroSteps":       []string{"{USERNAME}", "{TAB}", "{PASSWORD}", "{ENTER}"},
+				},
+			},
+			found: true,
+		},
+		{
+			name: "typed text in steps is masked",
+			value: []interface{}{
+				map[string]interface{}{
+					"macroSequence": "{USERNAME}{TAB}hunter22{ENTER}",
+				},
+			},
+			unmask: false,
+			expected: []map[string]interface{}{
+				{
+					"macroSequence": "{US***ER}",
+					"macroSteps":    []string{"{USERNAME}", "{TAB}", "hun***r22", "{ENTER}"},
+				},
+			},
+			found: true,
+		},
+		{
+			name: "braced text that is not a known key is masked",
+			value: []interface{}{
+				map[string]interface{}{
+					"macroSequence": "{USERNAME}{TAB}{hunter22}{ENTER}",
+				},
+			},
+			unmask: false,
+			expected: []map[string]interface{}{
+				{
+					"macroSequence": "{US***ER}",
+					"macroSteps":    []string{"{USERNAME}", "{TAB}", "{hu***22}", "{ENTER}"},
+				},
+			},
+			found: true,
+		},
+		{
+			name: "free-form macro stays a string",
+			value: []interface{}{
+				map[string]interface{}{
+					"macroSequence": "type the username then press tab",
+				},
+			},
+			unmask: true,
+			expected: []map[string]interface{}{
+				{
+					"macroSequence": "type the username then press tab",
+				},
+			},
+			found: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, found := client.processAppFillerField(tt.value, tt.unmask)
+			assert.Equal(t, tt.found, found, "Found status should match")
+			if tt.found {
+				assert.Equal(t, tt.expected, result, "App filler data should match")
+			}
+		})
+	}
+}
+
+func TestParseMacroSequence(t *testing.T) {
+	tests := []struct {
+		sequence   string
+		steps      []string
+		structured bool
+	}{
+		{"{USERNAME}{TAB}{PASSWORD}{ENTER}", []string{"{USERNAME}", "{TAB}", "{PASSWORD}", "{ENTER}"}, true},
+		{"admin{TAB}{PASSWORD}", []string{"admin", "{TAB}", "{PASSWORD}"}, true},
+		{"plain text", []string{"plain text"}, false},
+		{"unclosed {TAB", []string{"unclosed {TAB"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sequence, func(t *testing.T) {
+			steps, structured := ParseMacroSequence(tt.sequence)
+			assert.Equal(t, tt.structured, structured)
+			assert.Equal(t, tt.steps, steps)
+			assert.Equal(t, tt.sequence, strings.Join(steps, ""), "steps should join back into the sequence")
+		})
+	}
+}
+
 func TestProcessBooleanField(t *testing.T) {
 	client := &Client{}
 
@@ -1059,8 +1249,22 @@ func TestIsSensitiveFieldComprehensive(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isSensitiveField(tt.field)
+			result := IsSensitiveField(tt.field)
 			assert.Equal(t, tt.sensitive, result, "Sensitivity check should match for field %s", tt.field)
 		})
 	}
 }
+
+func TestMaskNotationValue(t *testing.T) {
+	// securityQuestion[1] returns one pair; only the answer is masked
+	pair := map[string]interface{}{"question": "What was your first pet's name?", "answer": "Fluffy"}
+	assert.Equal(t, map[string]interface{}{
+		"question": "What was your first pet's name?",
+		"answer":   "******",
+	}, maskNotationValue(pair, false))
+	assert.Equal(t, "Fluffy", pair["answer"], "the original value must not be modified")
+
+	// securityQuestion[1][answer] is sensitive through its property
+	assert.Equal(t, "******", maskNotationValue("Fluffy", IsSensitiveField("answer")))
+	assert.Equal(t, "What was your first pet's name?", maskNotationValue("What was your first pet's name?", false))
+}
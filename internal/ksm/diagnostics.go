@@ -0,0 +1,166 @@
+package ksm
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// InitFailure names a distinguishable reason a KSM client could not be created or
+// could not reach Keeper
+type InitFailure string
+
+// Diagnosed initialization failures
+const (
+	InitMissingConfigKey   InitFailure = "missing_config_key"   // clientId, privateKey or appKey is absent
+	InitInvalidConfigKey   InitFailure = "invalid_config_key"   // a key is present but cannot be decoded
+	InitHostUnreachable    InitFailure = "hostname_unreachable" // DNS lookup or connection to the KSM host failed
+	InitCertificateFailure InitFailure = "certificate_rejected" // the KSM host's TLS certificate was not accepted
+	InitClockSkew          InitFailure = "clock_skew"           // the local clock is too far from Keeper's
+	InitAccessDenied       InitFailure = "access_denied"        // Keeper rejected the application's credentials
+	InitUnknown            InitFailure = "unknown"
+)
+
+// maxClockSkew is how far the local clock may differ from Keeper's before a failed
+// connection is blamed on it
+const maxClockSkew = 5 * time.Minute
+
+// InitError is a KSM client creation or connection failure with its diagnosed cause
+type InitError struct {
+	Failure InitFailure
+	Detail  string // what went wrong and how to fix it
+	Err     error  // the SDK error, if it reported one
+}
+
+func (e *InitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Detail, e.Err)
+	}
+	return e.Detail
+}
+
+func (e *InitError) Unwrap() error {
+	return e.Err
+}
+
+// diagnoseConfig checks that a KSM configuration holds decodable credentials. It
+// returns nil when nothing is wrong with them.
+func diagnoseConfig(config sm.IKeyValueStorage) *InitError {
+	for _, key := range []sm.ConfigKey{sm.KEY_CLIENT_ID, sm.KEY_PRIVATE_KEY, sm.KEY_APP_KEY} {
+		if strings.TrimSpace(config.Get(key)) == "" {
+			return &InitError{
+				Failure: InitMissingConfigKey,
+				Detail:  fmt.Sprintf("KSM configuration has no %s; initialize the profile again with a new one-time token", key),
+			}
+		}
+	}
+	if _, err := sm.DerBase64PrivateKeyToPrivateKey(config.Get(sm.KEY_PRIVATE_KEY)); err != nil {
+		return &InitError{
+			Failure: InitInvalidConfigKey,
+			Detail:  "KSM configuration privateKey is not a base64 DER private key; the configuration may be truncated or copied incorrectly",
+			Err:     err,
+		}
+	}
+	if len(sm.Base64ToBytes(config.Get(sm.KEY_APP_KEY))) != sm.Aes256KeySize {
+		return &InitError{
+			Failure: InitInvalidConfigKey,
+			Detail:  "KSM configuration appKey is not a base64 256-bit key; the configuration may be truncated or copied incorrectly",
+		}
+	}
+	return nil
+}
+
+// diagnoseConnectionError explains why a request to Keeper failed, checking the
+// configuration, the error the SDK reported and finally the local clock
+func (c *Client) diagnoseConnectionError(err error) *InitError {
+	if diag := diagnoseConfig(c.sm.Config); diag != nil {
+		diag.Err = err
+		return diag
+	}
+	hostname := sm.GetServerHostname(c.sm.Hostname, c.sm.Config)
+	return classifyConnectionError(hostname, err, func() (time.Duration, error) {
+		// The SDK transport is reused so endpoint pins still apply to the probe
+		client := &http.Client{Transport: http.DefaultClient.Transport, Timeout: clockProbeTimeout}
+		return serverClockSkew(client, "https://"+hostname+"/")
+	})
+}
+
+// clockProbeTimeout bounds the request that compares the local clock with Keeper's,
+// which only runs while reporting a failure that has already happened
+const clockProbeTimeout = 5 * time.Second
+
+// classifyConnectionError maps an SDK request error to a failure. The SDK flattens
+// the errors it returns into strings, so they are matched by message. When the error
+// does not say why, clockSkew probes the local clock against the server's.
+func classifyConnectionError(hostname string, err error, clockSkew func() (time.Duration, error)) *InitError {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "no such host"):
+		return &InitError{
+			Failure: InitHostUnreachable,
+			Detail:  fmt.Sprintf("cannot resolve KSM hostname %s; check the hostname in the profile or KSM_HOSTNAME, and DNS", hostname),
+			Err:     err,
+		}
+	case containsAny(message, "connection refused", "i/o timeout", "network is unreachable", "Client.Timeout", "connection reset"):
+		return &InitError{
+			Failure: InitHostUnreachable,
+			Detail:  fmt.Sprintf("cannot connect to KSM hostname %s; check network access, firewalls and proxy settings", hostname),
+			Err:     err,
+		}
+	case containsAny(message, "x509:", "tls:", ErrEndpointPinMismatch.Error()):
+		return &InitError{
+			Failure: InitCertificateFailure,
+			Detail:  fmt.Sprintf("the TLS certificate presented by %s was rejected; check for an intercepting proxy and the configured endpoint pins", hostname),
+			Err:     err,
+		}
+	}
+
+	if skew, probeErr := clockSkew(); probeErr == nil && (skew > maxClockSkew || skew < -maxClockSkew) {
+		return &InitError{
+			Failure: InitClockSkew,
+			Detail:  fmt.Sprintf("the local clock differs from %s by %s; synchronize the system time (e.g. with NTP)", hostname, skew.Round(time.Second)),
+			Err:     err,
+		}
+	}
+	if containsAny(message, "access_denied", "Signature is invalid") {
+		return &InitError{
+			Failure: InitAccessDenied,
+			Detail:  "Keeper rejected the application's credentials; the device may have been removed from the application, so initialize the profile again with a new one-time token",
+			Err:     err,
+		}
+	}
+	return &InitError{
+		Failure: InitUnknown,
+		Detail:  fmt.Sprintf("request to KSM hostname %s failed", hostname),
+		Err:     err,
+	}
+}
+
+// serverClockSkew returns how far the local clock is ahead of the clock in the Date
+// header of a response from url
+func serverClockSkew(client *http.Client, url string) (time.Duration, error) {
+	response, err := client.Head(url)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+
+	serverTime, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("response has no usable Date header: %w", err)
+	}
+	return time.Since(serverTime), nil
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package ksm
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// validKSMConfig returns credentials that decode like those of a bound application
+func validKSMConfig(t *testing.T) map[string]string {
+	t.Helper()
+	privateKey, err := sm.GeneratePrivateKeyDer()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKeyDer() unexpected error: %v", err)
+	}
+	return map[string]string{
+		"clientId":   "test123",
+		"privateKey": sm.BytesToBase64(privateKey),
+		"appKey":     sm.BytesToBase64(make([]byte, sm.Aes256KeySize)),
+		"hostname":   "keepersecurity.com",
+	}
+}
+
+func TestDiagnoseConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      func(config map[string]string)
+		wantFailure InitFailure // empty for a valid configuration
+		wantDetail  string
+	}{
+		{name: "valid configuration", change: func(config map[string]string) {}},
+		{name: "missing client ID", change: func(config map[string]string) { delete(config, "clientId") }, wantFailure: InitMissingConfigKey, wantDetail: "no clientId"},
+		{name: "missing private key", change: func(config map[string]string) { config["privateKey"] = " " }, wantFailure: InitMissingConfigKey, wantDetail: "no privateKey"},
+		{name: "missing app key", change: func(config map[string]string) { delete(config, "appKey") }, wantFailure: InitMissingConfigKey, wantDetail: "no appKey"},
+		{name: "undecodable private key", change: func(config map[string]string) { config["privateKey"] = "key123" }, wantFailure: InitInvalidConfigKey, wantDetail: "privateKey"},
+		{name: "truncated app key", change: func(config map[string]string) { config["appKey"] = "app123" }, wantFailure: InitInvalidConfigKey, wantDetail: "appKey"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validKSMConfig(t)
+			tt.change(config)
+
+			diag := diagnoseConfig(sm.NewMemoryKeyValueStorage(config))
+			if tt.wantFailure == "" {
+				if diag != nil {
+					t.Errorf("diagnoseConfig() = %v, want nil", diag)
+				}
+				return
+			}
+			if diag == nil {
+				t.Fatalf("diagnoseConfig() = nil, want %s", tt.wantFailure)
+			}
+			if diag.Failure != tt.wantFailure || !strings.Contains(diag.Detail, tt.wantDetail) {
+				t.Errorf("diagnoseConfig() = %s %q, want %s mentioning %q", diag.Failure, diag.Detail, tt.wantFailure, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestNewClientDiagnosesConfig(t *testing.T) {
+	config := validKSMConfig(t)
+	delete(config, "clientId")
+
+	_, err := NewClient(&types.Profile{Name: "test", Config: config}, nil)
+	var diag *InitError
+	if !errors.As(err, &diag) {
+		t.Fatalf("NewClient() error = %v, want an *InitError", err)
+	}
+	if diag.Failure != InitMissingConfigKey {
+		t.Errorf("NewClient() failure = %s, want %s", diag.Failure, InitMissingConfigKey)
+	}
+	if !strings.HasPrefix(err.Error(), "failed to create secrets manager client: ") {
+		t.Errorf("NewClient() error = %q, want the client creation prefix", err.Error())
+	}
+}
+
+func TestClassifyConnectionError(t *testing.T) {
+	inSync := func() (time.Duration, error) { return 2 * time.Second, nil }
+
+	tests := []struct {
+		name        string
+		err         string
+		clockSkew   func() (time.Duration, error)
+		wantFailure InitFailure
+	}{
+		{
+			name:        "unknown hostname",
+			err:         `error during POST request: Post "https://ksm.example.invalid/api/rest/sm/v1/get_secret": dial tcp: lookup ksm.example.invalid: no such host`,
+			wantFailure: InitHostUnreachable,
+		},
+		{
+			name:        "connection refused",
+			err:         `error during POST request: Post "https://keepersecurity.com/api/rest/sm/v1/get_secret": dial tcp 10.0.0.1:443: connect: connection refused`,
+			wantFailure: InitHostUnreachable,
+		},
+		{
+			name:        "timeout",
+			err:         `error during POST request: Post "https://keepersecurity.com/api/rest/sm/v1/get_secret": dial tcp 10.0.0.1:443: i/o timeout`,
+			wantFailure: InitHostUnreachable,
+		},
+		{
+			name:        "untrusted certificate",
+			err:         `error during POST request: Post "https://keepersecurity.com/api/rest/sm/v1/get_secret": tls: failed to verify certificate: x509: certificate signed by unknown authority`,
+			wantFailure: InitCertificateFailure,
+		},
+		{
+			name:        "pinned key mismatch",
+			err:         "error during POST request: " + ErrEndpointPinMismatch.Error() + ": no certificate presented by keepersecurity.com has a pinned public key",
+			wantFailure: InitCertificateFailure,
+		},
+		{
+			name:        "clock skew",
+			err:         `POST Error: Error: access_denied, message=Signature is invalid`,
+			clockSkew:   func() (time.Duration, error) { return -20 * time.Minute, nil },
+			wantFailure: InitClockSkew,
+		},
+		{
+			name:        "credentials rejected",
+			err:         `POST Error: Error: access_denied, message=Signature is invalid`,
+			wantFailure: InitAccessDenied,
+		},
+		{
+			name:        "clock probe fails",
+			err:         `POST Error: Error: throttled, message=Too many requests`,
+			clockSkew:   func() (time.Duration, error) { return 0, errors.New("no Date header") },
+			wantFailure: InitUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clockSkew := tt.clockSkew
+			if clockSkew == nil {
+				clockSkew = inSync
+			}
+			sdkErr := errors.New(tt.err)
+
+			diag := classifyConnectionError("keepersecurity.com", sdkErr, clockSkew)
+			if diag.Failure != tt.wantFailure {
+				t.Errorf("classifyConnectionError() failure = %s (%s), want %s", diag.Failure, diag.Detail, tt.wantFailure)
+			}
+			if !errors.Is(diag, sdkErr) {
+				t.Errorf("classifyConnectionError() should wrap the SDK error, got %v", diag)
+			}
+		})
+	}
+}
+
+func TestServerClockSkew(t *testing.T) {
+	serverTime := time.Now().Add(-time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/no-date" {
+			w.Header()["Date"] = nil
+			return
+		}
+		w.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	skew, err := serverClockSkew(server.Client(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("serverClockSkew() unexpected error: %v", err)
+	}
+	if skew < 59*time.Minute || skew > 61*time.Minute {
+		t.Errorf("serverClockSkew() = %s, want about an hour", skew)
+	}
+
+	if _, err := serverClockSkew(server.Client(), server.URL+"/no-date"); err == nil {
+		t.Error("serverClockSkew() without a Date header: expected an error")
+	}
+}
@@ -0,0 +1,137 @@
+package ksm
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// FindDuplicates fetches every record (optionally only in the given folders) once and
+// groups the records sharing a title and type. When key fields are given, records
+// must also hold the same values in those fields; values are only compared by hash.
+func (c *Client) FindDuplicates(folderUIDs []string, keyFields []string) (*types.DuplicatesResult, error) {
+	if c.logger != nil {
+		c.logAccess("secrets", "find_duplicates", "", c.profile, true, map[string]interface{}{
+			"folders":    folderUIDs,
+			"key_fields": keyFields,
+		})
+	}
+
+	var records []*sm.Record
+	var err error
+	if len(folderUIDs) == 0 {
+		records, err = c.sm.GetSecrets([]string{})
+	} else {
+		records, err = c.sm.GetSecretsWithOptions(sm.QueryOptions{FoldersFilter: folderUIDs})
+	}
+	if err != nil {
+		if c.logger != nil {
+			c.logError("ksm", err, map[string]interface{}{
+				"operation": "find_duplicates",
+				"folders":   folderUIDs,
+			})
+		}
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	result := &types.DuplicatesResult{
+		RecordsChecked: len(records),
+		KeyFields:      keyFields,
+		Groups:         duplicateGroups(records, keyFields),
+	}
+
+	if c.logger != nil {
+		c.logSystem(audit.EventAccess, "Duplicate records searched", map[string]interface{}{
+			"records_checked":  result.RecordsChecked,
+			"duplicate_groups": len(result.Groups),
+		})
+	}
+	return result, nil
+}
+
+// duplicateGroups groups records by title (ignoring case and surrounding spaces),
+// type and the hashes of the key field values, and returns the groups with more than
+// one record in the order their first record was seen
+func duplicateGroups(records []*sm.Record, keyFields []string) []types.DuplicateGroup {
+	type groupKey struct {
+		title, recordType string
+		fields            [sha256.Size]byte
+	}
+	groups := make(map[groupKey]*types.DuplicateGroup)
+	var order []groupKey
+
+	for _, record := range records {
+		key := groupKey{
+			title:      strings.ToLower(strings.TrimSpace(record.Title())),
+			recordType: record.Type(),
+		}
+		if len(keyFields) > 0 {
+			key.fields = keyFieldsHash(record.RecordDict, keyFields)
+		}
+
+		group, exists := groups[key]
+		if !exists {
+			group = &types.DuplicateGroup{Title: record.Title(), Type: record.Type()}
+			groups[key] = group
+			order = append(order, key)
+		}
+		folderUID := record.InnerFolderUid()
+		if folderUID == "" {
+			folderUID = record.FolderUid()
+		}
+		group.Records = append(group.Records, types.DuplicateRecord{
+			UID:       record.Uid,
+			Title:     record.Title(),
+			FolderUID: folderUID,
+		})
+	}
+
+	result := []types.DuplicateGroup{}
+	for _, key := range order {
+		group := groups[key]
+		if len(group.Records) < 2 {
+			continue
+		}
+		group.Count = len(group.Records)
+		result = append(result, *group)
+	}
+	return result
+}
+
+// keyFieldsHash hashes the stored values of the given fields of a record. A key field
+// matches a standard field by type or a custom field by label or type; a missing
+// field hashes like an empty one, so records that both lack it still match.
+func keyFieldsHash(dict map[string]interface{}, keyFields []string) [sha256.Size]byte {
+	hash := sha256.New()
+	for _, keyField := range keyFields {
+		var values []interface{}
+		for _, section := range []string{"fields", "custom"} {
+			fields, _ := dict[section].([]interface{})
+			for _, field := range fields {
+				fieldMap, ok := field.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				fieldType, _ := fieldMap["type"].(string)
+				label, _ := fieldMap["label"].(string)
+				if fieldType != keyField && (section != "custom" || label != keyField) {
+					continue
+				}
+				if value, ok := fieldMap["value"].([]interface{}); ok {
+					values = append(values, value...)
+				}
+			}
+		}
+		encoded, _ := json.Marshal(values)
+		fmt.Fprintf(hash, "%s\x00%s\x00", keyField, encoded)
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
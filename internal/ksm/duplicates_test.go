@@ -0,0 +1,88 @@
+package ksm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+func TestDuplicateGroups(t *testing.T) {
+	loginRecord := func(uid, title, login, password string) *sm.Record {
+		return &sm.Record{Uid: uid, RecordDict: map[string]interface{}{
+			"title": title,
+			"type":  "login",
+			"fields": []interface{}{
+				map[string]interface{}{"type": "login", "value": []interface{}{login}},
+				map[string]interface{}{"type": "password", "value": []interface{}{password}},
+			},
+			"custom": []interface{}{
+				map[string]interface{}{"type": "text", "label": "Env", "value": []interface{}{"prod"}},
+			},
+		}}
+	}
+	records := []*sm.Record{
+		loginRecord("uid-1", "Prod DB", "admin", "pass-1"),
+		loginRecord("uid-2", " prod db ", "admin", "pass-2"),
+		loginRecord("uid-3", "Prod DB", "readonly", "pass-1"),
+		loginRecord("uid-4", "Staging DB", "admin", "pass-1"),
+		{Uid: "uid-5", RecordDict: map[string]interface{}{"title": "Prod DB", "type": "databaseCredentials"}},
+	}
+
+	tests := []struct {
+		name      string
+		keyFields []string
+		want      []types.DuplicateGroup
+	}{
+		{
+			name: "title and type",
+			want: []types.DuplicateGroup{{Title: "Prod DB", Type: "login", Count: 3, Records: []types.DuplicateRecord{
+				{UID: "uid-1", Title: "Prod DB"},
+				{UID: "uid-2", Title: " prod db "},
+				{UID: "uid-3", Title: "Prod DB"},
+			}}},
+		},
+		{
+			name:      "with login",
+			keyFields: []string{"login"},
+			want: []types.DuplicateGroup{{Title: "Prod DB", Type: "login", Count: 2, Records: []types.DuplicateRecord{
+				{UID: "uid-1", Title: "Prod DB"},
+				{UID: "uid-2", Title: " prod db "},
+			}}},
+		},
+		{
+			name:      "with password and custom label",
+			keyFields: []string{"password", "Env"},
+			want: []types.DuplicateGroup{{Title: "Prod DB", Type: "login", Count: 2, Records: []types.DuplicateRecord{
+				{UID: "uid-1", Title: "Prod DB"},
+				{UID: "uid-3", Title: "Prod DB"},
+			}}},
+		},
+		{
+			name:      "no duplicates",
+			keyFields: []string{"login", "password"},
+			want:      []types.DuplicateGroup{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := duplicateGroups(records, tt.keyFields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("duplicateGroups() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyFieldsHashMissingField(t *testing.T) {
+	a := map[string]interface{}{"fields": []interface{}{map[string]interface{}{"type": "login", "value": []interface{}{}}}}
+	b := map[string]interface{}{}
+	if keyFieldsHash(a, []string{"login"}) != keyFieldsHash(b, []string{"login"}) {
+		t.Error("an empty field and a missing field should hash alike")
+	}
+	c := map[string]interface{}{"fields": []interface{}{map[string]interface{}{"type": "login", "value": []interface{}{"admin"}}}}
+	if keyFieldsHash(a, []string{"login"}) == keyFieldsHash(c, []string{"login"}) {
+		t.Error("different values should not hash alike")
+	}
+}
@@ -0,0 +1,152 @@
+package ksm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// minFuzzyScore is the lowest score a title needs to be returned as a fuzzy match
+const minFuzzyScore = 0.4
+
+// FindSecretsFuzzy fetches every record once and ranks the titles by how closely they
+// match the query, tolerating typos and partial titles. At most limit matches are
+// returned, best first.
+func (c *Client) FindSecretsFuzzy(query string, limit int) ([]types.FuzzyMatch, error) {
+	if err := c.validator.ValidateSearchQuery(query); err != nil {
+		return nil, fmt.Errorf("invalid search query: %w", err)
+	}
+
+	if c.logger != nil {
+		c.logAccess("secrets", "fuzzy_search", "", c.profile, true, map[string]interface{}{
+			"query_length": len(query),
+			"limit":        limit,
+		})
+	}
+
+	records, err := c.sm.GetSecrets([]string{})
+	if err != nil {
+		if c.logger != nil {
+			c.logError("ksm", err, map[string]interface{}{
+				"operation": "find_secret_fuzzy",
+			})
+		}
+		return nil, fmt.Errorf("failed to search secrets: %w", err)
+	}
+
+	return rankFuzzyMatches(records, query, limit), nil
+}
+
+// rankFuzzyMatches scores every record title against the query and returns the
+// matches scoring at least minFuzzyScore, best first; ties keep the fetch order
+func rankFuzzyMatches(records []*sm.Record, query string, limit int) []types.FuzzyMatch {
+	matches := []types.FuzzyMatch{}
+	for _, record := range records {
+		score := fuzzyTitleScore(query, record.Title())
+		if score < minFuzzyScore {
+			continue
+		}
+		matches = append(matches, types.FuzzyMatch{
+			UID:    record.Uid,
+			Title:  record.Title(),
+			Type:   record.Type(),
+			Folder: record.FolderUid(),
+			Score:  math.Round(score*100) / 100,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// fuzzyTitleScore rates from 0 to 1 how closely title matches query. An exact match
+// scores 1 and a title containing the query 0.9; otherwise the score is the better
+// of the edit-distance similarity of the whole strings and the token overlap, where
+// each query word is matched to its most similar title word.
+func fuzzyTitleScore(query, title string) float64 {
+	q := strings.ToLower(strings.TrimSpace(query))
+	t := strings.ToLower(strings.TrimSpace(title))
+	if q == "" || t == "" {
+		return 0
+	}
+	if q == t {
+		return 1
+	}
+	if strings.Contains(t, q) {
+		return 0.9
+	}
+
+	score := editSimilarity(q, t)
+
+	queryTokens, titleTokens := fuzzyTokens(q), fuzzyTokens(t)
+	if len(queryTokens) > 0 && len(titleTokens) > 0 {
+		total := 0.0
+		for _, queryToken := range queryTokens {
+			best := 0.0
+			for _, titleToken := range titleTokens {
+				if similarity := editSimilarity(queryToken, titleToken); similarity > best {
+					best = similarity
+				}
+			}
+			total += best
+		}
+		// Stay below a title that contains the query verbatim
+		if overlap := 0.85 * total / float64(len(queryTokens)); overlap > score {
+			score = overlap
+		}
+	}
+	return score
+}
+
+// fuzzyTokens splits a lowercase string into words at any non-alphanumeric character
+func fuzzyTokens(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// editSimilarity is 1 minus the Levenshtein distance of a and b divided by the length
+// of the longer string, in runes
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the number of single-rune insertions, deletions and
+// substitutions needed to turn a into b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,81 @@
+package ksm
+
+import (
+	"testing"
+
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+func TestFuzzyTitleScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		title    string
+		minScore float64
+		maxScore float64
+	}{
+		{"exact ignoring case", "prod database", "Prod Database", 1, 1},
+		{"substring", "database", "Prod Database", 0.9, 0.9},
+		{"typo", "prdo databse", "Prod Database", 0.6, 0.85},
+		{"words out of order", "database prod", "Prod Database", 0.85, 0.85},
+		{"unrelated", "mail server", "Prod Database", 0, minFuzzyScore - 0.01},
+		{"empty title", "prod", "", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fuzzyTitleScore(tt.query, tt.title)
+			if got < tt.minScore || got > tt.maxScore {
+				t.Errorf("fuzzyTitleScore(%q, %q) = %.2f, want between %.2f and %.2f", tt.query, tt.title, got, tt.minScore, tt.maxScore)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"gmail", "gmial", 2},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRankFuzzyMatches(t *testing.T) {
+	record := func(uid, title string) *sm.Record {
+		return &sm.Record{Uid: uid, RecordDict: map[string]interface{}{"title": title, "type": "login"}}
+	}
+	records := []*sm.Record{
+		record("uid-1", "Staging Database"),
+		record("uid-2", "Mail Server"),
+		record("uid-3", "Prod Database"),
+		record("uid-4", "Prod Databases (old)"),
+	}
+
+	matches := rankFuzzyMatches(records, "prod databse", 2)
+	if len(matches) != 2 {
+		t.Fatalf("rankFuzzyMatches() returned %d matches, want 2: %+v", len(matches), matches)
+	}
+	if matches[0].UID != "uid-3" || matches[1].UID != "uid-4" {
+		t.Errorf("rankFuzzyMatches() order = %s, %s, want uid-3, uid-4", matches[0].UID, matches[1].UID)
+	}
+	if matches[0].Score < matches[1].Score {
+		t.Errorf("matches are not sorted by score: %+v", matches)
+	}
+
+	if all := rankFuzzyMatches(records, "prod databse", 0); len(all) != 3 {
+		t.Errorf("rankFuzzyMatches() without limit returned %d matches, want 3 (Mail Server excluded): %+v", len(all), all)
+	}
+	if none := rankFuzzyMatches(records, "zzzz", 5); len(none) != 0 {
+		t.Errorf("rankFuzzyMatches() = %+v, want no matches", none)
+	}
+}
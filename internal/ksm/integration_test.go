@@ -133,7 +133,7 @@ func TestRealKSMIntegration(t *testing.T) {
 	t.Log("Testing search...")
 	searchTerms := []string{"test", "login", "password"}
 	for _, term := range searchTerms {
-		results, err := client.SearchSecrets(term)
+		results, err := client.SearchSecrets(term, nil)
 		if err != nil {
 			t.Errorf("Search for '%s' failed: %v", term, err)
 		} else {
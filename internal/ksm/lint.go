@@ -0,0 +1,197 @@
+package ksm
+
+import (
+	"fmt"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// objectFieldStringKeys lists the field types whose values are arrays of objects, with
+// the object keys the extractors read as strings. Keys that are absent are not problems.
+var objectFieldStringKeys = map[string][]string{
+	"paymentCard":              {"cardNumber", "cardExpirationDate", "cardSecurityCode", "cardholderName"},
+	"bankCard":                 {"cardNumber", "cardExpirationDate", "cardSecurityCode", "cardholderName"},
+	"address":                  {"street1", "street2", "city", "state", "country", "zip"},
+	"phone":                    {"region", "number", "ext", "type"},
+	"bankAccount":              {"accountType", "routingNumber", "accountNumber", "otherType"},
+	"keyPair":                  {"publicKey", "privateKey"},
+	"host":                     {"hostName", "port"},
+	"pamHostname":              {"hostName", "port"},
+	"name":                     {"first", "middle", "last"},
+	"securityQuestion":         {"question", "answer"},
+	"pamResources":             nil,
+	"pamSettings":              nil,
+	"pamRemoteBrowserSettings": nil,
+	"script":                   nil,
+	"passkey":                  nil,
+	"appFiller":                nil,
+	"schedule":                 nil,
+}
+
+// booleanFieldTypes are the field types whose values are arrays of booleans
+var booleanFieldTypes = map[string]bool{
+	"isSSIDHidden": true,
+	"checkbox":     true,
+}
+
+// LintSecrets checks the field structures of every record (optionally only in the given
+// folders) and reports the records whose stored values do not have the shape their field
+// type requires. Such values are otherwise skipped silently when fields are extracted.
+func (c *Client) LintSecrets(folderUIDs []string) (*types.LintResult, error) {
+	if c.logger != nil {
+		c.logAccess("secrets", "lint", "", c.profile, true, map[string]interface{}{
+			"folders": folderUIDs,
+		})
+	}
+
+	var records []*sm.Record
+	var err error
+	if len(folderUIDs) == 0 {
+		records, err = c.sm.GetSecrets([]string{})
+	} else {
+		records, err = c.sm.GetSecretsWithOptions(sm.QueryOptions{FoldersFilter: folderUIDs})
+	}
+	if err != nil {
+		if c.logger != nil {
+			c.logError("ksm", err, map[string]interface{}{
+				"operation": "lint_secrets",
+				"folders":   folderUIDs,
+			})
+		}
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	result := &types.LintResult{RecordsChecked: len(records), Records: []types.RecordLintReport{}}
+	for _, record := range records {
+		issues := LintRecordDict(record.RecordDict)
+		if len(issues) == 0 {
+			continue
+		}
+		result.Records = append(result.Records, types.RecordLintReport{
+			UID:    record.Uid,
+			Title:  record.Title(),
+			Type:   record.Type(),
+			Issues: issues,
+		})
+	}
+
+	if c.logger != nil {
+		c.logSystem(audit.EventAccess, "Record field structures checked", map[string]interface{}{
+			"records_checked":   result.RecordsChecked,
+			"records_malformed": len(result.Records),
+		})
+	}
+	return result, nil
+}
+
+// LintRecordDict reports the structural problems in a record's raw "fields" and "custom"
+// sections. It never includes field values in the problems it describes.
+func LintRecordDict(dict map[string]interface{}) []types.FieldLintIssue {
+	var issues []types.FieldLintIssue
+	if dict == nil {
+		return []types.FieldLintIssue{{Section: "record", Problem: "record has no data"}}
+	}
+
+	for _, section := range []string{"fields", "custom"} {
+		raw, exists := dict[section]
+		if !exists || raw == nil {
+			continue
+		}
+		fields, ok := raw.([]interface{})
+		if !ok {
+			issues = append(issues, types.FieldLintIssue{
+				Section: section,
+				Index:   -1,
+				Problem: fmt.Sprintf("section is %s, expected an array of fields", describeJSONType(raw)),
+			})
+			continue
+		}
+
+		for i, field := range fields {
+			issue := types.FieldLintIssue{Section: section, Index: i}
+			fieldMap, ok := field.(map[string]interface{})
+			if !ok {
+				issue.Problem = fmt.Sprintf("field is %s, expected an object", describeJSONType(field))
+				issues = append(issues, issue)
+				continue
+			}
+			fieldType, _ := fieldMap["type"].(string)
+			issue.FieldType = fieldType
+			issue.Label, _ = fieldMap["label"].(string)
+			if fieldType == "" {
+				issue.Problem = "field has no type"
+				issues = append(issues, issue)
+				continue
+			}
+			for _, problem := range lintFieldValue(fieldType, fieldMap["value"]) {
+				issue.Problem = problem
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// lintFieldValue describes how a field value differs from the shape its type requires
+func lintFieldValue(fieldType string, value interface{}) []string {
+	if value == nil {
+		return nil // an unset value is valid for every type
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("value is %s, expected an array", describeJSONType(value))}
+	}
+
+	var problems []string
+	stringKeys, isObjectType := objectFieldStringKeys[fieldType]
+	for i, item := range values {
+		switch {
+		case isObjectType:
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("value[%d] is %s, expected an object", i, describeJSONType(item)))
+				continue
+			}
+			for _, key := range stringKeys {
+				if v, exists := object[key]; exists && v != nil {
+					if _, ok := v.(string); !ok {
+						problems = append(problems, fmt.Sprintf("value[%d].%s is %s, expected a string", i, key, describeJSONType(v)))
+					}
+				}
+			}
+		case booleanFieldTypes[fieldType]:
+			if _, ok := item.(bool); !ok {
+				problems = append(problems, fmt.Sprintf("value[%d] is %s, expected a boolean", i, describeJSONType(item)))
+			}
+		default:
+			switch item.(type) {
+			case string, float64, bool:
+			default:
+				problems = append(problems, fmt.Sprintf("value[%d] is %s, expected a string or number", i, describeJSONType(item)))
+			}
+		}
+	}
+	return problems
+}
+
+// describeJSONType names the JSON type of a decoded value for lint messages
+func describeJSONType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64, int, int64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("a %T", value)
+	}
+}
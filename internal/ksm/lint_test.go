@@ -0,0 +1,90 @@
+package ksm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+)
+
+func TestLintRecordDict(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   []types.FieldLintIssue
+	}{
+		{
+			name: "well formed record",
+			record: `{"fields": [
+				{"type": "login", "value": ["admin"]},
+				{"type": "password", "value": []},
+				{"type": "expirationDate", "value": [1767225600000]},
+				{"type": "paymentCard", "value": [{"cardNumber": "4111111111111111", "cardExpirationDate": "12/2030"}]},
+				{"type": "pamSettings", "value": [{"connection": [{"protocol": "ssh"}]}]},
+				{"type": "checkbox", "value": [true]},
+				{"type": "oneTimeCode"}
+			], "custom": [{"type": "text", "label": "Env", "value": ["prod"]}]}`,
+		},
+		{
+			name: "payment card stored as a string",
+			record: `{"fields": [
+				{"type": "login", "value": ["admin"]},
+				{"type": "paymentCard", "value": ["4111111111111111"]}
+			]}`,
+			want: []types.FieldLintIssue{
+				{Section: "fields", Index: 1, FieldType: "paymentCard", Problem: "value[0] is a string, expected an object"},
+			},
+		},
+		{
+			name: "value that is not an array",
+			record: `{"fields": [], "custom": [
+				{"type": "host", "label": "DB", "value": {"hostName": "db", "port": "5432"}}
+			]}`,
+			want: []types.FieldLintIssue{
+				{Section: "custom", Index: 0, FieldType: "host", Label: "DB", Problem: "value is an object, expected an array"},
+			},
+		},
+		{
+			name: "wrong sub-field and element types",
+			record: `{"fields": [
+				{"type": "host", "value": [{"hostName": "db", "port": 5432}]},
+				{"type": "isSSIDHidden", "value": ["yes"]},
+				{"type": "url", "value": [{"href": "https://example.com"}]},
+				{"value": ["untyped"]},
+				"login"
+			]}`,
+			want: []types.FieldLintIssue{
+				{Section: "fields", Index: 0, FieldType: "host", Problem: "value[0].port is a number, expected a string"},
+				{Section: "fields", Index: 1, FieldType: "isSSIDHidden", Problem: "value[0] is a string, expected a boolean"},
+				{Section: "fields", Index: 2, FieldType: "url", Problem: "value[0] is an object, expected a string or number"},
+				{Section: "fields", Index: 3, Problem: "field has no type"},
+				{Section: "fields", Index: 4, Problem: "field is a string, expected an object"},
+			},
+		},
+		{
+			name:   "section that is not an array",
+			record: `{"fields": {"login": "admin"}}`,
+			want: []types.FieldLintIssue{
+				{Section: "fields", Index: -1, Problem: "section is an object, expected an array of fields"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dict map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.record), &dict); err != nil {
+				t.Fatalf("invalid test record: %v", err)
+			}
+			got := LintRecordDict(dict)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LintRecordDict() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := LintRecordDict(nil); len(got) != 1 || got[0].Problem != "record has no data" {
+		t.Errorf("LintRecordDict(nil) = %+v, want a single 'record has no data' issue", got)
+	}
+}
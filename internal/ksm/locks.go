@@ -0,0 +1,45 @@
+package ksm
+
+import "sync"
+
+// recordLocks serializes read-modify-write operations on the same record. Over the
+// HTTP transport several tool calls can run at once, and two updates that both read
+// a record before either saves it would otherwise lose one of the changes. The zero
+// value is ready to use.
+type recordLocks struct {
+	mu    sync.Mutex
+	locks map[string]*recordLock
+}
+
+// recordLock is the mutex for one record UID, shared by every caller waiting on it
+type recordLock struct {
+	mu      sync.Mutex
+	waiters int // callers holding or waiting for mu; the entry is removed at zero
+}
+
+// lock blocks until the caller holds the lock for uid and returns the function that
+// releases it. Locks for different UIDs never block each other.
+func (l *recordLocks) lock(uid string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*recordLock)
+	}
+	entry, ok := l.locks[uid]
+	if !ok {
+		entry = &recordLock{}
+		l.locks[uid] = entry
+	}
+	entry.waiters++
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+		l.mu.Lock()
+		entry.waiters--
+		if entry.waiters == 0 {
+			delete(l.locks, uid)
+		}
+		l.mu.Unlock()
+	}
+}
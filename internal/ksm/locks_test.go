@@ -0,0 +1,139 @@
+package ksm
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/keeper-security/ksm-mcp/internal/validation"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// TestRecordLocksSerializeUpdates runs concurrent read-modify-write updates of the
+// same UID, as UpdateSecret does, and checks that none is lost. Run with -race.
+func TestRecordLocksSerializeUpdates(t *testing.T) {
+	var locks recordLocks
+	record := map[string]int{"counter": 0}
+
+	const updates = 50
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := locks.lock("rec-uid")
+			defer unlock()
+
+			value := record["counter"] // read
+			runtime.Gosched()          // let other updates run between read and save
+			record["counter"] = value + 1
+		}()
+	}
+	wg.Wait()
+
+	if record["counter"] != updates {
+		t.Errorf("counter = %d, want %d (updates were lost)", record["counter"], updates)
+	}
+	if len(locks.locks) != 0 {
+		t.Errorf("expected released locks to be removed, %d remain", len(locks.locks))
+	}
+}
+
+func TestRecordLocksIndependentUIDs(t *testing.T) {
+	var locks recordLocks
+	unlockA := locks.lock("uid-a")
+	defer unlockA()
+
+	done := make(chan struct{})
+	go func() {
+		unlockB := locks.lock("uid-b")
+		unlockB()
+		close(done)
+	}()
+	<-done // would deadlock if uid-b waited on uid-a
+}
+
+// fakeRecordStore keeps records as JSON, so every read returns a fresh copy as the
+// vault would, and yields between read and save to expose lost updates
+type fakeRecordStore struct {
+	mu      sync.Mutex
+	records map[string]string
+}
+
+func (f *fakeRecordStore) GetSecrets(uids []string) ([]*sm.Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var records []*sm.Record
+	for _, uid := range uids {
+		raw, ok := f.records[uid]
+		if !ok {
+			continue
+		}
+		dict := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(raw), &dict); err != nil {
+			return nil, err
+		}
+		records = append(records, &sm.Record{Uid: uid, RecordDict: dict, RawJson: raw})
+	}
+	runtime.Gosched()
+	return records, nil
+}
+
+func (f *fakeRecordStore) Save(record *sm.Record) error {
+	runtime.Gosched()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records[record.Uid] = record.RawJson
+	return nil
+}
+
+// TestUpdateSecretConcurrentSameUID runs concurrent UpdateSecret calls that each add a
+// different field to the same record and checks that every field was saved
+func TestUpdateSecretConcurrentSameUID(t *testing.T) {
+	const uid = "Concurrent_Record-UID1"
+	store := &fakeRecordStore{records: map[string]string{
+		uid: `{"title":"Shared","type":"login","fields":[]}`,
+	}}
+	const updates = 20
+	schema := &types.RecordTypeSchema{RecordType: "login"}
+	for i := 0; i < updates; i++ {
+		schema.Fields = append(schema.Fields, types.SchemaField{Name: fmt.Sprintf("field%d", i)})
+	}
+	client := &Client{
+		store:     store,
+		schemas:   func(string) (*types.RecordTypeSchema, error) { return schema, nil },
+		validator: validation.NewValidator(),
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, updates)
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- client.UpdateSecret(types.UpdateSecretParams{
+				UID:    uid,
+				Fields: []types.SecretField{{Type: fmt.Sprintf("field%d", i), Value: []interface{}{"value"}}},
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("UpdateSecret failed: %v", err)
+		}
+	}
+
+	records, _ := store.GetSecrets([]string{uid})
+	fields, _ := records[0].RecordDict["fields"].([]interface{})
+	if len(fields) != updates {
+		t.Errorf("record has %d fields, want %d (updates were lost)", len(fields), updates)
+	}
+	if len(client.records.locks) != 0 {
+		t.Errorf("expected released locks to be removed, %d remain", len(client.records.locks))
+	}
+}
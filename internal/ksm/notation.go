@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/keeper-security/ksm-mcp/internal/validation"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 )
 
@@ -16,13 +17,48 @@ var (
 	nestedPattern = regexp.MustCompile(`^(.+)\[(\d+)\]\[([a-zA-Z_]\w*)\]$`)
 )
 
+// notationEscapeChar escapes the notation delimiters inside a title, field
+// label or filename, matching the KSM SDK convention (e.g. "prod\/db").
+const notationEscapeChar = '\\'
+
+// EscapeNotationPart escapes notation delimiters so the value can be used as a
+// single notation part, e.g. the title "prod/db" becomes "prod\/db".
+func EscapeNotationPart(part string) string {
+	var b strings.Builder
+	for _, r := range part {
+		if strings.ContainsRune(validation.NotationSpecialChars, r) {
+			b.WriteRune(notationEscapeChar)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// UnescapeNotationPart reverses EscapeNotationPart
+func UnescapeNotationPart(part string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range part {
+		if !escaped && r == notationEscapeChar {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // ParseNotation parses KSM notation into structured format
 func ParseNotation(notation string) (*types.NotationResult, error) {
 	if notation == "" {
 		return nil, fmt.Errorf("notation cannot be empty")
 	}
 
-	parts := strings.Split(notation, "/")
+	parts, err := validation.SplitNotation(notation)
+	if err != nil {
+		return nil, err
+	}
 	if len(parts) < 2 {
 		return nil, fmt.Errorf("invalid notation format: expected at least 2 parts separated by '/'")
 	}
@@ -32,7 +68,7 @@ func ParseNotation(notation string) (*types.NotationResult, error) {
 	}
 
 	// First part is either UID or Title
-	first := parts[0]
+	first := UnescapeNotationPart(parts[0])
 	if isValidUID(first) {
 		result.UID = first
 	} else {
@@ -58,7 +94,7 @@ func ParseNotation(notation string) (*types.NotationResult, error) {
 		if len(parts) < 3 {
 			return nil, fmt.Errorf("file notation requires filename")
 		}
-		result.File = parts[2]
+		result.File = UnescapeNotationPart(parts[2])
 		return result, nil
 
 	default:
@@ -75,7 +111,7 @@ func parseFieldNotation(result *types.NotationResult, fieldPart string) (*types.
 
 	// Check for nested array with property: field[0][property]
 	if matches := nestedPattern.FindStringSubmatch(fieldPart); matches != nil {
-		result.Field = matches[1]
+		result.Field = UnescapeNotationPart(matches[1])
 		index, err := strconv.Atoi(matches[2])
 		if err != nil {
 			return nil, fmt.Errorf("invalid array index: %s", matches[2])
@@ -87,7 +123,7 @@ func parseFieldNotation(result *types.NotationResult, fieldPart string) (*types.
 
 	// Check for array access: field[0]
 	if matches := arrayPattern.FindStringSubmatch(fieldPart); matches != nil {
-		result.Field = matches[1]
+		result.Field = UnescapeNotationPart(matches[1])
 		index, err := strconv.Atoi(matches[2])
 		if err != nil {
 			return nil, fmt.Errorf("invalid array index: %s", matches[2])
@@ -98,13 +134,13 @@ func parseFieldNotation(result *types.NotationResult, fieldPart string) (*types.
 
 	// Check for property access: field[property]
 	if matches := propPattern.FindStringSubmatch(fieldPart); matches != nil {
-		result.Field = matches[1]
+		result.Field = UnescapeNotationPart(matches[1])
 		result.Property = matches[2]
 		return result, nil
 	}
 
 	// Simple field
-	result.Field = fieldPart
+	result.Field = UnescapeNotationPart(fieldPart)
 	return result, nil
 }
 
@@ -116,14 +152,14 @@ func BuildNotation(result *types.NotationResult) string {
 	if result.UID != "" {
 		parts = append(parts, result.UID)
 	} else if result.Title != "" {
-		parts = append(parts, result.Title)
+		parts = append(parts, EscapeNotationPart(result.Title))
 	} else {
 		return ""
 	}
 
 	// Second part: type
 	if result.File != "" {
-		parts = append(parts, "file", result.File)
+		parts = append(parts, "file", EscapeNotationPart(result.File))
 		return strings.Join(parts, "/")
 	}
 
@@ -134,7 +170,7 @@ func BuildNotation(result *types.NotationResult) string {
 	}
 
 	// Third part: field with optional array/property
-	field := result.Field
+	field := EscapeNotationPart(result.Field)
 	if result.Property != "" && result.Index >= 0 {
 		// Nested: field[0][property]
 		field = fmt.Sprintf("%s[%d][%s]", field, result.Index, result.Property)
@@ -150,6 +186,31 @@ func BuildNotation(result *types.NotationResult) string {
 	return strings.Join(parts, "/")
 }
 
+// RebaseNotationIndex rewrites the array index of a notation written with the given
+// index base (0 or 1) into the 0-based index KSM uses, so with base 1 "url[1]" becomes
+// "url[0]". Notations without an index are returned unchanged.
+func RebaseNotationIndex(notation string, base int) (string, error) {
+	if base == 0 {
+		return notation, nil
+	}
+	if base != 1 {
+		return "", fmt.Errorf("unsupported notation index base %d: expected 0 or 1", base)
+	}
+
+	parsed, err := ParseNotation(notation)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Index < 0 {
+		return notation, nil
+	}
+	if parsed.Index < base {
+		return "", fmt.Errorf("index %d is invalid: notation indices start at %d", parsed.Index, base)
+	}
+	parsed.Index -= base
+	return BuildNotation(parsed), nil
+}
+
 // ValidateNotation validates a notation string
 func ValidateNotation(notation string) error {
 	_, err := ParseNotation(notation)
@@ -158,11 +219,16 @@ func ValidateNotation(notation string) error {
 
 // isValidUID checks if a string is a valid UID format
 func isValidUID(s string) bool {
-	if len(s) < 16 || len(s) > 32 {
+	minLength, maxLength := validation.UIDLengthBounds()
+	if len(s) < minLength || len(s) > maxLength {
+		return false
+	}
+	// UIDs are base64url: alphanumeric, underscore and hyphen, optionally padded
+	unpadded := strings.TrimRight(s, "=")
+	if len(s)-len(unpadded) > 2 || unpadded == "" {
 		return false
 	}
-	// UIDs typically contain alphanumeric, underscore, and hyphen
-	for _, r := range s {
+	for _, r := range unpadded {
 		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
 			(r >= '0' && r <= '9') || r == '_' || r == '-') {
 			return false
@@ -202,24 +268,27 @@ func ExtractFieldPath(notation string) (string, error) {
 
 // IsFileNotation checks if notation refers to a file
 func IsFileNotation(notation string) bool {
-	parts := strings.Split(notation, "/")
-	return len(parts) >= 2 && parts[1] == "file"
+	parts, err := validation.SplitNotation(notation)
+	return err == nil && len(parts) >= 2 && parts[1] == "file"
 }
 
 // IsCustomFieldNotation checks if notation refers to a custom field
 func IsCustomFieldNotation(notation string) bool {
-	parts := strings.Split(notation, "/")
-	return len(parts) >= 2 && parts[1] == "custom_field"
+	parts, err := validation.SplitNotation(notation)
+	return err == nil && len(parts) >= 2 && parts[1] == "custom_field"
 }
 
 // SplitNotationParts splits notation into its component parts
 func SplitNotationParts(notation string) (recordRef string, fieldType string, fieldPath string, err error) {
-	parts := strings.Split(notation, "/")
+	parts, err := validation.SplitNotation(notation)
+	if err != nil {
+		return "", "", "", err
+	}
 	if len(parts) < 3 {
 		return "", "", "", fmt.Errorf("invalid notation: expected at least 3 parts")
 	}
 
-	recordRef = parts[0]
+	recordRef = UnescapeNotationPart(parts[0])
 	fieldType = parts[1]
 	fieldPath = strings.Join(parts[2:], "/")
 
@@ -77,6 +77,55 @@ func TestParseNotation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:     "Title with escaped slash",
+			notation: `prod\/db/field/password`,
+			want: &types.NotationResult{
+				Title: "prod/db",
+				Field: "password",
+				Index: -1,
+			},
+			wantErr: false,
+		},
+		{
+			name:     "Escaped slash in custom field label",
+			notation: `prod\/db/custom_field/host\/port[0]`,
+			want: &types.NotationResult{
+				Title:  "prod/db",
+				Field:  "host/port",
+				Custom: true,
+				Index:  0,
+			},
+			wantErr: false,
+		},
+		{
+			name:     "Escaped slash in filename",
+			notation: `NJ_xXSkk3xYI1h9ql5lAiQ/file/certs\/prod.pem`,
+			want: &types.NotationResult{
+				UID:   "NJ_xXSkk3xYI1h9ql5lAiQ",
+				File:  "certs/prod.pem",
+				Index: -1,
+			},
+			wantErr: false,
+		},
+		{
+			name:     "unescaped slash in title",
+			notation: "prod/db/field/password",
+			want:     nil,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid escape sequence",
+			notation: `prod\db/field/password`,
+			want:     nil,
+			wantErr:  true,
+		},
+		{
+			name:     "trailing escape character",
+			notation: `prod/field/password\`,
+			want:     nil,
+			wantErr:  true,
+		},
 		{
 			name:     "empty notation",
 			notation: "",
@@ -239,6 +288,9 @@ func TestIsValidUID(t *testing.T) {
 		{"with space", "12345678901234 56", false},
 		{"with special char", "1234567890123456!", false},
 		{"empty", "", false},
+		{"base64url padding", "NJ_xXSkk3xYI1h9ql5lAiQ==", true},
+		{"too much padding", "NJ_xXSkk3xYI1h9ql5lAiQ===", false},
+		{"only padding", "================", false},
 	}
 
 	for _, tt := range tests {
@@ -397,6 +449,14 @@ func TestSplitNotationParts(t *testing.T) {
 			wantPath: "password",
 			wantErr:  false,
 		},
+		{
+			name:     "title with escaped slash",
+			notation: `prod\/db/field/password`,
+			wantRef:  "prod/db",
+			wantType: "field",
+			wantPath: "password",
+			wantErr:  false,
+		},
 		{
 			name:     "invalid - too short",
 			notation: "UID123/field",
@@ -472,6 +532,8 @@ func TestRoundTripNotation(t *testing.T) {
 		"NJ_xXSkk3xYI1h9ql5lAiQ/custom_field/phone[0][number]",
 		"NJ_xXSkk3xYI1h9ql5lAiQ/file/document.pdf",
 		"My Secret/field/password",
+		`prod\/db/field/password`,
+		`prod\/db/custom_field/host\/port`,
 	}
 
 	for _, notation := range notations {
@@ -488,3 +550,70 @@ func TestRoundTripNotation(t *testing.T) {
 		})
 	}
 }
+
+func TestEscapeNotationPart(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain title", "My Secret", "My Secret"},
+		{"slash", "prod/db", `prod\/db`},
+		{"brackets", "list[1]", `list\[1\]`},
+		{"backslash", `C:\temp`, `C:\\temp`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EscapeNotationPart(tt.in)
+			if got != tt.want {
+				t.Errorf("EscapeNotationPart() = %v, want %v", got, tt.want)
+			}
+			if unescaped := UnescapeNotationPart(got); unescaped != tt.in {
+				t.Errorf("UnescapeNotationPart() = %v, want %v", unescaped, tt.in)
+			}
+		})
+	}
+
+	// A title containing '/' must build a notation that parses back to the same title
+	notation := BuildNotation(&types.NotationResult{Title: "prod/db", Field: "password", Index: -1})
+	parsed, err := ParseNotation(notation)
+	if err != nil {
+		t.Fatalf("Failed to parse built notation %s: %v", notation, err)
+	}
+	if parsed.Title != "prod/db" || parsed.Field != "password" {
+		t.Errorf("Parsed built notation = %+v, want title prod/db and field password", parsed)
+	}
+}
+
+func TestRebaseNotationIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		notation string
+		base     int
+		want     string
+		wantErr  bool
+	}{
+		{"base 0 is unchanged", "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[1]", 0, "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[1]", false},
+		{"base 1 first value", "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[1]", 1, "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[0]", false},
+		{"base 1 second value", "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[2]", 1, "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[1]", false},
+		{"base 1 nested property", "NJ_xXSkk3xYI1h9ql5lAiQ/custom_field/phone[1][number]", 1, "NJ_xXSkk3xYI1h9ql5lAiQ/custom_field/phone[0][number]", false},
+		{"base 1 title with escapes", `prod\/db/field/url[2]`, 1, `prod\/db/field/url[1]`, false},
+		{"base 1 without index", "NJ_xXSkk3xYI1h9ql5lAiQ/field/password", 1, "NJ_xXSkk3xYI1h9ql5lAiQ/field/password", false},
+		{"base 1 property only", "NJ_xXSkk3xYI1h9ql5lAiQ/field/name[first]", 1, "NJ_xXSkk3xYI1h9ql5lAiQ/field/name[first]", false},
+		{"base 1 rejects index 0", "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[0]", 1, "", true},
+		{"unsupported base", "NJ_xXSkk3xYI1h9ql5lAiQ/field/url[0]", 2, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RebaseNotationIndex(tt.notation, tt.base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RebaseNotationIndex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RebaseNotationIndex() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package ksm
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// ErrEndpointPinMismatch is returned when the KSM endpoint does not match the configured pin
+var ErrEndpointPinMismatch = errors.New("KSM endpoint does not match the configured pin")
+
+// EndpointPin restricts the KSM endpoint a client may talk to. Hostnames lists the
+// accepted KSM hostnames; SPKIHashes holds SHA-256 hashes of accepted certificate
+// public keys, one of which must appear in the chain presented by the KSM hostname.
+// Either list may be empty to pin only the other.
+type EndpointPin struct {
+	Hostnames  []string
+	SPKIHashes [][]byte
+}
+
+// ParseEndpointPin validates the configured hostnames and public key pins. Pins are
+// base64 SHA-256 hashes of a certificate's SubjectPublicKeyInfo, optionally prefixed
+// with "sha256/" as printed by curl. It returns nil when nothing is pinned.
+func ParseEndpointPin(hostnames, spkiPins []string) (*EndpointPin, error) {
+	if len(hostnames) == 0 && len(spkiPins) == 0 {
+		return nil, nil
+	}
+
+	pin := &EndpointPin{}
+	for _, hostname := range hostnames {
+		hostname = strings.ToLower(strings.TrimSpace(hostname))
+		if hostname == "" {
+			return nil, errors.New("pinned hostnames must not be empty")
+		}
+		if strings.ContainsAny(hostname, "/: ") {
+			return nil, fmt.Errorf("pinned hostname %q must be a bare hostname without scheme, port or path", hostname)
+		}
+		pin.Hostnames = append(pin.Hostnames, hostname)
+	}
+	for _, encoded := range spkiPins {
+		encoded = strings.TrimPrefix(strings.TrimSpace(encoded), "sha256/")
+		hash, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("public key pin %q must be a base64 SHA-256 hash", encoded)
+		}
+		pin.SPKIHashes = append(pin.SPKIHashes, hash)
+	}
+	return pin, nil
+}
+
+// CheckHostname rejects a KSM hostname that is not pinned
+func (p *EndpointPin) CheckHostname(hostname string) error {
+	if len(p.Hostnames) == 0 || p.allowsHost(hostname) {
+		return nil
+	}
+	return fmt.Errorf("%w: hostname %s is not one of %s", ErrEndpointPinMismatch, hostname, strings.Join(p.Hostnames, ", "))
+}
+
+// allowsHost reports whether hostname is pinned, ignoring case
+func (p *EndpointPin) allowsHost(hostname string) bool {
+	for _, pinned := range p.Hostnames {
+		if strings.EqualFold(pinned, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// pinnedTransport tracks the transport EnforceEndpointPin installed on
+// http.DefaultClient, which the SDK sends its KSM requests through. hosts are the
+// KSM hostnames of the clients loaded with public key pins, and unpinned is the
+// transport to restore once pins no longer apply.
+var pinnedTransport struct {
+	sync.Mutex
+	active   bool
+	hosts    map[string]bool
+	unpinned http.RoundTripper
+}
+
+// verifyConnection runs after the standard certificate verification. Connections to
+// the given KSM hostnames must present a pinned public key. The SDK also uses
+// http.DefaultClient for attachment uploads and downloads, which go to Keeper's file
+// storage hosts under other names; those keep the standard verification only.
+func (p *EndpointPin) verifyConnection(hosts map[string]bool) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if len(p.SPKIHashes) == 0 || !hosts[strings.ToLower(state.ServerName)] {
+			return nil
+		}
+		for _, cert := range state.PeerCertificates {
+			hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pinned := range p.SPKIHashes {
+				if string(hash[:]) == string(pinned) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: no certificate presented by %s has a pinned public key", ErrEndpointPinMismatch, state.ServerName)
+	}
+}
+
+// wrapTransport returns a copy of base that enforces the public key pins on
+// connections to the given KSM hostnames
+func (p *EndpointPin) wrapTransport(base *http.Transport, hosts []string) *http.Transport {
+	pinnedHosts := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		pinnedHosts[strings.ToLower(host)] = true
+	}
+	transport := base.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.VerifyConnection = p.verifyConnection(pinnedHosts)
+	return transport
+}
+
+// EnforceEndpointPin checks the client's KSM hostname against the pin and, when
+// public keys are pinned, routes SDK requests through a transport that requires them
+// on connections to that hostname. The SDK sends its requests with
+// http.DefaultClient's transport, so the transport is shared by every client in the
+// process and covers the hostnames of all clients pinned so far. A pin without
+// public keys, or a nil pin, restores the transport in place before pinning.
+func (c *Client) EnforceEndpointPin(pin *EndpointPin) error {
+	if pin == nil {
+		return releaseEndpointPins()
+	}
+	if !c.sm.VerifySslCerts {
+		return fmt.Errorf("%w: certificate verification is disabled (KSM_SKIP_VERIFY)", ErrEndpointPinMismatch)
+	}
+	hostname := sm.GetServerHostname(c.sm.Hostname, c.sm.Config)
+	if err := pin.CheckHostname(hostname); err != nil {
+		return err
+	}
+	if len(pin.SPKIHashes) == 0 {
+		return releaseEndpointPins()
+	}
+
+	pinnedTransport.Lock()
+	defer pinnedTransport.Unlock()
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.New("cannot enforce public key pins: the default HTTP transport has been replaced")
+	}
+	if !pinnedTransport.active {
+		pinnedTransport.active = true
+		pinnedTransport.hosts = make(map[string]bool)
+		pinnedTransport.unpinned = http.DefaultClient.Transport
+	}
+	pinnedTransport.hosts[strings.ToLower(hostname)] = true
+	hosts := make([]string, 0, len(pinnedTransport.hosts))
+	for host := range pinnedTransport.hosts {
+		hosts = append(hosts, host)
+	}
+	http.DefaultClient.Transport = pin.wrapTransport(base, hosts)
+	return nil
+}
+
+// releaseEndpointPins restores the transport http.DefaultClient had before public
+// key pins were enforced
+func releaseEndpointPins() error {
+	pinnedTransport.Lock()
+	defer pinnedTransport.Unlock()
+	if pinnedTransport.active {
+		http.DefaultClient.Transport = pinnedTransport.unpinned
+		pinnedTransport.active = false
+		pinnedTransport.hosts = nil
+		pinnedTransport.unpinned = nil
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package ksm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+)
+
+func TestParseEndpointPin(t *testing.T) {
+	validPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	tests := []struct {
+		name      string
+		hostnames []string
+		pins      []string
+		wantNil   bool
+		wantErr   string
+	}{
+		{name: "nothing pinned", wantNil: true},
+		{name: "hostnames only", hostnames: []string{" KeeperSecurity.com "}},
+		{name: "public key only", pins: []string{validPin}},
+		{name: "curl style prefix", pins: []string{"sha256/" + validPin}},
+		{name: "empty hostname", hostnames: []string{" "}, wantErr: "must not be empty"},
+		{name: "hostname with scheme", hostnames: []string{"https://keepersecurity.com"}, wantErr: "bare hostname"},
+		{name: "hostname with port", hostnames: []string{"keepersecurity.com:443"}, wantErr: "bare hostname"},
+		{name: "pin is not base64", pins: []string{"not base64!"}, wantErr: "base64 SHA-256"},
+		{name: "pin is not a SHA-256 hash", pins: []string{base64.StdEncoding.EncodeToString([]byte("short"))}, wantErr: "base64 SHA-256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pin, err := ParseEndpointPin(tt.hostnames, tt.pins)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseEndpointPin() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEndpointPin() unexpected error: %v", err)
+			}
+			if (pin == nil) != tt.wantNil {
+				t.Errorf("ParseEndpointPin() = %+v, want nil: %v", pin, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestEndpointPinCheckHostname(t *testing.T) {
+	pin, err := ParseEndpointPin([]string{"keepersecurity.com", "keepersecurity.eu"}, nil)
+	if err != nil {
+		t.Fatalf("ParseEndpointPin() unexpected error: %v", err)
+	}
+
+	if err := pin.CheckHostname("KEEPERSECURITY.EU"); err != nil {
+		t.Errorf("CheckHostname() pinned hostname: unexpected error %v", err)
+	}
+	err = pin.CheckHostname("keepersecurity.com.attacker.example")
+	if !errors.Is(err, ErrEndpointPinMismatch) {
+		t.Errorf("CheckHostname() error = %v, want ErrEndpointPinMismatch", err)
+	}
+
+	keysOnly := &EndpointPin{SPKIHashes: [][]byte{make([]byte, sha256.Size)}}
+	if err := keysOnly.CheckHostname("any.example.com"); err != nil {
+		t.Errorf("CheckHostname() without pinned hostnames: unexpected error %v", err)
+	}
+}
+
+func TestEndpointPinTransport(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // rejected handshakes are expected
+	server.StartTLS()
+	defer server.Close()
+
+	serverKey := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	otherKey := sha256.Sum256([]byte("another public key"))
+
+	// The test certificate is valid for example.com; every connection goes to the test server
+	base := server.Client().Transport.(*http.Transport).Clone()
+	base.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+
+	tests := []struct {
+		name     string
+		pin      *EndpointPin
+		hosts    []string
+		mismatch bool
+	}{
+		{name: "pinned key", pin: &EndpointPin{Hostnames: []string{"example.com"}, SPKIHashes: [][]byte{otherKey[:], serverKey[:]}}, hosts: []string{"example.com"}},
+		{name: "key pinned without hostnames", pin: &EndpointPin{SPKIHashes: [][]byte{serverKey[:]}}, hosts: []string{"EXAMPLE.com"}},
+		{name: "other key", pin: &EndpointPin{Hostnames: []string{"example.com"}, SPKIHashes: [][]byte{otherKey[:]}}, hosts: []string{"example.com"}, mismatch: true},
+		{name: "other key without hostnames", pin: &EndpointPin{SPKIHashes: [][]byte{otherKey[:]}}, hosts: []string{"example.com"}, mismatch: true},
+		// Attachments are stored on hosts other than the KSM hostname
+		{name: "host other than the KSM hostname", pin: &EndpointPin{SPKIHashes: [][]byte{otherKey[:]}}, hosts: []string{"keepersecurity.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: tt.pin.wrapTransport(base, tt.hosts)}
+			response, err := client.Get("https://example.com/")
+			if response != nil {
+				response.Body.Close()
+			}
+			if tt.mismatch {
+				if !errors.Is(err, ErrEndpointPinMismatch) {
+					t.Errorf("Get() error = %v, want ErrEndpointPinMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Get() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEnforceEndpointPin(t *testing.T) {
+	newClient := func(t *testing.T, hostname string) *Client {
+		t.Helper()
+		client, err := NewClient(&types.Profile{Name: "test", Config: map[string]string{
+			"clientId":   "test123",
+			"privateKey": "key123",
+			"appKey":     "app123",
+			"hostname":   hostname,
+		}}, nil)
+		if err != nil {
+			t.Fatalf("NewClient() unexpected error: %v", err)
+		}
+		return client
+	}
+	pin, err := ParseEndpointPin([]string{"keepersecurity.com"}, nil)
+	if err != nil {
+		t.Fatalf("ParseEndpointPin() unexpected error: %v", err)
+	}
+
+	if err := newClient(t, "keepersecurity.com").EnforceEndpointPin(pin); err != nil {
+		t.Errorf("EnforceEndpointPin() pinned hostname: unexpected error %v", err)
+	}
+	if err := newClient(t, "keepersecurity.com").EnforceEndpointPin(nil); err != nil {
+		t.Errorf("EnforceEndpointPin(nil) unexpected error: %v", err)
+	}
+	if err := newClient(t, "keepersecurity.eu").EnforceEndpointPin(pin); !errors.Is(err, ErrEndpointPinMismatch) {
+		t.Errorf("EnforceEndpointPin() configured hostname: error = %v, want ErrEndpointPinMismatch", err)
+	}
+
+	t.Run("hostname override", func(t *testing.T) {
+		t.Setenv("KSM_HOSTNAME", "ksm.attacker.example")
+		if err := newClient(t, "keepersecurity.com").EnforceEndpointPin(pin); !errors.Is(err, ErrEndpointPinMismatch) {
+			t.Errorf("EnforceEndpointPin() error = %v, want ErrEndpointPinMismatch", err)
+		}
+	})
+
+	t.Run("public key pins are installed and released", func(t *testing.T) {
+		original := http.DefaultClient.Transport
+		t.Cleanup(func() {
+			_ = releaseEndpointPins()
+			http.DefaultClient.Transport = original
+		})
+		keyPin, err := ParseEndpointPin(nil, []string{base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))})
+		if err != nil {
+			t.Fatalf("ParseEndpointPin() unexpected error: %v", err)
+		}
+
+		if err := newClient(t, "keepersecurity.com").EnforceEndpointPin(keyPin); err != nil {
+			t.Fatalf("EnforceEndpointPin() unexpected error: %v", err)
+		}
+		if err := newClient(t, "keepersecurity.eu").EnforceEndpointPin(keyPin); err != nil {
+			t.Fatalf("EnforceEndpointPin() unexpected error: %v", err)
+		}
+		if http.DefaultClient.Transport == original {
+			t.Fatal("EnforceEndpointPin() did not install a pinned transport")
+		}
+		if !pinnedTransport.hosts["keepersecurity.com"] || !pinnedTransport.hosts["keepersecurity.eu"] || len(pinnedTransport.hosts) != 2 {
+			t.Errorf("pinned hosts = %v, want the KSM hostnames of both clients", pinnedTransport.hosts)
+		}
+
+		if err := newClient(t, "keepersecurity.com").EnforceEndpointPin(nil); err != nil {
+			t.Fatalf("EnforceEndpointPin(nil) unexpected error: %v", err)
+		}
+		if http.DefaultClient.Transport != original {
+			t.Error("EnforceEndpointPin(nil) did not restore the transport in place before pinning")
+		}
+	})
+
+	t.Run("certificate verification disabled", func(t *testing.T) {
+		t.Setenv("KSM_SKIP_VERIFY", "true")
+		if err := newClient(t, "keepersecurity.com").EnforceEndpointPin(pin); err == nil || !strings.Contains(err.Error(), "KSM_SKIP_VERIFY") {
+			t.Errorf("EnforceEndpointPin() error = %v, want a KSM_SKIP_VERIFY error", err)
+		}
+	})
+}
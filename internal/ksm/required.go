@@ -0,0 +1,219 @@
+package ksm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/internal/recordtemplates"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// FindMissingRequiredFields checks every record (optionally only in the given folders)
+// against the schema of its record type and reports the records without values for
+// required fields. Records whose type has no schema are counted under UnknownTypes.
+func (c *Client) FindMissingRequiredFields(folderUIDs []string) (*types.MissingFieldsResult, error) {
+	if c.logger != nil {
+		c.logAccess("secrets", "missing_required_fields", "", c.profile, true, map[string]interface{}{
+			"folders": folderUIDs,
+		})
+	}
+
+	var records []*sm.Record
+	var err error
+	if len(folderUIDs) == 0 {
+		records, err = c.sm.GetSecrets([]string{})
+	} else {
+		records, err = c.sm.GetSecretsWithOptions(sm.QueryOptions{FoldersFilter: folderUIDs})
+	}
+	if err != nil {
+		if c.logger != nil {
+			c.logError("ksm", err, map[string]interface{}{
+				"operation": "missing_required_fields",
+				"folders":   folderUIDs,
+			})
+		}
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	result := missingFieldsReport(records, recordtemplates.GetSchema)
+
+	if c.logger != nil {
+		c.logSystem(audit.EventAccess, "Records checked for missing required fields", map[string]interface{}{
+			"records_checked":    result.RecordsChecked,
+			"records_incomplete": len(result.Records),
+			"unknown_types":      len(result.UnknownTypes),
+		})
+	}
+	return result, nil
+}
+
+// RecordMissingRequiredFields returns the required fields of schema that have no value
+// on the record with the given UID
+func (c *Client) RecordMissingRequiredFields(uid string, schema *types.RecordTypeSchema) ([]string, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID: %w", err)
+	}
+
+	records, err := c.sm.GetSecrets([]string{uid})
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "missing_required_fields",
+			"uid":       uid,
+		})
+		return nil, fmt.Errorf("failed to get secret: %w", classifySDKError(err))
+	}
+	if len(records) == 0 {
+		return nil, ErrSecretNotFound
+	}
+	return MissingRequiredFields(records[0].RecordDict, schema), nil
+}
+
+// missingFieldsReport checks records against the schemas returned by getSchema, loading
+// each record type's schema once
+func missingFieldsReport(records []*sm.Record, getSchema func(string) (*types.RecordTypeSchema, error)) *types.MissingFieldsResult {
+	result := &types.MissingFieldsResult{RecordsChecked: len(records), Records: []types.MissingFieldsRecord{}}
+	schemas := make(map[string]*types.RecordTypeSchema)
+	for _, record := range records {
+		recordType := record.Type()
+		schema, loaded := schemas[recordType]
+		if !loaded {
+			schema, _ = getSchema(recordType)
+			schemas[recordType] = schema
+		}
+		if schema == nil {
+			if result.UnknownTypes == nil {
+				result.UnknownTypes = make(map[string]int)
+			}
+			result.UnknownTypes[recordType]++
+			continue
+		}
+
+		missing := MissingRequiredFields(record.RecordDict, schema)
+		if len(missing) == 0 {
+			continue
+		}
+		result.Records = append(result.Records, types.MissingFieldsRecord{
+			UID:     record.Uid,
+			Title:   record.Title(),
+			Type:    recordType,
+			Missing: missing,
+		})
+	}
+	return result
+}
+
+// MissingRequiredFields returns the names of the required schema fields that have no
+// value in a record's raw "fields" and "custom" sections. Complex fields are reported
+// per required element, e.g. "pamHostname.port".
+func MissingRequiredFields(dict map[string]interface{}, schema *types.RecordTypeSchema) []string {
+	var missing []string
+	for _, field := range schema.Fields {
+		if !field.Required {
+			continue
+		}
+
+		section := "fields"
+		name := field.Name
+		if strings.HasPrefix(name, "custom.") {
+			section = "custom"
+			name = strings.TrimPrefix(name, "custom.")
+		}
+		base, element, _ := strings.Cut(name, ".")
+		fieldType := field.Ref
+		if fieldType == "" {
+			fieldType = base
+		}
+
+		label := ""
+		if base != fieldType {
+			label = base // labeled field, such as a text field labeled cardholderName
+		}
+		if !hasFieldValue(findRecordField(dict, section, fieldType, label), storedElementKeys(element)) {
+			missing = append(missing, field.Name)
+		}
+	}
+	return missing
+}
+
+// findRecordField returns the first field of a section with the given type and label.
+// Fields created without their template label are matched by type alone.
+func findRecordField(dict map[string]interface{}, section, fieldType, label string) map[string]interface{} {
+	fields, _ := dict[section].([]interface{})
+	var unlabeled map[string]interface{}
+	for _, raw := range fields {
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if t, _ := field["type"].(string); t != fieldType {
+			continue
+		}
+		fieldLabel, _ := field["label"].(string)
+		if label == "" || fieldLabel == label {
+			return field
+		}
+		if fieldLabel == "" && unlabeled == nil {
+			unlabeled = field
+		}
+	}
+	return unlabeled
+}
+
+// storedElementKeys returns the object keys that hold a schema element on a record
+func storedElementKeys(element string) []string {
+	if element == "" {
+		return nil
+	}
+	return []string{element}
+}
+
+// hasFieldValue reports whether a field holds a non-empty value or, when elementKeys
+// are given, an object value with a non-empty value under one of them
+func hasFieldValue(field map[string]interface{}, elementKeys []string) bool {
+	if field == nil {
+		return false
+	}
+	values, _ := field["value"].([]interface{})
+	for _, value := range values {
+		if len(elementKeys) > 0 {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, key := range elementKeys {
+				if !isEmptyValue(object[key]) {
+					return true
+				}
+			}
+			continue
+		}
+		if object, ok := value.(map[string]interface{}); ok {
+			for _, v := range object {
+				if !isEmptyValue(v) {
+					return true
+				}
+			}
+			continue
+		}
+		if !isEmptyValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether a raw field value is missing or blank
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,148 @@
+package ksm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/keeper-security/ksm-mcp/internal/recordtemplates"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+func TestMissingRequiredFields(t *testing.T) {
+	if err := recordtemplates.LoadRecordTemplates(); err != nil {
+		t.Fatalf("LoadRecordTemplates() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		recordType string
+		fields     []interface{}
+		custom     []interface{}
+		expected   []string
+	}{
+		{
+			name:       "complete pamMachine",
+			recordType: "pamMachine",
+			fields: []interface{}{
+				map[string]interface{}{"type": "pamHostname", "value": []interface{}{map[string]interface{}{"hostName": "10.0.0.5", "port": "22"}}},
+			},
+		},
+		{
+			name:       "pamMachine without port",
+			recordType: "pamMachine",
+			fields: []interface{}{
+				map[string]interface{}{"type": "pamHostname", "value": []interface{}{map[string]interface{}{"hostName": "10.0.0.5", "port": ""}}},
+			},
+			expected: []string{"pamHostname.port"},
+		},
+		{
+			name:       "pamMachine without pamHostname field",
+			recordType: "pamMachine",
+			fields:     []interface{}{},
+			expected:   []string{"pamHostname.hostName", "pamHostname.port"},
+		},
+		{
+			name:       "pamUser with blank login",
+			recordType: "pamUser",
+			fields: []interface{}{
+				map[string]interface{}{"type": "login", "value": []interface{}{"  "}},
+			},
+			expected: []string{"login"},
+		},
+		{
+			name:       "contact name stored as first and last",
+			recordType: "contact",
+			fields: []interface{}{
+				map[string]interface{}{"type": "name", "value": []interface{}{map[string]interface{}{"first": "Ada", "last": "Lovelace"}}},
+			},
+		},
+		{
+			name:       "contact without name",
+			recordType: "contact",
+			fields:     []interface{}{map[string]interface{}{"type": "name", "value": []interface{}{}}},
+			expected:   []string{"name.first", "name.last"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := recordtemplates.GetSchema(tt.recordType)
+			if err != nil {
+				t.Fatalf("GetSchema(%s) error = %v", tt.recordType, err)
+			}
+			dict := map[string]interface{}{"type": tt.recordType, "fields": tt.fields, "custom": tt.custom}
+			if got := MissingRequiredFields(dict, schema); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("MissingRequiredFields() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMissingRequiredFieldsLabels(t *testing.T) {
+	schema := &types.RecordTypeSchema{RecordType: "server", Fields: []types.SchemaField{
+		{Name: "environment", Ref: "text", Required: true},
+		{Name: "custom.owner", Ref: "text", Required: true},
+	}}
+
+	labeled := map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"type": "text", "label": "notes", "value": []interface{}{"other"}},
+			map[string]interface{}{"type": "text", "label": "environment", "value": []interface{}{"prod"}},
+		},
+		"custom": []interface{}{
+			map[string]interface{}{"type": "text", "label": "owner", "value": []interface{}{"ops"}},
+		},
+	}
+	if got := MissingRequiredFields(labeled, schema); len(got) != 0 {
+		t.Errorf("MissingRequiredFields() with labeled fields = %v, want none", got)
+	}
+
+	// A field with another label does not satisfy the requirement
+	mislabeled := map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"type": "text", "label": "notes", "value": []interface{}{"other"}},
+		},
+	}
+	if got, want := MissingRequiredFields(mislabeled, schema), []string{"environment", "custom.owner"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingRequiredFields() with a mislabeled field = %v, want %v", got, want)
+	}
+}
+
+func TestMissingFieldsReport(t *testing.T) {
+	schema := &types.RecordTypeSchema{RecordType: "pamUser", Fields: []types.SchemaField{
+		{Name: "login", Ref: "login", Required: true},
+	}}
+	loads := 0
+	getSchema := func(recordType string) (*types.RecordTypeSchema, error) {
+		loads++
+		if recordType == "pamUser" {
+			return schema, nil
+		}
+		return nil, errors.New("record type not found")
+	}
+
+	records := []*sm.Record{
+		{Uid: "uid-1", RecordDict: map[string]interface{}{"title": "svc-a", "type": "pamUser", "fields": []interface{}{
+			map[string]interface{}{"type": "login", "value": []interface{}{"svc-a"}},
+		}}},
+		{Uid: "uid-2", RecordDict: map[string]interface{}{"title": "svc-b", "type": "pamUser", "fields": []interface{}{}}},
+		{Uid: "uid-3", RecordDict: map[string]interface{}{"title": "legacy", "type": "customType"}},
+		{Uid: "uid-4", RecordDict: map[string]interface{}{"title": "legacy 2", "type": "customType"}},
+	}
+
+	result := missingFieldsReport(records, getSchema)
+	if result.RecordsChecked != 4 {
+		t.Errorf("RecordsChecked = %d, want 4", result.RecordsChecked)
+	}
+	if len(result.Records) != 1 || result.Records[0].UID != "uid-2" || !reflect.DeepEqual(result.Records[0].Missing, []string{"login"}) {
+		t.Errorf("Records = %+v, want uid-2 missing login", result.Records)
+	}
+	if result.UnknownTypes["customType"] != 2 {
+		t.Errorf("UnknownTypes = %v, want customType: 2", result.UnknownTypes)
+	}
+	if loads != 2 {
+		t.Errorf("schemas loaded %d times, want once per record type", loads)
+	}
+}
@@ -0,0 +1,251 @@
+package ksm
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// totpSeedBytes is the size of generated TOTP seeds (160 bits, as recommended by RFC 4226)
+const totpSeedBytes = 20
+
+// minTOTPSeedBytes is the shortest seed accepted from a caller (80 bits, the size many
+// services still issue)
+const minTOTPSeedBytes = 10
+
+// TOTP parameters authenticator apps assume when a provisioning URI leaves them out
+const (
+	defaultTOTPAlgorithm = "SHA1"
+	defaultTOTPDigits    = 6
+	defaultTOTPPeriod    = 30
+)
+
+// GenerateTOTPURI creates a new random TOTP seed and returns the otpauth:// provisioning URI
+// for it along with the base32 encoded seed. The URI uses the defaults authenticator apps
+// expect: SHA1, 6 digits and a 30 second period.
+func GenerateTOTPURI(issuer, account string) (string, string, error) {
+	if strings.TrimSpace(account) == "" {
+		return "", "", errors.New("account name is required for a TOTP URI")
+	}
+
+	seed := make([]byte, totpSeedBytes)
+	if _, err := rand.Read(seed); err != nil {
+		return "", "", fmt.Errorf("failed to generate TOTP seed: %w", err)
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(seed)
+
+	return BuildTOTPURI(issuer, account, secret, "", 0, 0)
+}
+
+// BuildTOTPURI returns the otpauth:// provisioning URI for an existing base32 seed, such
+// as one copied from another authenticator, along with the normalized seed. Spaces,
+// dashes, padding and lower case letters are accepted in the seed. An empty algorithm
+// and zero digits or period select SHA1, 6 digits and 30 seconds.
+func BuildTOTPURI(issuer, account, secret, algorithm string, digits, period int) (string, string, error) {
+	if strings.TrimSpace(account) == "" {
+		return "", "", errors.New("account name is required for a TOTP URI")
+	}
+	secret, err := NormalizeTOTPSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+	algorithm, digits, period, err = validateTOTPParameters(algorithm, digits, period)
+	if err != nil {
+		return "", "", err
+	}
+
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	query.Set("algorithm", algorithm)
+	query.Set("digits", strconv.Itoa(digits))
+	query.Set("period", strconv.Itoa(period))
+
+	uri := (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}).String()
+
+	// Make sure the SDK can produce codes from the URI before it is stored anywhere
+	if _, err := sm.GetTotpCode(uri); err != nil {
+		return "", "", fmt.Errorf("TOTP URI is not usable: %w", err)
+	}
+
+	return uri, secret, nil
+}
+
+// ParseTOTPURI validates an otpauth://totp/ provisioning URI and returns its issuer,
+// account and seed. The issuer comes from the issuer parameter, or else from the
+// "Issuer:" prefix of the label.
+func ParseTOTPURI(uri string) (issuer, account, secret string, err error) {
+	parsed, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || !strings.EqualFold(parsed.Scheme, "otpauth") {
+		return "", "", "", errors.New("not a valid otpauth:// URI")
+	}
+	if !strings.EqualFold(parsed.Host, "totp") {
+		return "", "", "", fmt.Errorf("only otpauth://totp/ URIs are supported, got otpauth://%s/", parsed.Host)
+	}
+	query := parsed.Query()
+
+	secret, err = NormalizeTOTPSecret(query.Get("secret"))
+	if err != nil {
+		return "", "", "", err
+	}
+
+	digits, period := 0, 0
+	if value := query.Get("digits"); value != "" {
+		if digits, err = strconv.Atoi(value); err != nil {
+			return "", "", "", fmt.Errorf("invalid digits %q in the URI", value)
+		}
+	}
+	if value := query.Get("period"); value != "" {
+		if period, err = strconv.Atoi(value); err != nil {
+			return "", "", "", fmt.Errorf("invalid period %q in the URI", value)
+		}
+	}
+	if _, _, _, err := validateTOTPParameters(query.Get("algorithm"), digits, period); err != nil {
+		return "", "", "", err
+	}
+	// The URI is stored as given, so the SDK must accept it unchanged
+	if _, err := sm.GetTotpCode(strings.TrimSpace(uri)); err != nil {
+		return "", "", "", fmt.Errorf("TOTP URI is not usable: %w", err)
+	}
+
+	label := strings.TrimPrefix(parsed.Path, "/")
+	labelIssuer, labelAccount, found := strings.Cut(label, ":")
+	if !found {
+		labelIssuer, labelAccount = "", label
+	}
+	issuer = query.Get("issuer")
+	if issuer == "" {
+		issuer = labelIssuer
+	}
+	return issuer, strings.TrimSpace(labelAccount), secret, nil
+}
+
+// NormalizeTOTPSecret returns a base32 TOTP seed in the unpadded upper case form
+// provisioning URIs use. Spaces and dashes, as shown by many services for
+// readability, are removed.
+func NormalizeTOTPSecret(secret string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(secret))
+	normalized = strings.TrimRight(normalized, "=")
+	if normalized == "" {
+		return "", errors.New("TOTP secret is empty")
+	}
+
+	seed, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalized)
+	if err != nil {
+		return "", errors.New("TOTP secret is not valid base32 (letters A-Z and digits 2-7)")
+	}
+	if len(seed) < minTOTPSeedBytes {
+		return "", fmt.Errorf("TOTP secret is too short: %d bits, at least %d required", len(seed)*8, minTOTPSeedBytes*8)
+	}
+	return normalized, nil
+}
+
+// validateTOTPParameters applies the defaults to unset TOTP parameters and checks
+// that the SDK can generate codes with them
+func validateTOTPParameters(algorithm string, digits, period int) (string, int, int, error) {
+	algorithm = strings.ToUpper(strings.TrimSpace(algorithm))
+	if algorithm == "" {
+		algorithm = defaultTOTPAlgorithm
+	}
+	if digits == 0 {
+		digits = defaultTOTPDigits
+	}
+	if period == 0 {
+		period = defaultTOTPPeriod
+	}
+
+	switch algorithm {
+	case "SHA1", "SHA256", "SHA512":
+	default:
+		return "", 0, 0, fmt.Errorf("unsupported TOTP algorithm %q: use SHA1, SHA256 or SHA512", algorithm)
+	}
+	if digits < 6 || digits > 8 {
+		return "", 0, 0, fmt.Errorf("TOTP digits must be between 6 and 8, got %d", digits)
+	}
+	if period <= 0 {
+		return "", 0, 0, fmt.Errorf("TOTP period must be a positive number of seconds, got %d", period)
+	}
+	return algorithm, digits, period, nil
+}
+
+// totpVerifyWindow is how many periods before and after the current one VerifyTOTPCode
+// accepts, allowing for clock skew and a code entered just as it rolled over
+const totpVerifyWindow = 1
+
+// VerifyTOTPCode reports whether code is valid for the otpauth:// URI at the given
+// time, checking the current period and totpVerifyWindow periods either side. The
+// drift is the matching period relative to the current one. Codes are compared in
+// constant time.
+func VerifyTOTPCode(totpURL, code string, at time.Time) (bool, int, error) {
+	parsed, err := url.Parse(totpURL)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "otpauth") {
+		return false, 0, errors.New("stored TOTP value is not a valid otpauth:// URI")
+	}
+	query := parsed.Query()
+
+	totp := sm.TOTP{
+		Secret:    strings.TrimSpace(query.Get("secret")),
+		Digits:    6,
+		Algorithm: "SHA1",
+		Period:    30,
+	}
+	if totp.Secret == "" {
+		return false, 0, errors.New("stored TOTP URI has no secret")
+	}
+	if digits, err := strconv.Atoi(query.Get("digits")); err == nil && digits > 0 {
+		totp.Digits = digits
+	}
+	if algorithm := strings.TrimSpace(query.Get("algorithm")); algorithm != "" {
+		totp.Algorithm = algorithm
+	}
+	if period, err := strconv.ParseInt(query.Get("period"), 10, 64); err == nil && period > 0 {
+		totp.Period = period
+	}
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totp.Digits || strings.Trim(code, "0123456789") != "" {
+		return false, 0, fmt.Errorf("code must be %d digits", totp.Digits)
+	}
+
+	drifts := []int{0}
+	for d := 1; d <= totpVerifyWindow; d++ {
+		drifts = append(drifts, -d, d)
+	}
+	now := at.Unix()
+	for _, drift := range drifts {
+		totp.UnixTime = now + int64(drift)*totp.Period
+		expected, _, err := totp.Generate()
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to generate TOTP: %w", err)
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return true, drift, nil
+		}
+	}
+	return false, 0, nil
+}
@@ -0,0 +1,186 @@
+package ksm
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+func TestGenerateTOTPURI(t *testing.T) {
+	uri, secret, err := GenerateTOTPURI("Keeper", "alice@example.com")
+	if err != nil {
+		t.Fatalf("GenerateTOTPURI() unexpected error: %v", err)
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("generated URI does not parse: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("unexpected URI prefix: %s", uri)
+	}
+	if parsed.Path != "/Keeper:alice@example.com" {
+		t.Errorf("unexpected label %q", parsed.Path)
+	}
+	if got := parsed.Query().Get("secret"); got != secret {
+		t.Errorf("secret in URI = %q, want %q", got, secret)
+	}
+	if got := parsed.Query().Get("issuer"); got != "Keeper" {
+		t.Errorf("issuer = %q, want Keeper", got)
+	}
+	if len(secret) != 32 || strings.ContainsAny(secret, "=") {
+		t.Errorf("unexpected seed encoding %q", secret)
+	}
+
+	// Seeds must be random
+	_, other, err := GenerateTOTPURI("Keeper", "alice@example.com")
+	if err != nil {
+		t.Fatalf("second GenerateTOTPURI() unexpected error: %v", err)
+	}
+	if other == secret {
+		t.Error("expected a new seed on each call")
+	}
+
+	if _, _, err := GenerateTOTPURI("Keeper", " "); err == nil {
+		t.Error("expected error for empty account")
+	}
+}
+
+func TestBuildTOTPURI(t *testing.T) {
+	uri, secret, err := BuildTOTPURI("Acme", "alice@example.com", "jbsw y3dp-ehpk 3pxp==", "", 0, 0)
+	if err != nil {
+		t.Fatalf("BuildTOTPURI() unexpected error: %v", err)
+	}
+	if secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("normalized secret = %q, want JBSWY3DPEHPK3PXP", secret)
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("built URI does not parse: %v", err)
+	}
+	if parsed.Path != "/Acme:alice@example.com" {
+		t.Errorf("unexpected label %q", parsed.Path)
+	}
+	query := parsed.Query()
+	for key, want := range map[string]string{"secret": secret, "issuer": "Acme", "algorithm": "SHA1", "digits": "6", "period": "30"} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	uri, _, err = BuildTOTPURI("Acme", "alice", "JBSWY3DPEHPK3PXP", "sha256", 8, 60)
+	if err != nil {
+		t.Fatalf("BuildTOTPURI() with parameters unexpected error: %v", err)
+	}
+	if !strings.Contains(uri, "algorithm=SHA256") || !strings.Contains(uri, "digits=8") || !strings.Contains(uri, "period=60") {
+		t.Errorf("parameters missing from %s", uri)
+	}
+
+	bad := []struct {
+		name, secret, algorithm string
+		digits, period          int
+	}{
+		{"not base32", "JBSWY3DP1HPK3PXP", "", 0, 0},
+		{"too short", "JBSWY3DP", "", 0, 0},
+		{"empty", " ", "", 0, 0},
+		{"unsupported algorithm", "JBSWY3DPEHPK3PXP", "MD5", 0, 0},
+		{"too many digits", "JBSWY3DPEHPK3PXP", "", 10, 0},
+		{"negative period", "JBSWY3DPEHPK3PXP", "", 0, -30},
+	}
+	for _, tt := range bad {
+		if _, _, err := BuildTOTPURI("Acme", "alice", tt.secret, tt.algorithm, tt.digits, tt.period); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+	if _, _, err := BuildTOTPURI("Acme", "", "JBSWY3DPEHPK3PXP", "", 0, 0); err == nil {
+		t.Error("expected error for empty account")
+	}
+}
+
+func TestParseTOTPURI(t *testing.T) {
+	tests := []struct {
+		name        string
+		uri         string
+		wantIssuer  string
+		wantAccount string
+	}{
+		{"issuer parameter", "otpauth://totp/Acme:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Acme", "Acme", "alice@example.com"},
+		{"issuer from label", "otpauth://totp/GitHub:bob?secret=JBSWY3DPEHPK3PXP&digits=8&algorithm=SHA512", "GitHub", "bob"},
+		{"account only", "otpauth://totp/carol?secret=JBSWY3DPEHPK3PXP", "", "carol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer, account, secret, err := ParseTOTPURI(tt.uri)
+			if err != nil {
+				t.Fatalf("ParseTOTPURI() unexpected error: %v", err)
+			}
+			if issuer != tt.wantIssuer || account != tt.wantAccount || secret != "JBSWY3DPEHPK3PXP" {
+				t.Errorf("ParseTOTPURI() = (%q, %q, %q), want (%q, %q, JBSWY3DPEHPK3PXP)", issuer, account, secret, tt.wantIssuer, tt.wantAccount)
+			}
+		})
+	}
+
+	for _, bad := range []string{
+		"https://example.com/?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://hotp/Acme:alice?secret=JBSWY3DPEHPK3PXP&counter=1",
+		"otpauth://totp/Acme:alice?issuer=Acme",
+		"otpauth://totp/Acme:alice?secret=NOT-BASE32!",
+		"otpauth://totp/Acme:alice?secret=JBSWY3DPEHPK3PXP&digits=six",
+		"otpauth://totp/Acme:alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+	} {
+		if _, _, _, err := ParseTOTPURI(bad); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestVerifyTOTPCode(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	uri := "otpauth://totp/Keeper:alice@example.com?secret=" + secret + "&issuer=Keeper&algorithm=SHA1&digits=6&period=30"
+	at := time.Unix(1700000000, 0)
+
+	codeAt := func(offset int64) string {
+		totp := sm.TOTP{Secret: secret, Digits: 6, Algorithm: "SHA1", Period: 30, UnixTime: at.Unix() + offset}
+		code, _, err := totp.Generate()
+		if err != nil {
+			t.Fatalf("failed to generate reference code: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name      string
+		code      string
+		wantValid bool
+		wantDrift int
+	}{
+		{"current period", codeAt(0), true, 0},
+		{"previous period", codeAt(-30), true, -1},
+		{"next period", codeAt(30), true, 1},
+		{"spaces are ignored", codeAt(0)[:3] + " " + codeAt(0)[3:], true, 0},
+		{"outside the window", codeAt(-90), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, drift, err := VerifyTOTPCode(uri, tt.code, at)
+			if err != nil {
+				t.Fatalf("VerifyTOTPCode() unexpected error: %v", err)
+			}
+			if valid != tt.wantValid || drift != tt.wantDrift {
+				t.Errorf("VerifyTOTPCode() = (%v, %d), want (%v, %d)", valid, drift, tt.wantValid, tt.wantDrift)
+			}
+		})
+	}
+
+	for _, bad := range []string{"12345", "1234567", "12a456", ""} {
+		if _, _, err := VerifyTOTPCode(uri, bad, at); err == nil {
+			t.Errorf("expected error for malformed code %q", bad)
+		}
+	}
+	if _, _, err := VerifyTOTPCode("https://example.com", "123456", at); err == nil {
+		t.Error("expected error for a value that is not an otpauth URI")
+	}
+}
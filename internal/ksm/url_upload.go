@@ -0,0 +1,290 @@
+package ksm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/keeper-security/ksm-mcp/internal/validation"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	sm "github.com/keeper-security/secrets-manager-go/core"
+)
+
+// DefaultMaxURLUploadBytes caps the files UploadFileFromURL downloads when no cap is
+// configured. The SDK reads and encrypts the whole file in memory to upload it, so
+// the cap also bounds the memory an upload takes.
+const DefaultMaxURLUploadBytes = 10 << 20
+
+// ErrUploadTooLarge is returned when a file to upload from a URL exceeds the size cap
+var ErrUploadTooLarge = errors.New("file exceeds the upload size limit")
+
+// sniffLength is how many leading bytes content type detection looks at
+const sniffLength = 512
+
+// stagedFile is a downloaded file waiting in a temporary file to be uploaded
+type stagedFile struct {
+	path     string
+	name     string
+	mimeType string
+	size     int64
+}
+
+// ValidateUploadURL checks a URL to download a file from: it must pass the URL
+// validation applied to record fields and use http or https
+func ValidateUploadURL(rawURL string) error {
+	if rawURL == "" {
+		return errors.New("URL is required")
+	}
+	if err := validation.NewValidator().ValidateURL(rawURL); err != nil {
+		return err
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("URL cannot be parsed: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("URL scheme %q is not supported; use http or https", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return errors.New("URL has no host")
+	}
+	return nil
+}
+
+// UploadFileFromURL downloads a file and attaches it to a record. The download is
+// streamed to a temporary file and abandoned as soon as it passes maxBytes (0 uses
+// DefaultMaxURLUploadBytes), so an oversized file is refused before it is read in
+// full. The SDK then reads the staged file into memory and encrypts it there for
+// upload. An empty title uses the file name.
+func (c *Client) UploadFileFromURL(uid, rawURL, title string, maxBytes int64) (*types.UploadedFile, error) {
+	if err := c.validator.ValidateUID(uid); err != nil {
+		return nil, fmt.Errorf("invalid UID: %w", err)
+	}
+	if err := ValidateUploadURL(rawURL); err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxURLUploadBytes
+	}
+
+	c.logAccess("file", "upload_from_url", uid, c.profile, true, map[string]interface{}{
+		"url": RedactURL(rawURL),
+	})
+
+	file, err := stageDownload(urlUploadHTTPClient(), rawURL, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.path)
+	if title == "" {
+		title = file.name
+	}
+
+	// Uploading adds the file to the record and saves it, so it is serialized like an update
+	unlock := c.records.lock(uid)
+	defer unlock()
+
+	records, err := c.sm.GetSecrets([]string{uid})
+	if err != nil || len(records) == 0 {
+		return nil, ErrSecretNotFound
+	}
+
+	upload, err := sm.GetFileForUpload(file.path, file.name, title, file.mimeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare file for upload: %w", err)
+	}
+	fileUID, err := c.sm.UploadFile(records[0], upload)
+	if err != nil {
+		c.logError("ksm", err, map[string]interface{}{
+			"operation": "upload_file_from_url",
+			"uid":       uid,
+		})
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	return &types.UploadedFile{
+		FileUID:  fileUID,
+		Name:     file.name,
+		Title:    title,
+		MimeType: file.mimeType,
+		Size:     file.size,
+	}, nil
+}
+
+// stageDownload streams the body of rawURL into a temporary file, refusing bodies
+// larger than maxBytes. The caller removes the file.
+func stageDownload(client *http.Client, rawURL string, maxBytes int64) (*stagedFile, error) {
+	response, err := client.Get(rawURL)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to download %s: %w", RedactURL(rawURL), err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: server returned %s", RedactURL(rawURL), response.Status)
+	}
+	if response.ContentLength > maxBytes {
+		return nil, fmt.Errorf("%w: %s is %d bytes, more than %d", ErrUploadTooLarge, RedactURL(rawURL), response.ContentLength, maxBytes)
+	}
+
+	tmp, err := os.CreateTemp("", "ksm-mcp-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	staged := &stagedFile{path: tmp.Name()}
+	fail := func(err error) (*stagedFile, error) {
+		tmp.Close()
+		os.Remove(staged.path)
+		return nil, err
+	}
+
+	// Read one byte past the cap so an oversized body is detected without reading it all
+	body := io.LimitReader(response.Body, maxBytes+1)
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fail(fmt.Errorf("failed to download %s: %w", RedactURL(rawURL), err))
+	}
+	head = head[:n]
+	if _, err := tmp.Write(head); err != nil {
+		return fail(fmt.Errorf("failed to write temporary file: %w", err))
+	}
+	rest, err := io.Copy(tmp, body)
+	if err != nil {
+		return fail(fmt.Errorf("failed to download %s: %w", RedactURL(rawURL), err))
+	}
+	staged.size = int64(n) + rest
+	if staged.size > maxBytes {
+		return fail(fmt.Errorf("%w: %s is more than %d bytes", ErrUploadTooLarge, RedactURL(rawURL), maxBytes))
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(staged.path)
+		return nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	staged.name = downloadFileName(response)
+	staged.mimeType = detectContentType(response.Header.Get("Content-Type"), head)
+	return staged, nil
+}
+
+// detectContentType prefers a specific Content-Type sent by the server and otherwise
+// sniffs the leading bytes of the file
+func detectContentType(header string, head []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(header); err == nil && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	return http.DetectContentType(head)
+}
+
+// downloadFileName names a downloaded file from its Content-Disposition header, or the
+// last segment of the final URL after redirects
+func downloadFileName(response *http.Response) string {
+	if _, params, err := mime.ParseMediaType(response.Header.Get("Content-Disposition")); err == nil {
+		if name := filepath.Base(params["filename"]); params["filename"] != "" && name != "." && name != string(filepath.Separator) {
+			return name
+		}
+	}
+	if response.Request != nil && response.Request.URL != nil {
+		if name := path.Base(response.Request.URL.Path); name != "." && name != "/" {
+			return name
+		}
+	}
+	return "download"
+}
+
+// RedactURL drops credentials, query and fragment from a URL before it is logged or
+// reported, since they may carry access tokens
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// urlUploadHTTPClient returns the client downloads for uploads go through. It only
+// follows redirects to http and https URLs and cannot connect to local addresses.
+func urlUploadHTTPClient() *http.Client {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if base, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = base.Clone()
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, Control: rejectLocalAddress}
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = guardedProxy(transport.Proxy)
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Minute,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// guardedProxy wraps the proxy selection of the download transport. Through a proxy
+// the dialer connects to the proxy rather than the URL's host, so the host is
+// resolved and its addresses checked here before the request is handed over.
+func guardedProxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return nil
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(req.Context(), req.URL.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s: %w", req.URL.Hostname(), err)
+		}
+		for _, addr := range addrs {
+			if isLocalAddress(addr.IP) {
+				return nil, fmt.Errorf("downloads from local address %s are not allowed", addr.IP)
+			}
+		}
+		return proxyURL, nil
+	}
+}
+
+// rejectLocalAddress refuses connections to local addresses, such as cloud metadata
+// endpoints, so a URL cannot reach services local to the server. It runs after DNS
+// resolution, for every address dialed.
+func rejectLocalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isLocalAddress(ip) {
+		return fmt.Errorf("downloads from local address %s are not allowed", host)
+	}
+	return nil
+}
+
+// isLocalAddress reports whether ip is a loopback, private, link-local or
+// unspecified address
+func isLocalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
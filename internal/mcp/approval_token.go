@@ -0,0 +1,172 @@
+package mcp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
+)
+
+// ApprovalTokenMetaKey is the tools/call params._meta key carrying an approval token.
+// The server speaks MCP over stdio, so the request metadata plays the role of a
+// request header; an HTTP gateway in front of it copies its trusted header here.
+const ApprovalTokenMetaKey = "ksm/approval_token"
+
+// MinApprovalTokenSecretLength is the shortest accepted approval token signing secret
+const MinApprovalTokenSecretLength = 32
+
+// ApprovalClaims are the signed contents of an approval token. The token approves
+// one action: the tool and arguments it was issued for, until it expires, once.
+type ApprovalClaims struct {
+	Tool         string `json:"tool"`
+	ActionSHA256 string `json:"action_sha256"` // see actionDigest
+	ExpiresAt    int64  `json:"exp"`           // Unix seconds
+	ID           string `json:"jti"`           // unique per token; a used ID is rejected
+}
+
+// SignApprovalToken issues an approval token for calling tool with args. It is what
+// an upstream approval service does after deciding an action; the server only
+// verifies tokens.
+func SignApprovalToken(secret []byte, tool string, args json.RawMessage, expiresAt time.Time, id string) (string, error) {
+	if id == "" {
+		return "", errors.New("approval token ID is required")
+	}
+	payload, err := json.Marshal(ApprovalClaims{
+		Tool:         tool,
+		ActionSHA256: actionDigest(tool, args),
+		ExpiresAt:    expiresAt.Unix(),
+		ID:           id,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode approval claims: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signApprovalPayload(secret, encoded), nil
+}
+
+// verifyApprovalToken checks the token's signature, expiry and scope against the
+// requested tool call and marks its ID as used
+func (s *Server) verifyApprovalToken(token, tool string, args json.RawMessage) error {
+	secret := s.options.ApprovalTokenSecret
+	if len(secret) == 0 {
+		return errors.New("approval tokens are not enabled on this server")
+	}
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || encoded == "" || signature == "" {
+		return errors.New("malformed approval token")
+	}
+	if !hmac.Equal([]byte(signature), []byte(signApprovalPayload(secret, encoded))) {
+		return errors.New("invalid approval token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return errors.New("malformed approval token")
+	}
+	var claims ApprovalClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return errors.New("malformed approval token claims")
+	}
+
+	now := time.Now()
+	if now.Unix() >= claims.ExpiresAt {
+		return errors.New("approval token has expired")
+	}
+	if claims.Tool != tool {
+		return fmt.Errorf("approval token was issued for %s, not %s", claims.Tool, tool)
+	}
+	if !hmac.Equal([]byte(claims.ActionSHA256), []byte(actionDigest(tool, args))) {
+		return errors.New("approval token was issued for different arguments")
+	}
+	if claims.ID == "" {
+		return errors.New("approval token has no ID")
+	}
+
+	s.confirmationsMu.Lock()
+	defer s.confirmationsMu.Unlock()
+	for id, expires := range s.usedApprovalTokens {
+		if now.Unix() >= expires {
+			delete(s.usedApprovalTokens, id)
+		}
+	}
+	if _, used := s.usedApprovalTokens[claims.ID]; used {
+		return errors.New("approval token has already been used")
+	}
+	if s.usedApprovalTokens == nil {
+		s.usedApprovalTokens = make(map[string]int64)
+	}
+	s.usedApprovalTokens[claims.ID] = claims.ExpiresAt
+	return nil
+}
+
+// resolveWithApprovalToken runs the confirmed handler of a confirmation_required result
+// when the tool call carried a valid approval token for it. A token that fails
+// verification rejects the call rather than falling back to a confirmation prompt.
+func (s *Server) resolveWithApprovalToken(token, tool string, args json.RawMessage, result interface{}) (interface{}, error) {
+	resultMap, ok := result.(map[string]interface{})
+	if token == "" || !ok || resultMap["status"] != "confirmation_required" {
+		return result, nil
+	}
+	details, _ := resultMap["confirmation_details"].(map[string]interface{})
+	promptArgs, _ := details["prompt_arguments"].(map[string]interface{})
+	originalTool, _ := promptArgs["original_tool_name"].(string)
+	argsJSON, _ := promptArgs["original_tool_args_json"].(string)
+
+	handler, ok := s.confirmedActionHandlers()[originalTool]
+	if !ok || originalTool != tool {
+		return result, nil
+	}
+
+	if err := s.verifyApprovalToken(token, tool, args); err != nil {
+		s.logSystem(audit.EventAccessDenied, "Approval token rejected", map[string]interface{}{
+			"tool":    tool,
+			"reason":  err.Error(),
+			"profile": s.currentProfile,
+		})
+		return nil, &ToolError{
+			Code:    ErrorCodeAccessDenied,
+			Message: fmt.Sprintf("approval token rejected for %s: %v", tool, err),
+			Err:     err,
+		}
+	}
+	s.logSystem(audit.EventAccess, "Confirmation satisfied by approval token", map[string]interface{}{
+		"tool":    tool,
+		"profile": s.currentProfile,
+	})
+
+	client, err := s.getCurrentClient()
+	if err != nil {
+		return nil, fmt.Errorf("no active session for confirmed action: %w", err)
+	}
+	if argsJSON == "" {
+		argsJSON = "{}"
+	}
+	_, endToolCall := s.beginToolCall()
+	defer endToolCall()
+	return handler(client, json.RawMessage(argsJSON))
+}
+
+// actionDigest is the hex SHA-256 of the tool name, a newline and the arguments
+// re-encoded as compact JSON with sorted keys, so formatting does not matter
+func actionDigest(tool string, args json.RawMessage) string {
+	argsJSON := string(args)
+	if argsJSON == "" {
+		argsJSON = "{}"
+	}
+	sum := sha256.Sum256([]byte(confirmationFingerprint(tool, argsJSON)))
+	return hex.EncodeToString(sum[:])
+}
+
+// signApprovalPayload returns the base64url HMAC-SHA256 of an encoded token payload
+func signApprovalPayload(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
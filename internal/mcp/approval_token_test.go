@@ -0,0 +1,156 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveWithApprovalToken(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	args := json.RawMessage(`{"uid":"NJ_xXSkk3xYI1h9ql5lAiQ"}`)
+	expires := time.Now().Add(time.Minute)
+
+	sign := func(t *testing.T, key []byte, tool string, signedArgs json.RawMessage, expiresAt time.Time, id string) string {
+		t.Helper()
+		token, err := SignApprovalToken(key, tool, signedArgs, expiresAt, id)
+		assert.NoError(t, err)
+		return token
+	}
+	pendingDelete := func(t *testing.T, server *Server, client *mockKSMClient) interface{} {
+		t.Helper()
+		pending, err := server.executeDeleteSecret(client, args)
+		assert.NoError(t, err)
+		assert.Equal(t, "confirmation_required", pending.(map[string]interface{})["status"])
+		return pending
+	}
+
+	t.Run("valid token runs the action", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("DeleteSecret", "NJ_xXSkk3xYI1h9ql5lAiQ", true).Return(nil)
+		server := newHandlerTestServer(&ServerOptions{ApprovalTokenSecret: secret}, mockClient)
+
+		token := sign(t, secret, "delete_secret", args, expires, "approval-1")
+		result, err := server.resolveWithApprovalToken(token, "delete_secret", args, pendingDelete(t, server, mockClient))
+		assert.NoError(t, err)
+		assert.Equal(t, "NJ_xXSkk3xYI1h9ql5lAiQ", result.(map[string]interface{})["uid"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("argument formatting does not matter", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("DeleteSecret", "NJ_xXSkk3xYI1h9ql5lAiQ", true).Return(nil)
+		server := newHandlerTestServer(&ServerOptions{ApprovalTokenSecret: secret}, mockClient)
+
+		token := sign(t, secret, "delete_secret", json.RawMessage("{ \"uid\": \"NJ_xXSkk3xYI1h9ql5lAiQ\" }"), expires, "approval-2")
+		_, err := server.resolveWithApprovalToken(token, "delete_secret", args, pendingDelete(t, server, mockClient))
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	rejected := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr string
+	}{
+		{
+			name: "wrong signing secret",
+			token: func(t *testing.T) string {
+				return sign(t, []byte("another-secret-another-secret-xx"), "delete_secret", args, expires, "approval-3")
+			},
+			wantErr: "invalid approval token signature",
+		},
+		{
+			name: "tampered claims",
+			token: func(t *testing.T) string {
+				token := sign(t, secret, "delete_secret", args, expires, "approval-4")
+				return "x" + token
+			},
+			wantErr: "invalid approval token signature",
+		},
+		{
+			name:    "malformed",
+			token:   func(t *testing.T) string { return "not-a-token" },
+			wantErr: "malformed approval token",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				return sign(t, secret, "delete_secret", args, time.Now().Add(-time.Second), "approval-5")
+			},
+			wantErr: "approval token has expired",
+		},
+		{
+			name:    "issued for another tool",
+			token:   func(t *testing.T) string { return sign(t, secret, "delete_folder", args, expires, "approval-6") },
+			wantErr: "approval token was issued for delete_folder, not delete_secret",
+		},
+		{
+			name: "issued for other arguments",
+			token: func(t *testing.T) string {
+				return sign(t, secret, "delete_secret", json.RawMessage(`{"uid":"Zq8bXSkk3xYI1h9ql5lAiQ"}`), expires, "approval-7")
+			},
+			wantErr: "approval token was issued for different arguments",
+		},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			server := newHandlerTestServer(&ServerOptions{ApprovalTokenSecret: secret}, mockClient)
+
+			_, err := server.resolveWithApprovalToken(tt.token(t), "delete_secret", args, pendingDelete(t, server, mockClient))
+			var toolErr *ToolError
+			assert.True(t, errors.As(err, &toolErr))
+			assert.Equal(t, ErrorCodeAccessDenied, toolErr.Code)
+			assert.EqualError(t, toolErr.Err, tt.wantErr)
+			mockClient.AssertNotCalled(t, "DeleteSecret", "NJ_xXSkk3xYI1h9ql5lAiQ", true)
+		})
+	}
+
+	t.Run("token cannot be reused", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("DeleteSecret", "NJ_xXSkk3xYI1h9ql5lAiQ", true).Return(nil).Once()
+		server := newHandlerTestServer(&ServerOptions{ApprovalTokenSecret: secret}, mockClient)
+
+		token := sign(t, secret, "delete_secret", args, expires, "approval-8")
+		_, err := server.resolveWithApprovalToken(token, "delete_secret", args, pendingDelete(t, server, mockClient))
+		assert.NoError(t, err)
+		_, err = server.resolveWithApprovalToken(token, "delete_secret", args, pendingDelete(t, server, mockClient))
+		assert.ErrorContains(t, err, "approval token has already been used")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("disabled without a secret", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		token := sign(t, secret, "delete_secret", args, expires, "approval-9")
+		_, err := server.resolveWithApprovalToken(token, "delete_secret", args, pendingDelete(t, server, mockClient))
+		assert.ErrorContains(t, err, "approval tokens are not enabled on this server")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("no token keeps the confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{ApprovalTokenSecret: secret}, mockClient)
+
+		pending := pendingDelete(t, server, mockClient)
+		result, err := server.resolveWithApprovalToken("", "delete_secret", args, pending)
+		assert.NoError(t, err)
+		assert.Equal(t, pending, result)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("results that need no confirmation pass through", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{ApprovalTokenSecret: secret}, new(mockKSMClient))
+
+		done := map[string]interface{}{"uid": "NJ_xXSkk3xYI1h9ql5lAiQ"}
+		token := sign(t, secret, "get_secret", args, expires, "approval-10")
+		result, err := server.resolveWithApprovalToken(token, "get_secret", args, done)
+		assert.NoError(t, err)
+		assert.Equal(t, done, result)
+	})
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// beginRequest marks the tools/call request with the given JSON-RPC ID as the one
+// being handled, so a notifications/cancelled naming it can stop its tool calls.
+// The returned function must be called once the request has been answered.
+func (s *Server) beginRequest(id interface{}) func() {
+	s.callMu.Lock()
+	s.requestID = requestKey(id)
+	s.requestCancelled = false
+	s.callMu.Unlock()
+
+	return func() {
+		s.callMu.Lock()
+		s.requestID = ""
+		s.requestCancelled = false
+		s.callMu.Unlock()
+	}
+}
+
+// beginToolCall creates the context of a tool call. It is cancelled when the server
+// stops, when the client cancels the request or disconnects and, when
+// ServerOptions.BulkTimeout is set, once that timeout has passed. The returned
+// function releases it and must be called when the call returns.
+func (s *Server) beginToolCall() (context.Context, context.CancelFunc) {
+	base := s.runCtx
+	if base == nil {
+		base = context.Background()
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if s.options.BulkTimeout > 0 {
+		ctx, cancel = context.WithTimeout(base, s.options.BulkTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(base)
+	}
+
+	s.callMu.Lock()
+	s.callCtx = ctx
+	s.callCancel = cancel
+	if s.requestCancelled {
+		// The request was cancelled before this call started, e.g. while waiting for a decision
+		cancel()
+	}
+	s.callMu.Unlock()
+
+	return ctx, func() {
+		cancel()
+		s.callMu.Lock()
+		s.callCtx = nil
+		s.callCancel = nil
+		s.callMu.Unlock()
+	}
+}
+
+// cancelRequest handles a notifications/cancelled naming the given request ID. It
+// stops the running tool call if it belongs to that request; a notification for a
+// request that has already been answered is ignored.
+func (s *Server) cancelRequest(id interface{}) {
+	key := requestKey(id)
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	if key == "" || key != s.requestID {
+		return
+	}
+	s.requestCancelled = true
+	if s.callCancel != nil {
+		s.callCancel()
+	}
+}
+
+// cancelActiveCall stops the running tool call, if any. It is used once the client
+// has disconnected and no response can be delivered.
+func (s *Server) cancelActiveCall() {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	if s.requestID != "" {
+		s.requestCancelled = true
+	}
+	if s.callCancel != nil {
+		s.callCancel()
+	}
+}
+
+// requestKey identifies a JSON-RPC request ID. IDs are decoded the same way for the
+// request and its cancellation, so their formatted values compare equal.
+func requestKey(id interface{}) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+// toolContext returns the context of the tool call being executed. Bulk operations
+// check it between records and stop early once it is done.
+func (s *Server) toolContext() context.Context {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	if s.callCtx == nil {
+		return context.Background()
+	}
+	return s.callCtx
+}
+
+// markCancelled records in a bulk response that the operation stopped before
+// processing every item. The items already processed stay in the response.
+func markCancelled(response map[string]interface{}, err error, remaining int) {
+	reason := "cancelled"
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = "timeout"
+	}
+	response["cancelled"] = true
+	response["cancel_reason"] = reason
+	response["remaining"] = remaining
+	message, _ := response["message"].(string)
+	response["message"] = fmt.Sprintf("%s Stopped early (%s): %d item(s) were not processed.", message, reason, remaining)
+}
@@ -0,0 +1,268 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
+)
+
+// Confirmations are forgotten after confirmationTTL, and at most maxTrackedConfirmations
+// are kept so a client that never answers cannot grow them without bound. A forgotten
+// confirmation can no longer be executed.
+const (
+	confirmationTTL         = 30 * time.Minute
+	maxTrackedConfirmations = 256
+)
+
+// pendingConfirmation is a confirmation_required response that has not been executed or cancelled
+type pendingConfirmation struct {
+	toolName    string
+	fingerprint string
+	issuedAt    time.Time
+}
+
+// pruneConfirmations drops expired confirmations and, when the map is full, the oldest
+// ones. The caller holds confirmationsMu.
+func (s *Server) pruneConfirmations(now time.Time) {
+	for id, pending := range s.pendingConfirmations {
+		if now.Sub(pending.issuedAt) > confirmationTTL {
+			delete(s.pendingConfirmations, id)
+		}
+	}
+	for len(s.pendingConfirmations) >= maxTrackedConfirmations {
+		oldest := ""
+		for id, pending := range s.pendingConfirmations {
+			if oldest == "" || pending.issuedAt.Before(s.pendingConfirmations[oldest].issuedAt) {
+				oldest = id
+			}
+		}
+		delete(s.pendingConfirmations, oldest)
+	}
+}
+
+// forgetConfirmations removes the pending confirmations of an action
+func (s *Server) forgetConfirmations(fingerprint string) {
+	s.confirmationsMu.Lock()
+	defer s.confirmationsMu.Unlock()
+	for id, pending := range s.pendingConfirmations {
+		if pending.fingerprint == fingerprint {
+			delete(s.pendingConfirmations, id)
+		}
+	}
+}
+
+// confirmationRequired returns the confirmation_required response that asks the client
+// to confirm a call of toolName with argsJSON through the ksm_confirm_action prompt
+func (s *Server) confirmationRequired(toolName, actionDescription string, warning confirmationWarning, argsJSON string) map[string]interface{} {
+	return map[string]interface{}{
+		"status":  "confirmation_required",
+		"message": fmt.Sprintf("Keeper Secrets Manager requires confirmation to %s. Use the 'ksm_confirm_action' prompt.", actionDescription),
+		"confirmation_details": map[string]interface{}{
+			"prompt_name": "ksm_confirm_action",
+			"prompt_arguments": map[string]interface{}{
+				"action_description":      actionDescription,
+				"warning_message":         s.confirmationWarningText(warning),
+				"original_tool_name":      toolName,
+				"original_tool_args_json": argsJSON,
+			},
+		},
+	}
+}
+
+// trackConfirmation assigns a confirmation ID to a confirmation_required result and
+// records it as pending. Other results are returned unchanged.
+func (s *Server) trackConfirmation(result interface{}) (interface{}, error) {
+	resultMap, ok := result.(map[string]interface{})
+	if !ok || resultMap["status"] != "confirmation_required" {
+		return result, nil
+	}
+	details, ok := resultMap["confirmation_details"].(map[string]interface{})
+	if !ok {
+		return result, nil
+	}
+	promptArgs, ok := details["prompt_arguments"].(map[string]interface{})
+	if !ok {
+		return result, nil
+	}
+	toolName, _ := promptArgs["original_tool_name"].(string)
+	argsJSON, _ := promptArgs["original_tool_args_json"].(string)
+
+	// Executing requires the ID, so a confirmation without one could never be approved
+	id, err := generateConfirmationID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.confirmationsMu.Lock()
+	if s.pendingConfirmations == nil {
+		s.pendingConfirmations = make(map[string]pendingConfirmation)
+	}
+	s.pruneConfirmations(now)
+	s.pendingConfirmations[id] = pendingConfirmation{toolName: toolName, fingerprint: confirmationFingerprint(toolName, argsJSON), issuedAt: now}
+	s.confirmationsMu.Unlock()
+
+	details["confirmation_id"] = id
+	promptArgs["confirmation_id"] = id
+	return resultMap, nil
+}
+
+// consumeConfirmation checks that a confirmed action was issued a confirmation that is
+// still pending, unexpired and for the same tool and arguments, and removes it so it
+// is used once. Anything else is refused.
+func (s *Server) consumeConfirmation(id, toolName, argsJSON string) error {
+	if id == "" {
+		return &ToolError{
+			Code:    ErrorCodeStaleConfirmation,
+			Message: fmt.Sprintf("confirmation_id is required to execute %s; call the tool again to get a confirmation", toolName),
+		}
+	}
+
+	s.confirmationsMu.Lock()
+	defer s.confirmationsMu.Unlock()
+	s.pruneConfirmations(time.Now())
+
+	pending, ok := s.pendingConfirmations[id]
+	if !ok {
+		return &ToolError{
+			Code:    ErrorCodeStaleConfirmation,
+			Message: fmt.Sprintf("confirmation %s is no longer valid: it was cancelled, already used, expired, or never issued", id),
+		}
+	}
+	if pending.fingerprint != confirmationFingerprint(toolName, argsJSON) {
+		return fmt.Errorf("confirmation %s was issued for a different %s request; the tool name and arguments must match the confirmation", id, pending.toolName)
+	}
+	delete(s.pendingConfirmations, id)
+	return nil
+}
+
+// executeCancelConfirmation handles the cancel_confirmation tool
+func (s *Server) executeCancelConfirmation(args json.RawMessage) (interface{}, error) {
+	var params struct {
+		ConfirmationID string `json:"confirmation_id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters for cancel_confirmation: %w", err)
+	}
+	if params.ConfirmationID == "" {
+		return nil, fmt.Errorf("confirmation_id is required for cancel_confirmation")
+	}
+
+	s.confirmationsMu.Lock()
+	s.pruneConfirmations(time.Now())
+	pending, ok := s.pendingConfirmations[params.ConfirmationID]
+	if ok {
+		delete(s.pendingConfirmations, params.ConfirmationID)
+	}
+	s.confirmationsMu.Unlock()
+
+	if !ok {
+		return nil, &ToolError{
+			Code:    ErrorCodeNotFound,
+			Message: fmt.Sprintf("no pending confirmation with ID %s", params.ConfirmationID),
+		}
+	}
+
+	s.logSystem(audit.EventAccess, "CancelConfirmation: Pending confirmation cancelled", map[string]interface{}{
+		"profile":         s.currentProfile,
+		"confirmation_id": params.ConfirmationID,
+		"original_tool":   pending.toolName,
+	})
+
+	return map[string]interface{}{
+		"status":             "cancelled",
+		"confirmation_id":    params.ConfirmationID,
+		"original_tool_name": pending.toolName,
+		"message":            fmt.Sprintf("Pending %s confirmation cancelled; executing it now will be rejected.", pending.toolName),
+	}, nil
+}
+
+// confirmationFingerprint identifies an action by tool name and arguments. Arguments
+// are re-encoded so formatting differences do not change the fingerprint.
+func confirmationFingerprint(toolName, argsJSON string) string {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(argsJSON), &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			argsJSON = string(canonical)
+		}
+	}
+	return toolName + "\n" + argsJSON
+}
+
+// generateConfirmationID returns a random confirmation identifier
+func generateConfirmationID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate confirmation ID: %w", err)
+	}
+	return "conf-" + hex.EncodeToString(buf), nil
+}
+
+// resolveWithBackend lets the configured confirmation backend decide a confirmation_required
+// result: approved actions run immediately and denied ones are reported as denied. Without a
+// backend the result is returned unchanged for the MCP client to confirm.
+func (s *Server) resolveWithBackend(result interface{}) (interface{}, error) {
+	backend := s.options.ConfirmationBackend
+	if backend == nil {
+		return result, nil
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok || resultMap["status"] != "confirmation_required" {
+		return result, nil
+	}
+	details, _ := resultMap["confirmation_details"].(map[string]interface{})
+	promptArgs, _ := details["prompt_arguments"].(map[string]interface{})
+	toolName, _ := promptArgs["original_tool_name"].(string)
+	argsJSON, _ := promptArgs["original_tool_args_json"].(string)
+	actionDescription, _ := promptArgs["action_description"].(string)
+	warningMessage, _ := promptArgs["warning_message"].(string)
+
+	handler, ok := s.confirmedActionHandlers()[toolName]
+	if !ok {
+		return result, nil
+	}
+	// The backend's decision settles the action, so earlier confirmations of it are dropped
+	defer s.forgetConfirmations(confirmationFingerprint(toolName, argsJSON))
+
+	// Tool arguments can hold secret values, so the backend only sees the description
+	decision := backend.ConfirmOperation(context.Background(), toolName, actionDescription, map[string]interface{}{
+		"warning": warningMessage,
+	})
+	s.logSystem(audit.EventAccess, "Confirmation decided by backend", map[string]interface{}{
+		"tool":      toolName,
+		"approved":  decision.Approved,
+		"timed_out": decision.TimedOut,
+		"profile":   s.currentProfile,
+	})
+	if decision.Error != nil {
+		return nil, fmt.Errorf("confirmation backend failed for %s: %w", toolName, decision.Error)
+	}
+	if decision.TimedOut || !decision.Approved {
+		message := "The confirmation backend denied the operation."
+		if decision.TimedOut {
+			message = "The confirmation backend did not respond in time; the operation was not performed."
+		}
+		denied := map[string]interface{}{"status": "operation_denied", "message": message}
+		if decision.Reason != "" {
+			denied["reason"] = decision.Reason
+		}
+		return denied, nil
+	}
+
+	client, err := s.getCurrentClient()
+	if err != nil {
+		return nil, fmt.Errorf("no active session for confirmed action: %w", err)
+	}
+	if argsJSON == "" {
+		argsJSON = "{}"
+	}
+	// The time spent waiting for the decision does not count against the bulk timeout
+	_, endToolCall := s.beginToolCall()
+	defer endToolCall()
+	return handler(client, json.RawMessage(argsJSON))
+}
@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateFormatPresets are the named layouts accepted for mcp.date_format
+var dateFormatPresets = map[string]string{
+	"iso":      time.RFC3339,
+	"rfc3339":  time.RFC3339,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05 MST",
+	"rfc1123":  time.RFC1123,
+	"us":       "01/02/2006",
+	"eu":       "02/01/2006",
+}
+
+// dateFieldTypes are the record fields Keeper stores as epoch milliseconds
+var dateFieldTypes = map[string]bool{
+	"date":           true,
+	"birthDate":      true,
+	"expirationDate": true,
+}
+
+// DateFormat renders epoch-millisecond date fields for responses
+type DateFormat struct {
+	Layout   string         // Go time layout
+	Location *time.Location // zone the date is shown in
+}
+
+// ParseDateFormat resolves the configured date format and timezone. The format is a
+// preset name (iso, date, datetime, rfc1123, us, eu) or a Go time layout; the
+// timezone is an IANA name such as "Europe/Berlin". It returns nil when neither is
+// set, which keeps date fields as the epoch milliseconds Keeper stores.
+func ParseDateFormat(format, timezone string) (*DateFormat, error) {
+	if format == "" && timezone == "" {
+		return nil, nil
+	}
+
+	layout := time.RFC3339
+	if format != "" {
+		if preset, ok := dateFormatPresets[strings.ToLower(format)]; ok {
+			layout = preset
+		} else if strings.ContainsAny(format, "0123456789") {
+			layout = format
+		} else {
+			return nil, fmt.Errorf("invalid date format %q: use a preset (iso, date, datetime, rfc1123, us, eu) or a Go time layout such as 2006-01-02", format)
+		}
+	}
+
+	location := time.UTC
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		location = loc
+	}
+
+	return &DateFormat{Layout: layout, Location: location}, nil
+}
+
+// Format renders an epoch-milliseconds value. Values that are not a number are
+// returned unchanged, so dates already stored as text are left alone.
+func (f *DateFormat) Format(value interface{}) interface{} {
+	var epoch int64
+	switch v := value.(type) {
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return value
+		}
+		epoch = n
+	case float64:
+		epoch = int64(v)
+	case int64:
+		epoch = v
+	case int:
+		epoch = int64(v)
+	default:
+		return value
+	}
+	return time.UnixMilli(epoch).In(f.Location).Format(f.Layout)
+}
+
+// formatDateFields renders the date fields of a get_secret result with the configured
+// date format. Without a configured format the result is returned unchanged.
+func (s *Server) formatDateFields(secret map[string]interface{}) map[string]interface{} {
+	format := s.options.DateFormat
+	if format == nil || secret == nil {
+		return secret
+	}
+	for key, value := range secret {
+		if dateFieldTypes[key] {
+			secret[key] = format.Format(value)
+		}
+	}
+	return secret
+}
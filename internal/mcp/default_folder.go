@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
+)
+
+// errNoAccessibleFolders reports that the application has no folder to create records in
+var errNoAccessibleFolders = errors.New("no folders are shared with this application")
+
+// noSharedFolderHint explains why the server cannot create the first shared folder itself
+const noSharedFolderHint = "Keeper Secrets Manager can only create folders inside a shared folder the application already has, so a shared folder cannot be created for it. Share a folder with this application in the Keeper vault (Secrets Manager > Applications > Edit), then retry."
+
+// ensureDefaultFolder runs at startup when ServerOptions.DefaultFolderName is set. When
+// no folder is accessible it would create the default shared folder, but KSM only
+// creates folders under a shared folder the application already holds, so it reports
+// errNoAccessibleFolders with how to fix it instead. When folders are accessible
+// nothing is changed.
+func (s *Server) ensureDefaultFolder(client KSMClient) error {
+	name := s.options.DefaultFolderName
+	if name == "" {
+		return nil
+	}
+
+	folders, err := client.ListFolders()
+	if err != nil {
+		return fmt.Errorf("failed to list folders to check for the default folder '%s': %w", name, err)
+	}
+
+	if len(folders.Folders) == 0 {
+		s.logSystem(audit.EventError, "DefaultFolder: No folders are accessible and the default shared folder cannot be created", map[string]interface{}{
+			"profile":     s.currentProfile,
+			"folder_name": name,
+		})
+		return fmt.Errorf("%w, and default folder '%s' cannot be created: %s", errNoAccessibleFolders, name, noSharedFolderHint)
+	}
+
+	details := map[string]interface{}{
+		"profile":     s.currentProfile,
+		"folder_name": name,
+		"folders":     len(folders.Folders),
+	}
+	for _, folder := range folders.Folders {
+		if folder.Name == name {
+			details["folder_uid"] = folder.UID
+			s.logSystem(audit.EventStartup, "DefaultFolder: Default folder is accessible", details)
+			return nil
+		}
+	}
+	s.logSystem(audit.EventStartup, "DefaultFolder: Folders are accessible, so the default folder is not created", details)
+	return nil
+}
+
+// checkDefaultFolder runs ensureDefaultFolder for the initial profile. Problems are
+// logged and printed to stderr but do not stop the server, which can still read secrets.
+func (s *Server) checkDefaultFolder() {
+	if s.options.DefaultFolderName == "" {
+		return
+	}
+	client, err := s.getCurrentClient()
+	if err != nil {
+		return
+	}
+	if err := s.ensureDefaultFolder(client); err != nil {
+		s.logError("startup", err, map[string]interface{}{"folder_name": s.options.DefaultFolderName})
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+	}
+}
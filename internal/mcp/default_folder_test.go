@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureDefaultFolder(t *testing.T) {
+	t.Run("disabled without a name", func(t *testing.T) {
+		client := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, client)
+
+		assert.NoError(t, server.ensureDefaultFolder(client))
+		client.AssertNotCalled(t, "ListFolders")
+	})
+
+	t.Run("no accessible folders", func(t *testing.T) {
+		client := new(mockKSMClient)
+		client.On("ListFolders").Return(&types.ListFoldersResponse{}, nil)
+		server := newHandlerTestServer(&ServerOptions{DefaultFolderName: "MCP Secrets"}, client)
+
+		err := server.ensureDefaultFolder(client)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, errNoAccessibleFolders))
+		assert.Contains(t, err.Error(), "'MCP Secrets' cannot be created")
+		assert.Contains(t, err.Error(), "Share a folder with this application")
+		client.AssertNotCalled(t, "CreateFolder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("folders accessible", func(t *testing.T) {
+		client := new(mockKSMClient)
+		client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+			{UID: "sharedFolderUID01", Name: "Team"},
+		}}, nil)
+		server := newHandlerTestServer(&ServerOptions{DefaultFolderName: "MCP Secrets"}, client)
+
+		assert.NoError(t, server.ensureDefaultFolder(client))
+		client.AssertNotCalled(t, "CreateFolder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("list error", func(t *testing.T) {
+		client := new(mockKSMClient)
+		client.On("ListFolders").Return(nil, errors.New("network down"))
+		server := newHandlerTestServer(&ServerOptions{DefaultFolderName: "MCP Secrets"}, client)
+
+		err := server.ensureDefaultFolder(client)
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, errNoAccessibleFolders))
+		assert.Contains(t, err.Error(), "network down")
+	})
+}
+
+func TestCreateSecretNoFoldersClarification(t *testing.T) {
+	client := new(mockKSMClient)
+	client.On("ListFolders").Return(&types.ListFoldersResponse{}, nil)
+	server := newHandlerTestServer(&ServerOptions{}, client)
+
+	result, err := server.executeCreateSecret(client, []byte(`{"type":"login","title":"New Login","fields":[]}`))
+	require.NoError(t, err)
+
+	response, ok := result.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "folder_required_clarification", response["status"])
+	assert.Contains(t, response["message"], "cannot be created for it")
+	client.AssertNotCalled(t, "CreateSecret", mock.Anything)
+}
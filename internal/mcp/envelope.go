@@ -0,0 +1,79 @@
+package mcp
+
+import "reflect"
+
+// Response envelopes accepted for mcp.response_envelope
+const (
+	EnvelopeWrapped = "wrapped" // results wrapped in maps with keys such as secrets, count and message (default)
+	EnvelopeRaw     = "raw"     // read tools return the result object itself
+)
+
+// rawEnvelopeKeys maps read tools to the key holding their result in the wrapped envelope
+var rawEnvelopeKeys = map[string]string{
+	"list_secrets":      "secrets",
+	"get_secrets":       "results",
+	"search_secrets":    "results",
+	"find_secret_fuzzy": "matches",
+	"get_field":         "value",
+	"list_folders":      "folders",
+	"writable_folders":  "folders",
+}
+
+// rawEnvelopeExtraKeys hold information next to a read tool's result, such as per-UID
+// errors, the records never accessed or how folder names were resolved. A result
+// carrying any of them, non-empty, keeps the wrapped form.
+var rawEnvelopeExtraKeys = []string{"unmask_audit", "errors", "never_accessed", "resolved_folders"}
+
+// rawSecretMetadataKeys are dropped from a raw get_secret result, leaving the field map
+var rawSecretMetadataKeys = []string{"uid", "title", "type"}
+
+// applyEnvelope returns the result of a read tool unwrapped when ServerOptions.ResponseEnvelope
+// is EnvelopeRaw. Results carrying a status, such as confirmation requests and not-found
+// results, or one of rawEnvelopeExtraKeys keep the wrapped form so nothing is lost.
+func (s *Server) applyEnvelope(toolName string, result interface{}) interface{} {
+	if s.options.ResponseEnvelope != EnvelopeRaw {
+		return result
+	}
+	response, ok := result.(map[string]interface{})
+	if !ok {
+		return result
+	}
+	if _, hasStatus := response["status"]; hasStatus {
+		return result
+	}
+	for _, key := range rawEnvelopeExtraKeys {
+		if value, ok := response[key]; ok && !isEmptyValue(value) {
+			return result
+		}
+	}
+
+	if toolName == "get_secret" {
+		fields := make(map[string]interface{}, len(response))
+		for key, value := range response {
+			fields[key] = value
+		}
+		for _, key := range rawSecretMetadataKeys {
+			delete(fields, key)
+		}
+		return fields
+	}
+	if key, ok := rawEnvelopeKeys[toolName]; ok {
+		if value, ok := response[key]; ok {
+			return value
+		}
+	}
+	return result
+}
+
+// isEmptyValue reports whether value is nil or an empty slice or map
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
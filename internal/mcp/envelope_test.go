@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyEnvelope(t *testing.T) {
+	secrets := []map[string]interface{}{{"uid": "rec-1", "title": "Prod DB"}}
+	record := map[string]interface{}{
+		"uid":      "rec-1",
+		"title":    "Prod DB",
+		"type":     "login",
+		"login":    "admin",
+		"password": "****",
+	}
+
+	t.Run("wrapped by default", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+
+		wrapped := map[string]interface{}{"secrets": secrets, "count": 1}
+		assert.Equal(t, wrapped, server.applyEnvelope("list_secrets", wrapped))
+		assert.Equal(t, record, server.applyEnvelope("get_secret", record))
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{ResponseEnvelope: EnvelopeRaw}, new(mockKSMClient))
+
+		assert.Equal(t, secrets, server.applyEnvelope("list_secrets", map[string]interface{}{"secrets": secrets, "count": 1}))
+		assert.Equal(t, "admin", server.applyEnvelope("get_field", map[string]interface{}{"value": "admin", "notation": "rec-1/field/login"}))
+		assert.Equal(t, map[string]interface{}{"login": "admin", "password": "****"}, server.applyEnvelope("get_secret", record))
+		assert.Equal(t, "Prod DB", record["title"], "the original result must not be modified")
+	})
+
+	t.Run("raw keeps status and audited results wrapped", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{ResponseEnvelope: EnvelopeRaw}, new(mockKSMClient))
+
+		confirmation := map[string]interface{}{"status": "confirmation_required", "message": "confirm"}
+		assert.Equal(t, confirmation, server.applyEnvelope("get_secret", confirmation))
+		notFound := map[string]interface{}{"status": "not_found", "results": []map[string]interface{}{}, "count": 0}
+		assert.Equal(t, notFound, server.applyEnvelope("search_secrets", notFound))
+		audited := map[string]interface{}{"value": "secret", "unmask_audit": map[string]interface{}{"count": 1}}
+		assert.Equal(t, audited, server.applyEnvelope("get_field", audited))
+	})
+
+	t.Run("raw keeps results with extra information wrapped", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{ResponseEnvelope: EnvelopeRaw}, new(mockKSMClient))
+
+		failed := map[string]interface{}{"results": []interface{}{}, "errors": []types.BatchError{{UID: "bad", Error: "not found"}}, "count": 0}
+		assert.Equal(t, failed, server.applyEnvelope("get_secrets", failed))
+		neverAccessed := map[string]interface{}{"secrets": secrets, "count": 1, "never_accessed": []string{"rec-1"}}
+		assert.Equal(t, neverAccessed, server.applyEnvelope("list_secrets", neverAccessed))
+		resolved := map[string]interface{}{"secrets": secrets, "count": 1, "resolved_folders": map[string]string{"Prod": "folder-1"}}
+		assert.Equal(t, resolved, server.applyEnvelope("list_secrets", resolved))
+
+		succeeded := map[string]interface{}{"results": []interface{}{"r"}, "errors": []types.BatchError{}, "count": 1}
+		assert.Equal(t, []interface{}{"r"}, server.applyEnvelope("get_secrets", succeeded), "empty extras do not block unwrapping")
+	})
+
+	t.Run("raw leaves other tools wrapped", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{ResponseEnvelope: EnvelopeRaw}, new(mockKSMClient))
+
+		created := map[string]interface{}{"uid": "rec-1", "message": "Secret created"}
+		assert.Equal(t, created, server.applyEnvelope("create_secret", created))
+	})
+}
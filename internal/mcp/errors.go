@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/keeper-security/ksm-mcp/internal/ksm"
+	"github.com/keeper-security/ksm-mcp/internal/validation"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+)
+
+// Tool error codes returned in the data of a tools/call error response
+const (
+	ErrorCodeNotFound          = "NOT_FOUND"
+	ErrorCodeAccessDenied      = "ACCESS_DENIED"
+	ErrorCodeInvalidInput      = "INVALID_INPUT"      // a value failed input validation or the tool's input schema
+	ErrorCodeUnsafeInput       = "UNSAFE_INPUT"       // a value looked like an injection or traversal attempt
+	ErrorCodeStaleConfirmation = "STALE_CONFIRMATION" // a confirmation was cancelled or already used
+)
+
+// ToolError is a tool failure with a stable code clients can act on
+type ToolError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *ToolError) Error() string {
+	return e.Message
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// toolErrorData returns the structured data for a tools/call error response, or nil
+// when err carries no code. Validation failures are given INVALID_INPUT or
+// UNSAFE_INPUT even when no handler wrapped them in a ToolError.
+func toolErrorData(err error) interface{} {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return types.SafeError{Code: toolErr.Code, Message: toolErr.Message}
+	}
+	var validationErr *validation.Error
+	if errors.As(err, &validationErr) {
+		return types.SafeError{Code: validationErrorCode(validationErr), Message: err.Error()}
+	}
+	return nil
+}
+
+// validationErrorCode maps a validation failure to its tool error code
+func validationErrorCode(err *validation.Error) string {
+	switch err.Reason {
+	case validation.ErrCommandInjection, validation.ErrPathTraversal, validation.ErrInjectionPattern,
+		validation.ErrHTMLContent, validation.ErrDangerousURL:
+		return ErrorCodeUnsafeInput
+	default:
+		return ErrorCodeInvalidInput
+	}
+}
+
+// classifySecretLookupError turns a failed record lookup into a NOT_FOUND or
+// ACCESS_DENIED ToolError. The SDK reports an unknown UID and a record the
+// application cannot read the same way, so when Keeper does not say access was
+// denied the UID is cross-checked against the records the application can list.
+func classifySecretLookupError(client KSMClient, uid string, err error) error {
+	if errors.Is(err, ksm.ErrAccessDenied) {
+		return &ToolError{
+			Code:    ErrorCodeAccessDenied,
+			Message: fmt.Sprintf("access denied to secret %s: the application is not permitted to read this record", uid),
+			Err:     err,
+		}
+	}
+	if !errors.Is(err, ksm.ErrSecretNotFound) {
+		return err
+	}
+
+	secrets, listErr := client.ListSecrets(nil)
+	if listErr == nil {
+		for _, secret := range secrets {
+			if secret.UID == uid {
+				return &ToolError{
+					Code:    ErrorCodeAccessDenied,
+					Message: fmt.Sprintf("access denied to secret %s: the record is visible to this application but its contents could not be read; check the permissions of the shared folder that contains it", uid),
+					Err:     err,
+				}
+			}
+		}
+	}
+
+	return &ToolError{
+		Code:    ErrorCodeNotFound,
+		Message: fmt.Sprintf("secret %s not found: check the UID, and that the record (or a shared folder containing it) is shared with this application", uid),
+		Err:     err,
+	}
+}
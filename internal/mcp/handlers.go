@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/internal/storage"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 )
 
@@ -73,6 +74,9 @@ func (s *Server) sendInitializeResponse(writer *bufio.Writer, requestID interfac
 				"create": true,
 				"end":    true,
 			},
+			"experimental": map[string]interface{}{
+				"ksm": s.ksmCapabilities(),
+			},
 		},
 		"serverInfo": map[string]interface{}{
 			"name":    "ksm-mcp",
@@ -83,6 +87,24 @@ func (s *Server) sendInitializeResponse(writer *bufio.Writer, requestID interfac
 	return s.sendResponse(writer, requestID, response)
 }
 
+// ksmCapabilities describes the server's security posture so clients can adapt their UI
+func (s *Server) ksmCapabilities() map[string]interface{} {
+	maskMode := "sensitive_fields"
+	if s.options.MaskNotes {
+		maskMode = "sensitive_fields_and_notes"
+	}
+
+	return map[string]interface{}{
+		"confirmation_required": !(s.options.BatchMode || s.options.AutoApprove),
+		"batch_mode":            s.options.BatchMode,
+		"auto_approve":          s.options.AutoApprove,
+		"transports":            []string{"stdio"},
+		"mask_mode":             maskMode,
+		"read_only":             false, // mutating tools are always exposed
+		"tool_count":            len(s.getAvailableTools()),
+	}
+}
+
 // handleInitialized handles the initialized notification
 func (s *Server) handleInitialized(request types.MCPRequest, writer *bufio.Writer) error {
 	// This is a notification, no response needed
@@ -107,6 +129,9 @@ func (s *Server) handleToolCall(request types.MCPRequest, writer *bufio.Writer)
 	var params struct {
 		Name      string          `json:"name"`
 		Arguments json.RawMessage `json:"arguments"`
+		Meta      struct {
+			ApprovalToken string `json:"ksm/approval_token"`
+		} `json:"_meta"`
 	}
 
 	if request.Params != nil {
@@ -119,12 +144,27 @@ func (s *Server) handleToolCall(request types.MCPRequest, writer *bufio.Writer)
 		}
 	}
 
+	endRequest := s.beginRequest(request.ID)
+	defer endRequest()
+
 	// Route to appropriate tool handler
+	_, endToolCall := s.beginToolCall()
 	result, err := s.executeTool(params.Name, params.Arguments)
+	endToolCall()
+	if err == nil {
+		result, err = s.resolveWithApprovalToken(params.Meta.ApprovalToken, params.Name, params.Arguments, result)
+	}
+	if err == nil {
+		result, err = s.resolveWithBackend(result)
+	}
+	if err == nil {
+		result, err = s.trackConfirmation(result)
+	}
 	if err != nil {
-		_ = s.sendErrorResponse(writer, request.ID, -32002, err.Error(), nil)
+		_ = s.sendErrorResponse(writer, request.ID, -32002, err.Error(), toolErrorData(err))
 		return nil // Don't return error after sending response
 	}
+	result = s.applyEnvelope(params.Name, result)
 
 	// Wrap tool result in proper format
 	response := map[string]interface{}{
@@ -163,7 +203,15 @@ func (s *Server) handleSessionsList(request types.MCPRequest, writer *bufio.Writ
 		}
 
 		if profile != nil {
+			metadata := storage.DescribeProfile(profile)
 			session["created_at"] = profile.CreatedAt
+			session["hostname"] = metadata.Hostname
+			if metadata.Region != "" {
+				session["region"] = metadata.Region
+			}
+			if metadata.ClientID != "" {
+				session["client_id"] = metadata.ClientID
+			}
 		}
 
 		sessions = append(sessions, session)
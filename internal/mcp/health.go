@@ -2,8 +2,14 @@ package mcp
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"time"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
 )
 
 // HealthStatus represents the health check result
@@ -22,28 +28,39 @@ type Check struct {
 	Error  string `json:"error,omitempty"`
 }
 
+// Bounds on the KSM connectivity check. Probes arrive every few seconds, so a
+// result is reused for healthCheckCacheTTL instead of listing the vault each time.
+const (
+	healthCheckCacheTTL = 30 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+)
+
 // HealthCheck performs a health check on the MCP server
 func (s *Server) HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	// Snapshot the state under the lock; the KSM call below must not hold it.
 	s.mu.RLock()
-	defer s.mu.RUnlock()
+	currentProfile := s.currentProfile
+	client, hasClient := s.profiles[currentProfile]
+	store := s.storage
+	s.mu.RUnlock()
 
 	status := &HealthStatus{
 		Status:    "healthy",
 		Timestamp: time.Now(),
-		Profile:   s.currentProfile,
+		Profile:   currentProfile,
 		Uptime:    time.Since(s.startTime).Round(time.Second).String(),
 		Checks:    []Check{},
 	}
 
 	// Check storage
 	storageCheck := Check{Name: "storage", Status: "ok"}
-	if s.storage == nil {
+	if store == nil {
 		storageCheck.Status = "failed"
 		storageCheck.Error = "storage not initialized"
 		status.Status = "unhealthy"
 	} else {
 		// Try to list profiles to verify storage is working
-		profiles := s.storage.ListProfiles()
+		profiles := store.ListProfiles()
 		if len(profiles) == 0 {
 			storageCheck.Status = "warning"
 			storageCheck.Error = "no profiles found"
@@ -53,7 +70,7 @@ func (s *Server) HealthCheck(ctx context.Context) (*HealthStatus, error) {
 
 	// Check current profile
 	profileCheck := Check{Name: "profile", Status: "ok"}
-	if s.currentProfile == "" {
+	if currentProfile == "" {
 		profileCheck.Status = "warning"
 		profileCheck.Error = "no profile loaded"
 		if status.Status == "healthy" {
@@ -61,7 +78,7 @@ func (s *Server) HealthCheck(ctx context.Context) (*HealthStatus, error) {
 		}
 	} else {
 		// Verify profile can be loaded
-		if client, exists := s.profiles[s.currentProfile]; !exists || client == nil {
+		if !hasClient || client == nil {
 			profileCheck.Status = "failed"
 			profileCheck.Error = "profile not accessible"
 			status.Status = "unhealthy"
@@ -71,13 +88,10 @@ func (s *Server) HealthCheck(ctx context.Context) (*HealthStatus, error) {
 
 	// Check KSM connection (if profile is loaded)
 	ksmCheck := Check{Name: "ksm_connection", Status: "ok"}
-	if s.currentProfile != "" {
-		if client, exists := s.profiles[s.currentProfile]; exists && client != nil {
-			// Try a simple operation to verify connection
-			// Use a lightweight operation to check connectivity
-			if _, err := client.ListSecrets([]string{}); err != nil {
-				ksmCheck.Status = "failed"
-				ksmCheck.Error = fmt.Sprintf("connection test failed: %v", err)
+	if currentProfile != "" {
+		if hasClient && client != nil {
+			ksmCheck = s.checkKSMConnection(ctx, currentProfile, client)
+			if ksmCheck.Status != "ok" {
 				status.Status = "unhealthy"
 			}
 		} else {
@@ -120,6 +134,46 @@ func (s *Server) HealthCheck(ctx context.Context) (*HealthStatus, error) {
 	return status, nil
 }
 
+// checkKSMConnection lists secrets to verify the profile can reach KSM. The
+// result is cached per profile for healthCheckCacheTTL, and the call gives up
+// after healthCheckTimeout or when ctx is done, whichever comes first.
+func (s *Server) checkKSMConnection(ctx context.Context, profile string, client KSMClient) Check {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	if s.healthProfile == profile && !s.healthCheckedAt.IsZero() && time.Since(s.healthCheckedAt) < healthCheckCacheTTL {
+		return s.healthKSMCheck
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	// The client API takes no context, so the call runs on its own goroutine
+	// and is abandoned if it outlives ctx.
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.ListSecrets([]string{})
+		done <- err
+	}()
+
+	check := Check{Name: "ksm_connection", Status: "ok"}
+	select {
+	case err := <-done:
+		if err != nil {
+			check.Status = "failed"
+			check.Error = fmt.Sprintf("connection test failed: %v", err)
+		}
+	case <-ctx.Done():
+		check.Status = "failed"
+		check.Error = fmt.Sprintf("connection test failed: %v", ctx.Err())
+	}
+
+	s.healthKSMCheck = check
+	s.healthProfile = profile
+	s.healthCheckedAt = time.Now()
+	return check
+}
+
 // handleHealthCheck processes health check requests via MCP
 func (s *Server) handleHealthCheck(ctx context.Context, params interface{}) (interface{}, error) {
 	health, err := s.HealthCheck(ctx)
@@ -150,3 +204,91 @@ func (s *Server) handleHealthCheck(ctx context.Context, params interface{}) (int
 
 	return result, nil
 }
+
+// ready reports whether the server can serve requests: not unhealthy and with KSM reachable
+func (h *HealthStatus) ready() bool {
+	if h.Status == "unhealthy" {
+		return false
+	}
+	for _, check := range h.Checks {
+		if check.Name == "ksm_connection" {
+			return check.Status == "ok"
+		}
+	}
+	return false
+}
+
+// healthProbe is the body served at /healthz. The endpoint is unauthenticated,
+// so it carries only the overall status and whether each check passed, never
+// the profile name or error details.
+type healthProbe struct {
+	Status string          `json:"status"`
+	Checks map[string]bool `json:"checks"`
+}
+
+// HealthHandler serves the health check as plain HTTP for container liveness and readiness probes.
+// It responds 200 when the server is up and KSM is reachable and 503 otherwise, with a
+// healthProbe as the JSON body.
+func (s *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		health, err := s.HealthCheck(r.Context())
+		if err != nil {
+			http.Error(w, "health check failed", http.StatusServiceUnavailable)
+			return
+		}
+
+		probe := healthProbe{Status: health.Status, Checks: make(map[string]bool, len(health.Checks))}
+		for _, check := range health.Checks {
+			probe.Checks[check.Name] = check.Status == "ok"
+		}
+
+		code := http.StatusOK
+		if !health.ready() {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(probe)
+		}
+	})
+}
+
+// StartHealthServer listens on addr and serves HealthHandler at /healthz until ctx is done.
+// It is separate from the MCP transport, which stays on stdio.
+func (s *Server) StartHealthServer(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for health checks on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", s.HealthHandler())
+	httpServer := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logError("health", err, map[string]interface{}{"addr": addr})
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	s.logSystem(audit.EventStartup, "Health endpoint listening", map[string]interface{}{
+		"addr": listener.Addr().String(),
+	})
+	return nil
+}
@@ -12,26 +12,47 @@ type KSMClient interface {
 	// Basic secret operations
 	ListSecrets(folderUIDs []string) ([]*types.SecretMetadata, error)
 	GetSecret(uid string, fields []string, unmask bool) (map[string]interface{}, error)
+	GetSecretSafe(uid string) (map[string]interface{}, error)
+	GetSecrets(uids []string, fields []string, unmask bool) (*types.BatchResult, error)
 	GetField(notation string, unmask bool) (interface{}, error)
-	SearchSecrets(query string) ([]*types.SecretMetadata, error)
+	SearchSecrets(query string, folderUIDs []string) ([]*types.SecretMetadata, error)
+	FindSecretsFuzzy(query string, limit int) ([]types.FuzzyMatch, error)
+	ListCustomFields(uid string) ([]types.CustomFieldInfo, error)
+	RecordLayout(uid string) (*types.FullRecordTemplate, error)
+	FindByCustomField(label, value string) ([]*types.SecretMetadata, error)
 	CreateSecret(params types.CreateSecretParams) (string, error)
 	UpdateSecret(params types.UpdateSecretParams) error
+	RenameSecret(uid, newTitle string) error
+	AppendNotes(uid, text string) error
 	DeleteSecret(uid string, permanent bool) error
 
 	// Password operations
 	GeneratePassword(params types.GeneratePasswordParams) (string, error)
+	RotatePassword(uid, password string) error
 
 	// TOTP operations
 	GetTOTPCode(uid string) (*types.TOTPResponse, error)
+	VerifyTOTPCode(uid, code string) (*types.TOTPVerification, error)
 
 	// File operations
 	UploadFile(uid, filePath, title string) error
+	UploadFileFromURL(uid, rawURL, title string, maxBytes int64) (*types.UploadedFile, error)
 	DownloadFile(uid, fileUID, savePath string) error
 
 	// Folder operations
 	ListFolders() (*types.ListFoldersResponse, error)
 	CreateFolder(name, parentUID string) (string, error)
 	DeleteFolder(uid string, force bool) error
+	GetSecretPath(uid string) (string, error)
+	Fingerprint(uid string) (string, error)
+	GetSecretFolderUIDs(uid string) ([]string, error)
+	IsSharedFolder(uid string) (bool, error)
+
+	// Maintenance operations
+	LintSecrets(folderUIDs []string) (*types.LintResult, error)
+	FindMissingRequiredFields(folderUIDs []string) (*types.MissingFieldsResult, error)
+	RecordMissingRequiredFields(uid string, schema *types.RecordTypeSchema) ([]string, error)
+	FindDuplicates(folderUIDs []string, keyFields []string) (*types.DuplicatesResult, error)
 
 	// Health check
 	TestConnection() error
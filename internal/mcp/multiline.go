@@ -0,0 +1,34 @@
+package mcp
+
+import "strings"
+
+// normalizeLineEndings converts CRLF and lone CR line endings to LF in every string
+// within value when ServerOptions.NormalizeLineEndings is set. Certificates, scripts
+// and notes pasted from Windows otherwise carry \r characters that break the display
+// of JSON results in some clients.
+func (s *Server) normalizeLineEndings(value interface{}) interface{} {
+	if !s.options.NormalizeLineEndings {
+		return value
+	}
+	return transformStrings(value, normalizeNewlines)
+}
+
+// normalizeSecretLineEndings normalizes the line endings of every field of a
+// get_secret result
+func (s *Server) normalizeSecretLineEndings(secret map[string]interface{}) map[string]interface{} {
+	if !s.options.NormalizeLineEndings || secret == nil {
+		return secret
+	}
+	for key, value := range secret {
+		secret[key] = transformStrings(value, normalizeNewlines)
+	}
+	return secret
+}
+
+// normalizeNewlines replaces CRLF and lone CR line endings with LF
+func normalizeNewlines(str string) string {
+	if !strings.Contains(str, "\r") {
+		return str
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(str, "\r\n", "\n"), "\r", "\n")
+}
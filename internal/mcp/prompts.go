@@ -40,6 +40,11 @@ func (s *Server) getAvailablePrompts() []map[string]interface{} {
 					"description": "The JSON string of arguments originally passed to the tool. This will be passed to ksm_execute_confirmed_action.",
 					"required":    true,
 				},
+				{
+					"name":        "confirmation_id",
+					"description": "The ID of the pending confirmation. This will be passed to ksm_execute_confirmed_action, or to cancel_confirmation to abandon the action.",
+					"required":    false,
+				},
 			},
 		},
 	}
@@ -0,0 +1,81 @@
+package mcp
+
+import (
+	"fmt"
+
+	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/internal/recordtemplates"
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+)
+
+// Handling of record types without a template, accepted for mcp.unknown_type_schema
+const (
+	UnknownTypeSynthesize = "synthesize" // build a schema from an existing record of the type and cache it (default)
+	UnknownTypeError      = "error"      // report that no schema is available
+)
+
+// schemaForRecordType returns the template schema for a record type. For a type without
+// a template it falls back to a schema synthesized from the fields of one of its
+// records: sampleUID when given, otherwise the first accessible record of that type.
+// Synthesized schemas are cached per profile for the rest of the server run, since
+// profiles can reach different vaults with their own record types of the same name.
+func (s *Server) schemaForRecordType(client KSMClient, recordType, sampleUID string) (*types.RecordTypeSchema, error) {
+	schema, err := recordtemplates.GetSchema(recordType)
+	if err == nil || s.options.UnknownRecordTypeSchema == UnknownTypeError || recordType == "" {
+		return schema, err
+	}
+	cacheKey := synthesizedSchemaKey{profile: s.currentProfile, recordType: recordType}
+	s.synthesizedMu.Lock()
+	cached, ok := s.synthesizedSchemas[cacheKey]
+	s.synthesizedMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	if sampleUID == "" {
+		secrets, listErr := client.ListSecrets(nil)
+		if listErr != nil {
+			return nil, fmt.Errorf("%w; listing records to synthesize a schema also failed: %v", err, listErr)
+		}
+		for _, secret := range secrets {
+			if secret != nil && secret.Type == recordType {
+				sampleUID = secret.UID
+				break
+			}
+		}
+		if sampleUID == "" {
+			return nil, fmt.Errorf("%w, and no accessible record of this type to synthesize a schema from", err)
+		}
+	}
+
+	layout, layoutErr := client.RecordLayout(sampleUID)
+	if layoutErr != nil {
+		return nil, fmt.Errorf("%w; reading record %s to synthesize a schema failed: %w", err, sampleUID, layoutErr)
+	}
+	if layout.ID != recordType {
+		return nil, fmt.Errorf("%w; record %s is of type '%s'", err, sampleUID, layout.ID)
+	}
+
+	s.logSystem(audit.EventAccess, "Synthesized schema for record type without a template", map[string]interface{}{
+		"record_type": recordType,
+		"uid":         sampleUID,
+		"profile":     s.currentProfile,
+	})
+	synthesized, err := recordtemplates.SynthesizeSchema(layout)
+	if err != nil {
+		return nil, err
+	}
+	s.synthesizedMu.Lock()
+	if s.synthesizedSchemas == nil {
+		s.synthesizedSchemas = make(map[synthesizedSchemaKey]*types.RecordTypeSchema)
+	}
+	s.synthesizedSchemas[cacheKey] = synthesized
+	s.synthesizedMu.Unlock()
+	return synthesized, nil
+}
+
+// synthesizedSchemaKey identifies a synthesized schema in the server's cache
+type synthesizedSchemaKey struct {
+	profile    string
+	recordType string
+}
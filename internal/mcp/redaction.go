@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/keeper-security/ksm-mcp/internal/validation"
+)
+
+// CompileRedactionPatterns compiles the configured value redaction patterns
+func CompileRedactionPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// redactValues masks every substring matching a configured redaction pattern in
+// any string within value, descending into maps and slices. It is applied to
+// masked responses so secrets stored in fields without a sensitive name are
+// still hidden.
+func (s *Server) redactValues(value interface{}) interface{} {
+	if len(s.options.RedactionPatterns) == 0 {
+		return value
+	}
+	return transformStrings(value, s.redactString)
+}
+
+// transformStrings returns a copy of value with fn applied to every string within it,
+// descending into maps and slices. Other values are returned unchanged.
+func transformStrings(value interface{}, fn func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		transformed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			transformed[key] = transformStrings(item, fn)
+		}
+		return transformed
+	case []interface{}:
+		transformed := make([]interface{}, len(v))
+		for i, item := range v {
+			transformed[i] = transformStrings(item, fn)
+		}
+		return transformed
+	case []map[string]interface{}:
+		transformed := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			transformed[i], _ = transformStrings(item, fn).(map[string]interface{})
+		}
+		return transformed
+	case []string:
+		transformed := make([]string, len(v))
+		for i, item := range v {
+			transformed[i] = fn(item)
+		}
+		return transformed
+	default:
+		return value
+	}
+}
+
+// redactString masks the parts of str matching any configured redaction pattern
+func (s *Server) redactString(str string) string {
+	for _, pattern := range s.options.RedactionPatterns {
+		str = pattern.ReplaceAllStringFunc(str, maskRedactedValue)
+	}
+	return str
+}
+
+// maskRedactedValue masks a matched value, keeping only its length visible
+func maskRedactedValue(match string) string {
+	return fmt.Sprintf("[REDACTED - %d characters]", len(match))
+}
+
+var (
+	// errorSecretAssignmentPattern matches "password=..." style fragments in error text
+	errorSecretAssignmentPattern = regexp.MustCompile(`(?i)\b(password|passwd|passphrase|secret|token|api[_-]?key|private[_-]?key|pin|code)(\s*[:=]\s*)("[^"]*"|'[^']*'|\S+)`)
+	// errorOpaqueTokenPattern matches long opaque strings such as keys and tokens. It
+	// excludes '/', so the UID and path of a keeper:// notation are matched separately.
+	errorOpaqueTokenPattern = regexp.MustCompile(`[A-Za-z0-9+_=-]{24,}`)
+	// errorUIDValidator recognizes record UIDs, which stay readable in error text.
+	// UID length bounds are configurable, so a match can be a valid UID.
+	errorUIDValidator = validation.NewValidator()
+)
+
+// sanitizeErrorMessage returns err's message with secret-looking content masked,
+// for error text that is returned to the client alongside record data
+func (s *Server) sanitizeErrorMessage(err error) string {
+	message := errorSecretAssignmentPattern.ReplaceAllStringFunc(err.Error(), func(match string) string {
+		parts := errorSecretAssignmentPattern.FindStringSubmatch(match)
+		return parts[1] + parts[2] + maskRedactedValue(parts[3])
+	})
+	message = errorOpaqueTokenPattern.ReplaceAllStringFunc(message, func(match string) string {
+		if errorUIDValidator.ValidateUID(match) == nil {
+			return match
+		}
+		return maskRedactedValue(match)
+	})
+	return s.redactString(message)
+}
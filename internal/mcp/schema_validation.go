@@ -0,0 +1,199 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// validateToolArgs checks args against the InputSchema of the named tool. Tools
+// without a declared schema are not checked.
+func (s *Server) validateToolArgs(toolName string, args json.RawMessage) error {
+	var schema map[string]interface{}
+	for _, tool := range s.getAvailableTools() {
+		if tool.Name == toolName {
+			schema = tool.InputSchema
+			break
+		}
+	}
+	if schema == nil {
+		return nil
+	}
+
+	var decoded interface{} = map[string]interface{}{}
+	if trimmed := bytes.TrimSpace(args); len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			return &ToolError{
+				Code:    ErrorCodeInvalidInput,
+				Message: fmt.Sprintf("invalid parameters for %s: arguments are not valid JSON: %v", toolName, err),
+				Err:     err,
+			}
+		}
+	}
+
+	if err := validateSchemaValue(schema, decoded, "arguments"); err != nil {
+		return &ToolError{
+			Code:    ErrorCodeInvalidInput,
+			Message: fmt.Sprintf("invalid parameters for %s: %v", toolName, err),
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// validateSchemaValue validates value against the subset of JSON Schema used by the
+// tool definitions: type, properties, required, items, enum, minimum, maximum,
+// minLength, minItems, maxItems and nullable.
+func validateSchemaValue(schema map[string]interface{}, value interface{}, path string) error {
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+	}
+
+	if schemaType, ok := schema["type"].(string); ok {
+		if err := checkSchemaType(schemaType, value, path); err != nil {
+			return err
+		}
+	}
+
+	if allowed := schemaStrings(schema["enum"]); len(allowed) > 0 {
+		str, _ := value.(string)
+		if !containsString(allowed, str) {
+			return fmt.Errorf("%s must be one of %s", path, strings.Join(allowed, ", "))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if minLength, ok := schemaNumber(schema["minLength"]); ok && float64(len(v)) < minLength {
+			if minLength == 1 {
+				return fmt.Errorf("%s must not be empty", path)
+			}
+			return fmt.Errorf("%s must be at least %v characters", path, minLength)
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if minimum, ok := schemaNumber(schema["minimum"]); ok && n < minimum {
+			return fmt.Errorf("%s must be at least %v", path, minimum)
+		}
+		if maximum, ok := schemaNumber(schema["maximum"]); ok && n > maximum {
+			return fmt.Errorf("%s must be at most %v", path, maximum)
+		}
+	case []interface{}:
+		if minItems, ok := schemaNumber(schema["minItems"]); ok && float64(len(v)) < minItems {
+			return fmt.Errorf("%s must contain at least %v items", path, minItems)
+		}
+		if maxItems, ok := schemaNumber(schema["maxItems"]); ok && float64(len(v)) > maxItems {
+			return fmt.Errorf("%s must contain at most %v items", path, maxItems)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchemaValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		required := schemaStrings(schema["required"])
+		for _, name := range required {
+			if _, present := v[name]; !present {
+				return fmt.Errorf("%s is required", joinSchemaPath(path, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, propValue := range v {
+			propSchema, ok := properties[name].(map[string]interface{})
+			if !ok {
+				continue // undeclared properties are ignored by the handlers
+			}
+			// Clients commonly send null for an optional parameter they are not using
+			if propValue == nil && !containsString(required, name) {
+				continue
+			}
+			if err := validateSchemaValue(propSchema, propValue, joinSchemaPath(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSchemaType reports whether value has the given JSON Schema type
+func checkSchemaType(schemaType string, value interface{}, path string) error {
+	valid := false
+	switch schemaType {
+	case "object":
+		_, valid = value.(map[string]interface{})
+	case "array":
+		_, valid = value.([]interface{})
+	case "string":
+		_, valid = value.(string)
+	case "boolean":
+		_, valid = value.(bool)
+	case "number":
+		_, valid = value.(json.Number)
+	case "integer":
+		if n, ok := value.(json.Number); ok {
+			f, err := n.Float64()
+			valid = err == nil && f == math.Trunc(f)
+		}
+	default:
+		valid = true
+	}
+	if !valid {
+		return fmt.Errorf("%s must be of type %s", path, schemaType)
+	}
+	return nil
+}
+
+// schemaStrings reads a string list keyword, which tool definitions declare as []string
+func schemaStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// schemaNumber reads a numeric keyword such as minimum
+func schemaNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinSchemaPath(path, name string) string {
+	if path == "arguments" {
+		return name
+	}
+	return path + "." + name
+}
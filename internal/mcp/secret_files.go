@@ -0,0 +1,27 @@
+package mcp
+
+// Handling of the files array in secret results, accepted for mcp.files_array
+const (
+	FilesArrayPresent = "present" // list files only when the record has attachments (default)
+	FilesArrayOmit    = "omit"    // never list files, for clients that do not handle attachments
+	FilesArrayAlways  = "always"  // always list files, as an empty array when there are none
+)
+
+// applyFilesArray adjusts the files array of a secret result to ServerOptions.FilesArray.
+// Results limited to requested fields never list files, so they are left alone. It
+// only shapes tool output: handlers that look up attachments read them from
+// KSMClient.GetSecret directly.
+func (s *Server) applyFilesArray(secret map[string]interface{}, fields []string) map[string]interface{} {
+	if secret == nil || len(fields) > 0 {
+		return secret
+	}
+	switch s.options.FilesArray {
+	case FilesArrayOmit:
+		delete(secret, "files")
+	case FilesArrayAlways:
+		if _, ok := secret["files"]; !ok {
+			secret["files"] = []map[string]interface{}{}
+		}
+	}
+	return secret
+}
@@ -3,10 +3,12 @@ package mcp
 import (
 	"bufio"
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"sync"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/keeper-security/ksm-mcp/internal/ksm"
 	"github.com/keeper-security/ksm-mcp/internal/storage"
 	"github.com/keeper-security/ksm-mcp/internal/ui"
+	"github.com/keeper-security/ksm-mcp/internal/validation"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 )
 
@@ -38,6 +41,35 @@ type Server struct {
 	// Session management
 	sessionID string
 	startTime time.Time
+
+	// Tool call contexts; runCtx is the context the server was started with.
+	// requestID is the tools/call request being handled and requestCancelled
+	// records that the client cancelled it.
+	runCtx           context.Context
+	callMu           sync.Mutex
+	callCtx          context.Context
+	callCancel       context.CancelFunc
+	requestID        string
+	requestCancelled bool
+
+	// Last access per record in the audit log, built on first use
+	accessIndexOnce sync.Once
+	accessIndex     *audit.AccessIndex
+
+	// Schemas synthesized for record types without a template, per profile
+	synthesizedMu      sync.Mutex
+	synthesizedSchemas map[synthesizedSchemaKey]*types.RecordTypeSchema
+
+	// Last KSM connectivity result of the health check, reused for healthCheckCacheTTL
+	healthMu        sync.Mutex
+	healthKSMCheck  Check
+	healthProfile   string
+	healthCheckedAt time.Time
+
+	// Outstanding confirmation_required responses, keyed by confirmation ID
+	confirmationsMu      sync.Mutex
+	pendingConfirmations map[string]pendingConfirmation
+	usedApprovalTokens   map[string]int64 // approval token ID -> expiry (Unix seconds)
 }
 
 // ServerOptions configuration for the server
@@ -48,8 +80,87 @@ type ServerOptions struct {
 	ProfileName string
 	RateLimit   int    // requests per minute
 	Version     string // Server version
+	MaskNotes   bool   // Mask record notes unless the secret is explicitly unmasked
+
+	// BulkTimeout bounds each tool call; bulk operations that run past it stop
+	// between records and return partial results. 0 means no limit.
+	BulkTimeout time.Duration
+
+	// MaskBankOtherType masks a populated bankAccount otherType unless the secret is unmasked
+	MaskBankOtherType bool
+
+	// PasswordPolicy is enforced on generated passwords. When nil, validation.DefaultPasswordPolicy
+	// applies only to passwords saved to the vault, not to ones generate_password returns
+	PasswordPolicy *validation.PasswordPolicy
+	// PasswordGenerationAttempts bounds regeneration when a generated password misses the policy
+	PasswordGenerationAttempts int
+	// DefaultPasswordLength is the length of generated passwords when a call requests
+	// none; 0 uses ksm.DefaultPasswordLength
+	DefaultPasswordLength int
+	// RedactionPatterns mask matching values anywhere in masked responses, whatever the field name
+	RedactionPatterns []*regexp.Regexp
+	// SearchEmptyResult selects how search_secrets reports no matches (SearchEmptyResultList by default)
+	SearchEmptyResult string
+	// DefaultFolderDeleteForce is the force value delete_folder uses when the parameter is omitted
+	DefaultFolderDeleteForce bool
+	// MultiValueFieldTypes lists field types that may keep multiple values on create/update
+	MultiValueFieldTypes []string
+	// MaxNotesLength caps the notes accepted by create/update, in characters; 0 means no limit
+	MaxNotesLength int
+	// ConfirmationVerbosity selects verbose (default) or concise confirmation warnings
+	ConfirmationVerbosity string
+	// TrimFieldValues strips leading/trailing whitespace from string field values on create/update
+	TrimFieldValues bool
+	// TrimSensitiveFields extends TrimFieldValues to password, secret and other sensitive fields
+	TrimSensitiveFields bool
+	// NormalizeLineEndings converts CRLF and CR line endings to LF in secret and field values returned
+	NormalizeLineEndings bool
+	// DateFormat renders date fields in responses; nil returns the stored epoch milliseconds
+	DateFormat *DateFormat
+	// NotationIndexBase is the first array index in notations passed to get_field (0, matching KSM, or 1)
+	NotationIndexBase int
+	// MaxUnmaskedRecords caps how many records get_all_secrets_unmasked returns; 0 uses defaultMaxUnmaskedRecords
+	MaxUnmaskedRecords int
+	// MaxURLUploadBytes caps the files upload_file_from_url downloads; 0 uses
+	// ksm.DefaultMaxURLUploadBytes
+	MaxURLUploadBytes int64
+	// ConfirmationBackend decides confirmations instead of the MCP client; nil keeps the ksm_confirm_action prompt flow
+	ConfirmationBackend ui.Backend
+	// RequireExplicitUnmaskLog writes a warning audit event for every record unmasked without confirmation
+	RequireExplicitUnmaskLog bool
+	// UnmaskLogSummary adds a summary of those unmasks to the tool response
+	UnmaskLogSummary bool
+	// ConfirmationBypassFolders lists folder UIDs whose records get_secret and get_field
+	// unmask without confirmation
+	ConfirmationBypassFolders []string
+	// ResponseEnvelope selects wrapped (default) or raw results for read tools
+	ResponseEnvelope string
+	// UnknownRecordTypeSchema selects synthesize (default) or error for record types
+	// without a template
+	UnknownRecordTypeSchema string
+	// FilesArray selects present (default), omit or always for the files array of
+	// get_secret, get_secrets and get_secret_safe results
+	FilesArray string
+	// DefaultFolderName opts in to checking at startup that records can be created,
+	// reporting clearly when no folder is accessible; empty disables the check
+	DefaultFolderName string
+	// ApprovalTokenSecret verifies approval tokens that pre-authorize a single confirmed
+	// action; empty disables approval tokens
+	ApprovalTokenSecret []byte
+	// FingerprintKey keys the hashes returned by secret_fingerprint; empty uses a random
+	// key, so fingerprints are only comparable within one server run
+	FingerprintKey []byte
+	// EndpointPin restricts the KSM hostname and certificate public keys clients connect
+	// to; nil disables pinning
+	EndpointPin *ksm.EndpointPin
 }
 
+// search_secrets empty result modes
+const (
+	SearchEmptyResultList     = "empty_list" // return an empty results list with count 0
+	SearchEmptyResultNotFound = "not_found"  // return a structured NOT_FOUND result
+)
+
 // NewServer creates a new MCP server
 func NewServer(storage storage.ProfileStoreInterface, logger *audit.Logger, options *ServerOptions) *Server {
 	if options == nil {
@@ -62,6 +173,12 @@ func NewServer(storage storage.ProfileStoreInterface, logger *audit.Logger, opti
 	if options.Version == "" { // Ensure version is not empty
 		options.Version = "unknown"
 	}
+	if len(options.FingerprintKey) == 0 {
+		key := make([]byte, ksm.MinFingerprintKeyLength)
+		if _, err := rand.Read(key); err == nil {
+			options.FingerprintKey = key
+		}
+	}
 
 	confirmConfig := types.Confirmation{
 		BatchMode:   options.BatchMode,
@@ -70,11 +187,16 @@ func NewServer(storage storage.ProfileStoreInterface, logger *audit.Logger, opti
 		DefaultDeny: false,
 	}
 
+	var confirmer ConfirmerInterface = ui.NewConfirmer(confirmConfig)
+	if options.ConfirmationBackend != nil {
+		confirmer = options.ConfirmationBackend
+	}
+
 	s := &Server{
 		storage:     storage,
 		profiles:    make(map[string]KSMClient),
 		logger:      logger,
-		confirmer:   ui.NewConfirmer(confirmConfig),
+		confirmer:   confirmer,
 		options:     options,
 		rateLimiter: NewRateLimiter(options.RateLimit),
 		sessionID:   generateSessionID(),
@@ -109,6 +231,8 @@ func (s *Server) defaultGetCurrentClientImpl() (KSMClient, error) {
 
 // Start starts the MCP server
 func (s *Server) Start(ctx context.Context) error {
+	s.runCtx = ctx
+
 	// Log server start
 	s.logSystem(audit.EventStartup, "MCP server started", map[string]interface{}{
 		"session_id": s.sessionID,
@@ -122,14 +246,18 @@ func (s *Server) Start(ctx context.Context) error {
 			s.logError("startup", fmt.Errorf("failed to load initial profile '%s': %w", s.options.ProfileName, err), nil)
 			return fmt.Errorf("failed to load initial profile '%s': %w", s.options.ProfileName, err)
 		}
+		s.mu.Lock()
 		s.currentProfile = s.options.ProfileName
+		s.mu.Unlock()
 		s.logSystem(audit.EventStartup, "Initial profile loaded", map[string]interface{}{"profile": s.currentProfile})
+		s.checkDefaultFolder()
 	} else {
 		s.logSystem(audit.EventStartup, "No initial profile specified, server will wait for session/create or use direct config if available.", nil)
 	}
 
-	// Start reading from stdin
-	reader := bufio.NewReader(os.Stdin)
+	// Messages are read in the background so a cancellation, or the client going
+	// away, can stop the tool call that is running; everything else is handled in order.
+	lines, readErrs := s.readMessages(bufio.NewReader(os.Stdin))
 	writer := bufio.NewWriter(os.Stdout)
 	defer writer.Flush()
 
@@ -142,16 +270,12 @@ func (s *Server) Start(ctx context.Context) error {
 				"duration":   time.Since(s.startTime).String(),
 			})
 			return ctx.Err()
-		default:
-			// Read next message
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				if err == io.EOF {
-					return nil
-				}
-				return fmt.Errorf("failed to read message: %w", err)
+		case err := <-readErrs:
+			if err == io.EOF {
+				return nil
 			}
-
+			return fmt.Errorf("failed to read message: %w", err)
+		case line := <-lines:
 			// Process message
 			if err := s.processMessage(line, writer); err != nil {
 				s.logError("mcp", err, map[string]interface{}{
@@ -164,6 +288,51 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
+// maxQueuedMessages is how many messages can wait while a tool call runs
+const maxQueuedMessages = 64
+
+// readMessages reads newline-delimited messages until the reader fails. Cancellation
+// notifications are acted on as soon as they are read instead of being queued behind
+// the call they cancel, and a read error cancels the running call before it is reported.
+func (s *Server) readMessages(reader *bufio.Reader) (<-chan []byte, <-chan error) {
+	// Buffered so that messages queued behind a running call do not hold up reading a cancellation
+	lines := make(chan []byte, maxQueuedMessages)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				s.cancelActiveCall()
+				errs <- err
+				return
+			}
+			if id, ok := cancelledRequestID(line); ok {
+				s.cancelRequest(id)
+				s.logSystem(audit.EventAccess, "MCP request cancelled by client", map[string]interface{}{
+					"request_id": id,
+				})
+				continue
+			}
+			lines <- line
+		}
+	}()
+	return lines, errs
+}
+
+// cancelledRequestID returns the request ID named by a notifications/cancelled message
+func cancelledRequestID(line []byte) (interface{}, bool) {
+	var notification struct {
+		Method string `json:"method"`
+		Params struct {
+			RequestID interface{} `json:"requestId"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(line, &notification); err != nil || notification.Method != "notifications/cancelled" {
+		return nil, false
+	}
+	return notification.Params.RequestID, true
+}
+
 // processMessage processes a single MCP message
 func (s *Server) processMessage(data []byte, writer *bufio.Writer) error {
 	var request types.MCPRequest
@@ -245,6 +414,11 @@ func (s *Server) loadProfile(name string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create KSM client: %w", err)
 	}
+	client.SetMaskBankOtherType(s.options.MaskBankOtherType)
+	client.SetFingerprintKey(s.options.FingerprintKey)
+	if err := client.EnforceEndpointPin(s.options.EndpointPin); err != nil {
+		return fmt.Errorf("failed to connect to KSM: %w", err)
+	}
 
 	// Test connection
 	if err := client.TestConnection(); err != nil {
@@ -322,6 +496,70 @@ func (s *Server) logSystem(eventType audit.EventType, message string, details ma
 	}
 }
 
+// confirmationBypassFolder returns the folder in ConfirmationBypassFolders that holds
+// the record, or "" when the record must still be confirmed. A lookup failure
+// keeps the confirmation.
+func (s *Server) confirmationBypassFolder(client KSMClient, uid string) string {
+	if len(s.options.ConfirmationBypassFolders) == 0 || uid == "" {
+		return ""
+	}
+	folderUIDs, err := client.GetSecretFolderUIDs(uid)
+	if err != nil {
+		return ""
+	}
+	for _, folderUID := range folderUIDs {
+		for _, bypass := range s.options.ConfirmationBypassFolders {
+			if folderUID == bypass {
+				return folderUID
+			}
+		}
+	}
+	return ""
+}
+
+// logUnmaskWithoutConfirmation records each record a batch/auto-approve run unmasked with a
+// warning audit event when RequireExplicitUnmaskLog is set. It returns the summary to include
+// in the tool response, or nil when no summary was requested.
+func (s *Server) logUnmaskWithoutConfirmation(tool string, uids []string) map[string]interface{} {
+	if !s.options.RequireExplicitUnmaskLog || !(s.options.BatchMode || s.options.AutoApprove) {
+		return nil
+	}
+
+	mode := "auto_approve"
+	if s.options.BatchMode {
+		mode = "batch"
+	}
+	if s.logger != nil {
+		for _, uid := range uids {
+			s.logger.Log(&audit.AuditEvent{
+				Type:     audit.EventSecretAccess,
+				Severity: audit.SeverityWarning,
+				Source:   "mcp",
+				Profile:  s.currentProfile,
+				Resource: uid,
+				Action:   "unmask_without_confirmation",
+				Result:   "UNMASKED",
+				Details: map[string]interface{}{
+					"tool": tool,
+					"mode": mode,
+				},
+			})
+		}
+	}
+
+	if !s.options.UnmaskLogSummary {
+		return nil
+	}
+	return map[string]interface{}{
+		"unmasked_without_confirmation": true,
+		"mode":                          mode,
+		"tool":                          tool,
+		"uids":                          uids,
+		"count":                         len(uids),
+		"message":                       fmt.Sprintf("%d record(s) were unmasked without user confirmation (%s mode); each unmask was written to the audit log as a warning.", len(uids), mode),
+	}
+}
+
 func (s *Server) logError(source string, err error, details map[string]interface{}) {
 	if s.logger != nil {
 		s.logger.LogError(source, err, details)
@@ -3,7 +3,11 @@ package mcp
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 	"github.com/keeper-security/ksm-mcp/internal/storage"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // testLogger creates a logger for testing
@@ -129,6 +134,64 @@ func TestServer_HandleInitialize(t *testing.T) {
 	}
 }
 
+func TestServer_InitializeCapabilities(t *testing.T) {
+	storage := &storage.ProfileStore{}
+	logger := testLogger(t)
+
+	tests := []struct {
+		name                 string
+		options              *ServerOptions
+		confirmationRequired bool
+		maskMode             string
+	}{
+		{
+			name:                 "interactive defaults",
+			options:              &ServerOptions{},
+			confirmationRequired: true,
+			maskMode:             "sensitive_fields",
+		},
+		{
+			name:                 "batch mode with masked notes",
+			options:              &ServerOptions{BatchMode: true, MaskNotes: true},
+			confirmationRequired: false,
+			maskMode:             "sensitive_fields_and_notes",
+		},
+		{
+			name:                 "auto approve",
+			options:              &ServerOptions{AutoApprove: true},
+			confirmationRequired: false,
+			maskMode:             "sensitive_fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(storage, logger, tt.options)
+
+			var buf bytes.Buffer
+			writer := bufio.NewWriter(&buf)
+			err := server.sendInitializeResponse(writer, 1)
+			assert.NoError(t, err)
+			writer.Flush()
+
+			var response types.MCPResponse
+			assert.NoError(t, json.Unmarshal(buf.Bytes(), &response))
+
+			result := response.Result.(map[string]interface{})
+			capabilities := result["capabilities"].(map[string]interface{})
+			ksmCaps := capabilities["experimental"].(map[string]interface{})["ksm"].(map[string]interface{})
+
+			assert.Equal(t, tt.confirmationRequired, ksmCaps["confirmation_required"])
+			assert.Equal(t, tt.options.BatchMode, ksmCaps["batch_mode"])
+			assert.Equal(t, tt.options.AutoApprove, ksmCaps["auto_approve"])
+			assert.Equal(t, tt.maskMode, ksmCaps["mask_mode"])
+			assert.Equal(t, false, ksmCaps["read_only"])
+			assert.Equal(t, []interface{}{"stdio"}, ksmCaps["transports"])
+			assert.Equal(t, float64(len(server.getAvailableTools())), ksmCaps["tool_count"])
+		})
+	}
+}
+
 func TestServer_HandleToolsList(t *testing.T) {
 	storage := &storage.ProfileStore{}
 	logger := testLogger(t)
@@ -300,3 +363,177 @@ func TestServer_GetAvailableTools(t *testing.T) {
 		}
 	}
 }
+
+func TestServer_HealthHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		profile    string
+		mockSetup  func(*mockKSMClient)
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:    "healthy when KSM is reachable",
+			method:  http.MethodGet,
+			profile: "test",
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListSecrets", []string{}).Return([]*types.SecretMetadata{}, nil)
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: "healthy",
+		},
+		{
+			name:    "unavailable when KSM is unreachable",
+			method:  http.MethodGet,
+			profile: "test",
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListSecrets", []string{}).Return(nil, errors.New("dial tcp: connection refused"))
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unhealthy",
+		},
+		{
+			name:       "unavailable before a profile is loaded",
+			method:     http.MethodGet,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "degraded",
+		},
+		{
+			name:     "rejects other methods",
+			method:   http.MethodPost,
+			profile:  "test",
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockClient)
+			}
+			store := storage.NewMemoryProfileStore()
+			store.AddProfile("test", mockClient)
+			server := NewServer(store, testLogger(t), nil)
+			if tt.profile != "" {
+				server.profiles[tt.profile] = mockClient
+				server.currentProfile = tt.profile
+			}
+
+			recorder := httptest.NewRecorder()
+			server.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(tt.method, "/healthz", nil))
+
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			if tt.wantStatus != "" {
+				assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+				var probe healthProbe
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &probe))
+				assert.Equal(t, tt.wantStatus, probe.Status)
+				assert.Equal(t, tt.wantCode == http.StatusOK, probe.Checks["ksm_connection"])
+				assert.NotContains(t, recorder.Body.String(), "test")
+				assert.NotContains(t, recorder.Body.String(), "connection refused")
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HealthHandlerKSMCheck(t *testing.T) {
+	newServer := func(client *mockKSMClient) *Server {
+		store := storage.NewMemoryProfileStore()
+		store.AddProfile("test", client)
+		server := NewServer(store, testLogger(t), nil)
+		server.profiles["test"] = client
+		server.currentProfile = "test"
+		return server
+	}
+
+	t.Run("reuses a recent result", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string{}).Return([]*types.SecretMetadata{}, nil).Once()
+		server := newServer(mockClient)
+
+		for i := 0; i < 3; i++ {
+			recorder := httptest.NewRecorder()
+			server.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		}
+		mockClient.AssertNumberOfCalls(t, "ListSecrets", 1)
+	})
+
+	t.Run("gives up when the request context ends", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string{}).Run(func(mock.Arguments) { <-release }).Return([]*types.SecretMetadata{}, nil)
+		server := newServer(mockClient)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		recorder := httptest.NewRecorder()
+		server.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil).WithContext(ctx))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "deadline")
+	})
+
+	t.Run("does not hold the server lock while checking", func(t *testing.T) {
+		release := make(chan struct{})
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string{}).Run(func(mock.Arguments) { <-release }).Return([]*types.SecretMetadata{}, nil)
+		server := newServer(mockClient)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			recorder := httptest.NewRecorder()
+			server.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		}()
+
+		// A writer must get the lock while the KSM call is in flight.
+		time.Sleep(10 * time.Millisecond)
+		locked := make(chan struct{})
+		go func() {
+			server.mu.Lock()
+			server.mu.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			t.Fatal("server lock held during the KSM check")
+		}
+		close(release)
+		<-done
+	})
+}
+
+func TestServer_HandleSessionsListShowsHostname(t *testing.T) {
+	const privateKey = "MIGHAgEAMBMGByqGSM49AgEGCCqGSM49AwEHBG0wawIBAQQg"
+	store := storage.NewMemoryProfileStore()
+	assert.NoError(t, store.CreateProfile("eu-app", map[string]string{
+		"clientId":   "Q2xpZW50SWRWYWx1ZTEyMzQ1Njc4OTA=",
+		"privateKey": privateKey,
+		"appKey":     "YXBwLWtleS1tYXRlcmlhbC0xMjM0NTY3OA==",
+		"hostname":   "keepersecurity.eu",
+	}))
+	server := NewServer(store, testLogger(t), nil)
+
+	var buf bytes.Buffer
+	writer := bufio.NewWriter(&buf)
+	assert.NoError(t, server.handleSessionsList(types.MCPRequest{JSONRPC: "2.0", ID: 1, Method: "sessions/list"}, writer))
+	writer.Flush()
+
+	assert.NotContains(t, buf.String(), privateKey)
+	assert.NotContains(t, buf.String(), "Q2xpZW50SWRWYWx1ZTEyMzQ1Njc4OTA=")
+
+	var response types.MCPResponse
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &response))
+	sessions := response.Result.(map[string]interface{})["sessions"].([]interface{})
+	assert.Len(t, sessions, 1)
+	session := sessions[0].(map[string]interface{})
+	assert.Equal(t, "keepersecurity.eu", session["hostname"])
+	assert.Equal(t, "EU", session["region"])
+	assert.Equal(t, "Q2xp...OTA=", session["client_id"])
+}
@@ -1,18 +1,32 @@
 package mcp
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/internal/ksm"
 	"github.com/keeper-security/ksm-mcp/internal/recordtemplates"
 	"github.com/keeper-security/ksm-mcp/internal/ui"
+	"github.com/keeper-security/ksm-mcp/internal/validation"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Mock KSM Client
@@ -36,8 +50,56 @@ func (m *mockKSMClient) GetSecret(uid string, fields []string, unmask bool) (map
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
-func (m *mockKSMClient) SearchSecrets(query string) ([]*types.SecretMetadata, error) {
-	args := m.Called(query)
+func (m *mockKSMClient) GetSecretSafe(uid string) (map[string]interface{}, error) {
+	args := m.Called(uid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
+func (m *mockKSMClient) SearchSecrets(query string, folderUIDs []string) ([]*types.SecretMetadata, error) {
+	args := m.Called(query, folderUIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.SecretMetadata), args.Error(1)
+}
+
+func (m *mockKSMClient) FindSecretsFuzzy(query string, limit int) ([]types.FuzzyMatch, error) {
+	args := m.Called(query, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]types.FuzzyMatch), args.Error(1)
+}
+
+func (m *mockKSMClient) GetSecrets(uids []string, fields []string, unmask bool) (*types.BatchResult, error) {
+	args := m.Called(uids, fields, unmask)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.BatchResult), args.Error(1)
+}
+
+func (m *mockKSMClient) ListCustomFields(uid string) ([]types.CustomFieldInfo, error) {
+	args := m.Called(uid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]types.CustomFieldInfo), args.Error(1)
+}
+
+func (m *mockKSMClient) RecordLayout(uid string) (*types.FullRecordTemplate, error) {
+	args := m.Called(uid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.FullRecordTemplate), args.Error(1)
+}
+
+func (m *mockKSMClient) FindByCustomField(label, value string) ([]*types.SecretMetadata, error) {
+	args := m.Called(label, value)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -54,6 +116,21 @@ func (m *mockKSMClient) UpdateSecret(params types.UpdateSecretParams) error {
 	return args.Error(0)
 }
 
+func (m *mockKSMClient) RenameSecret(uid, newTitle string) error {
+	args := m.Called(uid, newTitle)
+	return args.Error(0)
+}
+
+func (m *mockKSMClient) RotatePassword(uid, password string) error {
+	args := m.Called(uid, password)
+	return args.Error(0)
+}
+
+func (m *mockKSMClient) AppendNotes(uid, text string) error {
+	args := m.Called(uid, text)
+	return args.Error(0)
+}
+
 func (m *mockKSMClient) DeleteSecret(uid string, permanent bool) error {
 	args := m.Called(uid, permanent)
 	return args.Error(0)
@@ -77,11 +154,27 @@ func (m *mockKSMClient) GetTOTPCode(uid string) (*types.TOTPResponse, error) {
 	return args.Get(0).(*types.TOTPResponse), args.Error(1)
 }
 
+func (m *mockKSMClient) VerifyTOTPCode(uid, code string) (*types.TOTPVerification, error) {
+	args := m.Called(uid, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.TOTPVerification), args.Error(1)
+}
+
 func (m *mockKSMClient) UploadFile(uid, filePath, title string) error {
 	args := m.Called(uid, filePath, title)
 	return args.Error(0)
 }
 
+func (m *mockKSMClient) UploadFileFromURL(uid, rawURL, title string, maxBytes int64) (*types.UploadedFile, error) {
+	args := m.Called(uid, rawURL, title, maxBytes)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.UploadedFile), args.Error(1)
+}
+
 func (m *mockKSMClient) DownloadFile(uid, fileUID, savePath string) error {
 	args := m.Called(uid, fileUID, savePath)
 	return args.Error(0)
@@ -95,6 +188,61 @@ func (m *mockKSMClient) ListFolders() (*types.ListFoldersResponse, error) {
 	return args.Get(0).(*types.ListFoldersResponse), args.Error(1)
 }
 
+func (m *mockKSMClient) GetSecretPath(uid string) (string, error) {
+	args := m.Called(uid)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockKSMClient) Fingerprint(uid string) (string, error) {
+	args := m.Called(uid)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockKSMClient) IsSharedFolder(uid string) (bool, error) {
+	args := m.Called(uid)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockKSMClient) GetSecretFolderUIDs(uid string) ([]string, error) {
+	args := m.Called(uid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockKSMClient) LintSecrets(folderUIDs []string) (*types.LintResult, error) {
+	args := m.Called(folderUIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.LintResult), args.Error(1)
+}
+
+func (m *mockKSMClient) FindMissingRequiredFields(folderUIDs []string) (*types.MissingFieldsResult, error) {
+	args := m.Called(folderUIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.MissingFieldsResult), args.Error(1)
+}
+
+func (m *mockKSMClient) RecordMissingRequiredFields(uid string, schema *types.RecordTypeSchema) ([]string, error) {
+	args := m.Called(uid, schema)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockKSMClient) FindDuplicates(folderUIDs []string, keyFields []string) (*types.DuplicatesResult, error) {
+	args := m.Called(folderUIDs, keyFields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.DuplicatesResult), args.Error(1)
+}
+
 func (m *mockKSMClient) CreateFolder(name string, parentUID string) (string, error) {
 	args := m.Called(name, parentUID)
 	return args.String(0), args.Error(1)
@@ -175,6 +323,25 @@ func TestExecuteCreateSecret(t *testing.T) {
 				assert.Contains(t, resultMap["message"].(string), "Secret created successfully (confirmed).")
 			},
 		},
+		{
+			name:          "batch mode - configured multi-value field keeps all values",
+			args:          json.RawMessage(`{"type":"login","title":"Mirrors","folder_uid":"folder_abc","fields":[{"type":"url","value":["https://a.example.com","https://b.example.com"]},{"type":"login","value":["first","second"]}]}`),
+			serverOptions: &ServerOptions{BatchMode: true, MultiValueFieldTypes: []string{"url"}},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("CreateSecret", mock.MatchedBy(func(p types.CreateSecretParams) bool {
+					values := map[string][]interface{}{}
+					for _, f := range p.Fields {
+						values[f.Type] = f.Value
+					}
+					return len(values["url"]) == 2 && len(values["login"]) == 1
+				})).Return("test-uid-multi-value", nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "test-uid-multi-value", resultMap["uid"])
+			},
+		},
 		{
 			name:          "invalid JSON parameters",
 			args:          json.RawMessage(`{"invalid json`),
@@ -261,6 +428,9 @@ func TestExecuteListSecrets(t *testing.T) {
 			args:        json.RawMessage(`{"folder_uid":"folder123"}`),
 			expectError: false,
 			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "folder123", Name: "Prod"},
+				}}, nil)
 				client.On("ListSecrets", []string{"folder123"}).Return([]*types.SecretMetadata{
 					{UID: "uid1", Title: "Secret 1", Type: "login", Folder: "folder123"},
 				}, nil)
@@ -275,6 +445,10 @@ func TestExecuteListSecrets(t *testing.T) {
 			args:        json.RawMessage(`{"folder_uids":["folder123","folder456"]}`),
 			expectError: false,
 			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "folder123", Name: "Prod"},
+					{UID: "folder456", Name: "Staging"},
+				}}, nil)
 				client.On("ListSecrets", []string{"folder123", "folder456"}).Return([]*types.SecretMetadata{
 					{UID: "uid1", Title: "Secret 1", Type: "login", Folder: "folder123"},
 					{UID: "uid2", Title: "Secret 2", Type: "login", Folder: "folder456"},
@@ -283,6 +457,67 @@ func TestExecuteListSecrets(t *testing.T) {
 			validate: func(t *testing.T, result interface{}) {
 				resultMap := result.(map[string]interface{})
 				assert.Equal(t, 2, resultMap["count"])
+				assert.NotContains(t, resultMap, "resolved_folders")
+			},
+		},
+		{
+			name:        "list with folder name filter",
+			args:        json.RawMessage(`{"folder_uid":"Prod"}`),
+			expectError: false,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "folder123", Name: "Prod"},
+					{UID: "folder456", Name: "Staging"},
+				}}, nil)
+				client.On("ListSecrets", []string{"folder123"}).Return([]*types.SecretMetadata{
+					{UID: "uid1", Title: "Secret 1", Type: "login", Folder: "folder123"},
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, 1, resultMap["count"])
+				assert.Equal(t, map[string]string{"Prod": "folder123"}, resultMap["resolved_folders"])
+			},
+		},
+		{
+			name:        "list with folder path and UID filters",
+			args:        json.RawMessage(`{"folder_uids":["Apps / Prod","folder456"]}`),
+			expectError: false,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "apps", Name: "Apps"},
+					{UID: "folder123", Name: "Prod", ParentUID: "apps"},
+					{UID: "folder789", Name: "Prod", ParentUID: "legacy"},
+					{UID: "folder456", Name: "Staging"},
+				}}, nil)
+				client.On("ListSecrets", []string{"folder123", "folder456"}).Return([]*types.SecretMetadata{}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, map[string]string{"Apps / Prod": "folder123"}, resultMap["resolved_folders"])
+			},
+		},
+		{
+			name:        "ambiguous folder name",
+			args:        json.RawMessage(`{"folder_uid":"Prod"}`),
+			expectError: true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "folder123", Name: "Prod"},
+					{UID: "folder789", Name: "Prod", ParentUID: "legacy"},
+				}}, nil)
+			},
+		},
+		{
+			name:        "unknown filter passed through when folders cannot be listed",
+			args:        json.RawMessage(`{"folder_uid":"folder999"}`),
+			expectError: false,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(nil, errors.New("KSM error"))
+				client.On("ListSecrets", []string{"folder999"}).Return([]*types.SecretMetadata{}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				assert.Equal(t, 0, result.(map[string]interface{})["count"])
 			},
 		},
 		{
@@ -323,6 +558,53 @@ func TestExecuteListSecrets(t *testing.T) {
 	}
 }
 
+func TestExecuteListSecretsLastAccessed(t *testing.T) {
+	logger, err := audit.NewLogger(audit.Config{FilePath: filepath.Join(t.TempDir(), "audit.log")})
+	require.NoError(t, err)
+	defer logger.Close()
+	logger.LogSecretOperation(audit.EventSecretAccess, "uid-used", "", "default", true, nil)
+	logger.LogAccess("field", "get", "uid-used/field/password", "default", true, nil)
+	time.Sleep(200 * time.Millisecond)
+
+	secrets := []*types.SecretMetadata{
+		{UID: "uid-used", Title: "Used"},
+		{UID: "uid-dormant", Title: "Dormant"},
+	}
+	mockClient := new(mockKSMClient)
+	mockClient.On("ListSecrets", []string(nil)).Return(secrets, nil)
+	server := newHandlerTestServer(&ServerOptions{}, mockClient)
+	server.logger = logger
+
+	result, err := server.executeListSecrets(mockClient, json.RawMessage(`{"include_last_accessed":true}`))
+	require.NoError(t, err)
+	resultMap := result.(map[string]interface{})
+	assert.Equal(t, 1, resultMap["never_accessed"])
+	listed := resultMap["secrets"].([]*types.SecretMetadata)
+	require.Len(t, listed, 2)
+	require.NotNil(t, listed[0].LastAccessed)
+	assert.Equal(t, 2, *listed[0].AccessCount)
+	assert.Nil(t, listed[1].LastAccessed)
+	assert.Nil(t, secrets[0].LastAccessed, "the client's metadata should not be modified")
+
+	// Without the option the listing is unchanged
+	result, err = server.executeListSecrets(mockClient, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.NotContains(t, result.(map[string]interface{}), "never_accessed")
+}
+
+// cardNumberRedactionPattern matches 13-19 digit payment card numbers with optional separators
+const cardNumberRedactionPattern = `\b(?:\d[ -]?){12,18}\d\b`
+
+func TestCompileRedactionPatterns(t *testing.T) {
+	patterns, err := CompileRedactionPatterns([]string{cardNumberRedactionPattern, `\bAKIA[0-9A-Z]{16}\b`})
+	assert.NoError(t, err)
+	assert.Len(t, patterns, 2)
+
+	_, err = CompileRedactionPatterns([]string{"["})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid redaction pattern")
+}
+
 func TestExecuteGetSecret(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -363,6 +645,108 @@ func TestExecuteGetSecret(t *testing.T) {
 				assert.Contains(t, resultMap["message"].(string), "Reveal unmasked secret 'Test Unmask' (UID: test-uid)")
 			},
 		},
+		{
+			name:          "get secret masked - notes masked when mask_notes enabled",
+			args:          json.RawMessage(`{"uid":"test-uid"}`),
+			serverOptions: &ServerOptions{MaskNotes: true},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":   "test-uid",
+					"notes": "db host is 10.0.0.5, fallback creds in vault",
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "[MASKED - 44 characters]", resultMap["notes"])
+			},
+		},
+		{
+			name:          "get secret masked - notes returned when mask_notes disabled",
+			args:          json.RawMessage(`{"uid":"test-uid"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":   "test-uid",
+					"notes": "plain notes",
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "plain notes", resultMap["notes"])
+			},
+		},
+		{
+			name:          "get secret masked - card number in text field redacted by value pattern",
+			args:          json.RawMessage(`{"uid":"test-uid"}`),
+			serverOptions: &ServerOptions{RedactionPatterns: []*regexp.Regexp{regexp.MustCompile(cardNumberRedactionPattern)}},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":  "test-uid",
+					"text": "4111 1111 1111 1111",
+					"custom_fields": map[string]interface{}{
+						"Billing Memo": "charged to 4111-1111-1111-1111 on renewal",
+					},
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "test-uid", resultMap["uid"])
+				assert.Equal(t, "[REDACTED - 19 characters]", resultMap["text"])
+				custom := resultMap["custom_fields"].(map[string]interface{})
+				assert.Equal(t, "charged to [REDACTED - 19 characters] on renewal", custom["Billing Memo"])
+			},
+		},
+		{
+			name:          "get secret masked - text field untouched without redaction patterns",
+			args:          json.RawMessage(`{"uid":"test-uid"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":  "test-uid",
+					"text": "4111 1111 1111 1111",
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "4111 1111 1111 1111", resultMap["text"])
+			},
+		},
+		{
+			name:          "get secret unmasked - batch mode skips value redaction",
+			args:          json.RawMessage(`{"uid":"test-uid-batch","unmask":true}`),
+			serverOptions: &ServerOptions{BatchMode: true, RedactionPatterns: []*regexp.Regexp{regexp.MustCompile(cardNumberRedactionPattern)}},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("GetSecret", "test-uid-batch", []string(nil), true).Return(map[string]interface{}{"text": "4111 1111 1111 1111"}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "4111 1111 1111 1111", resultMap["text"])
+			},
+		},
+		{
+			name:          "get secret unmasked - long notes called out in warning",
+			args:          json.RawMessage(`{"uid":"test-uid","unmask":true}`),
+			serverOptions: &ServerOptions{},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("GetSecret", "test-uid", []string{}, false).Return(map[string]interface{}{
+					"title": "Runbook",
+					"notes": strings.Repeat("n", 500),
+				}, nil).Once()
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "confirmation_required", resultMap["status"])
+				details := resultMap["confirmation_details"].(map[string]interface{})
+				promptArgs := details["prompt_arguments"].(map[string]interface{})
+				assert.Contains(t, promptArgs["warning_message"].(string), "notes (500 characters")
+			},
+		},
 		{
 			name:          "get secret unmasked - batch mode",
 			args:          json.RawMessage(`{"uid":"test-uid-batch","unmask":true}`),
@@ -440,6 +824,25 @@ func TestExecuteUpdateSecret(t *testing.T) {
 				assert.Equal(t, "Secret updated successfully (confirmed).", resultMap["message"])
 			},
 		},
+		{
+			name:          "batch mode - configured multi-value field keeps all values",
+			args:          json.RawMessage(`{"uid":"test-uid","fields":[{"type":"url","value":["https://a.example.com","https://b.example.com"]},{"type":"login","value":["first","second"]}]}`),
+			serverOptions: &ServerOptions{BatchMode: true, MultiValueFieldTypes: []string{"url"}},
+			expectError:   false,
+			mockSetup: func(client *mockKSMClient, confirmer *mockConfirmer) {
+				client.On("UpdateSecret", mock.MatchedBy(func(p types.UpdateSecretParams) bool {
+					values := map[string][]interface{}{}
+					for _, f := range p.Fields {
+						values[f.Type] = f.Value
+					}
+					return len(values["url"]) == 2 && len(values["login"]) == 1
+				})).Return(nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "test-uid", resultMap["uid"])
+			},
+		},
 		{
 			name:          "update - confirmation path",
 			args:          json.RawMessage(`{"uid":"test-uid-conf","title":"Confirm Update"}`),
@@ -555,18 +958,19 @@ func TestExecuteDeleteSecret(t *testing.T) {
 // Test Search operation
 func TestExecuteSearchSecrets(t *testing.T) {
 	tests := []struct {
-		name        string
-		args        json.RawMessage
-		expectError bool
-		mockSetup   func(*mockKSMClient)
-		validate    func(*testing.T, interface{})
+		name          string
+		args          json.RawMessage
+		serverOptions *ServerOptions
+		expectError   bool
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
 	}{
 		{
 			name:        "successful search",
 			args:        json.RawMessage(`{"query":"password"}`),
 			expectError: false,
 			mockSetup: func(client *mockKSMClient) {
-				client.On("SearchSecrets", "password").Return([]*types.SecretMetadata{
+				client.On("SearchSecrets", "password", []string(nil)).Return([]*types.SecretMetadata{
 					{UID: "uid1", Title: "Admin Password", Type: "password"},
 					{UID: "uid2", Title: "DB Password", Type: "password"},
 				}, nil)
@@ -584,42 +988,106 @@ func TestExecuteSearchSecrets(t *testing.T) {
 			args:        json.RawMessage(`{"query":"nonexistent"}`),
 			expectError: false,
 			mockSetup: func(client *mockKSMClient) {
-				client.On("SearchSecrets", "nonexistent").Return([]*types.SecretMetadata{}, nil)
+				client.On("SearchSecrets", "nonexistent", []string(nil)).Return([]*types.SecretMetadata{}, nil)
 			},
 			validate: func(t *testing.T, result interface{}) {
 				resultMap := result.(map[string]interface{})
 				assert.Equal(t, 0, resultMap["count"])
+				assert.NotContains(t, resultMap, "status")
 			},
 		},
 		{
-			name:        "search error",
-			args:        json.RawMessage(`{"query":"test"}`),
-			expectError: true,
+			name:          "empty search results - explicit empty_list mode",
+			args:          json.RawMessage(`{"query":"nonexistent"}`),
+			serverOptions: &ServerOptions{SearchEmptyResult: SearchEmptyResultList},
 			mockSetup: func(client *mockKSMClient) {
-				client.On("SearchSecrets", "test").Return(nil, errors.New("search failed"))
+				client.On("SearchSecrets", "nonexistent", []string(nil)).Return([]*types.SecretMetadata{}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, 0, resultMap["count"])
+				assert.Empty(t, resultMap["results"])
+				assert.NotContains(t, resultMap, "error")
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			mockClient := new(mockKSMClient)
-			logger, _ := audit.NewLogger(audit.Config{
-				FilePath: "/tmp/test-audit.log",
-			})
-			server := &Server{
-				logger: logger,
-			}
-
-			tt.mockSetup(mockClient)
-
-			result, err := server.executeSearchSecrets(mockClient, tt.args)
-
-			if tt.expectError {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-				if tt.validate != nil {
+		{
+			name:          "empty search results - not_found mode",
+			args:          json.RawMessage(`{"query":"nonexistent"}`),
+			serverOptions: &ServerOptions{SearchEmptyResult: SearchEmptyResultNotFound},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("SearchSecrets", "nonexistent", []string(nil)).Return([]*types.SecretMetadata{}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "not_found", resultMap["status"])
+				assert.Equal(t, "NOT_FOUND", resultMap["error"].(types.SafeError).Code)
+				assert.Equal(t, 0, resultMap["count"])
+			},
+		},
+		{
+			name:          "matches unaffected by not_found mode",
+			args:          json.RawMessage(`{"query":"db"}`),
+			serverOptions: &ServerOptions{SearchEmptyResult: SearchEmptyResultNotFound},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("SearchSecrets", "db", []string(nil)).Return([]*types.SecretMetadata{
+					{UID: "uid2", Title: "DB Password", Type: "password"},
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, 1, resultMap["count"])
+				assert.NotContains(t, resultMap, "status")
+			},
+		},
+		{
+			name: "scoped to folders",
+			args: json.RawMessage(`{"query":"db","folder_uids":["folder-1","folder-2"]}`),
+			mockSetup: func(client *mockKSMClient) {
+				client.On("SearchSecrets", "db", []string{"folder-1", "folder-2"}).Return([]*types.SecretMetadata{
+					{UID: "uid2", Title: "DB Password", Type: "password", Folder: "folder-2"},
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, 1, resultMap["count"])
+				results := resultMap["results"].([]map[string]interface{})
+				assert.Equal(t, "folder-2", results[0]["folder"])
+			},
+		},
+		{
+			name:        "search error",
+			args:        json.RawMessage(`{"query":"test"}`),
+			expectError: true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("SearchSecrets", "test", []string(nil)).Return(nil, errors.New("search failed"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			logger, _ := audit.NewLogger(audit.Config{
+				FilePath: "/tmp/test-audit.log",
+			})
+			options := tt.serverOptions
+			if options == nil {
+				options = &ServerOptions{}
+			}
+			server := &Server{
+				logger:  logger,
+				options: options,
+			}
+
+			tt.mockSetup(mockClient)
+
+			result, err := server.executeSearchSecrets(mockClient, tt.args)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
 					tt.validate(t, result)
 				}
 			}
@@ -629,6 +1097,48 @@ func TestExecuteSearchSecrets(t *testing.T) {
 	}
 }
 
+func TestExecuteFindSecretFuzzy(t *testing.T) {
+	matches := []types.FuzzyMatch{
+		{UID: "uid-1", Title: "Prod Database", Type: "databaseCredentials", Score: 0.8},
+		{UID: "uid-2", Title: "Prod Databases (old)", Type: "databaseCredentials", Score: 0.62},
+	}
+
+	t.Run("default limit", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindSecretsFuzzy", "prdo databse", defaultFuzzyMatches).Return(matches, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindSecretFuzzy(mockClient, json.RawMessage(`{"query":"prdo databse"}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 2, resultMap["count"])
+		assert.Equal(t, matches, resultMap["matches"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("explicit limit and no matches", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindSecretsFuzzy", "zzz", 1).Return([]types.FuzzyMatch{}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindSecretFuzzy(mockClient, json.RawMessage(`{"query":"zzz","limit":1}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 0, resultMap["count"])
+		assert.Contains(t, resultMap["message"], "No secret title is close to the query")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("limit out of range", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeFindSecretFuzzy(mockClient, json.RawMessage(`{"query":"db","limit":500}`))
+		assert.EqualError(t, err, "limit must be between 1 and 50")
+		mockClient.AssertNotCalled(t, "FindSecretsFuzzy", mock.Anything, mock.Anything)
+	})
+}
+
 // Test ksm_execute_confirmed_action tool
 func TestExecuteKsmExecuteConfirmedAction(t *testing.T) {
 	tests := []struct {
@@ -644,7 +1154,7 @@ func TestExecuteKsmExecuteConfirmedAction(t *testing.T) {
 			name: "approve create_secret",
 			args: json.RawMessage(`{
 				"original_tool_name": "create_secret",
-				"original_tool_args_json": "{\"type\":\"login\",\"title\":\"Confirmed Secret\", \"folder_uid\":\"mock_folder_uid\"}",
+				"original_tool_args_json": "{\"type\":\"login\",\"title\":\"Confirmed Secret\", \"folder_uid\":\"mock_folder_uid\", \"fields\":[{\"type\":\"login\",\"value\":[\"user\"]}]}",
 				"user_decision": true
 			}`),
 			expectError: false,
@@ -691,6 +1201,30 @@ func TestExecuteKsmExecuteConfirmedAction(t *testing.T) {
 				assert.Equal(t, "secret_value", resultMap["password"])
 			},
 		},
+		{
+			name: "approve update_secrets with an empty uid",
+			args: json.RawMessage(`{
+				"original_tool_name": "update_secrets",
+				"original_tool_args_json": "{\"updates\":[{\"uid\":\"\",\"title\":\"x\"}]}",
+				"user_decision": true
+			}`),
+			expectError:     true,
+			expectedMessage: "updates[0].uid must not be empty",
+		},
+		{
+			name: "approve update_secrets with a complex value",
+			args: json.RawMessage(`{
+				"original_tool_name": "update_secrets",
+				"original_tool_args_json": "{\"updates\":[{\"uid\":\"uid-1\",\"fields\":[{\"type\":\"name\",\"value\":[{\"first\":\"Ada\"}]}]}]}",
+				"user_decision": true
+			}`),
+			expectError: false,
+			mockClientSetup: func(client *mockKSMClient) {
+				client.On("UpdateSecret", mock.MatchedBy(func(p types.UpdateSecretParams) bool {
+					return p.UID == "uid-1" && len(p.Fields) == 1 && p.Fields[0].Type == "name"
+				})).Return(nil)
+			},
+		},
 		{
 			name: "unknown original_tool_name",
 			args: json.RawMessage(`{
@@ -720,7 +1254,18 @@ func TestExecuteKsmExecuteConfirmedAction(t *testing.T) {
 				tt.mockClientSetup(mockClient)
 			}
 
-			result, err := server.executeKsmExecuteConfirmedAction(tt.args)
+			// Issue the confirmation the client is answering
+			var call map[string]interface{}
+			require.NoError(t, json.Unmarshal(tt.args, &call))
+			toolName, _ := call["original_tool_name"].(string)
+			argsJSON, _ := call["original_tool_args_json"].(string)
+			server.pendingConfirmations = map[string]pendingConfirmation{
+				"conf-test": {toolName: toolName, fingerprint: confirmationFingerprint(toolName, argsJSON), issuedAt: time.Now()},
+			}
+			call["confirmation_id"] = "conf-test"
+			args, _ := json.Marshal(call)
+
+			result, err := server.executeKsmExecuteConfirmedAction(args)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -766,7 +1311,9 @@ func TestExecuteGetRecordTypeSchema(t *testing.T) {
 	parseErrs := recordtemplates.GetParseErrors()
 	assert.Empty(t, parseErrs, "There should be no template parsing errors for embedded files")
 
-	mockClient := new(mockKSMClient) // Not strictly used by GetRecordTypeSchema but part of server signature
+	// The client is only asked for records to synthesize a schema from; it has none
+	mockClient := new(mockKSMClient)
+	mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{}, nil)
 	logger, _ := audit.NewLogger(audit.Config{FilePath: "/tmp/test-schema-audit.log"})
 	server := &Server{
 		logger:  logger,
@@ -873,3 +1420,4416 @@ func TestExecuteGetRecordTypeSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteGetRecordTypeSchemaUnmappedType(t *testing.T) {
+	assert.NoError(t, recordtemplates.LoadRecordTemplates())
+
+	layout := &types.FullRecordTemplate{
+		ID: "gizmoWidget",
+		Fields: []types.RecordTemplateField{
+			{Ref: "login"},
+			{Ref: "host"},
+			{Ref: "widgetCode", Label: "Unit Code"},
+		},
+		Custom: []types.RecordTemplateField{{Ref: "text", Label: "Env"}},
+	}
+
+	t.Run("synthesized from a record of the type and cached", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{
+			{UID: "login-uid", Type: "login"},
+			{UID: "widget-uid", Type: "gizmoWidget"},
+		}, nil).Once()
+		mockClient.On("RecordLayout", "widget-uid").Return(layout, nil).Once()
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetRecordTypeSchema(mockClient, json.RawMessage(`{"type":"gizmoWidget"}`))
+		assert.NoError(t, err)
+		schema := result.(*types.RecordTypeSchema)
+		assert.Equal(t, "gizmoWidget", schema.RecordType)
+		var names []string
+		for _, field := range schema.Fields {
+			names = append(names, field.Name)
+			assert.False(t, field.Required, "synthesized field %s should not be required", field.Name)
+		}
+		assert.Equal(t, []string{"login", "host.hostName", "host.port", "Unit Code", "custom.Env"}, names)
+		assert.Equal(t, "widgetCode", schema.Fields[3].Type)
+
+		// The second lookup is served from the cache
+		again, err := server.executeGetRecordTypeSchema(mockClient, json.RawMessage(`{"type":"gizmoWidget"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, schema, again)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("cached per profile", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("RecordLayout", "relay-prod").Return(&types.FullRecordTemplate{
+			ID:     "relayHub",
+			Fields: []types.RecordTemplateField{{Ref: "url"}},
+		}, nil).Once()
+		mockClient.On("RecordLayout", "relay-dev").Return(&types.FullRecordTemplate{
+			ID:     "relayHub",
+			Fields: []types.RecordTemplateField{{Ref: "login"}},
+		}, nil).Once()
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		server.currentProfile = "prod"
+		prod, err := server.executeGetRecordTypeSchema(mockClient, json.RawMessage(`{"type":"relayHub","uid":"relay-prod"}`))
+		require.NoError(t, err)
+		server.currentProfile = "dev"
+		dev, err := server.executeGetRecordTypeSchema(mockClient, json.RawMessage(`{"type":"relayHub","uid":"relay-dev"}`))
+		require.NoError(t, err)
+
+		assert.Equal(t, "url", prod.(*types.RecordTypeSchema).Fields[0].Name)
+		assert.Equal(t, "login", dev.(*types.RecordTypeSchema).Fields[0].Name, "another profile's schema is not reused")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("synthesized from the given record", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("RecordLayout", "sensor-uid").Return(&types.FullRecordTemplate{
+			ID:     "sensorProbe",
+			Fields: []types.RecordTemplateField{{Ref: "url"}},
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetRecordTypeSchema(mockClient, json.RawMessage(`{"type":"sensorProbe","uid":"sensor-uid"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "url", result.(*types.RecordTypeSchema).Fields[0].Name)
+		mockClient.AssertNotCalled(t, "ListSecrets", []string(nil))
+	})
+
+	t.Run("given record of another type", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("RecordLayout", "login-uid").Return(&types.FullRecordTemplate{ID: "login"}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeGetRecordTypeSchema(mockClient, json.RawMessage(`{"type":"relayNode","uid":"login-uid"}`))
+		assert.ErrorContains(t, err, "record login-uid is of type 'login'")
+	})
+
+	t.Run("synthesis disabled", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{UnknownRecordTypeSchema: UnknownTypeError}, mockClient)
+
+		_, err := server.executeGetRecordTypeSchema(mockClient, json.RawMessage(`{"type":"beaconUnit"}`))
+		assert.ErrorContains(t, err, "record template not found for ID: beaconUnit")
+		mockClient.AssertNotCalled(t, "ListSecrets", []string(nil))
+	})
+}
+
+// newHandlerTestServer builds a server wired to the given mock client
+func newHandlerTestServer(options *ServerOptions, client *mockKSMClient) *Server {
+	logger, _ := audit.NewLogger(audit.Config{FilePath: "/tmp/test-audit.log"})
+	server := &Server{
+		confirmer: new(mockConfirmer),
+		logger:    logger,
+		options:   options,
+	}
+	server.getCurrentClient = func() (KSMClient, error) { return client, nil }
+	return server
+}
+
+func TestExecuteEmptyFolder(t *testing.T) {
+	folders := &types.ListFoldersResponse{Folders: []types.FolderInfo{
+		{UID: "root-folder", Name: "Prod"},
+		{UID: "child-folder", Name: "DB", ParentUID: "root-folder"},
+		{UID: "grandchild-folder", Name: "Replicas", ParentUID: "child-folder"},
+		{UID: "other-folder", Name: "Dev"},
+	}}
+	secrets := []*types.SecretMetadata{
+		{UID: "secret-1", Title: "Primary", Folder: "root-folder"},
+		{UID: "secret-2", Title: "Replica", Folder: "grandchild-folder"},
+	}
+	folderFilter := []string{"root-folder", "child-folder", "grandchild-folder"}
+
+	tests := []struct {
+		name          string
+		args          json.RawMessage
+		serverOptions *ServerOptions
+		expectError   bool
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
+	}{
+		{
+			name:          "confirmation shows counts",
+			args:          json.RawMessage(`{"folder_uid":"root-folder"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+				client.On("ListSecrets", folderFilter).Return(secrets, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "confirmation_required", resultMap["status"])
+				assert.Contains(t, resultMap["message"].(string), "deleting 2 secret(s) and 2 subfolder(s)")
+			},
+		},
+		{
+			name:          "batch mode deletes contents deepest first",
+			args:          json.RawMessage(`{"folder_uid":"root-folder"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+				client.On("ListSecrets", folderFilter).Return(secrets, nil)
+				client.On("DeleteSecret", "secret-1", true).Return(nil)
+				client.On("DeleteSecret", "secret-2", true).Return(errors.New("access denied"))
+				client.On("DeleteFolder", "grandchild-folder", false).Return(nil)
+				client.On("DeleteFolder", "child-folder", false).Return(nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, 3, resultMap["deleted"])
+				assert.Equal(t, 1, resultMap["failed"])
+				results := resultMap["results"].([]map[string]interface{})
+				assert.Len(t, results, 4)
+				assert.Equal(t, "grandchild-folder", results[2]["uid"])
+				assert.Equal(t, "child-folder", results[3]["uid"])
+				assert.Equal(t, false, results[1]["success"])
+			},
+		},
+		{
+			name:          "already empty folder",
+			args:          json.RawMessage(`{"folder_uid":"other-folder"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+				client.On("ListSecrets", []string{"other-folder"}).Return([]*types.SecretMetadata{}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Contains(t, resultMap["message"].(string), "already empty")
+			},
+		},
+		{
+			name:          "unknown folder",
+			args:          json.RawMessage(`{"folder_uid":"missing-folder"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+			},
+		},
+		{
+			name:          "missing folder uid",
+			args:          json.RawMessage(`{}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.serverOptions, mockClient)
+
+			result, err := server.executeEmptyFolder(mockClient, tt.args)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteGeneratePasswordPolicy(t *testing.T) {
+	strictPolicy := &validation.PasswordPolicy{MinLength: 24, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSpecial: true}
+	compliant := "Abcdefgh1!Abcdefgh1!Abcd"
+
+	tests := []struct {
+		name          string
+		args          json.RawMessage
+		serverOptions *ServerOptions
+		expectError   string
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
+	}{
+		{
+			name:          "strict policy regenerates until compliant",
+			args:          json.RawMessage(`{"length":24}`),
+			serverOptions: &ServerOptions{PasswordPolicy: strictPolicy},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GeneratePassword", types.GeneratePasswordParams{Length: 24}).Return("abcdefghabcdefghabcdefgh", nil).Once()
+				client.On("GeneratePassword", types.GeneratePasswordParams{Length: 24}).Return(compliant, nil).Once()
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, compliant, resultMap["password"])
+				assert.Equal(t, types.PasswordComposition{
+					Length: 24, Lowercase: 17, Uppercase: 3, Digits: 2, Special: 2, EntropyBits: 153,
+				}, resultMap["composition"])
+				assert.Contains(t, resultMap["warning"], "exposed to AI model")
+			},
+		},
+		{
+			name:          "strict policy fails after configured attempts",
+			args:          json.RawMessage(`{"length":24}`),
+			serverOptions: &ServerOptions{PasswordPolicy: strictPolicy, PasswordGenerationAttempts: 3},
+			expectError:   "after 3 attempts",
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GeneratePassword", types.GeneratePasswordParams{Length: 24}).Return("abcdefghabcdefghabcdefgh", nil).Times(3)
+			},
+		},
+		{
+			name:          "requested length below policy minimum",
+			args:          json.RawMessage(`{"length":16}`),
+			serverOptions: &ServerOptions{PasswordPolicy: strictPolicy},
+			expectError:   "below the password policy minimum of 24",
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+		{
+			name:          "non-compliant password is never saved",
+			args:          json.RawMessage(`{"length":24,"save_to_secret":"DB","folder_uid":"folder-uid"}`),
+			serverOptions: &ServerOptions{PasswordPolicy: strictPolicy, PasswordGenerationAttempts: 2},
+			expectError:   "did not meet the password policy",
+			mockSetup: func(client *mockKSMClient) {
+				client.On("IsSharedFolder", "folder-uid").Return(true, nil)
+				client.On("GeneratePassword", mock.Anything).Return("short", nil).Times(2)
+			},
+		},
+		{
+			name:          "folder outside the shared folders is rejected before generating",
+			args:          json.RawMessage(`{"length":24,"save_to_secret":"DB","folder_uid":"nested-uid"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   "folder_uid 'nested-uid' is not a shared folder or a subfolder inside one",
+			mockSetup: func(client *mockKSMClient) {
+				client.On("IsSharedFolder", "nested-uid").Return(false, nil)
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "shared-uid", Name: "Team"},
+					{UID: "sub-uid", Name: "Prod", ParentUID: "shared-uid"},
+					{UID: "nested-uid", Name: "Old", ParentUID: "hidden-uid"},
+				}}, nil)
+			},
+		},
+		{
+			name:          "nested subfolder of a shared folder can be saved to",
+			args:          json.RawMessage(`{"length":24,"save_to_secret":"DB","folder_uid":"nested-uid"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("IsSharedFolder", "nested-uid").Return(false, nil)
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "shared-uid", Name: "Team"},
+					{UID: "sub-uid", Name: "Prod", ParentUID: "shared-uid"},
+					{UID: "nested-uid", Name: "Old", ParentUID: "sub-uid"},
+				}}, nil)
+				client.On("GeneratePassword", types.GeneratePasswordParams{Length: 24, SaveToSecret: "DB", FolderUID: "nested-uid"}).Return(compliant, nil).Once()
+				client.On("CreateSecret", mock.MatchedBy(func(params types.CreateSecretParams) bool {
+					return params.FolderUID == "nested-uid"
+				})).Return("new-uid", nil)
+			},
+		},
+		{
+			name:          "inaccessible folder is rejected before generating",
+			args:          json.RawMessage(`{"length":24,"save_to_secret":"DB","folder_uid":"missing-uid"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   "not accessible to this application",
+			mockSetup: func(client *mockKSMClient) {
+				client.On("IsSharedFolder", "missing-uid").Return(false, ksm.ErrFolderNotFound)
+			},
+		},
+		{
+			name:          "direct subfolder of a shared folder can be saved to",
+			args:          json.RawMessage(`{"length":24,"save_to_secret":"DB","folder_uid":"sub-uid"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("IsSharedFolder", "sub-uid").Return(false, nil)
+				client.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+					{UID: "shared-uid", Name: "Team"},
+					{UID: "sub-uid", Name: "Prod", ParentUID: "shared-uid"},
+				}}, nil)
+				client.On("GeneratePassword", types.GeneratePasswordParams{Length: 24, SaveToSecret: "DB", FolderUID: "sub-uid"}).Return(compliant, nil).Once()
+				client.On("CreateSecret", mock.MatchedBy(func(params types.CreateSecretParams) bool {
+					return params.FolderUID == "sub-uid" && params.Title == "DB"
+				})).Return("new-uid", nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "new-uid", resultMap["uid"])
+				assert.NotContains(t, resultMap, "password")
+			},
+		},
+		{
+			name:          "returned password is not held to the default policy",
+			args:          json.RawMessage(`{"length":10}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GeneratePassword", types.GeneratePasswordParams{Length: 10}).Return("abcdefghij", nil).Once()
+			},
+			validate: func(t *testing.T, result interface{}) {
+				assert.Equal(t, "abcdefghij", result.(map[string]interface{})["password"])
+			},
+		},
+		{
+			name:          "saved password is held to the default policy",
+			args:          json.RawMessage(`{"length":10,"save_to_secret":"DB","folder_uid":"folder-uid"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   "below the password policy minimum of 12",
+			mockSetup: func(client *mockKSMClient) {
+				client.On("IsSharedFolder", "folder-uid").Return(true, nil)
+			},
+		},
+		{
+			name:          "default policy accepts strong password",
+			args:          json.RawMessage(`{}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GeneratePassword", types.GeneratePasswordParams{Length: ksm.DefaultPasswordLength}).Return(compliant, nil).Once()
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, len(compliant), resultMap["length"])
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.serverOptions, mockClient)
+
+			result, err := server.executeGeneratePassword(mockClient, tt.args)
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteGetAllSecretsUnmaskedOutputFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        json.RawMessage
+		expectError bool
+		expectNote  bool
+	}{
+		{name: "default json", args: json.RawMessage(`{}`)},
+		{name: "ndjson falls back to array on stdio", args: json.RawMessage(`{"output_format":"ndjson"}`), expectNote: true},
+		{name: "invalid format", args: json.RawMessage(`{"output_format":"csv"}`), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			if !tt.expectError {
+				mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{{UID: "uid-1", Title: "One"}}, nil)
+				mockClient.On("GetSecret", "uid-1", []string(nil), true).Return(map[string]interface{}{"uid": "uid-1", "password": "pw"}, nil)
+			}
+			server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+			result, err := server.executeGetAllSecretsUnmasked(mockClient, tt.args)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			assert.Equal(t, "json", resultMap["output_format"])
+			assert.Len(t, resultMap["secrets"], 1)
+			_, hasNote := resultMap["output_format_note"]
+			assert.Equal(t, tt.expectNote, hasNote)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteGetAllSecretsUnmaskedRecordLimit(t *testing.T) {
+	threeRecords := []*types.SecretMetadata{{UID: "uid-1"}, {UID: "uid-2"}, {UID: "uid-3"}}
+
+	t.Run("refuses vault over the cap before confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string(nil)).Return(threeRecords, nil)
+		server := newHandlerTestServer(&ServerOptions{MaxUnmaskedRecords: 2}, mockClient)
+
+		_, err := server.executeGetAllSecretsUnmasked(mockClient, json.RawMessage(`{}`))
+		assert.EqualError(t, err, "vault contains 3 records, more than the get_all_secrets_unmasked limit of 2; pass folder_uid to unmask one folder at a time")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("refuses folder over the cap in batch mode", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string{"folder-1"}).Return(threeRecords, nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true, MaxUnmaskedRecords: 2}, mockClient)
+
+		_, err := server.executeGetAllSecretsUnmasked(mockClient, json.RawMessage(`{"folder_uid":"folder-1"}`))
+		assert.ErrorContains(t, err, "folder folder-1 contains 3 records, more than the get_all_secrets_unmasked limit of 2")
+		mockClient.AssertNotCalled(t, "GetSecret", mock.Anything, mock.Anything, mock.Anything)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("confirmation shows the cap", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string(nil)).Return(threeRecords, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetAllSecretsUnmasked(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		assert.Contains(t, resultMap["message"], "(3 records, limit 100)")
+		promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+		assert.Contains(t, promptArgs["warning_message"], "At most 100 records can be unmasked in one call.")
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteGetAllSecretsUnmaskedErrorSanitized(t *testing.T) {
+	const leakedToken = "eyJhbGciOiJIUzI1NiJ9dGhpc2lzYXNlY3JldA"
+	mockClient := new(mockKSMClient)
+	mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{
+		{UID: "uid-ok", Title: "Fine"},
+		{UID: "uid-bad", Title: "Broken"},
+		{UID: "uid-card", Title: "Card Note"},
+	}, nil)
+	mockClient.On("GetSecret", "uid-ok", []string(nil), true).Return(map[string]interface{}{"uid": "uid-ok"}, nil)
+	mockClient.On("GetSecret", "uid-bad", []string(nil), true).Return(nil,
+		fmt.Errorf("decode failed for password=Hunter2!Secret with token %s", leakedToken))
+	mockClient.On("GetSecret", "uid-card", []string(nil), true).Return(nil,
+		errors.New("unexpected value 4111 1111 1111 1111 in field"))
+	server := newHandlerTestServer(&ServerOptions{
+		BatchMode:         true,
+		RedactionPatterns: []*regexp.Regexp{regexp.MustCompile(cardNumberRedactionPattern)},
+	}, mockClient)
+
+	result, err := server.executeGetAllSecretsUnmasked(mockClient, json.RawMessage(`{}`))
+	assert.NoError(t, err)
+	secrets := result.(map[string]interface{})["secrets"].([]map[string]interface{})
+	assert.Len(t, secrets, 3)
+
+	badEntry := secrets[1]["error"].(string)
+	assert.Equal(t, "uid-bad", secrets[1]["uid"])
+	assert.Contains(t, badEntry, "Failed to retrieve: decode failed for password=[REDACTED")
+	assert.NotContains(t, badEntry, "Hunter2!Secret")
+	assert.NotContains(t, badEntry, leakedToken)
+
+	cardEntry := secrets[2]["error"].(string)
+	assert.NotContains(t, cardEntry, "4111 1111 1111 1111")
+	assert.Contains(t, cardEntry, "[REDACTED")
+	mockClient.AssertExpectations(t)
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	secret := func() map[string]interface{} {
+		return map[string]interface{}{
+			"uid":   "test-uid",
+			"notes": "line one\r\nline two\rline three",
+			"keyPair": map[string]interface{}{
+				"publicKey": "-----BEGIN PUBLIC KEY-----\r\nMIIBIjAN\r\n-----END PUBLIC KEY-----",
+			},
+			"url": []interface{}{"https://example.com"},
+		}
+	}
+
+	t.Run("get_secret normalizes masked and unmasked results", func(t *testing.T) {
+		for _, unmask := range []bool{false, true} {
+			mockClient := new(mockKSMClient)
+			mockClient.On("GetSecret", "test-uid", []string(nil), unmask).Return(secret(), nil)
+			server := newHandlerTestServer(&ServerOptions{BatchMode: true, NormalizeLineEndings: true}, mockClient)
+
+			result, err := server.executeGetSecret(mockClient, json.RawMessage(fmt.Sprintf(`{"uid":"test-uid","unmask":%t}`, unmask)))
+			assert.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			assert.Equal(t, "line one\nline two\nline three", resultMap["notes"])
+			assert.Equal(t, "-----BEGIN PUBLIC KEY-----\nMIIBIjAN\n-----END PUBLIC KEY-----", resultMap["keyPair"].(map[string]interface{})["publicKey"])
+			assert.Equal(t, []interface{}{"https://example.com"}, resultMap["url"])
+			mockClient.AssertExpectations(t)
+		}
+	})
+
+	t.Run("get_field normalizes the value", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetField", "test-uid/field/note", false).Return("a\r\nb", nil)
+		server := newHandlerTestServer(&ServerOptions{NormalizeLineEndings: true}, mockClient)
+
+		result, err := server.executeGetField(mockClient, json.RawMessage(`{"notation":"test-uid/field/note"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "a\nb", result.(map[string]interface{})["value"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "test-uid", []string(nil), false).Return(secret(), nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"test-uid"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "line one\r\nline two\rline three", result.(map[string]interface{})["notes"])
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestFilesArray(t *testing.T) {
+	files := []map[string]interface{}{{"name": "cert.pem", "title": "Certificate", "size": 1200, "type": "application/x-pem-file"}}
+	withFiles := func() map[string]interface{} {
+		return map[string]interface{}{"uid": "test-uid", "title": "TLS", "files": files}
+	}
+	withoutFiles := func() map[string]interface{} {
+		return map[string]interface{}{"uid": "test-uid", "title": "TLS"}
+	}
+
+	tests := []struct {
+		name       string
+		mode       string
+		secret     map[string]interface{}
+		wantFiles  interface{}
+		wantListed bool
+	}{
+		{name: "default lists existing files", mode: "", secret: withFiles(), wantFiles: files, wantListed: true},
+		{name: "default leaves out an empty list", mode: "", secret: withoutFiles()},
+		{name: "omit drops files", mode: FilesArrayOmit, secret: withFiles()},
+		{name: "always keeps existing files", mode: FilesArrayAlways, secret: withFiles(), wantFiles: files, wantListed: true},
+		{name: "always adds an empty list", mode: FilesArrayAlways, secret: withoutFiles(), wantFiles: []map[string]interface{}{}, wantListed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			mockClient.On("GetSecret", "test-uid", []string(nil), false).Return(tt.secret, nil)
+			server := newHandlerTestServer(&ServerOptions{FilesArray: tt.mode}, mockClient)
+
+			result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"test-uid"}`))
+			require.NoError(t, err)
+			got, listed := result.(map[string]interface{})["files"]
+			assert.Equal(t, tt.wantListed, listed)
+			assert.Equal(t, tt.wantFiles, got)
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	t.Run("unmasked results follow the setting", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "test-uid", []string(nil), true).Return(withFiles(), nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true, FilesArray: FilesArrayOmit}, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"test-uid","unmask":true}`))
+		require.NoError(t, err)
+		assert.NotContains(t, result, "files")
+	})
+
+	t.Run("always does not add files to requested fields", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "test-uid", []string{"login"}, false).Return(map[string]interface{}{"uid": "test-uid", "login": "admin"}, nil)
+		server := newHandlerTestServer(&ServerOptions{FilesArray: FilesArrayAlways}, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"test-uid","fields":["login"]}`))
+		require.NoError(t, err)
+		assert.NotContains(t, result, "files")
+	})
+
+	t.Run("omit keeps attachments available to download_all_files", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "test-uid", []string(nil), false).Return(withFiles(), nil)
+		mockClient.On("DownloadFile", "test-uid", mock.Anything, mock.Anything).Return(nil)
+		server := newHandlerTestServer(&ServerOptions{FilesArray: FilesArrayOmit}, mockClient)
+
+		_, err := server.executeDownloadAllFilesConfirmed(mockClient, json.RawMessage(`{"uid":"test-uid"}`))
+		assert.NotContains(t, fmt.Sprint(err), "has no file attachments")
+		mockClient.AssertCalled(t, "DownloadFile", "test-uid", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSanitizeErrorMessageKeepsUIDs(t *testing.T) {
+	const leakedToken = "eyJhbGciOiJIUzI1NiJ9dGhpc2lzYXNlY3JldA"
+	tests := []struct {
+		name       string
+		err        error
+		keep       []string
+		wantMasked string
+	}{
+		{
+			name: "notation",
+			err:  errors.New("failed to resolve keeper://AbCdEfGhIjKlMnOpQrStUv/field/password: field not found"),
+			keep: []string{"keeper://AbCdEfGhIjKlMnOpQrStUv/field/password"},
+		},
+		{
+			name: "24-character UID",
+			err:  errors.New("record AbCdEfGhIjKlMnOpQrStUvWx not found"),
+			keep: []string{"AbCdEfGhIjKlMnOpQrStUvWx"},
+		},
+		{
+			name:       "token longer than any UID",
+			err:        fmt.Errorf("record AbCdEfGhIjKlMnOpQrStUvWx rejected token %s", leakedToken),
+			keep:       []string{"AbCdEfGhIjKlMnOpQrStUvWx"},
+			wantMasked: leakedToken,
+		},
+	}
+
+	server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := server.sanitizeErrorMessage(tt.err)
+			for _, kept := range tt.keep {
+				assert.Contains(t, message, kept)
+			}
+			if tt.wantMasked != "" {
+				assert.NotContains(t, message, tt.wantMasked)
+				assert.Contains(t, message, "[REDACTED")
+			}
+		})
+	}
+}
+
+func TestExecuteRenameSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          json.RawMessage
+		serverOptions *ServerOptions
+		expectError   bool
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
+	}{
+		{
+			name:          "confirmation path shows old and new title",
+			args:          json.RawMessage(`{"uid":"test-uid","new_title":"prod/db primary"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string{}, false).Return(map[string]interface{}{"title": "Old DB"}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "confirmation_required", resultMap["status"])
+				assert.Contains(t, resultMap["message"].(string), "'Old DB' (UID: test-uid) to 'prod/db primary'")
+			},
+		},
+		{
+			name:          "batch mode renames directly",
+			args:          json.RawMessage(`{"uid":"test-uid","new_title":"New DB"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("RenameSecret", "test-uid", "New DB").Return(nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "New DB", resultMap["title"])
+			},
+		},
+		{
+			name:          "invalid title rejected before confirmation",
+			args:          json.RawMessage(`{"uid":"test-uid","new_title":"<script>alert(1)</script>"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+		{
+			name:          "empty title rejected",
+			args:          json.RawMessage(`{"uid":"test-uid","new_title":""}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.serverOptions, mockClient)
+
+			result, err := server.executeRenameSecret(mockClient, tt.args)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteSessionInfo(t *testing.T) {
+	folders := &types.ListFoldersResponse{Folders: []types.FolderInfo{
+		{UID: "folder-1", Name: "Prod"},
+		{UID: "folder-2", Name: "Dev"},
+	}}
+	secrets := []*types.SecretMetadata{
+		{UID: "secret-1", Title: "DB", Folder: "folder-1"},
+		{UID: "secret-2", Title: "API", Folder: "folder-1"},
+		{UID: "secret-3", Title: "Cache", Folder: "folder-2"},
+	}
+
+	tests := []struct {
+		name          string
+		serverOptions *ServerOptions
+		expectError   bool
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
+	}{
+		{
+			name:          "interactive session reports counts",
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+				client.On("ListSecrets", []string(nil)).Return(secrets, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "test-profile", resultMap["profile"])
+				assert.Equal(t, false, resultMap["read_only"])
+				assert.Equal(t, "interactive", resultMap["confirmation_policy"])
+				assert.Equal(t, true, resultMap["confirmation_required"])
+				assert.Equal(t, 2, resultMap["folder_count"])
+				assert.Equal(t, 3, resultMap["record_count"])
+			},
+		},
+		{
+			name:          "auto approve reported",
+			serverOptions: &ServerOptions{BatchMode: true, AutoApprove: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(&types.ListFoldersResponse{}, nil)
+				client.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "auto_approve", resultMap["confirmation_policy"])
+				assert.Equal(t, false, resultMap["confirmation_required"])
+				assert.Equal(t, 0, resultMap["record_count"])
+			},
+		},
+		{
+			name:          "folder listing failure",
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(nil, errors.New("network error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.serverOptions, mockClient)
+			server.currentProfile = "test-profile"
+
+			result, err := server.executeSessionInfo(mockClient, json.RawMessage(`{}`))
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteValidateRecord(t *testing.T) {
+	assert.NoError(t, recordtemplates.LoadRecordTemplates())
+
+	tests := []struct {
+		name        string
+		args        json.RawMessage
+		expectError bool
+		mockSetup   func(*mockKSMClient)
+		validate    func(*testing.T, interface{})
+	}{
+		{
+			name: "valid login record",
+			args: json.RawMessage(`{"uid":"login-uid"}`),
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "login-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":      "login-uid",
+					"title":    "Mail",
+					"type":     "login",
+					"login":    "user@example.com",
+					"password": "pas***123",
+					"url":      "https://mail.example.com",
+					"notes":    "primary mailbox",
+				}, nil)
+				client.On("RecordMissingRequiredFields", "login-uid", mock.Anything).Return([]string(nil), nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, true, resultMap["valid"])
+				assert.Empty(t, resultMap["missing_required_fields"])
+				assert.Empty(t, resultMap["unexpected_fields"])
+				assert.Empty(t, resultMap["type_mismatches"])
+			},
+		},
+		{
+			name: "bank account missing required field",
+			args: json.RawMessage(`{"uid":"bank-uid"}`),
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "bank-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":   "bank-uid",
+					"title": "Checking",
+					"type":  "bankAccount",
+					"login": "jdoe",
+				}, nil)
+				client.On("RecordMissingRequiredFields", "bank-uid", mock.Anything).Return([]string{"bankAccount.accountType", "bankAccount.accountNumber"}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, false, resultMap["valid"])
+				assert.Contains(t, resultMap["missing_required_fields"], "bankAccount.accountType")
+				assert.Contains(t, resultMap["missing_required_fields"], "bankAccount.accountNumber")
+			},
+		},
+		{
+			name: "unexpected field and type mismatch reported without values",
+			args: json.RawMessage(`{"uid":"login-uid"}`),
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "login-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":      "login-uid",
+					"title":    "Mail",
+					"type":     "login",
+					"password": map[string]interface{}{"cardNumber": "411***111"},
+					"host":     map[string]interface{}{"hostName": "db.internal"},
+				}, nil)
+				client.On("RecordMissingRequiredFields", "login-uid", mock.Anything).Return([]string(nil), nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, false, resultMap["valid"])
+				assert.Equal(t, []string{"host"}, resultMap["unexpected_fields"])
+				mismatches := resultMap["type_mismatches"].([]map[string]interface{})
+				assert.Len(t, mismatches, 1)
+				assert.Equal(t, "password", mismatches[0]["field"])
+				assert.Equal(t, "single value", mismatches[0]["expected"])
+				assert.Equal(t, "object", mismatches[0]["actual"])
+				assert.NotContains(t, fmt.Sprintf("%v", result), "411***111")
+			},
+		},
+		{
+			name: "record type without a template checked against its own layout",
+			args: json.RawMessage(`{"uid":"odd-uid"}`),
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "odd-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":   "odd-uid",
+					"type":  "notARealType",
+					"login": "svc-widget",
+					"host":  map[string]interface{}{"hostName": "10.0.0.5", "port": "8443"},
+				}, nil)
+				client.On("RecordLayout", "odd-uid").Return(&types.FullRecordTemplate{
+					ID:     "notARealType",
+					Fields: []types.RecordTemplateField{{Ref: "login"}, {Ref: "host"}},
+				}, nil)
+				client.On("RecordMissingRequiredFields", "odd-uid", mock.Anything).Return([]string(nil), nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, true, resultMap["valid"])
+				assert.Empty(t, resultMap["unexpected_fields"])
+				assert.Empty(t, resultMap["type_mismatches"])
+			},
+		},
+		{
+			name:        "record type without a template and an unreadable layout",
+			args:        json.RawMessage(`{"uid":"odd-uid"}`),
+			expectError: true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "odd-uid", []string(nil), false).Return(map[string]interface{}{
+					"uid":  "odd-uid",
+					"type": "anotherUnmappedType",
+				}, nil)
+				client.On("RecordLayout", "odd-uid").Return(nil, ksm.ErrAccessDenied)
+			},
+		},
+		{
+			name:        "missing uid",
+			args:        json.RawMessage(`{}`),
+			expectError: true,
+			mockSetup:   func(client *mockKSMClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+			result, err := server.executeValidateRecord(mockClient, tt.args)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteGetSecretPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        json.RawMessage
+		expectError bool
+		mockSetup   func(*mockKSMClient)
+		validate    func(*testing.T, interface{})
+	}{
+		{
+			name: "record in nested folder",
+			args: json.RawMessage(`{"uid":"test-uid"}`),
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecretPath", "test-uid").Return("Engineering / Prod / DB", nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "Engineering / Prod / DB", resultMap["path"])
+				assert.Equal(t, true, resultMap["in_folder"])
+			},
+		},
+		{
+			name: "record at root or shared directly",
+			args: json.RawMessage(`{"uid":"test-uid"}`),
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecretPath", "test-uid").Return("", nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "", resultMap["path"])
+				assert.Equal(t, false, resultMap["in_folder"])
+				assert.Contains(t, resultMap["message"], "not inside an accessible folder")
+			},
+		},
+		{
+			name:        "lookup error",
+			args:        json.RawMessage(`{"uid":"missing-uid"}`),
+			expectError: true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecretPath", "missing-uid").Return("", errors.New("secret not found"))
+			},
+		},
+		{
+			name:        "missing uid",
+			args:        json.RawMessage(`{}`),
+			expectError: true,
+			mockSetup:   func(client *mockKSMClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+			result, err := server.executeGetSecretPath(mockClient, tt.args)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteGetSecretSafe(t *testing.T) {
+	safeSecret := func() map[string]interface{} {
+		return map[string]interface{}{
+			"uid":            "NJ_xXSkk3xYI1h9ql5lAiQ",
+			"title":          "Prod DB",
+			"type":           "login",
+			"login":          "admin",
+			"notes":          "rotated monthly",
+			"omitted_fields": []string{"password"},
+		}
+	}
+
+	t.Run("returns the safe fields", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretSafe", "NJ_xXSkk3xYI1h9ql5lAiQ").Return(safeSecret(), nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetSecretSafe(mockClient, json.RawMessage(`{"uid":"NJ_xXSkk3xYI1h9ql5lAiQ"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, safeSecret(), result)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("masked notes are omitted", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretSafe", "NJ_xXSkk3xYI1h9ql5lAiQ").Return(safeSecret(), nil)
+		server := newHandlerTestServer(&ServerOptions{MaskNotes: true}, mockClient)
+
+		result, err := server.executeGetSecretSafe(mockClient, json.RawMessage(`{"uid":"NJ_xXSkk3xYI1h9ql5lAiQ"}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.NotContains(t, resultMap, "notes")
+		assert.Equal(t, []string{"password", "notes"}, resultMap["omitted_fields"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("unknown record", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretSafe", "NJ_xXSkk3xYI1h9ql5lAiQ").Return(nil, ksm.ErrSecretNotFound)
+		mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeGetSecretSafe(mockClient, json.RawMessage(`{"uid":"NJ_xXSkk3xYI1h9ql5lAiQ"}`))
+		var toolErr *ToolError
+		assert.True(t, errors.As(err, &toolErr))
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteSecretFingerprint(t *testing.T) {
+	t.Run("returns the fingerprint", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("Fingerprint", "NJ_xXSkk3xYI1h9ql5lAiQ").Return("9f2c", nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeSecretFingerprint(mockClient, json.RawMessage(`{"uid":"NJ_xXSkk3xYI1h9ql5lAiQ"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"uid":         "NJ_xXSkk3xYI1h9ql5lAiQ",
+			"fingerprint": "9f2c",
+			"algorithm":   "HMAC-SHA256",
+		}, result)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("unknown record", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("Fingerprint", "NJ_xXSkk3xYI1h9ql5lAiQ").Return("", ksm.ErrSecretNotFound)
+		mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeSecretFingerprint(mockClient, json.RawMessage(`{"uid":"NJ_xXSkk3xYI1h9ql5lAiQ"}`))
+		var toolErr *ToolError
+		assert.True(t, errors.As(err, &toolErr))
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("missing uid", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeSecretFingerprint(mockClient, json.RawMessage(`{}`))
+		assert.EqualError(t, err, "uid parameter is required for secret_fingerprint")
+		mockClient.AssertNotCalled(t, "Fingerprint", mock.Anything)
+	})
+}
+
+func TestExecuteUpdateSecrets(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          json.RawMessage
+		serverOptions *ServerOptions
+		expectError   bool
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
+	}{
+		{
+			name:          "single confirmation covers every update",
+			args:          json.RawMessage(`{"updates":[{"uid":"uid-1","title":"A"},{"uid":"uid-2","notes":"n"}]}`),
+			serverOptions: &ServerOptions{},
+			mockSetup:     func(client *mockKSMClient) {},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "confirmation_required", resultMap["status"])
+				assert.Contains(t, resultMap["message"].(string), "Update 2 KSM secret(s)")
+				details := resultMap["confirmation_details"].(map[string]interface{})
+				promptArgs := details["prompt_arguments"].(map[string]interface{})
+				assert.Equal(t, "update_secrets", promptArgs["original_tool_name"])
+				assert.Contains(t, promptArgs["warning_message"].(string), "uid-1, uid-2")
+			},
+		},
+		{
+			name:          "batch mode applies updates with per-uid results",
+			args:          json.RawMessage(`{"updates":[{"uid":"uid-1","fields":[{"type":"login","value":["alice","extra"]}]},{"uid":"uid-2","title":"Renamed"}]}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("UpdateSecret", types.UpdateSecretParams{
+					UID:    "uid-1",
+					Fields: []types.SecretField{{Type: "login", Value: []interface{}{"alice"}}},
+				}).Return(nil)
+				client.On("UpdateSecret", types.UpdateSecretParams{
+					UID:    "uid-2",
+					Title:  "Renamed",
+					Fields: []types.SecretField{},
+				}).Return(errors.New("record locked"))
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, 1, resultMap["updated"])
+				assert.Equal(t, 1, resultMap["failed"])
+				results := resultMap["results"].([]map[string]interface{})
+				assert.Equal(t, true, results[0]["success"])
+				assert.Equal(t, false, results[1]["success"])
+				assert.Equal(t, "record locked", results[1]["error"])
+				warnings := resultMap["warnings"].([]string)
+				assert.Len(t, warnings, 1)
+				assert.True(t, strings.HasPrefix(warnings[0], "uid-1: "))
+			},
+		},
+		{
+			name:          "duplicate uid rejected",
+			args:          json.RawMessage(`{"updates":[{"uid":"uid-1"},{"uid":"uid-1"}]}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.serverOptions, mockClient)
+
+			result, err := server.executeUpdateSecrets(mockClient, tt.args)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteDeleteFolderDefaultForce(t *testing.T) {
+	folders := &types.ListFoldersResponse{Folders: []types.FolderInfo{{UID: "folder-uid", Name: "Old Projects"}}}
+
+	tests := []struct {
+		name          string
+		args          json.RawMessage
+		serverOptions *ServerOptions
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
+	}{
+		{
+			name:          "shipped default keeps force false when omitted",
+			args:          json.RawMessage(`{"folder_uid":"folder-uid"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				promptArgs := result.(map[string]interface{})["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+				assert.Contains(t, promptArgs["warning_message"].(string), "must be empty")
+				assert.Contains(t, promptArgs["warning_message"].(string), "server default (force: false)")
+				assert.JSONEq(t, `{"folder_uid":"folder-uid","force":false}`, promptArgs["original_tool_args_json"].(string))
+			},
+		},
+		{
+			name:          "configured default force applies when omitted",
+			args:          json.RawMessage(`{"folder_uid":"folder-uid"}`),
+			serverOptions: &ServerOptions{DefaultFolderDeleteForce: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				promptArgs := result.(map[string]interface{})["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+				assert.Contains(t, promptArgs["warning_message"].(string), "ALL ITS CONTENTS")
+				assert.Contains(t, promptArgs["warning_message"].(string), "server default (force: true)")
+				assert.JSONEq(t, `{"folder_uid":"folder-uid","force":true}`, promptArgs["original_tool_args_json"].(string))
+			},
+		},
+		{
+			name:          "explicit force overrides configured default",
+			args:          json.RawMessage(`{"folder_uid":"folder-uid","force":false}`),
+			serverOptions: &ServerOptions{DefaultFolderDeleteForce: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				promptArgs := result.(map[string]interface{})["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+				assert.Contains(t, promptArgs["warning_message"].(string), "must be empty")
+				assert.NotContains(t, promptArgs["warning_message"].(string), "server default")
+			},
+		},
+		{
+			name:          "batch mode deletes with configured default",
+			args:          json.RawMessage(`{"folder_uid":"folder-uid"}`),
+			serverOptions: &ServerOptions{BatchMode: true, DefaultFolderDeleteForce: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+				client.On("DeleteFolder", "folder-uid", true).Return(nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				assert.Equal(t, "folder-uid", result.(map[string]interface{})["folder_uid"])
+			},
+		},
+		{
+			name:          "batch mode deletes with shipped default",
+			args:          json.RawMessage(`{"folder_uid":"folder-uid"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+				client.On("DeleteFolder", "folder-uid", false).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.serverOptions, mockClient)
+
+			result, err := server.executeDeleteFolder(mockClient, tt.args)
+			assert.NoError(t, err)
+			if tt.validate != nil {
+				tt.validate(t, result)
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteSetupTOTP(t *testing.T) {
+	isTOTPUpdate := func(uid string) interface{} {
+		return mock.MatchedBy(func(params types.UpdateSecretParams) bool {
+			if params.UID != uid || len(params.Fields) != 1 || params.Fields[0].Type != "oneTimeCode" {
+				return false
+			}
+			uri, ok := params.Fields[0].Value[0].(string)
+			return ok && strings.HasPrefix(uri, "otpauth://totp/")
+		})
+	}
+	// expectStored makes the seed written by UpdateSecret what reading it back returns
+	expectStored := func(client *mockKSMClient, update interface{}) {
+		readBack := client.On("GetField", "test-uid/field/oneTimeCode", true)
+		client.On("UpdateSecret", update).Return(nil).Run(func(args mock.Arguments) {
+			readBack.Return([]interface{}{args.Get(0).(types.UpdateSecretParams).Fields[0].Value[0]}, nil)
+		})
+	}
+
+	tests := []struct {
+		name          string
+		args          json.RawMessage
+		serverOptions *ServerOptions
+		expectError   bool
+		mockSetup     func(*mockKSMClient)
+		validate      func(*testing.T, interface{})
+	}{
+		{
+			name:          "confirmation path pins resolved account without a seed",
+			args:          json.RawMessage(`{"uid":"test-uid"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{
+					"title": "Mail",
+					"login": "alice@example.com",
+				}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "confirmation_required", resultMap["status"])
+				promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+				assert.JSONEq(t, `{"uid":"test-uid","issuer":"Keeper","account":"alice@example.com"}`, promptArgs["original_tool_args_json"].(string))
+				assert.NotContains(t, fmt.Sprintf("%v", result), "otpauth://")
+			},
+		},
+		{
+			name:          "batch mode stores seed and keeps it masked",
+			args:          json.RawMessage(`{"uid":"test-uid","issuer":"Acme"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{"title": "Mail"}, nil)
+				expectStored(client, isTOTPUpdate("test-uid"))
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "Acme", resultMap["issuer"])
+				assert.Equal(t, "Mail", resultMap["account"])
+				assert.NotContains(t, resultMap, "seed")
+				assert.NotContains(t, resultMap, "provisioning_uri")
+				assert.Contains(t, resultMap["note"], "get_secret and unmask: true")
+			},
+		},
+		{
+			name:          "unmask returns provisioning uri",
+			args:          json.RawMessage(`{"uid":"test-uid","account":"ops","unmask":true}`),
+			serverOptions: &ServerOptions{AutoApprove: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{"title": "Mail"}, nil)
+				expectStored(client, isTOTPUpdate("test-uid"))
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				uri := resultMap["provisioning_uri"].(string)
+				assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Keeper:ops?"))
+				assert.Contains(t, uri, "secret="+resultMap["seed"].(string))
+			},
+		},
+		{
+			name:          "bare secret is normalized into a uri with defaults",
+			args:          json.RawMessage(`{"uid":"test-uid","secret":"jbsw y3dp ehpk 3pxp","unmask":true}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{
+					"title": "Mail",
+					"login": "alice@example.com",
+				}, nil)
+				expectStored(client, isTOTPUpdate("test-uid"))
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "JBSWY3DPEHPK3PXP", resultMap["seed"])
+				assert.Equal(t, "otpauth://totp/Keeper:alice@example.com?algorithm=SHA1&digits=6&issuer=Keeper&period=30&secret=JBSWY3DPEHPK3PXP", resultMap["provisioning_uri"])
+			},
+		},
+		{
+			name:          "otpauth uri is stored as given",
+			args:          json.RawMessage(`{"uid":"test-uid","uri":"otpauth://totp/GitHub:bob?secret=JBSWY3DPEHPK3PXP&issuer=GitHub&digits=8"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{"title": "GitHub"}, nil)
+				expectStored(client, mock.MatchedBy(func(params types.UpdateSecretParams) bool {
+					return len(params.Fields) == 1 && params.Fields[0].Value[0] == "otpauth://totp/GitHub:bob?secret=JBSWY3DPEHPK3PXP&issuer=GitHub&digits=8"
+				}))
+			},
+			validate: func(t *testing.T, result interface{}) {
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, "GitHub", resultMap["issuer"])
+				assert.Equal(t, "bob", resultMap["account"])
+				assert.NotContains(t, resultMap, "seed")
+			},
+		},
+		{
+			name:          "provided seed confirmation pins the uri's issuer and account",
+			args:          json.RawMessage(`{"uid":"test-uid","uri":"otpauth://totp/GitHub:bob?secret=JBSWY3DPEHPK3PXP"}`),
+			serverOptions: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{"title": "GitHub"}, nil)
+			},
+			validate: func(t *testing.T, result interface{}) {
+				promptArgs := result.(map[string]interface{})["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+				assert.Contains(t, promptArgs["action_description"], "Store the provided TOTP seed")
+				assert.JSONEq(t, `{"uid":"test-uid","uri":"otpauth://totp/GitHub:bob?secret=JBSWY3DPEHPK3PXP","issuer":"GitHub","account":"bob"}`, promptArgs["original_tool_args_json"].(string))
+			},
+		},
+		{
+			name:          "invalid base32 secret rejected before confirmation",
+			args:          json.RawMessage(`{"uid":"test-uid","secret":"not a seed!"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+		{
+			name:          "uri and secret together rejected",
+			args:          json.RawMessage(`{"uid":"test-uid","secret":"JBSWY3DPEHPK3PXP","uri":"otpauth://totp/a?secret=JBSWY3DPEHPK3PXP"}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+		{
+			name:          "digits without a secret rejected",
+			args:          json.RawMessage(`{"uid":"test-uid","digits":8}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+		{
+			name:          "store failure surfaces error",
+			args:          json.RawMessage(`{"uid":"test-uid"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			expectError:   true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{"title": "Mail"}, nil)
+				client.On("UpdateSecret", isTOTPUpdate("test-uid")).Return(errors.New("save failed"))
+			},
+		},
+		{
+			name:          "provided uri not kept by the vault is an error",
+			args:          json.RawMessage(`{"uid":"test-uid","uri":"otpauth://totp/GitHub:bob?secret=JBSWY3DPEHPK3PXP"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			expectError:   true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{"title": "GitHub"}, nil)
+				client.On("UpdateSecret", mock.Anything).Return(nil)
+				client.On("GetField", "test-uid/field/oneTimeCode", true).Return([]interface{}{"otpauth://totp/Old:bob?secret=GEZDGNBV"}, nil)
+			},
+		},
+		{
+			name:          "seed missing after saving is an error",
+			args:          json.RawMessage(`{"uid":"test-uid"}`),
+			serverOptions: &ServerOptions{BatchMode: true},
+			expectError:   true,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "test-uid", []string(nil), false).Return(map[string]interface{}{"title": "Mail"}, nil)
+				client.On("UpdateSecret", isTOTPUpdate("test-uid")).Return(nil)
+				client.On("GetField", "test-uid/field/oneTimeCode", true).Return(nil, errors.New("field not found"))
+			},
+		},
+		{
+			name:          "missing uid",
+			args:          json.RawMessage(`{}`),
+			serverOptions: &ServerOptions{},
+			expectError:   true,
+			mockSetup:     func(client *mockKSMClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.serverOptions, mockClient)
+
+			result, err := server.executeSetupTOTP(mockClient, tt.args)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				if tt.validate != nil {
+					tt.validate(t, result)
+				}
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessFieldsForSDKPaymentCardholder(t *testing.T) {
+	fields, warnings, err := processFieldsForSDK([]types.SecretField{
+		{Type: "paymentCard.cardNumber", Value: []interface{}{"4111111111111111"}},
+		{Type: "paymentCard.cardExpirationDate", Value: []interface{}{"12/2027"}},
+		{Type: "paymentCard.cardSecurityCode", Value: []interface{}{"123"}},
+		{Type: "paymentCard.cardholderName", Value: []interface{}{"Jane Q Public"}},
+	}, nil, fieldTrimming{})
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "paymentCard", fields[0].Type)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"cardNumber":         "4111111111111111",
+		"cardExpirationDate": "12/2027",
+		"cardSecurityCode":   "123",
+		"cardholderName":     "Jane Q Public",
+	}}, fields[0].Value)
+}
+
+func TestProcessFieldsForSDKMultiValueFieldTypes(t *testing.T) {
+	input := []types.SecretField{
+		{Type: "text", Value: []interface{}{"one", "two", "three"}},
+		{Type: "password", Value: []interface{}{"p1", "p2"}},
+	}
+
+	// Default policy keeps only the first value of simple fields
+	fields, warnings, err := processFieldsForSDK(input, nil, fieldTrimming{})
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 2)
+	assert.Equal(t, []interface{}{"one"}, fields[0].Value)
+	assert.Equal(t, []interface{}{"p1"}, fields[1].Value)
+
+	// A configured multi-value type retains every value; others are still trimmed
+	fields, warnings, err = processFieldsForSDK(input, []string{"text"}, fieldTrimming{})
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, []interface{}{"one", "two", "three"}, fields[0].Value)
+	assert.Equal(t, []interface{}{"p1"}, fields[1].Value)
+}
+
+func TestProcessFieldsForSDKSecurityQuestions(t *testing.T) {
+	t.Run("one value per question", func(t *testing.T) {
+		fields, warnings, err := processFieldsForSDK([]types.SecretField{
+			{Type: "securityQuestion.question", Value: []interface{}{"First pet?", "Favourite city?"}},
+			{Type: "securityQuestion.answer", Value: []interface{}{"Fluffy", "Lisbon"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+		require.Len(t, fields, 1)
+		assert.Equal(t, "securityQuestion", fields[0].Type)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"question": "First pet?", "answer": "Fluffy"},
+			map[string]interface{}{"question": "Favourite city?", "answer": "Lisbon"},
+		}, fields[0].Value)
+	})
+
+	t.Run("indexed sub-fields", func(t *testing.T) {
+		fields, _, err := processFieldsForSDK([]types.SecretField{
+			{Type: "securityQuestion[1].question", Value: []interface{}{"Favourite city?"}},
+			{Type: "securityQuestion[1].answer", Value: []interface{}{"Lisbon"}},
+			{Type: "securityQuestion[0].question", Value: []interface{}{"First pet?"}},
+			{Type: "securityQuestion[0].answer", Value: []interface{}{"Fluffy"}},
+			{Type: "login", Value: []interface{}{"jane"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		require.Len(t, fields, 2)
+		assert.Equal(t, "login", fields[0].Type)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"question": "First pet?", "answer": "Fluffy"},
+			map[string]interface{}{"question": "Favourite city?", "answer": "Lisbon"},
+		}, fields[1].Value)
+	})
+
+	t.Run("an instance keeps its index", func(t *testing.T) {
+		fields, _, err := processFieldsForSDK([]types.SecretField{
+			{Type: "securityQuestion[1].answer", Value: []interface{}{"Lisbon"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{nil, map[string]interface{}{"answer": "Lisbon"}}, fields[0].Value,
+			"updates leave instance 0 as stored")
+		assert.Equal(t, []interface{}{map[string]interface{}{"answer": "Lisbon"}}, compactFieldValues(fields)[0].Value,
+			"creates pack the given instances")
+	})
+
+	t.Run("single-instance types keep only the first value", func(t *testing.T) {
+		fields, _, err := processFieldsForSDK([]types.SecretField{
+			{Type: "host.hostName", Value: []interface{}{"a.example.com", "b.example.com"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{"hostName": "a.example.com"}}, fields[0].Value)
+	})
+}
+
+func TestProcessFieldsForSDKAppFillerMacroSteps(t *testing.T) {
+	t.Run("steps are joined into the sequence", func(t *testing.T) {
+		fields, warnings, err := processFieldsForSDK([]types.SecretField{
+			{Type: "appFiller.applicationTitle", Value: []interface{}{"VPN Client"}},
+			{Type: "appFiller.macroSteps", Value: []interface{}{`["{USERNAME}","{TAB}","{PASSWORD}","{ENTER}"]`}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"applicationTitle": "VPN Client",
+			"macroSequence":    "{USERNAME}{TAB}{PASSWORD}{ENTER}",
+		}}, fields[0].Value)
+	})
+
+	t.Run("string sequence is kept", func(t *testing.T) {
+		fields, _, err := processFieldsForSDK([]types.SecretField{
+			{Type: "appFiller.macroSequence", Value: []interface{}{"{USERNAME}{TAB}{PASSWORD}"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{"macroSequence": "{USERNAME}{TAB}{PASSWORD}"}}, fields[0].Value)
+	})
+
+	t.Run("steps that are not a JSON array fall back to the string", func(t *testing.T) {
+		fields, warnings, err := processFieldsForSDK([]types.SecretField{
+			{Type: "appFiller.macroSteps", Value: []interface{}{"{USERNAME}{TAB}"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		require.Len(t, warnings, 1)
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{"macroSequence": "{USERNAME}{TAB}"}}, fields[0].Value)
+	})
+}
+
+func TestProcessFieldsForSDKName(t *testing.T) {
+	t.Run("explicit middle name", func(t *testing.T) {
+		fields, warnings, err := processFieldsForSDK([]types.SecretField{
+			{Type: "name.first", Value: []interface{}{"John"}},
+			{Type: "name.middle", Value: []interface{}{"Quincy"}},
+			{Type: "name.last", Value: []interface{}{"Doe"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+		require.Len(t, fields, 1)
+		assert.Equal(t, "name", fields[0].Type)
+		assert.Equal(t, []interface{}{map[string]interface{}{"first": "John", "middle": "Quincy", "last": "Doe"}}, fields[0].Value)
+	})
+
+	t.Run("legacy firstName and lastName", func(t *testing.T) {
+		fields, warnings, err := processFieldsForSDK([]types.SecretField{
+			{Type: "name.firstName", Value: []interface{}{"Jane"}},
+			{Type: "name.lastName", Value: []interface{}{"Smith"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{"first": "Jane", "middle": "", "last": "Smith"}}, fields[0].Value)
+	})
+
+	t.Run("fullName is split with a deprecation warning", func(t *testing.T) {
+		fields, warnings, err := processFieldsForSDK([]types.SecretField{
+			{Type: "name.fullName", Value: []interface{}{"Mary Ann Lee"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "deprecated")
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{"first": "Mary", "middle": "Ann", "last": "Lee"}}, fields[0].Value)
+	})
+
+	t.Run("fullName is ignored alongside explicit parts", func(t *testing.T) {
+		fields, warnings, err := processFieldsForSDK([]types.SecretField{
+			{Type: "name.first", Value: []interface{}{"John"}},
+			{Type: "name.last", Value: []interface{}{"Doe"}},
+			{Type: "name.fullName", Value: []interface{}{"Johnny Doe"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		require.Len(t, warnings, 1)
+		require.Len(t, fields, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{"first": "John", "middle": "", "last": "Doe"}}, fields[0].Value)
+	})
+}
+
+func TestProcessFieldsForSDKTrimWhitespace(t *testing.T) {
+	input := []types.SecretField{
+		{Type: "login", Value: []interface{}{"  admin@example.com\n"}},
+		{Type: "password", Value: []interface{}{" pass phrase "}},
+		{Type: "host", Value: []interface{}{map[string]interface{}{"hostName": " db.example.com", "port": "5432 "}}},
+		{Type: "url", Value: []interface{}{"https://example.com"}},
+	}
+
+	// Trimming is off by default
+	fields, warnings, err := processFieldsForSDK(input, nil, fieldTrimming{})
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []interface{}{"  admin@example.com\n"}, fields[0].Value)
+
+	// Enabled trimming leaves sensitive fields alone and warns for each changed field
+	fields, warnings, err = processFieldsForSDK(input, nil, fieldTrimming{Enabled: true})
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 2)
+	assert.Equal(t, []interface{}{"admin@example.com"}, fields[0].Value)
+	assert.Equal(t, []interface{}{" pass phrase "}, fields[1].Value)
+	assert.Equal(t, []interface{}{map[string]interface{}{"hostName": "db.example.com", "port": "5432"}}, fields[2].Value)
+	assert.Equal(t, []interface{}{"https://example.com"}, fields[3].Value)
+	for _, warning := range warnings {
+		assert.NotContains(t, warning, "admin@example.com")
+	}
+
+	// Sensitive fields are trimmed only when explicitly opted in
+	fields, warnings, err = processFieldsForSDK(input, nil, fieldTrimming{Enabled: true, Sensitive: true})
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 3)
+	assert.Equal(t, []interface{}{"pass phrase"}, fields[1].Value)
+
+	// The caller's fields are not modified
+	assert.Equal(t, []interface{}{"  admin@example.com\n"}, input[0].Value)
+}
+
+func TestExecuteGetSecretLookupErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         json.RawMessage
+		options      *ServerOptions
+		mockSetup    func(*mockKSMClient)
+		expectedCode string
+	}{
+		{
+			name:    "unknown uid is NOT_FOUND",
+			args:    json.RawMessage(`{"uid":"missing-uid"}`),
+			options: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "missing-uid", []string(nil), false).Return(nil, ksm.ErrSecretNotFound)
+				client.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{{UID: "other-uid"}}, nil)
+			},
+			expectedCode: ErrorCodeNotFound,
+		},
+		{
+			name:    "listed but unreadable uid is ACCESS_DENIED",
+			args:    json.RawMessage(`{"uid":"shared-uid"}`),
+			options: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "shared-uid", []string(nil), false).Return(nil, ksm.ErrSecretNotFound)
+				client.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{{UID: "shared-uid"}}, nil)
+			},
+			expectedCode: ErrorCodeAccessDenied,
+		},
+		{
+			name:    "access_denied from Keeper is ACCESS_DENIED without cross-check",
+			args:    json.RawMessage(`{"uid":"locked-uid","unmask":true}`),
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetSecret", "locked-uid", []string(nil), true).Return(nil, fmt.Errorf("failed to get secret: %w: Error: access_denied", ksm.ErrAccessDenied))
+			},
+			expectedCode: ErrorCodeAccessDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.options, mockClient)
+
+			_, err := server.executeGetSecret(mockClient, tt.args)
+			assert.Error(t, err)
+
+			var toolErr *ToolError
+			if assert.True(t, errors.As(err, &toolErr)) {
+				assert.Equal(t, tt.expectedCode, toolErr.Code)
+			}
+			data, ok := toolErrorData(err).(types.SafeError)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expectedCode, data.Code)
+
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestToolErrorDataValidation(t *testing.T) {
+	mockClient := new(mockKSMClient)
+	server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+	// Unsafe titles are reported as UNSAFE_INPUT
+	_, err := server.executeRenameSecret(mockClient, json.RawMessage(`{"uid":"uid-1","new_title":"Mail; rm -rf /"}`))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, validation.ErrCommandInjection))
+	data, ok := toolErrorData(err).(types.SafeError)
+	require.True(t, ok)
+	assert.Equal(t, ErrorCodeUnsafeInput, data.Code)
+
+	// Other validation failures are INVALID_INPUT
+	_, err = server.executeRenameSecret(mockClient, json.RawMessage(fmt.Sprintf(`{"uid":"uid-1","new_title":%q}`, strings.Repeat("t", 300))))
+	require.Error(t, err)
+	data, ok = toolErrorData(err).(types.SafeError)
+	require.True(t, ok)
+	assert.Equal(t, ErrorCodeInvalidInput, data.Code)
+	assert.Contains(t, data.Message, "255 characters")
+
+	// Errors unrelated to validation carry no code
+	assert.Nil(t, toolErrorData(errors.New("boom")))
+}
+
+func TestExecuteListToolsDetailed(t *testing.T) {
+	findTool := func(t *testing.T, result interface{}, name string) map[string]interface{} {
+		for _, tool := range result.(map[string]interface{})["tools"].([]map[string]interface{}) {
+			if tool["name"] == name {
+				return tool
+			}
+		}
+		t.Fatalf("tool %s not listed", name)
+		return nil
+	}
+
+	t.Run("interactive mode", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeListToolsDetailed(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, len(server.getAvailableTools()), resultMap["count"])
+		assert.Equal(t, false, resultMap["confirmation_bypassed"])
+
+		deleteSecret := findTool(t, result, "delete_secret")
+		assert.Equal(t, true, deleteSecret["requires_confirmation"])
+		assert.Equal(t, true, deleteSecret["mutating"])
+		assert.Equal(t, false, deleteSecret["read_only"])
+		assert.NotNil(t, deleteSecret["input_schema"])
+
+		listSecrets := findTool(t, result, "list_secrets")
+		assert.Equal(t, false, listSecrets["requires_confirmation"])
+		assert.Equal(t, true, listSecrets["read_only"])
+
+		download := findTool(t, result, "download_file")
+		assert.Equal(t, true, download["requires_confirmation"])
+		assert.Equal(t, false, download["mutating"])
+	})
+
+	t.Run("batch mode bypasses confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeListToolsDetailed(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		assert.Equal(t, true, result.(map[string]interface{})["confirmation_bypassed"])
+		assert.Equal(t, false, findTool(t, result, "delete_secret")["requires_confirmation"])
+	})
+
+	// Every confirmable tool must be exposed
+	server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+	exposed := map[string]bool{}
+	for _, tool := range server.getAvailableTools() {
+		exposed[tool.Name] = true
+	}
+	for name := range server.confirmedActionHandlers() {
+		assert.True(t, exposed[name], "confirmed handler for unexposed tool %s", name)
+	}
+	for name := range mutatingTools {
+		assert.True(t, exposed[name], "mutating flag for unexposed tool %s", name)
+	}
+}
+
+func TestGeneratePasswordDefaultLength(t *testing.T) {
+	compliant := "Abcdefgh1!Abcdefgh1!Abcd"
+
+	lengthDescription := func(server *Server, toolName string) string {
+		for _, tool := range server.getAvailableTools() {
+			if tool.Name == toolName {
+				properties := tool.InputSchema["properties"].(map[string]interface{})
+				return properties["length"].(map[string]interface{})["description"].(string)
+			}
+		}
+		t.Fatalf("tool %s is not exposed", toolName)
+		return ""
+	}
+
+	t.Run("configured default is used when no length is requested", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GeneratePassword", types.GeneratePasswordParams{Length: 24}).Return(compliant, nil).Once()
+		server := newHandlerTestServer(&ServerOptions{DefaultPasswordLength: 24}, mockClient)
+
+		_, err := server.executeGeneratePassword(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("requested length overrides the configured default", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GeneratePassword", types.GeneratePasswordParams{Length: 40}).Return(compliant+"Abcdefgh1!Abcdef", nil).Once()
+		server := newHandlerTestServer(&ServerOptions{DefaultPasswordLength: 24}, mockClient)
+
+		_, err := server.executeGeneratePassword(mockClient, json.RawMessage(`{"length":40}`))
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("configured default below the policy minimum", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{
+			DefaultPasswordLength: 16,
+			PasswordPolicy:        &validation.PasswordPolicy{MinLength: 20},
+		}, mockClient)
+
+		_, err := server.executeGeneratePassword(mockClient, json.RawMessage(`{}`))
+		assert.ErrorContains(t, err, "requested password length 16 is below the password policy minimum of 20")
+		mockClient.AssertNotCalled(t, "GeneratePassword", mock.Anything)
+	})
+
+	t.Run("tool schemas describe the configured default", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{DefaultPasswordLength: 24}, new(mockKSMClient))
+		assert.Equal(t, "Password length (default: 24)", lengthDescription(server, "generate_password"))
+		assert.Equal(t, "Password length (default: 24)", lengthDescription(server, "rotate_passwords_matching"))
+
+		unconfigured := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+		assert.Equal(t, "Password length (default: 32)", lengthDescription(unconfigured, "generate_password"))
+	})
+}
+
+func TestEnumFieldValidation(t *testing.T) {
+	assert.NoError(t, recordtemplates.LoadRecordTemplates())
+
+	t.Run("database record with invalid type is rejected before confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		args := json.RawMessage(`{"type":"databaseCredentials","title":"Orders DB","folder_uid":"folder_abc","fields":[{"type":"text","value":["orders"]},{"type":"databaseType","value":["Cassandra"]}]}`)
+		_, err := server.executeCreateSecret(mockClient, args)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid value 'Cassandra' for field 'databaseType'")
+		assert.Contains(t, err.Error(), "PostgreSQL, MySQL")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("wifiEncryption has no declared values and is not checked", func(t *testing.T) {
+		fields, _, err := processFieldsForSDK([]types.SecretField{
+			{Type: "wifiEncryption", Value: []interface{}{"WPA2-Enterprise"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"WPA2-Enterprise"}, fields[0].Value)
+	})
+
+	t.Run("update with invalid database type is rejected", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		_, err := server.executeUpdateSecret(mockClient, json.RawMessage(`{"uid":"db-uid","fields":[{"type":"databaseType","value":["Cassandra"]}]}`))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "must be one of PostgreSQL")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("valid values are normalized to canonical spelling", func(t *testing.T) {
+		fields, _, err := processFieldsForSDK([]types.SecretField{
+			{Type: "databaseType", Value: []interface{}{"postgresql"}},
+			{Type: "directoryType", Value: []interface{}{"openldap"}},
+		}, nil, fieldTrimming{})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"PostgreSQL"}, fields[0].Value)
+		assert.Equal(t, []interface{}{"OpenLDAP"}, fields[1].Value)
+	})
+}
+
+func TestExecuteAuditPasswords(t *testing.T) {
+	t.Run("interactive mode requires confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeAuditPasswords(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("reports reused and weak passwords without values", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{
+			{UID: "uid-a", Title: "Mail"},
+			{UID: "uid-b", Title: "Forum"},
+			{UID: "uid-c", Title: "Bank"},
+			{UID: "uid-d", Title: "Wifi Note"},
+			{UID: "uid-e", Title: "Broken"},
+		}, nil)
+		mockClient.On("GetSecret", "uid-a", []string{"password"}, true).Return(map[string]interface{}{"password": "Shared!Passw0rd"}, nil)
+		mockClient.On("GetSecret", "uid-b", []string{"password"}, true).Return(map[string]interface{}{"password": "Shared!Passw0rd"}, nil)
+		mockClient.On("GetSecret", "uid-c", []string{"password"}, true).Return(map[string]interface{}{"password": "hunter2"}, nil)
+		mockClient.On("GetSecret", "uid-d", []string{"password"}, true).Return(map[string]interface{}{"title": "Wifi Note"}, nil)
+		mockClient.On("GetSecret", "uid-e", []string{"password"}, true).Return(nil, errors.New("decrypt failed"))
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeAuditPasswords(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+
+		assert.Equal(t, 5, resultMap["records_checked"])
+		assert.Equal(t, 3, resultMap["passwords_audited"])
+		assert.Equal(t, 1, resultMap["without_password"])
+		assert.Equal(t, 1, resultMap["weak_count"])
+		assert.Equal(t, 2, resultMap["reused_count"])
+
+		weak := resultMap["weak"].([]map[string]interface{})
+		assert.Equal(t, "uid-c", weak[0]["uid"])
+		reused := resultMap["reused"].([]map[string]interface{})
+		assert.Len(t, reused, 1)
+		assert.Equal(t, 2, reused[0]["count"])
+		assert.Len(t, resultMap["failed"], 1)
+
+		encoded, _ := json.Marshal(result)
+		assert.NotContains(t, string(encoded), "Shared!Passw0rd")
+		assert.NotContains(t, string(encoded), "hunter2")
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteFindByFieldValue(t *testing.T) {
+	t.Run("matches non-sensitive fields without confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string{"folder-1"}).Return([]*types.SecretMetadata{
+			{UID: "uid-a", Title: "DB Primary", Type: "databaseCredentials"},
+			{UID: "uid-b", Title: "DB Replica", Type: "databaseCredentials"},
+			{UID: "uid-c", Title: "Mail", Type: "login"},
+		}, nil)
+		mockClient.On("GetSecret", "uid-a", []string{"host"}, false).Return(map[string]interface{}{
+			"host": map[string]interface{}{"hostName": "DB.example.com", "port": "5432"},
+		}, nil)
+		mockClient.On("GetSecret", "uid-b", []string{"host"}, false).Return(map[string]interface{}{
+			"host": map[string]interface{}{"hostName": "replica.example.com", "port": "5432"},
+		}, nil)
+		mockClient.On("GetSecret", "uid-c", []string{"host"}, false).Return(map[string]interface{}{"title": "Mail"}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindByFieldValue(mockClient, json.RawMessage(`{"field_type":"host","value":"db.example.com ","folder_uid":"folder-1"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		matches := resultMap["matches"].([]*types.SecretMetadata)
+		require.Len(t, matches, 1)
+		assert.Equal(t, "uid-a", matches[0].UID)
+		assert.Equal(t, 3, resultMap["records_checked"])
+
+		encoded, _ := json.Marshal(result)
+		assert.NotContains(t, string(encoded), "replica.example.com")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("sensitive fields require confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindByFieldValue(mockClient, json.RawMessage(`{"field_type":"password","value":"hunter2"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "confirmation_required", result.(map[string]interface{})["status"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("confirmed sensitive match never returns values", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{
+			{UID: "uid-a", Title: "Mail"},
+			{UID: "uid-b", Title: "Bank"},
+		}, nil)
+		mockClient.On("GetSecret", "uid-a", []string{"password"}, true).Return(map[string]interface{}{"password": "hunter2"}, nil)
+		mockClient.On("GetSecret", "uid-b", []string{"password"}, true).Return(map[string]interface{}{"password": "Other!Pass1"}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindByFieldValueConfirmed(mockClient, json.RawMessage(`{"field_type":"password","value":"hunter2"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 1, resultMap["count"])
+
+		encoded, _ := json.Marshal(result)
+		assert.NotContains(t, string(encoded), "hunter2")
+		assert.NotContains(t, string(encoded), "Other!Pass1")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("requires field type and value", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeFindByFieldValue(mockClient, json.RawMessage(`{"field_type":"login"}`))
+		assert.Error(t, err)
+		_, err = server.executeFindByFieldValue(mockClient, json.RawMessage(`{"value":"admin"}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestMaxNotesLength(t *testing.T) {
+	longNotes := strings.Repeat("n", 12000)
+	createArgs, _ := json.Marshal(map[string]interface{}{"title": "Runbook", "folder_uid": "folder-1", "notes": longNotes})
+	updateArgs, _ := json.Marshal(map[string]interface{}{"uid": "uid-1", "notes": longNotes})
+
+	t.Run("notes are not limited by default", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeUpdateSecret(mockClient, updateArgs)
+		require.NoError(t, err)
+		assert.Equal(t, "confirmation_required", result.(map[string]interface{})["status"])
+	})
+
+	t.Run("configured limit rejects longer notes", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{MaxNotesLength: 10000}, mockClient)
+
+		_, err := server.executeCreateSecret(mockClient, createArgs)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot exceed 10000 characters")
+		_, err = server.executeUpdateSecret(mockClient, updateArgs)
+		assert.Error(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("configured limit is named in the error", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{MaxNotesLength: 5000}, mockClient)
+
+		_, err := server.executeUpdateSecrets(mockClient, json.RawMessage(fmt.Sprintf(`{"updates":[{"uid":"uid-1","notes":%q}]}`, longNotes)))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot exceed 5000 characters")
+	})
+}
+
+func TestExecuteVerifyTOTP(t *testing.T) {
+	t.Run("returns the verification result", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("VerifyTOTPCode", "uid-1", "123456").Return(&types.TOTPVerification{UID: "uid-1", Valid: true, Drift: -1}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeVerifyTOTP(mockClient, json.RawMessage(`{"uid":"uid-1","code":"123456"}`))
+		require.NoError(t, err)
+		verification := result.(*types.TOTPVerification)
+		assert.True(t, verification.Valid)
+		assert.Equal(t, -1, verification.Drift)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("record without TOTP is not found", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("VerifyTOTPCode", "uid-1", "123456").Return(nil, ksm.ErrNoTOTP)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeVerifyTOTP(mockClient, json.RawMessage(`{"uid":"uid-1","code":"123456"}`))
+		var toolErr *ToolError
+		require.True(t, errors.As(err, &toolErr))
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+	})
+
+	t.Run("requires uid and code", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeVerifyTOTP(mockClient, json.RawMessage(`{"uid":"uid-1"}`))
+		assert.Error(t, err)
+		mockClient.AssertNotCalled(t, "VerifyTOTPCode", mock.Anything, mock.Anything)
+	})
+}
+
+func TestConfirmationRequired(t *testing.T) {
+	warning := confirmationWarning{Verbose: "Long warning.", Concise: "Short.", ExposesToAI: true}
+
+	server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+	result := server.confirmationRequired("get_secret", "Reveal unmasked secret uid-1", warning, `{"uid":"uid-1"}`)
+	assert.Equal(t, "confirmation_required", result["status"])
+	assert.Equal(t, "Keeper Secrets Manager requires confirmation to Reveal unmasked secret uid-1. Use the 'ksm_confirm_action' prompt.", result["message"])
+	details := result["confirmation_details"].(map[string]interface{})
+	assert.Equal(t, "ksm_confirm_action", details["prompt_name"])
+	assert.Equal(t, map[string]interface{}{
+		"action_description":      "Reveal unmasked secret uid-1",
+		"warning_message":         "Long warning.",
+		"original_tool_name":      "get_secret",
+		"original_tool_args_json": `{"uid":"uid-1"}`,
+	}, details["prompt_arguments"])
+
+	concise := newHandlerTestServer(&ServerOptions{ConfirmationVerbosity: VerbosityConcise}, new(mockKSMClient))
+	result = concise.confirmationRequired("get_secret", "Reveal unmasked secret uid-1", warning, `{}`)
+	promptArgs := result["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+	assert.Equal(t, "Short. "+aiExposureWarning, promptArgs["warning_message"])
+}
+
+func TestCancelConfirmation(t *testing.T) {
+	mockClient := new(mockKSMClient)
+	mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{}, nil).Once()
+	server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+	requestConfirmation := func(t *testing.T) (string, string) {
+		result, err := server.executeAuditPasswords(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		tracked, err := server.trackConfirmation(result)
+		require.NoError(t, err)
+		details := tracked.(map[string]interface{})["confirmation_details"].(map[string]interface{})
+		promptArgs := details["prompt_arguments"].(map[string]interface{})
+		id := details["confirmation_id"].(string)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, id, promptArgs["confirmation_id"])
+		return id, promptArgs["original_tool_args_json"].(string)
+	}
+	execute := func(id, argsJSON string) (interface{}, error) {
+		args, _ := json.Marshal(map[string]interface{}{
+			"original_tool_name":      "audit_passwords",
+			"original_tool_args_json": argsJSON,
+			"user_decision":           true,
+			"confirmation_id":         id,
+		})
+		return server.executeKsmExecuteConfirmedAction(args)
+	}
+	assertStale := func(t *testing.T, err error) {
+		var toolErr *ToolError
+		if assert.True(t, errors.As(err, &toolErr)) {
+			assert.Equal(t, ErrorCodeStaleConfirmation, toolErr.Code)
+		}
+	}
+
+	// A cancelled confirmation is rejected with or without its ID
+	id, argsJSON := requestConfirmation(t)
+	result, err := server.executeCancelConfirmation(json.RawMessage(fmt.Sprintf(`{"confirmation_id":%q}`, id)))
+	assert.NoError(t, err)
+	assert.Equal(t, "cancelled", result.(map[string]interface{})["status"])
+	_, err = execute(id, argsJSON)
+	assertStale(t, err)
+	_, err = execute("", argsJSON)
+	assertStale(t, err)
+
+	// Requesting the action again issues a fresh confirmation that can be used once
+	id, argsJSON = requestConfirmation(t)
+	_, err = execute(id, argsJSON)
+	assert.NoError(t, err)
+	_, err = execute(id, argsJSON)
+	assertStale(t, err)
+
+	// Unknown IDs cannot be cancelled
+	_, err = server.executeCancelConfirmation(json.RawMessage(`{"confirmation_id":"conf-unknown"}`))
+	var toolErr *ToolError
+	if assert.True(t, errors.As(err, &toolErr)) {
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+	}
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestConfirmationPruning(t *testing.T) {
+	t.Run("expired confirmations are forgotten", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+		old := time.Now().Add(-confirmationTTL - time.Minute)
+		server.pendingConfirmations = map[string]pendingConfirmation{
+			"conf-old": {toolName: "delete_secret", fingerprint: "old", issuedAt: old},
+			"conf-new": {toolName: "delete_secret", fingerprint: "new", issuedAt: time.Now()},
+		}
+
+		server.pruneConfirmations(time.Now())
+		assert.NotContains(t, server.pendingConfirmations, "conf-old")
+		assert.Contains(t, server.pendingConfirmations, "conf-new")
+	})
+
+	t.Run("the oldest confirmations are dropped at the cap", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+		server.pendingConfirmations = make(map[string]pendingConfirmation)
+		start := time.Now().Add(-time.Minute)
+		for i := 0; i < maxTrackedConfirmations; i++ {
+			server.pendingConfirmations[fmt.Sprintf("conf-%d", i)] = pendingConfirmation{
+				fingerprint: fmt.Sprintf("action-%d", i),
+				issuedAt:    start.Add(time.Duration(i) * time.Millisecond),
+			}
+		}
+
+		server.pruneConfirmations(time.Now())
+		assert.Len(t, server.pendingConfirmations, maxTrackedConfirmations-1)
+		assert.NotContains(t, server.pendingConfirmations, "conf-0")
+	})
+
+	t.Run("a backend decision drops the action's confirmations", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("DeleteSecret", "uid-1", true).Return(nil)
+		backend := new(mockConfirmer)
+		backend.On("ConfirmOperation", mock.Anything, "delete_secret", mock.Anything, mock.Anything).Return(&ui.ConfirmationResult{Approved: true})
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		args := json.RawMessage(`{"uid":"uid-1"}`)
+		pending, err := server.executeDeleteSecret(mockClient, args)
+		require.NoError(t, err)
+		_, err = server.trackConfirmation(pending)
+		require.NoError(t, err)
+		require.Len(t, server.pendingConfirmations, 1)
+
+		server.options.ConfirmationBackend = backend
+		pending, err = server.executeDeleteSecret(mockClient, args)
+		require.NoError(t, err)
+		_, err = server.resolveWithBackend(pending)
+		require.NoError(t, err)
+		assert.Empty(t, server.pendingConfirmations)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestCancelledConfirmationCannotRun(t *testing.T) {
+	setup := func(t *testing.T) (*Server, string, string) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+		pending, err := server.executeDeleteSecret(mockClient, json.RawMessage(`{"uid":"uid-1"}`))
+		require.NoError(t, err)
+		tracked, err := server.trackConfirmation(pending)
+		require.NoError(t, err)
+		details := tracked.(map[string]interface{})["confirmation_details"].(map[string]interface{})
+		id := details["confirmation_id"].(string)
+		_, err = server.executeCancelConfirmation(json.RawMessage(fmt.Sprintf(`{"confirmation_id":%q}`, id)))
+		require.NoError(t, err)
+		return server, id, details["prompt_arguments"].(map[string]interface{})["original_tool_args_json"].(string)
+	}
+	execute := func(server *Server, id, argsJSON string) error {
+		args := map[string]interface{}{
+			"original_tool_name":      "delete_secret",
+			"original_tool_args_json": argsJSON,
+			"user_decision":           true,
+		}
+		if id != "" {
+			args["confirmation_id"] = id
+		}
+		raw, _ := json.Marshal(args)
+		_, err := server.executeKsmExecuteConfirmedAction(raw)
+		return err
+	}
+	assertStale := func(t *testing.T, err error) {
+		var toolErr *ToolError
+		require.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, ErrorCodeStaleConfirmation, toolErr.Code)
+	}
+
+	t.Run("resent without an ID and with an extra key", func(t *testing.T) {
+		server, _, _ := setup(t)
+		assertStale(t, execute(server, "", `{"uid":"uid-1","force":true,"note":"again"}`))
+	})
+
+	t.Run("after the confirmation TTL", func(t *testing.T) {
+		server, id, argsJSON := setup(t)
+		server.confirmationsMu.Lock()
+		server.pruneConfirmations(time.Now().Add(confirmationTTL))
+		server.confirmationsMu.Unlock()
+		assertStale(t, execute(server, "", argsJSON))
+		assertStale(t, execute(server, id, argsJSON))
+	})
+
+	t.Run("after more confirmations than the cap", func(t *testing.T) {
+		server, id, argsJSON := setup(t)
+		for i := 0; i <= maxTrackedConfirmations; i++ {
+			pending, err := server.executeDeleteSecret(new(mockKSMClient), json.RawMessage(fmt.Sprintf(`{"uid":"uid-%d"}`, i+2)))
+			require.NoError(t, err)
+			_, err = server.trackConfirmation(pending)
+			require.NoError(t, err)
+		}
+		assertStale(t, execute(server, "", argsJSON))
+		assertStale(t, execute(server, id, argsJSON))
+	})
+}
+
+func TestExecuteCopyField(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        string
+		options     *ServerOptions
+		mockSetup   func(*mockKSMClient)
+		wantErr     string
+		wantConfirm bool
+	}{
+		{
+			name:        "requires confirmation",
+			args:        `{"source_uid":"src","source_field":"password","target_uid":"dst"}`,
+			options:     &ServerOptions{},
+			wantConfirm: true,
+		},
+		{
+			name:    "copies without returning the value",
+			args:    `{"source_uid":"src","source_field":"password","target_uid":"dst"}`,
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetField", "src/field/password", true).Return("S3cr3t!Value", nil)
+				client.On("UpdateSecret", types.UpdateSecretParams{
+					UID:    "dst",
+					Fields: []types.SecretField{{Type: "password", Value: []interface{}{"S3cr3t!Value"}}},
+				}).Return(nil)
+				client.On("GetField", "dst/field/password", true).Return("S3cr3t!Value", nil)
+			},
+		},
+		{
+			name:    "copies into a different field",
+			args:    `{"source_uid":"src","source_field":"login","target_uid":"dst","target_field":"text"}`,
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetField", "src/field/login", true).Return([]interface{}{"svc-deploy"}, nil)
+				client.On("UpdateSecret", types.UpdateSecretParams{
+					UID:    "dst",
+					Fields: []types.SecretField{{Type: "text", Value: []interface{}{"svc-deploy"}}},
+				}).Return(nil)
+				client.On("GetField", "dst/field/text", true).Return([]interface{}{"svc-deploy"}, nil)
+			},
+		},
+		{
+			name:    "target that did not keep the value is an error",
+			args:    `{"source_uid":"src","source_field":"password","target_uid":"dst"}`,
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetField", "src/field/password", true).Return("S3cr3t!Value", nil)
+				client.On("UpdateSecret", mock.Anything).Return(nil)
+				client.On("GetField", "dst/field/password", true).Return(nil, errors.New("failed to get field: field not found"))
+			},
+			wantErr: "field 'password' was not copied: reading back field 'password' of secret dst failed: failed to get field: field not found",
+		},
+		{
+			name:    "rejects complex field values",
+			args:    `{"source_uid":"src","source_field":"host","target_uid":"dst"}`,
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetField", "src/field/host", true).Return(map[string]interface{}{"hostName": "db", "port": "5432"}, nil)
+			},
+			wantErr: "field 'host' of secret src does not hold a single text value and cannot be copied",
+		},
+		{
+			name:    "rejects empty source",
+			args:    `{"source_uid":"src","source_field":"password","target_uid":"dst"}`,
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("GetField", "src/field/password", true).Return("", nil)
+			},
+			wantErr: "field 'password' of secret src is empty; nothing to copy",
+		},
+		{
+			name:    "rejects copying a sensitive field into an unmasked one",
+			args:    `{"source_uid":"src","source_field":"password","target_uid":"dst","target_field":"text"}`,
+			options: &ServerOptions{BatchMode: true},
+			wantErr: "field 'password' is sensitive and cannot be copied into 'text', which is not masked when read",
+		},
+		{
+			name:    "refuses the unmasked target before asking for confirmation",
+			args:    `{"source_uid":"src","source_field":"password","target_uid":"dst","target_field":"text"}`,
+			options: &ServerOptions{},
+			wantErr: "field 'password' is sensitive and cannot be copied into 'text', which is not masked when read",
+		},
+		{
+			name:    "rejects copying a field onto itself",
+			args:    `{"source_uid":"src","source_field":"password","target_uid":"src"}`,
+			options: &ServerOptions{BatchMode: true},
+			wantErr: "source and target are the same field; nothing to copy",
+		},
+		{
+			name:    "requires source and target",
+			args:    `{"source_uid":"src","target_uid":"dst"}`,
+			options: &ServerOptions{},
+			wantErr: "source_uid, source_field and target_uid are required to copy a field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockClient)
+			}
+			server := newHandlerTestServer(tt.options, mockClient)
+
+			result, err := server.executeCopyField(mockClient, json.RawMessage(tt.args))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				mockClient.AssertExpectations(t)
+				return
+			}
+			assert.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			if tt.wantConfirm {
+				assert.Equal(t, "confirmation_required", resultMap["status"])
+				promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+				assert.Equal(t, "copy_field", promptArgs["original_tool_name"])
+				assert.Contains(t, promptArgs["action_description"], "'password' field of KSM secret (UID: src) to the 'password' field of KSM secret (UID: dst)")
+			} else {
+				encoded, _ := json.Marshal(resultMap)
+				assert.NotContains(t, string(encoded), "S3cr3t!Value")
+				assert.NotContains(t, string(encoded), "svc-deploy")
+				assert.Equal(t, "dst", resultMap["target_uid"])
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteLintVault(t *testing.T) {
+	t.Run("reports malformed records", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("LintSecrets", []string{"folder-1"}).Return(&types.LintResult{
+			RecordsChecked: 3,
+			Records: []types.RecordLintReport{{
+				UID:   "card-uid",
+				Title: "Corporate Card",
+				Type:  "bankCard",
+				Issues: []types.FieldLintIssue{
+					{Section: "fields", Index: 0, FieldType: "paymentCard", Problem: "value[0] is a string, expected an object"},
+					{Section: "custom", Index: 1, FieldType: "host", Label: "Gateway", Problem: "value is an object, expected an array"},
+				},
+			}},
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeLintVault(mockClient, json.RawMessage(`{"folder_uid":"folder-1"}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 3, resultMap["records_checked"])
+		assert.Equal(t, 1, resultMap["count"])
+		assert.Equal(t, 2, resultMap["issue_count"])
+		assert.Equal(t, "Checked 3 records; 1 have 2 malformed fields that field extraction skips", resultMap["message"])
+		records := resultMap["records"].([]types.RecordLintReport)
+		assert.Equal(t, "card-uid", records[0].UID)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("clean vault", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("LintSecrets", []string(nil)).Return(&types.LintResult{RecordsChecked: 2, Records: []types.RecordLintReport{}}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeLintVault(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 0, resultMap["count"])
+		assert.Equal(t, "Checked 2 records; all field structures match their types", resultMap["message"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("LintSecrets", []string(nil)).Return(nil, errors.New("failed to list secrets: network down"))
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeLintVault(mockClient, json.RawMessage(`{}`))
+		assert.EqualError(t, err, "failed to list secrets: network down")
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteMissingRequiredFields(t *testing.T) {
+	t.Run("reports incomplete records", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindMissingRequiredFields", []string{"folder-1"}).Return(&types.MissingFieldsResult{
+			RecordsChecked: 4,
+			Records: []types.MissingFieldsRecord{
+				{UID: "pam-uid", Title: "web-01", Type: "pamMachine", Missing: []string{"pamHostname.port"}},
+				{UID: "bank-uid", Title: "Payroll", Type: "bankAccount", Missing: []string{"bankAccount.routingNumber", "bankAccount.accountNumber"}},
+			},
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeMissingRequiredFields(mockClient, json.RawMessage(`{"folder_uid":"folder-1"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 4, resultMap["records_checked"])
+		assert.Equal(t, 2, resultMap["count"])
+		assert.Equal(t, 3, resultMap["missing_count"])
+		assert.Equal(t, "Checked 4 records; 2 are missing 3 required field values", resultMap["message"])
+		assert.NotContains(t, resultMap, "unknown_types")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("unknown types are skipped", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindMissingRequiredFields", []string(nil)).Return(&types.MissingFieldsResult{
+			RecordsChecked: 3,
+			Records:        []types.MissingFieldsRecord{},
+			UnknownTypes:   map[string]int{"legacyType": 2},
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeMissingRequiredFields(mockClient, json.RawMessage(`{}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 0, resultMap["count"])
+		assert.Equal(t, map[string]int{"legacyType": 2}, resultMap["unknown_types"])
+		assert.Contains(t, resultMap["message"], "2 records of types without a schema were skipped")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindMissingRequiredFields", []string(nil)).Return(nil, errors.New("failed to list secrets: network down"))
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeMissingRequiredFields(mockClient, json.RawMessage(`{}`))
+		assert.EqualError(t, err, "failed to list secrets: network down")
+	})
+}
+
+func TestExecuteFindDuplicates(t *testing.T) {
+	groups := []types.DuplicateGroup{{
+		Title: "Prod DB",
+		Type:  "login",
+		Count: 2,
+		Records: []types.DuplicateRecord{
+			{UID: "uid-1", Title: "Prod DB", FolderUID: "folder-1"},
+			{UID: "uid-2", Title: "prod db", FolderUID: "folder-2"},
+		},
+	}}
+
+	t.Run("non-sensitive key fields run directly", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindDuplicates", []string{"folder-1"}, []string{"login", "url"}).Return(&types.DuplicatesResult{
+			RecordsChecked: 5,
+			KeyFields:      []string{"login", "url"},
+			Groups:         groups,
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindDuplicates(mockClient, json.RawMessage(`{"folder_uid":"folder-1","key_fields":[" login ","url"]}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 1, resultMap["count"])
+		assert.Equal(t, 2, resultMap["duplicate_records"])
+		assert.Equal(t, "Checked 5 records; found 1 groups of duplicates (2 records) sharing their title, type and login, url", resultMap["message"])
+		assert.Equal(t, groups, resultMap["groups"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("no duplicates", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindDuplicates", []string(nil), []string(nil)).Return(&types.DuplicatesResult{RecordsChecked: 3, Groups: []types.DuplicateGroup{}}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindDuplicates(mockClient, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 0, resultMap["count"])
+		assert.Equal(t, "Checked 3 records; none share their title and type", resultMap["message"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("sensitive key fields require confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeFindDuplicates(mockClient, json.RawMessage(`{"key_fields":["login","password"]}`))
+		assert.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+		assert.Equal(t, "Compare the sensitive fields password of all secrets to find duplicates", promptArgs["action_description"])
+		assert.Equal(t, "find_duplicates", promptArgs["original_tool_name"])
+		mockClient.AssertNotCalled(t, "FindDuplicates", mock.Anything, mock.Anything)
+	})
+
+	t.Run("sensitive key fields in batch mode", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindDuplicates", []string(nil), []string{"password"}).Return(&types.DuplicatesResult{RecordsChecked: 2, Groups: []types.DuplicateGroup{}}, nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeFindDuplicates(mockClient, json.RawMessage(`{"key_fields":["password"]}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.(map[string]interface{})["records_checked"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("empty key field", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeFindDuplicates(mockClient, json.RawMessage(`{"key_fields":["login"," "]}`))
+		assert.EqualError(t, err, "key_fields must not contain empty field names")
+	})
+}
+
+func TestResolveWithBackend(t *testing.T) {
+	args := json.RawMessage(`{"uid":"uid-1"}`)
+	tests := []struct {
+		name       string
+		decision   *ui.ConfirmationResult
+		deletes    bool
+		wantStatus string
+		wantErr    string
+	}{
+		{name: "approved runs the action", decision: &ui.ConfirmationResult{Approved: true}, deletes: true},
+		{name: "denied", decision: &ui.ConfirmationResult{Reason: "no change ticket"}, wantStatus: "operation_denied"},
+		{name: "timed out", decision: &ui.ConfirmationResult{TimedOut: true}, wantStatus: "operation_denied"},
+		{name: "backend error", decision: &ui.ConfirmationResult{Error: errors.New("webhook returned status 502")}, wantErr: "confirmation backend failed for delete_secret: webhook returned status 502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			backend := new(mockConfirmer)
+			backend.On("ConfirmOperation", mock.Anything, "delete_secret", "Permanently delete KSM secret (UID: uid-1)", map[string]interface{}{
+				"warning": "This action CANNOT BE UNDONE. The secret will be permanently removed from your Keeper vault.",
+			}).Return(tt.decision)
+			if tt.deletes {
+				mockClient.On("DeleteSecret", "uid-1", true).Return(nil)
+			}
+			server := newHandlerTestServer(&ServerOptions{ConfirmationBackend: backend}, mockClient)
+
+			pending, err := server.executeDeleteSecret(mockClient, args)
+			assert.NoError(t, err)
+			result, err := server.resolveWithBackend(pending)
+
+			switch {
+			case tt.wantErr != "":
+				assert.EqualError(t, err, tt.wantErr)
+			case tt.deletes:
+				assert.NoError(t, err)
+				assert.Equal(t, "uid-1", result.(map[string]interface{})["uid"])
+			default:
+				assert.NoError(t, err)
+				resultMap := result.(map[string]interface{})
+				assert.Equal(t, tt.wantStatus, resultMap["status"])
+				if tt.decision.Reason != "" {
+					assert.Equal(t, tt.decision.Reason, resultMap["reason"])
+				}
+			}
+			backend.AssertExpectations(t)
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	t.Run("direct execute after a denial is refused", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		backend := new(mockConfirmer)
+		backend.On("ConfirmOperation", mock.Anything, "delete_secret", mock.Anything, mock.Anything).Return(&ui.ConfirmationResult{Reason: "no change ticket"})
+		server := newHandlerTestServer(&ServerOptions{ConfirmationBackend: backend}, mockClient)
+
+		pending, err := server.executeDeleteSecret(mockClient, args)
+		require.NoError(t, err)
+		result, err := server.resolveWithBackend(pending)
+		require.NoError(t, err)
+		assert.Equal(t, "operation_denied", result.(map[string]interface{})["status"])
+
+		execArgs, _ := json.Marshal(map[string]interface{}{
+			"original_tool_name":      "delete_secret",
+			"original_tool_args_json": string(args),
+			"user_decision":           true,
+		})
+		_, err = server.executeKsmExecuteConfirmedAction(execArgs)
+		var toolErr *ToolError
+		require.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, ErrorCodeAccessDenied, toolErr.Code)
+		mockClient.AssertNotCalled(t, "DeleteSecret", mock.Anything, mock.Anything)
+	})
+
+	t.Run("no backend keeps the prompt flow", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		pending, err := server.executeDeleteSecret(mockClient, args)
+		assert.NoError(t, err)
+		result, err := server.resolveWithBackend(pending)
+		assert.NoError(t, err)
+		assert.Equal(t, "confirmation_required", result.(map[string]interface{})["status"])
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteAnnotateRecords(t *testing.T) {
+	matches := []*types.SecretMetadata{
+		{UID: "uid-1", Title: "DB Prod", Type: "databaseCredentials"},
+		{UID: "uid-2", Title: "DB Staging", Type: "databaseCredentials"},
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+
+	t.Run("requires confirmation with matches pinned", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "DB", []string(nil)).Return(matches, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeAnnotateRecords(mockClient, json.RawMessage(`{"query":"DB","template":"Migrated {date}"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+		assert.Contains(t, promptArgs["original_tool_args_json"], `"uids":["uid-1","uid-2"]`)
+		mockClient.AssertNotCalled(t, "AppendNotes", mock.Anything, mock.Anything)
+	})
+
+	t.Run("batch mode appends to each match", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "DB", []string(nil)).Return(matches, nil)
+		mockClient.On("AppendNotes", "uid-1", "Audited DB Prod on "+today).Return(nil)
+		mockClient.On("AppendNotes", "uid-2", "Audited DB Staging on "+today).Return(errors.New("save failed"))
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeAnnotateRecords(mockClient, json.RawMessage(`{"query":"DB","template":"Audited {title} on {date}"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 1, resultMap["annotated"])
+		assert.Equal(t, 1, resultMap["failed"])
+		results := resultMap["results"].([]map[string]interface{})
+		require.Len(t, results, 2)
+		assert.Equal(t, true, results[0]["success"])
+		assert.Equal(t, false, results[1]["success"])
+		assert.Equal(t, "save failed", results[1]["error"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("confirmed run skips records that no longer match", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "DB", []string(nil)).Return(matches[:1], nil)
+		mockClient.On("AppendNotes", "uid-1", "tagged").Return(nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeAnnotateRecordsConfirmed(mockClient, json.RawMessage(`{"query":"DB","template":"tagged","uids":["uid-1","uid-2"]}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 1, resultMap["annotated"])
+		results := resultMap["results"].([]map[string]interface{})
+		assert.Contains(t, results[1]["error"], "no longer matches")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "nothing", []string(nil)).Return([]*types.SecretMetadata{}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeAnnotateRecords(mockClient, json.RawMessage(`{"query":"nothing","template":"x"}`))
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.(map[string]interface{})["annotated"])
+	})
+}
+
+func TestExecuteRotatePasswordsMatching(t *testing.T) {
+	matches := []*types.SecretMetadata{
+		{UID: "uid-1", Title: "DB Prod", Type: "login"},
+		{UID: "uid-2", Title: "DB Staging", Type: "login"},
+		{UID: "uid-3", Title: "DB Card", Type: "bankCard"},
+	}
+	const generated = "Xk9#mQ2$vL7!pR4&"
+
+	t.Run("requires confirmation with login matches pinned", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "DB", []string(nil)).Return(matches, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeRotatePasswordsMatching(mockClient, json.RawMessage(`{"query":"DB","length":16}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		assert.Equal(t, 2, resultMap["count"])
+		promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+		assert.Contains(t, promptArgs["original_tool_args_json"], `"uids":["uid-1","uid-2"]`)
+		assert.Contains(t, promptArgs["warning_message"], "2 login record(s)")
+		mockClient.AssertNotCalled(t, "GeneratePassword", mock.Anything)
+		mockClient.AssertNotCalled(t, "RotatePassword", mock.Anything, mock.Anything)
+	})
+
+	t.Run("batch mode rotates each login match without returning passwords", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "DB", []string(nil)).Return(matches, nil)
+		mockClient.On("GeneratePassword", types.GeneratePasswordParams{Length: 16}).Return(generated, nil)
+		mockClient.On("RotatePassword", "uid-1", generated).Return(nil)
+		mockClient.On("RotatePassword", "uid-2", generated).Return(errors.New("save failed"))
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeRotatePasswordsMatching(mockClient, json.RawMessage(`{"query":"DB","length":16,"save_to_secret":"ignored"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 1, resultMap["rotated"])
+		assert.Equal(t, 1, resultMap["failed"])
+		results := resultMap["results"].([]map[string]interface{})
+		require.Len(t, results, 2)
+		assert.Equal(t, true, results[0]["success"])
+		assert.Equal(t, false, results[1]["success"])
+		assert.Equal(t, "save failed", results[1]["error"])
+
+		encoded, err := json.Marshal(result)
+		require.NoError(t, err)
+		assert.NotContains(t, string(encoded), generated)
+		mockClient.AssertNotCalled(t, "CreateSecret", mock.Anything)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("confirmed run skips records that no longer match", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "DB", []string(nil)).Return(matches[:1], nil)
+		mockClient.On("GeneratePassword", types.GeneratePasswordParams{Length: ksm.DefaultPasswordLength}).Return(generated, nil)
+		mockClient.On("RotatePassword", "uid-1", generated).Return(nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeRotatePasswordsMatchingConfirmed(mockClient, json.RawMessage(`{"query":"DB","uids":["uid-1","uid-2"]}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 1, resultMap["rotated"])
+		results := resultMap["results"].([]map[string]interface{})
+		assert.Contains(t, results[1]["error"], "no longer matches")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("length below the policy minimum", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		_, err := server.executeRotatePasswordsMatching(mockClient, json.RawMessage(`{"query":"DB","length":8}`))
+		assert.ErrorContains(t, err, "below the password policy minimum")
+		mockClient.AssertNotCalled(t, "SearchSecrets", mock.Anything, mock.Anything)
+	})
+
+	t.Run("no login matches", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("SearchSecrets", "Card", []string(nil)).Return(matches[2:], nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeRotatePasswordsMatching(mockClient, json.RawMessage(`{"query":"Card"}`))
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.(map[string]interface{})["rotated"])
+	})
+}
+
+func TestConfirmationVerbosity(t *testing.T) {
+	warningFor := func(t *testing.T, result interface{}) string {
+		resultMap := result.(map[string]interface{})
+		require.Equal(t, "confirmation_required", resultMap["status"])
+		promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+		return promptArgs["warning_message"].(string)
+	}
+
+	t.Run("delete_secret", func(t *testing.T) {
+		for _, verbosity := range []string{"", VerbosityVerbose, VerbosityConcise} {
+			mockClient := new(mockKSMClient)
+			server := newHandlerTestServer(&ServerOptions{ConfirmationVerbosity: verbosity}, mockClient)
+			result, err := server.executeDeleteSecret(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+			require.NoError(t, err)
+			warning := warningFor(t, result)
+			if verbosity == VerbosityConcise {
+				assert.Equal(t, confirmationWarnings["delete_secret"].Concise, warning)
+			} else {
+				assert.Equal(t, confirmationWarnings["delete_secret"].Verbose, warning)
+			}
+			assert.Contains(t, warning, "CANNOT BE UNDONE")
+		}
+	})
+
+	t.Run("get_secret keeps the AI exposure warning", func(t *testing.T) {
+		for _, verbosity := range []string{VerbosityVerbose, VerbosityConcise} {
+			mockClient := new(mockKSMClient)
+			mockClient.On("GetSecret", "rec-uid", []string{}, false).Return(map[string]interface{}{"title": "DB"}, nil)
+			server := newHandlerTestServer(&ServerOptions{ConfirmationVerbosity: verbosity}, mockClient)
+			result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"rec-uid","unmask":true}`))
+			require.NoError(t, err)
+			warning := warningFor(t, result)
+			assert.Contains(t, warning, "TO THE AI MODEL")
+			if verbosity == VerbosityConcise {
+				assert.Less(t, len(warning), len(getSecretWarning(0).Verbose))
+			}
+		}
+	})
+
+	t.Run("concise delete_folder keeps the applied default", func(t *testing.T) {
+		warning := (&Server{options: &ServerOptions{ConfirmationVerbosity: VerbosityConcise}}).confirmationWarningText(deleteFolderWarning(false, true))
+		assert.Contains(t, warning, "must be empty")
+		assert.Contains(t, warning, "server default (force: false)")
+	})
+}
+
+func TestExecuteWritableFolders(t *testing.T) {
+	mockClient := new(mockKSMClient)
+	mockClient.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+		{UID: "sf-1", Name: "Shared"},
+		{UID: "sub-1", Name: "Databases", ParentUID: "sf-1"},
+		{UID: "sub-2", Name: "Legacy", ParentUID: "sub-1"},
+		{UID: "sub-3", Name: "Replicas", ParentUID: "sub-2"},
+		{UID: "orphan", Name: "Orphan", ParentUID: "hidden"},
+	}}, nil)
+	server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+	result, err := server.executeWritableFolders(mockClient, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	resultMap := result.(map[string]interface{})
+	assert.Equal(t, 4, resultMap["count"])
+	assert.Equal(t, []types.WritableFolder{
+		{UID: "sf-1", Name: "Shared", SharedFolderUID: "sf-1", IsSharedFolder: true},
+		{UID: "sub-1", Name: "Databases", ParentUID: "sf-1", SharedFolderUID: "sf-1"},
+		{UID: "sub-2", Name: "Legacy", ParentUID: "sub-1", SharedFolderUID: "sf-1"},
+		{UID: "sub-3", Name: "Replicas", ParentUID: "sub-2", SharedFolderUID: "sf-1"},
+	}, resultMap["folders"])
+	mockClient.AssertExpectations(t)
+}
+
+func TestBulkOperationsCancelled(t *testing.T) {
+	secrets := []*types.SecretMetadata{
+		{UID: "uid-1", Title: "One"},
+		{UID: "uid-2", Title: "Two"},
+		{UID: "uid-3", Title: "Three"},
+	}
+
+	// newCancelledServer starts a tool call whose context is cancelled by the test
+	newCancelledServer := func(t *testing.T, client *mockKSMClient) (*Server, context.CancelFunc) {
+		server := newHandlerTestServer(&ServerOptions{}, client)
+		ctx, cancel := context.WithCancel(context.Background())
+		server.callCtx = ctx
+		t.Cleanup(cancel)
+		return server, cancel
+	}
+
+	t.Run("get_all_secrets_unmasked returns the records read before cancellation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server, cancel := newCancelledServer(t, mockClient)
+		mockClient.On("ListSecrets", []string(nil)).Return(secrets, nil)
+		mockClient.On("GetSecret", "uid-1", []string(nil), true).Run(func(mock.Arguments) { cancel() }).
+			Return(map[string]interface{}{"uid": "uid-1", "password": "p1"}, nil)
+
+		result, err := server.executeGetAllSecretsUnmaskedConfirmed(mockClient, json.RawMessage(`{}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, true, resultMap["cancelled"])
+		assert.Equal(t, "cancelled", resultMap["cancel_reason"])
+		assert.Equal(t, 2, resultMap["remaining"])
+		assert.Equal(t, 1, resultMap["count"])
+		mockClient.AssertNotCalled(t, "GetSecret", "uid-2", mock.Anything, mock.Anything)
+	})
+
+	t.Run("update_secrets stops before the remaining updates", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server, cancel := newCancelledServer(t, mockClient)
+		mockClient.On("UpdateSecret", mock.MatchedBy(func(p types.UpdateSecretParams) bool { return p.UID == "uid-1" })).
+			Run(func(mock.Arguments) { cancel() }).Return(nil)
+
+		args := json.RawMessage(`{"updates":[` +
+			`{"uid":"uid-1","fields":[{"type":"url","value":["https://one"]}]},` +
+			`{"uid":"uid-2","fields":[{"type":"url","value":["https://two"]}]}]}`)
+		result, err := server.executeUpdateSecretsConfirmed(mockClient, args)
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, true, resultMap["cancelled"])
+		assert.Equal(t, 1, resultMap["updated"])
+		assert.Equal(t, 1, resultMap["remaining"])
+		mockClient.AssertNumberOfCalls(t, "UpdateSecret", 1)
+	})
+
+	t.Run("empty_folder keeps the folder and subfolders not yet reached", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server, cancel := newCancelledServer(t, mockClient)
+		mockClient.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+			{UID: "root-folder", Name: "Prod"},
+			{UID: "child-folder", Name: "DB", ParentUID: "root-folder"},
+		}}, nil)
+		mockClient.On("ListSecrets", mock.Anything).Return([]*types.SecretMetadata{
+			{UID: "secret-1", Title: "Primary", Folder: "root-folder"},
+			{UID: "secret-2", Title: "Replica", Folder: "child-folder"},
+		}, nil)
+		mockClient.On("DeleteSecret", mock.Anything, true).Run(func(mock.Arguments) { cancel() }).Return(nil).Once()
+
+		result, err := server.executeEmptyFolderConfirmed(mockClient, json.RawMessage(`{"folder_uid":"root-folder"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, true, resultMap["cancelled"])
+		assert.Equal(t, 1, resultMap["deleted"])
+		assert.Equal(t, 2, resultMap["remaining"]) // one secret and the subfolder
+		mockClient.AssertNotCalled(t, "DeleteFolder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("bulk timeout is reported as timeout", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{BulkTimeout: time.Nanosecond}, mockClient)
+		_, endToolCall := server.beginToolCall()
+		defer endToolCall()
+		<-server.toolContext().Done()
+		mockClient.On("SearchSecrets", "prod", []string(nil)).Return(secrets[:1], nil)
+
+		result, err := server.executeAnnotateRecordsConfirmed(mockClient, json.RawMessage(`{"query":"prod","template":"checked"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "timeout", resultMap["cancel_reason"])
+		assert.Equal(t, 0, resultMap["annotated"])
+		mockClient.AssertNotCalled(t, "AppendNotes", mock.Anything, mock.Anything)
+	})
+}
+
+func TestToolCallCancellation(t *testing.T) {
+	t.Run("notifications/cancelled stops only the named request", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+		endRequest := server.beginRequest(float64(7))
+		defer endRequest()
+		ctx, endToolCall := server.beginToolCall()
+		defer endToolCall()
+
+		server.cancelRequest(float64(8))
+		assert.NoError(t, ctx.Err())
+		server.cancelRequest(float64(7))
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+
+	t.Run("a call started after the request was cancelled starts cancelled", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+		endRequest := server.beginRequest("req-1")
+		defer endRequest()
+		server.cancelRequest("req-1")
+
+		ctx, endToolCall := server.beginToolCall()
+		defer endToolCall()
+		assert.Error(t, ctx.Err())
+	})
+
+	t.Run("reader handles cancellations immediately and cancels on disconnect", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+		endRequest := server.beginRequest(float64(1))
+		defer endRequest()
+		ctx, endToolCall := server.beginToolCall()
+		defer endToolCall()
+
+		input := `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":2}}` + "\n" +
+			`{"jsonrpc":"2.0","id":3,"method":"tools/list"}` + "\n"
+		lines, errs := server.readMessages(bufio.NewReader(strings.NewReader(input)))
+
+		assert.Contains(t, string(<-lines), "tools/list", "cancellations are not queued")
+		assert.Equal(t, io.EOF, <-errs)
+		assert.ErrorIs(t, ctx.Err(), context.Canceled, "the running call is cancelled once the client is gone")
+	})
+}
+
+func TestExecuteGetSecrets(t *testing.T) {
+	t.Run("partial failures are reported per UID", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecrets", []string{"uid-1", "bad uid", "uid-3"}, []string(nil), false).Return(&types.BatchResult{
+			Results: []map[string]interface{}{{"uid": "uid-1", "title": "Mail"}},
+			Errors: []types.BatchError{
+				{UID: "bad uid", Error: "invalid UID: invalid format"},
+				{UID: "uid-3", Error: "secret not found"},
+			},
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetSecrets(mockClient, json.RawMessage(`{"uids":["uid-1","bad uid","uid-3"]}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 1, resultMap["count"])
+		assert.Equal(t, 2, resultMap["failed"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"uid": "uid-1", "title": "Mail"}}, resultMap["results"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("uids are required", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+		_, err := server.executeGetSecrets(nil, json.RawMessage(`{"uids":[]}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestExecuteTestField(t *testing.T) {
+	tests := []struct {
+		name      string
+		notation  string
+		value     interface{}
+		err       error
+		wantType  string
+		wantCount interface{}
+	}{
+		{name: "string field", notation: "uid-1/field/password", value: "********", wantType: "string"},
+		{name: "multi-value field", notation: "uid-1/field/url", value: []interface{}{"https://a", "https://b"}, wantType: "array", wantCount: 2},
+		{name: "complex field", notation: "uid-1/field/name", value: map[string]interface{}{"first": "Jane"}, wantType: "object"},
+		{name: "unresolved notation", notation: "uid-1/field/missing", err: errors.New("field not found")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			mockClient.On("GetField", tt.notation, false).Return(tt.value, tt.err)
+			server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+			result, err := server.executeTestField(mockClient, json.RawMessage(`{"notation":"`+tt.notation+`"}`))
+			require.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			assert.NotContains(t, resultMap, "value")
+			if tt.err != nil {
+				assert.Equal(t, false, resultMap["resolved"])
+				assert.Equal(t, "field not found", resultMap["error"])
+			} else {
+				assert.Equal(t, true, resultMap["resolved"])
+				assert.Equal(t, tt.wantType, resultMap["value_type"])
+				assert.Equal(t, tt.wantCount, resultMap["value_count"])
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfirmationBypassFolders(t *testing.T) {
+	const lowUID, normalUID = "LowSensitivityRec01", "ProductionRecord001"
+	options := &ServerOptions{ConfirmationBypassFolders: []string{"test-accounts"}}
+
+	t.Run("get_secret in a bypass subfolder unmasks directly", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretFolderUIDs", lowUID).Return([]string{"shared-folder", "test-accounts"}, nil)
+		mockClient.On("GetSecret", lowUID, []string(nil), true).Return(map[string]interface{}{
+			"uid":      lowUID,
+			"password": "test-password",
+		}, nil)
+		server := newHandlerTestServer(options, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"`+lowUID+`","unmask":true}`))
+		require.NoError(t, err)
+		assert.Equal(t, "test-password", result.(map[string]interface{})["password"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("get_secret in another folder still requires confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretFolderUIDs", normalUID).Return([]string{"production"}, nil)
+		mockClient.On("GetSecret", normalUID, []string{}, false).Return(map[string]interface{}{"title": "Prod DB"}, nil)
+		server := newHandlerTestServer(options, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"`+normalUID+`","unmask":true}`))
+		require.NoError(t, err)
+		assert.Equal(t, "confirmation_required", result.(map[string]interface{})["status"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("folder lookup failure keeps the confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretFolderUIDs", normalUID).Return(nil, ksm.ErrSecretNotFound)
+		mockClient.On("GetSecret", normalUID, []string{}, false).Return(nil, ksm.ErrSecretNotFound)
+		server := newHandlerTestServer(options, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"`+normalUID+`","unmask":true}`))
+		require.NoError(t, err)
+		assert.Equal(t, "confirmation_required", result.(map[string]interface{})["status"])
+	})
+
+	t.Run("get_field in a bypass folder skips the confirmer", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretFolderUIDs", lowUID).Return([]string{"test-accounts"}, nil)
+		mockClient.On("GetField", lowUID+"/field/password", true).Return("test-password", nil)
+		server := newHandlerTestServer(options, mockClient)
+
+		result, err := server.executeGetField(mockClient, json.RawMessage(`{"notation":"`+lowUID+`/field/password","unmask":true}`))
+		require.NoError(t, err)
+		assert.Equal(t, "test-password", result.(map[string]interface{})["value"])
+		server.confirmer.(*mockConfirmer).AssertNotCalled(t, "Confirm", mock.Anything, mock.Anything)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("get_field in another folder asks the confirmer", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecretFolderUIDs", normalUID).Return([]string{"production"}, nil)
+		server := newHandlerTestServer(options, mockClient)
+		confirmer := server.confirmer.(*mockConfirmer)
+		confirmer.On("Confirm", mock.Anything, mock.Anything).Return(&ui.ConfirmationResult{Approved: false})
+
+		_, err := server.executeGetField(mockClient, json.RawMessage(`{"notation":"`+normalUID+`/field/password","unmask":true}`))
+		assert.Error(t, err)
+		confirmer.AssertExpectations(t)
+		mockClient.AssertNotCalled(t, "GetField", mock.Anything, mock.Anything)
+	})
+}
+
+func TestExecuteListCustomFields(t *testing.T) {
+	t.Run("custom fields listed without values", func(t *testing.T) {
+		fields := []types.CustomFieldInfo{
+			{Label: "API Key", Type: "secret", Sensitive: true, ValueCount: 1, Notation: "uid-1/custom_field/API Key"},
+			{Label: "Region", Type: "text", ValueCount: 1, Notation: "uid-1/custom_field/Region"},
+		}
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListCustomFields", "uid-1").Return(fields, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeListCustomFields(mockClient, json.RawMessage(`{"uid":"uid-1"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 2, resultMap["count"])
+		assert.Equal(t, fields, resultMap["custom_fields"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("unknown uid is NOT_FOUND", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListCustomFields", "missing-uid").Return(nil, ksm.ErrSecretNotFound)
+		mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeListCustomFields(mockClient, json.RawMessage(`{"uid":"missing-uid"}`))
+		var toolErr *ToolError
+		require.True(t, errors.As(err, &toolErr))
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+	})
+}
+
+func TestExecuteCreateFolderIdempotent(t *testing.T) {
+	folders := &types.ListFoldersResponse{Folders: []types.FolderInfo{
+		{UID: "shared-root", Name: "Team"},
+		{UID: "existing-child", Name: "Deploy Keys", ParentUID: "shared-root"},
+		{UID: "other-child", Name: "Deploy Keys", ParentUID: "other-root"},
+	}}
+
+	tests := []struct {
+		name      string
+		args      json.RawMessage
+		options   *ServerOptions
+		mockSetup func(*mockKSMClient)
+		validate  func(*testing.T, map[string]interface{})
+	}{
+		{
+			name:    "existing folder returned without confirmation",
+			args:    json.RawMessage(`{"name":"Deploy Keys","parent_uid":"shared-root","idempotent":true}`),
+			options: &ServerOptions{},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, "existing-child", result["uid"])
+				assert.Equal(t, false, result["created"])
+			},
+		},
+		{
+			name:    "existing folder returned in batch mode without creating",
+			args:    json.RawMessage(`{"name":"Deploy Keys","parent_uid":"shared-root","idempotent":true}`),
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, "existing-child", result["uid"])
+				assert.Equal(t, false, result["created"])
+			},
+		},
+		{
+			name:    "same name under another parent is created",
+			args:    json.RawMessage(`{"name":"Deploy Keys","parent_uid":"team-b","idempotent":true}`),
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListFolders").Return(folders, nil)
+				client.On("CreateFolder", "Deploy Keys", "team-b").Return("new-folder", nil)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, "new-folder", result["uid"])
+				assert.Equal(t, true, result["created"])
+			},
+		},
+		{
+			name:    "without idempotent the existing folder is not looked up",
+			args:    json.RawMessage(`{"name":"Deploy Keys","parent_uid":"shared-root"}`),
+			options: &ServerOptions{BatchMode: true},
+			mockSetup: func(client *mockKSMClient) {
+				client.On("CreateFolder", "Deploy Keys", "shared-root").Return("dup-folder", nil)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, "dup-folder", result["uid"])
+				assert.Equal(t, true, result["created"])
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(tt.options, mockClient)
+
+			result, err := server.executeCreateFolder(mockClient, tt.args)
+			assert.NoError(t, err)
+			tt.validate(t, result.(map[string]interface{}))
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestParseExpirationDate(t *testing.T) {
+	day := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected time.Time
+		ok       bool
+	}{
+		{"epoch milliseconds number", float64(day.UnixMilli()), day, true},
+		{"epoch milliseconds string", strconv.FormatInt(day.UnixMilli(), 10), day, true},
+		{"epoch seconds", float64(day.Unix()), day, true},
+		{"ISO date", "2026-03-15", day, true},
+		{"card month is valid through month end", "03/2026", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), true},
+		{"short card year", "03/26", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), true},
+		{"garbage", "soon", time.Time{}, false},
+		{"zero", float64(0), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseExpirationDate(tt.value)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.True(t, tt.expected.Equal(got), "got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecuteExpiringSoon(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	inDays := func(days int) string {
+		return strconv.FormatInt(today.AddDate(0, 0, days).UnixMilli(), 10)
+	}
+
+	mockClient := new(mockKSMClient)
+	mockClient.On("ListSecrets", []string(nil)).Return([]*types.SecretMetadata{
+		{UID: "license", Title: "IDE License", Type: "softwareLicense"},
+		{UID: "passport", Title: "Passport", Type: "passport"},
+		{UID: "card", Title: "Corp Card", Type: "bankCard"},
+		{UID: "old-cert", Title: "Old Cert", Type: "general"},
+		{UID: "login", Title: "Mail", Type: "login"},
+	}, nil)
+	fields := []string{"expirationDate", "paymentCard"}
+	mockClient.On("GetSecret", "license", fields, false).Return(map[string]interface{}{"expirationDate": inDays(10)}, nil)
+	mockClient.On("GetSecret", "passport", fields, false).Return(map[string]interface{}{"expirationDate": inDays(400)}, nil)
+	mockClient.On("GetSecret", "card", fields, false).Return(map[string]interface{}{
+		"paymentCard": map[string]interface{}{
+			"cardNumber":         "411***111",
+			"cardExpirationDate": today.AddDate(0, 0, 20).Format("01/2006"),
+		},
+	}, nil)
+	mockClient.On("GetSecret", "old-cert", fields, false).Return(map[string]interface{}{"expirationDate": today.AddDate(0, 0, -3).Format("2006-01-02")}, nil)
+	mockClient.On("GetSecret", "login", fields, false).Return(map[string]interface{}{"title": "Mail"}, nil)
+	server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+	result, err := server.executeExpiringSoon(mockClient, json.RawMessage(`{"within_days":60}`))
+	assert.NoError(t, err)
+	resultMap := result.(map[string]interface{})
+	records := resultMap["records"].([]map[string]interface{})
+	assert.Equal(t, 3, resultMap["count"])
+
+	uids := make([]string, len(records))
+	for i, record := range records {
+		uids[i] = record["uid"].(string)
+		assert.NotContains(t, record, "cardNumber")
+	}
+	assert.Equal(t, "old-cert", uids[0], "results are sorted by expiration date")
+	assert.ElementsMatch(t, []string{"old-cert", "license", "card"}, uids)
+	assert.Equal(t, true, records[0]["expired"])
+	assert.Equal(t, -3, records[0]["days_remaining"])
+
+	_, err = server.executeExpiringSoon(mockClient, json.RawMessage(`{"within_days":-1}`))
+	assert.Error(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestExecuteToolValidatesArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		tool        string
+		args        string
+		errContains string
+	}{
+		{"length below minimum", "generate_password", `{"length":4}`, "length must be at least 8"},
+		{"length above maximum", "generate_password", `{"length":500}`, "length must be at most 100"},
+		{"non-integer length", "generate_password", `{"length":12.5}`, "length must be of type integer"},
+		{"wrong type", "get_secret", `{"uid":"abc","unmask":"yes"}`, "unmask must be of type boolean"},
+		{"missing required", "get_secret", `{}`, "uid is required"},
+		{"enum", "get_all_secrets_unmasked", `{"output_format":"xml"}`, "output_format must be one of json, ndjson"},
+		{"enum on a nested tool", "setup_totp", `{"uid":"abc","algorithm":"MD5"}`, "algorithm must be one of SHA1, SHA256, SHA512"},
+		{"nested required", "create_secret", `{"type":"login","title":"t","fields":[{"type":"login"}]}`, "fields[0].value is required"},
+		{"nested minItems", "create_secret", `{"type":"login","title":"t","fields":[{"type":"login","value":[]}]}`, "fields[0].value must contain at least 1 items"},
+		{"empty updates", "update_secrets", `{"updates":[]}`, "updates must contain at least 1 items"},
+		{"missing update uid", "update_secrets", `{"updates":[{"title":"A"}]}`, "updates[0].uid is required"},
+		{"empty update uid", "update_secrets", `{"updates":[{"uid":"","title":"A"}]}`, "updates[0].uid must not be empty"},
+		{"invalid JSON", "list_secrets", `{"folder_uid":`, "not valid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+			_, err := server.executeTool(tt.tool, json.RawMessage(tt.args))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+			var toolErr *ToolError
+			if assert.True(t, errors.As(err, &toolErr)) {
+				assert.Equal(t, ErrorCodeInvalidInput, toolErr.Code)
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	t.Run("valid arguments reach the handler", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{{UID: "folder-1", Name: "One"}}}, nil)
+		mockClient.On("ListSecrets", []string{"folder-1"}).Return([]*types.SecretMetadata{}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeTool("list_secrets", json.RawMessage(`{"folder_uid":"folder-1","folder_uids":null}`))
+		assert.NoError(t, err)
+		_, err = server.executeTool("list_tools_detailed", nil)
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteBuildNotation(t *testing.T) {
+	record := map[string]interface{}{
+		"uid":      "rec-uid",
+		"title":    "Prod DB",
+		"type":     "login",
+		"password": "******",
+		"url":      "https://db.example.com",
+		"name":     map[string]interface{}{"first": "Ada", "last": "Lovelace"},
+		"custom_fields": map[string]interface{}{
+			"API Key":        "abc***xyz",
+			"path/with[odd]": "value",
+		},
+		"files": []map[string]interface{}{{"name": "cert.pem", "title": "cert.pem"}},
+	}
+
+	tests := []struct {
+		name        string
+		args        string
+		expected    string
+		location    string
+		expectError bool
+	}{
+		{"standard field", `{"uid":"rec-uid","field":"password"}`, "rec-uid/field/password", "field", false},
+		{"standard field with index", `{"uid":"rec-uid","field":"url","index":0}`, "rec-uid/field/url[0]", "field", false},
+		{"complex sub-field", `{"uid":"rec-uid","field":"name.first"}`, "rec-uid/field/name[first]", "field", false},
+		{"custom field", `{"uid":"rec-uid","field":"API Key"}`, "rec-uid/custom_field/API Key", "custom_field", false},
+		{"custom field label is escaped", `{"uid":"rec-uid","field":"path/with[odd]"}`, `rec-uid/custom_field/path\/with\[odd\]`, "custom_field", false},
+		{"file", `{"uid":"rec-uid","field":"cert.pem"}`, "rec-uid/file/cert.pem", "file", false},
+		{"unknown field", `{"uid":"rec-uid","field":"pinCode"}`, "", "", true},
+		{"metadata key is not a field", `{"uid":"rec-uid","field":"title"}`, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(record, nil)
+			server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+			result, err := server.executeBuildNotation(mockClient, json.RawMessage(tt.args))
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			assert.Equal(t, tt.expected, resultMap["notation"])
+			assert.Equal(t, tt.location, resultMap["location"])
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExplicitUnmaskLog(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       *ServerOptions
+		expectWarning bool
+		expectSummary bool
+	}{
+		{"batch mode logs warning", &ServerOptions{BatchMode: true, RequireExplicitUnmaskLog: true}, true, false},
+		{"auto-approve logs warning with summary", &ServerOptions{AutoApprove: true, RequireExplicitUnmaskLog: true, UnmaskLogSummary: true}, true, true},
+		{"option off logs nothing", &ServerOptions{BatchMode: true, UnmaskLogSummary: true}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, err := audit.NewLogger(audit.Config{FilePath: filepath.Join(t.TempDir(), "audit.log")})
+			require.NoError(t, err)
+
+			mockClient := new(mockKSMClient)
+			mockClient.On("GetSecret", "rec-uid", []string(nil), true).
+				Return(map[string]interface{}{"uid": "rec-uid", "password": "hunter2"}, nil)
+			server := newHandlerTestServer(tt.options, mockClient)
+			server.logger = logger
+			server.currentProfile = "automation"
+
+			result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"rec-uid","unmask":true}`))
+			require.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			assert.Equal(t, "hunter2", resultMap["password"])
+
+			if tt.expectSummary {
+				summary, ok := resultMap["unmask_audit"].(map[string]interface{})
+				require.True(t, ok, "expected unmask_audit summary in response")
+				assert.Equal(t, []string{"rec-uid"}, summary["uids"])
+				assert.Equal(t, 1, summary["count"])
+			} else {
+				assert.NotContains(t, resultMap, "unmask_audit")
+			}
+
+			require.NoError(t, logger.Close())
+			events, err := logger.Search(audit.Query{Severities: []audit.Severity{audit.SeverityWarning}})
+			require.NoError(t, err)
+			if !tt.expectWarning {
+				assert.Empty(t, events)
+				return
+			}
+			require.Len(t, events, 1)
+			assert.Equal(t, "rec-uid", events[0].Resource)
+			assert.Equal(t, "automation", events[0].Profile)
+			assert.Equal(t, "unmask_without_confirmation", events[0].Action)
+			assert.Equal(t, "get_secret", events[0].Details["tool"])
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteGetFieldTypeSchema(t *testing.T) {
+	require.NoError(t, recordtemplates.LoadRecordTemplates())
+	server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+
+	t.Run("complex type lists sub-fields", func(t *testing.T) {
+		result, err := server.executeGetFieldTypeSchema(json.RawMessage(`{"field_type":"host"}`))
+		require.NoError(t, err)
+		schema := result.(*types.FieldTypeSchema)
+		assert.Equal(t, "host", schema.FieldType)
+		assert.False(t, schema.IsEnum)
+		assert.False(t, schema.Masked)
+		names := make([]string, 0, len(schema.SubFields))
+		for _, sf := range schema.SubFields {
+			names = append(names, sf.Name)
+		}
+		assert.Equal(t, []string{"host.hostName", "host.port"}, names)
+	})
+
+	t.Run("name sub-fields match the SDK", func(t *testing.T) {
+		result, err := server.executeGetFieldTypeSchema(json.RawMessage(`{"field_type":"name"}`))
+		require.NoError(t, err)
+		schema := result.(*types.FieldTypeSchema)
+		names := make([]string, 0, len(schema.SubFields))
+		for _, sf := range schema.SubFields {
+			names = append(names, sf.Name)
+		}
+		assert.Equal(t, []string{"name.first", "name.middle", "name.last"}, names)
+	})
+
+	t.Run("enum field lists allowed values", func(t *testing.T) {
+		result, err := server.executeGetFieldTypeSchema(json.RawMessage(`{"field_type":"databasetype"}`))
+		require.NoError(t, err)
+		schema := result.(*types.FieldTypeSchema)
+		assert.Equal(t, "databaseType", schema.FieldType)
+		assert.Equal(t, "dropdown", schema.Type)
+		assert.True(t, schema.IsEnum)
+		assert.Contains(t, schema.AllowedValues, "PostgreSQL")
+	})
+
+	t.Run("sensitive type is masked", func(t *testing.T) {
+		result, err := server.executeGetFieldTypeSchema(json.RawMessage(`{"field_type":"paymentCard"}`))
+		require.NoError(t, err)
+		schema := result.(*types.FieldTypeSchema)
+		assert.True(t, schema.Masked)
+		assert.NotEmpty(t, schema.SubFields)
+	})
+
+	t.Run("unknown type is not found", func(t *testing.T) {
+		_, err := server.executeGetFieldTypeSchema(json.RawMessage(`{"field_type":"noSuchField"}`))
+		var toolErr *ToolError
+		require.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+	})
+}
+
+func TestExecuteCreateFromTemplate(t *testing.T) {
+	require.NoError(t, recordtemplates.LoadRecordTemplates())
+
+	t.Run("returns placeholder payload", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeCreateFromTemplate(mockClient, json.RawMessage(`{"type":"bankCard","title":"Corporate Card"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		template := resultMap["template"].(types.CreateSecretParams)
+		assert.Equal(t, "bankCard", template.Type)
+		assert.Equal(t, "Corporate Card", template.Title)
+
+		fieldTypes := make([]string, 0, len(template.Fields))
+		for _, field := range template.Fields {
+			fieldTypes = append(fieldTypes, field.Type)
+			assert.Empty(t, field.Value, "placeholder for %s should be empty", field.Type)
+		}
+		assert.Contains(t, fieldTypes, "paymentCard.cardNumber")
+		assert.Contains(t, fieldTypes, "paymentCard.cardSecurityCode")
+		assert.Contains(t, fieldTypes, "text") // labeled cardholderName field is stored as text
+		assert.Contains(t, fieldTypes, "pinCode")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("create requires a title", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		_, err := server.executeCreateFromTemplate(mockClient, json.RawMessage(`{"type":"login","create":true}`))
+		assert.ErrorContains(t, err, "title parameter is required")
+	})
+
+	t.Run("create asks for confirmation through create_secret", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeCreateFromTemplate(mockClient, json.RawMessage(`{"type":"login","title":"New Login","folder_uid":"folder_abc","create":true}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+		assert.Equal(t, "create_secret", promptArgs["original_tool_name"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("create in batch mode creates placeholder record", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("CreateSecret", mock.MatchedBy(func(params types.CreateSecretParams) bool {
+			return params.Type == "login" && params.Title == "New Login" && params.FolderUID == "folder_abc" && len(params.Fields) > 0
+		})).Return("new-uid", nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeCreateFromTemplate(mockClient, json.RawMessage(`{"type":"login","title":"New Login","folder_uid":"folder_abc","create":true}`))
+		require.NoError(t, err)
+		assert.Equal(t, "new-uid", result.(map[string]interface{})["uid"])
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteIsSharedFolder(t *testing.T) {
+	tests := []struct {
+		name       string
+		shared     bool
+		err        error
+		wantShared bool
+		wantCode   string
+	}{
+		{name: "shared folder", shared: true, wantShared: true},
+		{name: "subfolder", shared: false, wantShared: false},
+		{name: "not accessible", err: ksm.ErrFolderNotFound, wantCode: ErrorCodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			mockClient.On("IsSharedFolder", "folderUID123456789012").Return(tt.shared, tt.err)
+			server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+			result, err := server.executeIsSharedFolder(mockClient, json.RawMessage(`{"folder_uid":"folderUID123456789012"}`))
+			if tt.wantCode != "" {
+				var toolErr *ToolError
+				require.ErrorAs(t, err, &toolErr)
+				assert.Equal(t, tt.wantCode, toolErr.Code)
+				assert.Contains(t, toolErr.Message, "writable_folders")
+				return
+			}
+			require.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			assert.Equal(t, tt.wantShared, resultMap["is_shared_folder"])
+			if !tt.wantShared {
+				assert.Contains(t, resultMap["message"], "not a shared folder")
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	t.Run("folder_uid required", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeIsSharedFolder(mockClient, json.RawMessage(`{}`))
+		assert.ErrorContains(t, err, "folder_uid parameter is required")
+	})
+}
+
+func TestExecuteTestFolderAccess(t *testing.T) {
+	folders := &types.ListFoldersResponse{Folders: []types.FolderInfo{
+		{UID: "shared-uid", Name: "Team"},
+		{UID: "sub-uid", Name: "Prod", ParentUID: "shared-uid"},
+		{UID: "orphan-uid", Name: "Old", ParentUID: "hidden-uid"},
+	}}
+	records := []*types.SecretMetadata{{UID: "rec-1", Title: "DB"}, {UID: "rec-2", Title: "API"}}
+
+	tests := []struct {
+		name      string
+		args      string
+		mockSetup func(*mockKSMClient)
+		validate  func(*testing.T, map[string]interface{})
+	}{
+		{
+			name: "subfolder of a shared folder can be read and created in",
+			args: `{"folder_uid":"sub-uid","check_create":true}`,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListSecrets", []string{"sub-uid"}).Return(records, nil)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, true, result["can_read"])
+				assert.Equal(t, 2, result["record_count"])
+				assert.Equal(t, true, result["can_create"])
+				assert.Equal(t, "shared-uid", result["shared_folder_uid"])
+				assert.Contains(t, result["note"], "Edit rights")
+				assert.NotContains(t, result, "issues")
+			},
+		},
+		{
+			name: "create check is opt-in",
+			args: `{"folder_uid":"shared-uid"}`,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListSecrets", []string{"shared-uid"}).Return(records, nil)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, true, result["can_read"])
+				assert.NotContains(t, result, "can_create")
+			},
+		},
+		{
+			name: "folder outside the shared folders cannot be created in",
+			args: `{"folder_uid":"orphan-uid","check_create":true}`,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListSecrets", []string{"orphan-uid"}).Return([]*types.SecretMetadata{}, nil)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, true, result["can_read"])
+				assert.Equal(t, false, result["can_create"])
+				assert.Contains(t, result["issues"].([]string)[0], "records cannot be created")
+			},
+		},
+		{
+			name: "listing failure is reported as no read access",
+			args: `{"folder_uid":"shared-uid","check_create":true}`,
+			mockSetup: func(client *mockKSMClient) {
+				client.On("ListSecrets", []string{"shared-uid"}).Return(nil, ksm.ErrAccessDenied)
+			},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, false, result["can_read"])
+				assert.NotContains(t, result, "record_count")
+				assert.Equal(t, true, result["can_create"])
+				assert.Contains(t, result["issues"].([]string)[0], "Listing the folder's records failed")
+			},
+		},
+		{
+			name:      "inaccessible folder",
+			args:      `{"folder_uid":"missing-uid","check_create":true}`,
+			mockSetup: func(client *mockKSMClient) {},
+			validate: func(t *testing.T, result map[string]interface{}) {
+				assert.Equal(t, false, result["can_read"])
+				assert.Equal(t, false, result["can_create"])
+				assert.Contains(t, result["issues"].([]string)[0], "not accessible to this application")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			mockClient.On("ListFolders").Return(folders, nil)
+			tt.mockSetup(mockClient)
+			server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+			result, err := server.executeTestFolderAccess(mockClient, json.RawMessage(tt.args))
+			require.NoError(t, err)
+			tt.validate(t, result.(map[string]interface{}))
+			mockClient.AssertNotCalled(t, "CreateSecret", mock.Anything)
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	t.Run("folder_uid required", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeTestFolderAccess(mockClient, json.RawMessage(`{}`))
+		assert.ErrorContains(t, err, "folder_uid parameter is required")
+	})
+}
+
+func TestExecuteUploadFileFromURL(t *testing.T) {
+	t.Run("requires confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeUploadFileFromURL(mockClient, json.RawMessage(`{"uid":"rec-1","url":"https://example.com/report.pdf?token=s3cret"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		assert.Contains(t, resultMap["message"], "https://example.com/report.pdf")
+		assert.NotContains(t, resultMap["message"], "s3cret", "the description shows the redacted URL")
+		mockClient.AssertNotCalled(t, "UploadFileFromURL", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejects a dangerous URL before confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		for _, rawURL := range []string{"javascript:alert(1)", "file:///etc/passwd", "ftp://example.com/a.txt"} {
+			_, err := server.executeUploadFileFromURL(mockClient, json.RawMessage(`{"uid":"rec-1","url":"`+rawURL+`"}`))
+			var toolErr *ToolError
+			require.ErrorAs(t, err, &toolErr, rawURL)
+			assert.Equal(t, ErrorCodeInvalidInput, toolErr.Code, rawURL)
+		}
+	})
+
+	t.Run("batch mode uploads with the configured cap", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		uploaded := &types.UploadedFile{FileUID: "file-1", Name: "report.pdf", Title: "Report", MimeType: "application/pdf", Size: 42}
+		mockClient.On("UploadFileFromURL", "rec-1", "https://example.com/report.pdf", "Report", int64(1024)).Return(uploaded, nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true, MaxURLUploadBytes: 1024}, mockClient)
+
+		result, err := server.executeUploadFileFromURL(mockClient, json.RawMessage(`{"uid":"rec-1","url":"https://example.com/report.pdf","title":"Report"}`))
+		require.NoError(t, err)
+		assert.Equal(t, uploaded, result.(map[string]interface{})["file"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("oversized file is invalid input", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("UploadFileFromURL", "rec-1", "https://example.com/big.iso", "", int64(0)).
+			Return(nil, fmt.Errorf("%w: https://example.com/big.iso is more than 52428800 bytes", ksm.ErrUploadTooLarge))
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		_, err := server.executeUploadFileFromURL(mockClient, json.RawMessage(`{"uid":"rec-1","url":"https://example.com/big.iso"}`))
+		var toolErr *ToolError
+		require.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, ErrorCodeInvalidInput, toolErr.Code)
+		assert.Contains(t, toolErr.Message, "nothing was attached")
+	})
+}
+
+func TestExecuteCreatePamResource(t *testing.T) {
+	require.NoError(t, recordtemplates.LoadRecordTemplates())
+
+	t.Run("assembles pamMachine fields", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		var created types.CreateSecretParams
+		mockClient.On("CreateSecret", mock.Anything).Run(func(args mock.Arguments) {
+			created = args.Get(0).(types.CreateSecretParams)
+		}).Return("pam-uid", nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeCreatePamResource(mockClient, json.RawMessage(`{
+			"title": "web-01",
+			"folder_uid": "folderUID123456789012",
+			"hostname": "10.0.0.5",
+			"port": "22",
+			"controller_uid": "controllerUID1234567890",
+			"resource_refs": ["pamUserUID12345678901", "pamUserUID12345678902"],
+			"protocol": "SSH"
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, "pam-uid", result.(map[string]interface{})["uid"])
+
+		assert.Equal(t, "pamMachine", created.Type)
+		assert.Equal(t, "folderUID123456789012", created.FolderUID)
+		values := make(map[string]interface{})
+		for _, field := range created.Fields {
+			require.Len(t, field.Value, 1, "field %s", field.Type)
+			values[field.Type] = field.Value[0]
+		}
+		assert.Equal(t, map[string]interface{}{"hostName": "10.0.0.5", "port": "22"}, values["pamHostname"])
+		assert.Equal(t, map[string]interface{}{
+			"controllerUid": "controllerUID1234567890",
+			"folderUid":     "folderUID123456789012",
+			"resourceRef":   []string{"pamUserUID12345678901", "pamUserUID12345678902"},
+		}, values["pamResources"])
+		assert.Equal(t, map[string]interface{}{
+			"connection": map[string]interface{}{"protocol": "ssh", "port": "22"},
+		}, values["pamSettings"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("asks for confirmation through create_secret", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeCreatePamResource(mockClient, json.RawMessage(`{"title":"web-01","folder_uid":"folderUID123456789012","hostname":"10.0.0.5","port":"22"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		promptArgs := resultMap["confirmation_details"].(map[string]interface{})["prompt_arguments"].(map[string]interface{})
+		assert.Equal(t, "create_secret", promptArgs["original_tool_name"])
+		assert.NotContains(t, promptArgs["original_tool_args_json"], "pamResources")
+		mockClient.AssertNotCalled(t, "CreateSecret", mock.Anything)
+	})
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"port required by pamMachine schema", `{"title":"web-01","hostname":"10.0.0.5"}`, "port is required by the pamMachine schema"},
+		{"invalid port", `{"title":"web-01","hostname":"10.0.0.5","port":"70000"}`, "port must be a number"},
+		{"not a PAM resource type", `{"type":"login","title":"web-01","hostname":"10.0.0.5","port":"22"}`, "type must be one of"},
+		{"resource refs need a controller", `{"title":"web-01","hostname":"10.0.0.5","port":"22","resource_refs":["pamUserUID12345678901"]}`, "controller_uid is required"},
+		{"invalid controller UID", `{"title":"web-01","hostname":"10.0.0.5","port":"22","controller_uid":"bad uid"}`, "invalid controller_uid"},
+		{"unknown protocol", `{"title":"web-01","hostname":"10.0.0.5","port":"22","protocol":"ftp"}`, "protocol must be one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+			_, err := server.executeCreatePamResource(mockClient, json.RawMessage(tt.args))
+			assert.ErrorContains(t, err, tt.want)
+			mockClient.AssertNotCalled(t, "CreateSecret", mock.Anything)
+		})
+	}
+}
+
+func TestExecuteDownloadAllFiles(t *testing.T) {
+	record := map[string]interface{}{
+		"uid": "rec-uid",
+		"files": []map[string]interface{}{
+			{"uid": "file-1", "name": "app.conf", "title": "config", "size": 9},
+			{"uid": "file-2", "name": "db.conf", "title": "config", "size": 8},
+		},
+	}
+	// Both attachments share a title, so only their UIDs tell them apart
+	contents := map[string]string{"file-1": "port=8080", "file-2": "host=db1"}
+	writeFile := func(args mock.Arguments) {
+		_ = os.WriteFile(args.String(2), []byte(contents[args.String(1)]), 0600)
+	}
+	readZip := func(t *testing.T, data []byte) map[string]string {
+		reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		entries := make(map[string]string)
+		for _, entry := range reader.File {
+			rc, err := entry.Open()
+			require.NoError(t, err)
+			body, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			rc.Close()
+			entries[entry.Name] = string(body)
+		}
+		return entries
+	}
+	expected := map[string]string{"app.conf": "port=8080", "db.conf": "host=db1"}
+
+	t.Run("requires confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeDownloadAllFiles(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "confirmation_required", result.(map[string]interface{})["status"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("returns inline zip", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(record, nil)
+		mockClient.On("DownloadFile", "rec-uid", "file-1", mock.Anything).Run(writeFile).Return(nil)
+		mockClient.On("DownloadFile", "rec-uid", "file-2", mock.Anything).Run(writeFile).Return(nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeDownloadAllFiles(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, 2, resultMap["file_count"])
+		data, err := base64.StdEncoding.DecodeString(resultMap["zip_base64"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, expected, readZip(t, data))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("writes zip to save_path", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(record, nil)
+		mockClient.On("DownloadFile", "rec-uid", "file-1", mock.Anything).Run(writeFile).Return(nil)
+		mockClient.On("DownloadFile", "rec-uid", "file-2", mock.Anything).Run(writeFile).Return(nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		savePath := filepath.Join(t.TempDir(), "files.zip")
+		result, err := server.executeDownloadAllFilesConfirmed(mockClient, json.RawMessage(fmt.Sprintf(`{"uid":"rec-uid","save_path":%q}`, savePath)))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, savePath, resultMap["path"])
+		assert.NotContains(t, resultMap, "zip_base64")
+		data, err := os.ReadFile(savePath)
+		require.NoError(t, err)
+		assert.Equal(t, expected, readZip(t, data))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("large attachments must be saved to disk", func(t *testing.T) {
+		large := map[string]interface{}{
+			"uid":   "rec-uid",
+			"files": []map[string]interface{}{{"name": "dump.sql", "title": "dump.sql", "size": maxInlineZipBytes + 1}},
+		}
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(large, nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		_, err := server.executeDownloadAllFilesConfirmed(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		assert.ErrorContains(t, err, "pass save_path")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("record without files", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(map[string]interface{}{"uid": "rec-uid"}, nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		_, err := server.executeDownloadAllFilesConfirmed(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		assert.ErrorContains(t, err, "no file attachments")
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteGetFieldNotationIndexBase(t *testing.T) {
+	tests := []struct {
+		name         string
+		base         int
+		notation     string
+		wantNotation string
+		expectError  bool
+	}{
+		{"0-based passes through", 0, "rec-uid-1234567890/field/url[1]", "rec-uid-1234567890/field/url[1]", false},
+		{"1-based first index", 1, "rec-uid-1234567890/field/url[1]", "rec-uid-1234567890/field/url[0]", false},
+		{"1-based second index", 1, "rec-uid-1234567890/field/url[2]", "rec-uid-1234567890/field/url[1]", false},
+		{"1-based rejects index 0", 1, "rec-uid-1234567890/field/url[0]", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			if !tt.expectError {
+				mockClient.On("GetField", tt.wantNotation, false).Return("https://example.com", nil)
+			}
+			server := newHandlerTestServer(&ServerOptions{NotationIndexBase: tt.base}, mockClient)
+
+			args, _ := json.Marshal(map[string]interface{}{"notation": tt.notation})
+			result, err := server.executeGetField(mockClient, args)
+			if tt.expectError {
+				assert.ErrorContains(t, err, "indices start at 1")
+				return
+			}
+			require.NoError(t, err)
+			resultMap := result.(map[string]interface{})
+			assert.Equal(t, "https://example.com", resultMap["value"])
+			assert.Equal(t, tt.notation, resultMap["notation"])
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExecuteGetFieldResultShape(t *testing.T) {
+	const notation = "rec-uid-1234567890/field/url"
+	multi := []interface{}{"https://a.example.com", "https://b.example.com"}
+
+	tests := []struct {
+		name  string
+		args  string
+		value interface{}
+		want  interface{}
+	}{
+		{"default keeps multiple values as array", `{"notation":"` + notation + `"}`, multi, multi},
+		{"default keeps single value scalar", `{"notation":"` + notation + `"}`, "https://a.example.com", "https://a.example.com"},
+		{"always_array keeps multiple values", `{"notation":"` + notation + `","always_array":true}`, multi, multi},
+		{"always_array wraps single value", `{"notation":"` + notation + `","always_array":true}`, "https://a.example.com", []interface{}{"https://a.example.com"}},
+		{"first_only returns first of multiple values", `{"notation":"` + notation + `","first_only":true}`, multi, "https://a.example.com"},
+		{"first_only keeps single value", `{"notation":"` + notation + `","first_only":true}`, "https://a.example.com", "https://a.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mockKSMClient)
+			mockClient.On("GetField", notation, false).Return(tt.value, nil)
+			server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+			result, err := server.executeGetField(mockClient, json.RawMessage(tt.args))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result.(map[string]interface{})["value"])
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	t.Run("modes are exclusive", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+		_, err := server.executeGetField(mockClient, json.RawMessage(`{"notation":"`+notation+`","always_array":true,"first_only":true}`))
+		assert.ErrorContains(t, err, "cannot both be set")
+	})
+}
+
+func TestExecuteClearTOTP(t *testing.T) {
+	t.Run("requires confirmation", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(map[string]interface{}{"uid": "rec-uid", "title": "VPN"}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeClearTOTP(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "confirmation_required", resultMap["status"])
+		assert.Contains(t, resultMap["message"], "'VPN'")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("batch mode clears TOTP", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("UpdateSecret", types.UpdateSecretParams{UID: "rec-uid", ClearTOTP: true}).Return(nil)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		result, err := server.executeClearTOTP(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "rec-uid", result.(map[string]interface{})["uid"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("record without TOTP", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("UpdateSecret", types.UpdateSecretParams{UID: "rec-uid", ClearTOTP: true}).Return(ksm.ErrNoTOTP)
+		server := newHandlerTestServer(&ServerOptions{BatchMode: true}, mockClient)
+
+		_, err := server.executeClearTOTPConfirmed(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		var toolErr *ToolError
+		require.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestParseDateFormat(t *testing.T) {
+	// 2024-03-15T23:30:00Z
+	const epochMs = "1710545400000"
+
+	tests := []struct {
+		name     string
+		format   string
+		timezone string
+		want     interface{}
+		wantErr  bool
+	}{
+		{"iso in UTC", "iso", "", "2024-03-15T23:30:00Z", false},
+		{"timezone only uses iso", "", "Asia/Tokyo", "2024-03-16T08:30:00+09:00", false},
+		{"date preset in New York", "date", "America/New_York", "2024-03-15", false},
+		{"eu preset in Berlin crosses midnight", "eu", "Europe/Berlin", "16/03/2024", false},
+		{"custom layout", "Jan 2, 2006 15:04", "Europe/Berlin", "Mar 16, 2024 00:30", false},
+		{"unknown preset", "fancy", "", nil, true},
+		{"unknown timezone", "iso", "Mars/Olympus", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ParseDateFormat(tt.format, tt.timezone)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, format.Format(epochMs))
+			assert.Equal(t, tt.want, format.Format(float64(1710545400000)))
+		})
+	}
+
+	format, err := ParseDateFormat("", "")
+	require.NoError(t, err)
+	assert.Nil(t, format, "no settings keeps epoch milliseconds")
+
+	format, _ = ParseDateFormat("iso", "")
+	assert.Equal(t, "03/15/2024", format.Format("03/15/2024"), "text dates are left unchanged")
+}
+
+func TestExecuteGetSecretDateFormat(t *testing.T) {
+	record := func() map[string]interface{} {
+		return map[string]interface{}{
+			"uid":            "rec-uid",
+			"title":          "Passport",
+			"type":           "passport",
+			"birthDate":      "1710545400000",
+			"expirationDate": "1710545400000",
+			"text":           "1710545400000",
+		}
+	}
+
+	t.Run("configured format", func(t *testing.T) {
+		format, err := ParseDateFormat("date", "Europe/Berlin")
+		require.NoError(t, err)
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(record(), nil)
+		server := newHandlerTestServer(&ServerOptions{DateFormat: format}, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "2024-03-16", resultMap["birthDate"])
+		assert.Equal(t, "2024-03-16", resultMap["expirationDate"])
+		assert.Equal(t, "1710545400000", resultMap["text"], "non-date fields are untouched")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("default keeps epoch milliseconds", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("GetSecret", "rec-uid", []string(nil), false).Return(record(), nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetSecret(mockClient, json.RawMessage(`{"uid":"rec-uid"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "1710545400000", result.(map[string]interface{})["birthDate"])
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestExecuteGetByExternalID(t *testing.T) {
+	args := json.RawMessage(`{"field_label":"External ID","value":"CRM-1001"}`)
+
+	t.Run("single match returned masked", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindByCustomField", "External ID", "CRM-1001").Return([]*types.SecretMetadata{
+			{UID: "recordUID12345678901", Title: "Acme"},
+		}, nil)
+		mockClient.On("GetSecret", "recordUID12345678901", []string(nil), false).Return(map[string]interface{}{
+			"uid":      "recordUID12345678901",
+			"title":    "Acme",
+			"password": "******",
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executeGetByExternalID(mockClient, args)
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, "recordUID12345678901", resultMap["uid"])
+		assert.Equal(t, "******", resultMap["password"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("no match is NOT_FOUND", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindByCustomField", "External ID", "CRM-1001").Return([]*types.SecretMetadata{}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeGetByExternalID(mockClient, args)
+		var toolErr *ToolError
+		require.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, ErrorCodeNotFound, toolErr.Code)
+	})
+
+	t.Run("ambiguous match lists the records", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		mockClient.On("FindByCustomField", "External ID", "CRM-1001").Return([]*types.SecretMetadata{
+			{UID: "recordUID12345678901", Title: "Acme"},
+			{UID: "recordUID12345678902", Title: "Acme (old)"},
+		}, nil)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeGetByExternalID(mockClient, args)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ambiguous")
+		assert.Contains(t, err.Error(), "recordUID12345678902")
+		mockClient.AssertNotCalled(t, "GetSecret", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("sensitive label rejected", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeGetByExternalID(mockClient, json.RawMessage(`{"field_label":"password","value":"hunter2"}`))
+		var toolErr *ToolError
+		require.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, ErrorCodeInvalidInput, toolErr.Code)
+		mockClient.AssertNotCalled(t, "FindByCustomField", mock.Anything, mock.Anything)
+	})
+
+	t.Run("value required", func(t *testing.T) {
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		_, err := server.executeGetByExternalID(mockClient, json.RawMessage(`{"field_label":"External ID"}`))
+		assert.ErrorContains(t, err, "value parameter is required")
+	})
+}
+
+func TestExecutePreviewFieldReconstruction(t *testing.T) {
+	args := json.RawMessage(`{"fields":[
+		{"type":"login","value":["admin"]},
+		{"type":"name.first","value":["Jane"]},
+		{"type":"name.last","value":["Doe"]},
+		{"type":"phone.number","value":["555-0100"]},
+		{"type":"phone.type","value":["Mobile"]},
+		{"type":"securityQuestion[0].question","value":["Pet?"]},
+		{"type":"securityQuestion[0].answer","value":["Rex"]},
+		{"type":"securityQuestion[1].question","value":["City?"]},
+		{"type":"securityQuestion[1].answer","value":["Oslo"]},
+		{"type":"text","value":["one","two"]}
+	]}`)
+
+	t.Run("complex fields are reconstructed without vault access", func(t *testing.T) {
+		// The mock has no expectations, so any client call would fail the test
+		mockClient := new(mockKSMClient)
+		server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+		result, err := server.executePreviewFieldReconstruction(args)
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+
+		fields := resultMap["fields"].([]types.SecretField)
+		byType := make(map[string][]interface{})
+		for _, field := range fields {
+			byType[field.Type] = field.Value
+		}
+		assert.Equal(t, 5, resultMap["count"])
+		assert.Equal(t, []interface{}{"admin"}, byType["login"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"first": "Jane", "middle": "", "last": "Doe"}}, byType["name"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"number": "555-0100", "type": "Mobile"}}, byType["phone"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"question": "Pet?", "answer": "Rex"},
+			map[string]interface{}{"question": "City?", "answer": "Oslo"},
+		}, byType["securityQuestion"])
+		assert.Equal(t, []interface{}{"one"}, byType["text"])
+		assert.Equal(t, []string{"Field 'text' has 2 values; using only the first."}, resultMap["warnings"])
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("configured multi-value types keep every value", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{MultiValueFieldTypes: []string{"text"}}, new(mockKSMClient))
+
+		result, err := server.executePreviewFieldReconstruction(json.RawMessage(`{"fields":[{"type":"text","value":["one","two"]}]}`))
+		require.NoError(t, err)
+		resultMap := result.(map[string]interface{})
+		assert.Equal(t, []types.SecretField{{Type: "text", Value: []interface{}{"one", "two"}}}, resultMap["fields"])
+		assert.Empty(t, resultMap["warnings"])
+	})
+
+	t.Run("fields required", func(t *testing.T) {
+		server := newHandlerTestServer(&ServerOptions{}, new(mockKSMClient))
+
+		_, err := server.executePreviewFieldReconstruction(json.RawMessage(`{}`))
+		assert.ErrorContains(t, err, "fields parameter is required")
+	})
+}
+
+func TestExecuteListSharedFolders(t *testing.T) {
+	mockClient := new(mockKSMClient)
+	mockClient.On("ListFolders").Return(&types.ListFoldersResponse{Folders: []types.FolderInfo{
+		{UID: "shared-a", Name: "Team"},
+		{UID: "sub-a", Name: "Prod", ParentUID: "shared-a"},
+		{UID: "shared-b", Name: "Finance"},
+	}}, nil)
+	mockClient.On("ListSecrets", []string{"shared-a"}).Return([]*types.SecretMetadata{{UID: "r1"}, {UID: "r2"}, {UID: "r3"}}, nil)
+	mockClient.On("ListSecrets", []string{"shared-b"}).Return(nil, errors.New("boom"))
+	server := newHandlerTestServer(&ServerOptions{}, mockClient)
+
+	result, err := server.executeListSharedFolders(mockClient, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	resultMap := result.(map[string]interface{})
+
+	assert.Equal(t, []types.SharedFolderSummary{
+		{UID: "shared-a", Name: "Team", Path: "Team", RecordCount: 3},
+		{UID: "shared-b", Name: "Finance", Path: "Finance", Error: "failed to count records"},
+	}, resultMap["folders"])
+	assert.Equal(t, 2, resultMap["count"])
+	assert.Equal(t, 3, resultMap["total_records"])
+	mockClient.AssertNotCalled(t, "ListSecrets", []string{"sub-a"})
+	mockClient.AssertExpectations(t)
+}
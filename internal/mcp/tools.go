@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/keeper-security/ksm-mcp/internal/audit"
+	"github.com/keeper-security/ksm-mcp/internal/ksm"
 	"github.com/keeper-security/ksm-mcp/pkg/types"
 )
 
@@ -15,18 +16,22 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 		// Phase 1 Tools
 		{
 			Name:        "list_secrets",
-			Description: "List all secrets (metadata only, no sensitive data). Supports filtering by single folder or multiple folders.",
+			Description: "List all secrets (metadata only, no sensitive data). Supports filtering by single folder or multiple folders. Each entry reports has_totp when a one-time code is configured.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "Filter by single folder UID (for backward compatibility)",
+						"description": "Filter by single folder UID, folder name or ' / ' separated folder path (for backward compatibility)",
 					},
 					"folder_uids": map[string]interface{}{
 						"type":        "array",
 						"items":       map[string]interface{}{"type": "string"},
-						"description": "Filter by multiple folder UIDs (uses KSM SDK folder filtering for better performance)",
+						"description": "Filter by multiple folder UIDs, names or ' / ' separated paths (uses KSM SDK folder filtering for better performance). Names matching more than one folder are rejected.",
+					},
+					"include_last_accessed": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Add last_accessed and access_count to each secret: when it was last read or changed through this server, according to the audit log. Secrets without them were not used in the current log; useful to find dormant credentials",
 					},
 				},
 			},
@@ -54,6 +59,41 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 				"required": []string{"uid"},
 			},
 		},
+		{
+			Name:        "get_secret_safe",
+			Description: "Get a secret by UID with every sensitive field (passwords, keys, card and account numbers, one-time codes, sensitive custom fields) omitted entirely rather than masked. Returns metadata, non-sensitive fields and the names of the omitted fields; no confirmation is needed.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "get_secrets",
+			Description: "Retrieve several secrets by UID in one call, with sensitive fields masked. UIDs that cannot be read are listed under errors while the other secrets are still returned. Use get_secret to unmask a secret.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uids": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "UIDs of the secrets (at most 100)",
+						"maxItems":    maxBatchSecrets,
+					},
+					"fields": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Fields to retrieve from each secret (default: all)",
+					},
+				},
+				"required": []string{"uids"},
+			},
+		},
 		{
 			Name:        "search_secrets",
 			Description: "Search secrets by title",
@@ -64,10 +104,63 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 						"type":        "string",
 						"description": "Search query",
 					},
+					"folder_uids": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Only search the secrets in these folders. Only those records are fetched, which is faster on large vaults",
+					},
 				},
 				"required": []string{"query"},
 			},
 		},
+		{
+			Name:        "find_secret_fuzzy",
+			Description: "Find secrets by approximate title, tolerating typos, missing words and word order (e.g. 'prdo databse' finds 'Prod Database'). Returns the best matches with a score from 0 to 1; metadata only, no field values.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Title or part of a title as remembered",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of matches to return (default: 5)",
+						"minimum":     1,
+						"maximum":     maxFuzzyMatches,
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+		{
+			Name:        "get_secret_path",
+			Description: "Get the folder path (breadcrumb) of a secret, e.g. 'Engineering / Prod / DB'",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "secret_fingerprint",
+			Description: "Get a stable keyed hash of a secret's title, type, fields and notes, for detecting changes without storing or reading the secret. Values are never returned; the hash changes when any of them changes.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
 		{
 			Name:        "get_field",
 			Description: "Get a specific field using KSM notation",
@@ -76,52 +169,103 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 				"properties": map[string]interface{}{
 					"notation": map[string]interface{}{
 						"type":        "string",
-						"description": "KSM notation (e.g., UID/field/password, Title/field/url[0])",
+						"description": "KSM notation (e.g., UID/field/password, Title/field/url[0]). Array indices are 0-based unless the server sets mcp.notation_index_base to 1. Escape '/', '[', ']' and '\\' inside titles, labels or filenames with a backslash (e.g., prod\\/db/field/password)",
 					},
 					"unmask": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Show unmasked value (requires confirmation)",
 					},
+					"always_array": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Always return the value as an array, even when the field has a single value. By default a single value is returned as a scalar and several values as an array.",
+					},
+					"first_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Always return a single value: the first one when the field has several. Cannot be combined with always_array.",
+					},
 				},
 				"required": []string{"notation"},
 			},
 		},
 		{
-			Name:        "generate_password",
-			Description: "Generate a secure password",
+			Name:        "test_field",
+			Description: "Check that a KSM notation resolves before requesting an unmasked read with get_field. The field is read masked and only whether it resolved and its value type (string, array, object, ...) are returned; no confirmation is needed.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"length": map[string]interface{}{
-						"type":        "integer",
-						"description": "Password length (default: 32)",
-						"minimum":     8,
-						"maximum":     100,
+					"notation": map[string]interface{}{
+						"type":        "string",
+						"description": "KSM notation to test, in the same form get_field accepts (e.g., UID/field/password, UID/custom_field/API Key)",
 					},
-					"lowercase": map[string]interface{}{
-						"type":        "integer",
-						"description": "Minimum lowercase characters",
-						"minimum":     0,
+				},
+				"required": []string{"notation"},
+			},
+		},
+		{
+			Name:        "list_custom_fields",
+			Description: "List the custom fields of a secret (label, type, whether the field is sensitive, and the notation to read it with get_field). Values are not returned.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret",
 					},
-					"uppercase": map[string]interface{}{
-						"type":        "integer",
-						"description": "Minimum uppercase characters",
-						"minimum":     0,
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "get_by_external_id",
+			Description: "Get the record whose custom field (e.g., 'External ID') holds the given value, returned masked like get_secret. For integrations that key records on the identifiers of external systems. Fails when no record or more than one record matches.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"field_label": map[string]interface{}{
+						"type":        "string",
+						"description": "Label of the custom field holding the external ID (case-insensitive)",
 					},
-					"digits": map[string]interface{}{
-						"type":        "integer",
-						"description": "Minimum digit characters",
-						"minimum":     0,
+					"value": map[string]interface{}{
+						"type":        "string",
+						"description": "External ID to look up (matched exactly)",
+					},
+				},
+				"required": []string{"field_label", "value"},
+			},
+		},
+		{
+			Name:        "build_notation",
+			Description: "Build the KSM notation for a field of a record (e.g., UID/field/password or UID/custom_field/API Key) for use with get_field. The record is inspected to choose between standard fields, custom fields and file attachments, and special characters are escaped.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Record UID",
+					},
+					"field": map[string]interface{}{
+						"type":        "string",
+						"description": "Field type (e.g., password, url, paymentCard.cardNumber), custom field label, or file name",
 					},
-					"special": map[string]interface{}{
+					"index": map[string]interface{}{
 						"type":        "integer",
-						"description": "Minimum special characters",
+						"description": "Optional: Value index for multi-value fields",
 						"minimum":     0,
 					},
-					"special_set": map[string]interface{}{
+					"property": map[string]interface{}{
 						"type":        "string",
-						"description": "Custom special character set",
+						"description": "Optional: Property of a complex field value (e.g., first for a name field)",
 					},
+				},
+				"required": []string{"uid", "field"},
+			},
+		},
+		{
+			Name:        "generate_password",
+			Description: "Generate a secure password",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": withProperties(passwordGenerationProperties(s.defaultPasswordLength()), map[string]interface{}{
 					"save_to_secret": map[string]interface{}{
 						"type":        "string",
 						"description": "If specified, saves password to a new secret with this title (password not exposed to AI).",
@@ -130,7 +274,7 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 						"type":        "string",
 						"description": "UID of the shared folder to save the new secret in. Required if save_to_secret is used.",
 					},
-				},
+				}),
 			},
 		},
 		{
@@ -147,7 +291,90 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 				"required": []string{"uid"},
 			},
 		},
+		{
+			Name:        "verify_totp",
+			Description: "Check whether a TOTP code is valid for a secret's stored seed, allowing one period of clock skew either way. Returns only whether the code matched, never the expected code.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID containing TOTP",
+					},
+					"code": map[string]interface{}{
+						"type":        "string",
+						"description": "The code to verify (e.g. 123456)",
+					},
+				},
+				"required": []string{"uid", "code"},
+			},
+		},
 		// Phase 2 Tools
+		{
+			Name:        "setup_totp",
+			Description: "Store a TOTP seed as the one-time code field of a secret (requires confirmation). Pass an existing otpauth:// URI as uri, or a bare base32 seed as secret (e.g. copied from another authenticator); with neither, a new seed is generated. The seed and provisioning URI are only returned when unmask is true.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret to attach TOTP to",
+					},
+					"uri": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional) Existing otpauth://totp/ provisioning URI to store as is. Its issuer, account, algorithm, digits and period are used.",
+					},
+					"secret": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional) Existing base32 seed (spaces, dashes and lower case are accepted). The URI is built from it with issuer and account.",
+					},
+					"algorithm": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional, with secret) Hash algorithm. Defaults to SHA1.",
+						"enum":        []string{"SHA1", "SHA256", "SHA512"},
+					},
+					"digits": map[string]interface{}{
+						"type":        "integer",
+						"description": "(Optional, with secret) Code length. Defaults to 6.",
+						"minimum":     6,
+						"maximum":     8,
+					},
+					"period": map[string]interface{}{
+						"type":        "integer",
+						"description": "(Optional, with secret) Seconds each code is valid. Defaults to 30.",
+						"minimum":     1,
+					},
+					"issuer": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional) Issuer shown in authenticator apps. Defaults to 'Keeper'.",
+					},
+					"account": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional) Account name shown in authenticator apps. Defaults to the record's login, or its title.",
+					},
+					"unmask": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Return the seed and otpauth:// provisioning URI so an authenticator can be enrolled",
+						"default":     false,
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "clear_totp",
+			Description: "Remove the one-time code (TOTP) fields from a secret, e.g. when 2FA is decommissioned for a credential (requires confirmation). The rest of the record is left unchanged.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret to remove TOTP from",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
 		{
 			Name:        "create_secret",
 			Description: "Create a new KSM secret. Fields are specified in a flattened format. Examples: 'login', 'password', 'bankAccount.accountType', 'phone.type', 'name.first', 'passkey.credentialId', 'passkey.privateKey' (as JSON string of JWK). For enum-like fields (e.g., phone.type), use TitleCase values (e.g., 'Mobile'). Requires confirmation.",
@@ -168,7 +395,48 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 					},
 					"fields": map[string]interface{}{
 						"type":        "array",
-						"description": "Array of field objects. Use flattened dot notation for complex fields (e.g., bankAccount.routingNumber). Each field value must be an array with a single string element, e.g., {type: \"login\", value: [\"user\"]}, {type: \"bankAccount.accountType\", value: [\"Checking\"]}.",
+						"description": "Array of field objects. Use flattened dot notation for complex fields (e.g., bankAccount.routingNumber). Each field value must be an array with a single string element, e.g., {type: \"login\", value: [\"user\"]}, {type: \"bankAccount.accountType\", value: [\"Checking\"]}. Several security questions are given as securityQuestion[0].question, securityQuestion[0].answer, securityQuestion[1].question, and so on. Names are given as name.first, name.middle and name.last.",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"type": map[string]interface{}{
+									"type":        "string",
+									"description": "Field type, using dot notation for sub-fields (e.g., login, bankAccount.accountType, address.street1).",
+								},
+								"value": map[string]interface{}{
+									"type":        "array",
+									"description": "Field value, as a single-element array (e.g., [\"the_value\"] ). For enum-like sub-fields (e.g. phone.type), use TitleCase values (e.g. [\"Mobile\"]). For passkey.privateKey, value should be a JSON string representing the JsonWebKey. Example complex fields: bankAccount:[{accountType,routingNumber,accountNumber}], name:[{first,middle,last}], passkey:[{privateKey (JSON string),credentialId,signCount,userId,relyingParty,username,createdDate}], appFiller:[{applicationTitle,contentFilter,macroSequence (or macroSteps as a JSON array of steps, e.g. [\"{USERNAME}\",\"{TAB}\",\"{PASSWORD}\"])}], script:[{command,fileRef,recordRef (comma-sep UIDs)}], pamResources:[{controllerUid,folderUid,resourceRef (comma-sep UIDs)}]",
+									"minItems":    1,
+								},
+							},
+							"required": []string{"type", "value"},
+						},
+					},
+					"notes": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional) Secret notes.",
+					},
+				},
+				"required": []string{"type", "title", "fields"},
+			},
+		},
+		{
+			Name:        "update_secret",
+			Description: "Update an existing KSM secret. Fields are specified in a flattened format. Examples: 'login', 'password', 'bankAccount.accountType', 'phone.type', 'name.first', 'passkey.credentialId', 'passkey.privateKey' (as JSON string of JWK). For enum-like fields (e.g., phone.type), use TitleCase values (e.g., 'Mobile'). Requires confirmation.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret to update.",
+					},
+					"title": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional) New title for the secret.",
+					},
+					"fields": map[string]interface{}{
+						"type":        "array",
+						"description": "Array of field objects to update or add. Use flattened dot notation for complex fields (e.g., bankAccount.routingNumber). Each field value must be an array with a single string element, e.g., {type: \"login\", value: [\"user\"]}, {type: \"bankAccount.accountType\", value: [\"Checking\"]}. If a field type is provided that already exists, its value will be replaced; sub-fields of a complex field that are not given keep their stored values. If it doesn't exist, it will be added when the record type defines that field; otherwise the update is rejected. Several security questions are given as securityQuestion[0].question, securityQuestion[0].answer, securityQuestion[1].question, and so on; securityQuestion[N] updates only that pair. Names are given as name.first, name.middle and name.last.",
 						"items": map[string]interface{}{
 							"type": "object",
 							"properties": map[string]interface{}{
@@ -178,244 +446,757 @@ func (s *Server) getAvailableTools() []types.MCPTool {
 								},
 								"value": map[string]interface{}{
 									"type":        "array",
-									"description": "Field value, as a single-element array (e.g., [\"the_value\"] ). For enum-like sub-fields (e.g. phone.type), use TitleCase values (e.g. [\"Mobile\"]). For passkey.privateKey, value should be a JSON string representing the JsonWebKey. Example complex fields: bankAccount:[{accountType,routingNumber,accountNumber}], name:[{first,middle,last}], passkey:[{privateKey (JSON string),credentialId,signCount,userId,relyingParty,username,createdDate}], appFiller:[{applicationTitle,contentFilter,macroSequence}], script:[{command,fileRef,recordRef (comma-sep UIDs)}], pamResources:[{controllerUid,folderUid,resourceRef (comma-sep UIDs)}]",
-									"items":       map[string]interface{}{"type": "string"},
+									"description": "Field value, as a single-element array (e.g., [\"the_value\"] ). For enum-like sub-fields (e.g. phone.type), use TitleCase values (e.g. [\"Mobile\"]). For passkey.privateKey, value should be a JSON string representing the JsonWebKey. Example complex fields: bankAccount:[{accountType,routingNumber,accountNumber}], name:[{first,middle,last}], passkey:[{privateKey (JSON string),credentialId,signCount,userId,relyingParty,username,createdDate}], appFiller:[{applicationTitle,contentFilter,macroSequence (or macroSteps as a JSON array of steps, e.g. [\"{USERNAME}\",\"{TAB}\",\"{PASSWORD}\"])}], script:[{command,fileRef,recordRef (comma-sep UIDs)}], pamResources:[{controllerUid,folderUid,resourceRef (comma-sep UIDs)}]",
 									"minItems":    1,
-									"maxItems":    1,
 								},
 							},
 							"required": []string{"type", "value"},
 						},
 					},
-					"notes": map[string]interface{}{
+					"notes": map[string]interface{}{
+						"type":        "string",
+						"description": "(Optional) New notes for the secret. If provided, this will replace existing notes.",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "update_secrets",
+			Description: "Update multiple existing secrets in one call (requires a single confirmation covering all of them). Each update uses the same format as update_secret; results are reported per UID.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"updates": map[string]interface{}{
+						"type":        "array",
+						"description": "Update specs, each with a uid and any of title, fields and notes as accepted by update_secret",
+						"minItems":    1,
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"uid": map[string]interface{}{
+									"type":        "string",
+									"description": "Secret UID",
+									"minLength":   1,
+								},
+								"title": map[string]interface{}{
+									"type":        "string",
+									"description": "(Optional) New title for the secret",
+								},
+								"fields": map[string]interface{}{
+									"type":        "array",
+									"description": "(Optional) Field objects to update or add, in the same flattened format as update_secret",
+									"items": map[string]interface{}{
+										"type": "object",
+										"properties": map[string]interface{}{
+											"type": map[string]interface{}{"type": "string"},
+											"value": map[string]interface{}{
+												"type":        "array",
+												"description": "Field value, as accepted by update_secret: text values or, for complex fields, objects",
+												"minItems":    1,
+											},
+										},
+										"required": []string{"type", "value"},
+									},
+								},
+								"notes": map[string]interface{}{
+									"type":        "string",
+									"description": "(Optional) New notes for the secret",
+								},
+							},
+							"required": []string{"uid"},
+						},
+					},
+				},
+				"required": []string{"updates"},
+			},
+		},
+		{
+			Name:        "annotate_records",
+			Description: "Append a note to every secret matching a search query, e.g. to tag records during a migration or audit (requires a single confirmation covering all matches). Existing notes are kept; results are reported per UID.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Search query selecting the records to annotate, as accepted by search_secrets",
+					},
+					"template": map[string]interface{}{
+						"type":        "string",
+						"description": "Note to append. {title}, {uid}, {type} and {date} (YYYY-MM-DD, UTC) are replaced for each record, e.g. \"Migrated to vault B on {date}\"",
+					},
+				},
+				"required": []string{"query", "template"},
+			},
+		},
+		{
+			Name:        "rotate_passwords_matching",
+			Description: "Replace the password of every login record matching a search query with a newly generated one (requires a single confirmation covering all matches). The new passwords are saved to Keeper and never returned; results are reported per UID.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": withProperties(passwordGenerationProperties(s.defaultPasswordLength()), map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Search query selecting the login records to rotate, as accepted by search_secrets",
+					},
+				}),
+				"required": []string{"query"},
+			},
+		},
+		{
+			Name:        "rename_secret",
+			Description: "Change the title of an existing secret without modifying its fields (requires confirmation)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID",
+					},
+					"new_title": map[string]interface{}{
+						"type":        "string",
+						"description": "New title for the secret",
+					},
+				},
+				"required": []string{"uid", "new_title"},
+			},
+		},
+		{
+			Name:        "copy_field",
+			Description: "Copy a field value from one secret to another without exposing the value to the AI model, e.g. to sync a password to a related record. The target field is overwritten (requires confirmation)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"source_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret to copy from",
+					},
+					"source_field": map[string]interface{}{
+						"type":        "string",
+						"description": "Standard field type to copy (e.g. password, login, url)",
+					},
+					"target_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the secret to copy to",
+					},
+					"target_field": map[string]interface{}{
+						"type":        "string",
+						"description": "Standard field type to write on the target (defaults to source_field). A sensitive source such as password can only be copied into another sensitive field",
+					},
+				},
+				"required": []string{"source_uid", "source_field", "target_uid"},
+			},
+		},
+		{
+			Name:        "delete_secret",
+			Description: "Delete a secret (requires confirmation)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID to delete",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "upload_file",
+			Description: "Upload a file to a secret (requires confirmation)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID",
+					},
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to file to upload",
+					},
+					"title": map[string]interface{}{
+						"type":        "string",
+						"description": "File title in KSM",
+					},
+				},
+				"required": []string{"uid", "file_path", "title"},
+			},
+		},
+		{
+			Name:        "upload_file_from_url",
+			Description: "Download a file from an http or https URL and attach it to a secret, without a local file path (requires confirmation). The file's type is detected and files over the server's size limit are refused.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID",
+					},
+					"url": map[string]interface{}{
+						"type":        "string",
+						"description": "http or https URL of the file",
+					},
+					"title": map[string]interface{}{
+						"type":        "string",
+						"description": "File title in KSM (default: the downloaded file's name)",
+					},
+				},
+				"required": []string{"uid", "url"},
+			},
+		},
+		{
+			Name:        "download_file",
+			Description: "Download a file from a secret",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID",
+					},
+					"file_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "File UID or name",
+					},
+					"save_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to save file",
+					},
+				},
+				"required": []string{"uid", "file_uid"},
+			},
+		},
+		{
+			Name:        "download_all_files",
+			Description: "Download every file attached to a secret as one zip archive (requires confirmation). Returned base64-encoded unless save_path is given; archives over 10 MB must be saved to disk.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Secret UID",
+					},
+					"save_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: path to write the zip file instead of returning it inline",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "list_folders",
+			Description: "List all folders",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+			},
+		},
+		{
+			Name:        "writable_folders",
+			Description: "List the folders new records can be created in (shared folders and the subfolders inside them), to pick a folder_uid for create_secret",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+			},
+		},
+		{
+			Name:        "list_shared_folders",
+			Description: "List the shared folders shared with the application with their UID, name, path and the number of records each holds, for an overview of the vault or to choose where to create records. Metadata only.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+			},
+		},
+		{
+			Name:        "is_shared_folder",
+			Description: "Check whether a folder UID is a shared folder shared with the application, which create operations require, or a subfolder inside one",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the folder to check",
+					},
+				},
+				"required": []string{"folder_uid"},
+			},
+		},
+		{
+			Name:        "test_folder_access",
+			Description: "Check what the application can do in a folder before a workflow depends on it: can_read reports whether its records can be listed, and can_create (with check_create) whether records can be created there. The create check writes nothing.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the folder to check",
+					},
+					"check_create": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also check whether records can be created in the folder (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"folder_uid"},
+			},
+		},
+		{
+			Name:        "create_folder",
+			Description: "Create a new folder (requires confirmation)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Folder name",
+					},
+					"parent_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Parent folder UID",
+					},
+					"idempotent": map[string]interface{}{
+						"type":        "boolean",
+						"description": "If true and a folder with the same name already exists under the parent, return its UID with created: false instead of creating another one",
+						"default":     false,
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		{
+			Name:        "health_check",
+			Description: "Check the health status of the MCP server",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "get_server_version",
+			Description: "Get the current version of the KSM MCP server",
+			InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+		},
+		{
+			Name:        "validate_record",
+			Description: "Check a record against its record type schema and report missing required fields, unexpected fields, and type mismatches. Read-only; no field values are returned.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the record to validate",
+					},
+				},
+				"required": []string{"uid"},
+			},
+		},
+		{
+			Name:        "session_info",
+			Description: "Report the active profile, read-only status, confirmation policy, and the number of accessible folders and records. Returns no secret values.",
+			InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+		},
+		{
+			Name:        "list_tools_detailed",
+			Description: "List every tool with its description, input schema, whether it requires user confirmation in the current mode, and whether it modifies the vault.",
+			InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+		},
+		{
+			Name:        "delete_folder",
+			Description: "Delete a folder (requires confirmation). Optionally force delete if not empty.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the folder to delete",
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"description": "If true, delete the folder even if it is not empty. This is a destructive operation. When omitted, the server's configured default is used (false unless changed).",
+					},
+				},
+				"required": []string{"folder_uid"},
+			},
+		},
+		{
+			Name:        "empty_folder",
+			Description: "Delete all secrets and subfolders inside a folder while keeping the folder itself (requires confirmation). Returns per-item results.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the folder to empty",
+					},
+				},
+				"required": []string{"folder_uid"},
+			},
+		},
+		// New tool for handling confirmed actions
+		{
+			Name:        "ksm_execute_confirmed_action",
+			Description: "Executes an action that has been previously confirmed by the user via a prompt.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"original_tool_name": map[string]interface{}{
+						"type":        "string",
+						"description": "The name of the original tool that required confirmation (e.g., 'create_secret').",
+					},
+					"original_tool_args_json": map[string]interface{}{
 						"type":        "string",
-						"description": "(Optional) Secret notes.",
+						"description": "The JSON string of arguments originally passed to the tool.",
+					},
+					"user_decision": map[string]interface{}{
+						"type":        "boolean",
+						"description": "User's decision: true if approved, false if denied.",
+					},
+					"confirmation_context": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional context from the confirmation prompt.",
+						"nullable":    true,
+					},
+					"confirmation_id": map[string]interface{}{
+						"type":        "string",
+						"description": "The confirmation_id from the confirmation_details of the original response. Rejected if the confirmation was cancelled, already used or expired (after 30 minutes).",
 					},
 				},
-				"required": []string{"type", "title", "fields"},
+				"required": []string{"original_tool_name", "original_tool_args_json", "user_decision", "confirmation_id"},
 			},
 		},
 		{
-			Name:        "update_secret",
-			Description: "Update an existing KSM secret. Fields are specified in a flattened format. Examples: 'login', 'password', 'bankAccount.accountType', 'phone.type', 'name.first', 'passkey.credentialId', 'passkey.privateKey' (as JSON string of JWK). For enum-like fields (e.g., phone.type), use TitleCase values (e.g., 'Mobile'). Requires confirmation.",
+			Name:        "cancel_confirmation",
+			Description: "Cancel a pending confirmation so the action it describes can no longer be executed with ksm_execute_confirmed_action.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"uid": map[string]interface{}{
+					"confirmation_id": map[string]interface{}{
 						"type":        "string",
-						"description": "UID of the secret to update.",
+						"description": "The confirmation_id from the confirmation_details of a confirmation_required response",
 					},
-					"title": map[string]interface{}{
+				},
+				"required": []string{"confirmation_id"},
+			},
+		},
+		{
+			Name:        "get_all_secrets_unmasked",
+			Description: "Get all secrets with complete unmasked data (passwords, custom fields, etc.) in a single operation. Refused when more records match than the configured limit (100 by default); filter by folder_uid instead (requires confirmation)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "(Optional) New title for the secret.",
+						"description": "Optional: Filter by folder UID",
 					},
 					"fields": map[string]interface{}{
 						"type":        "array",
-						"description": "Array of field objects to update or add. Use flattened dot notation for complex fields (e.g., bankAccount.routingNumber). Each field value must be an array with a single string element, e.g., {type: \"login\", value: [\"user\"]}, {type: \"bankAccount.accountType\", value: [\"Checking\"]}. If a field type is provided that already exists, its value will be replaced. If it doesn't exist, it will be added.",
-						"items": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"type": map[string]interface{}{
-									"type":        "string",
-									"description": "Field type, using dot notation for sub-fields (e.g., login, bankAccount.accountType, address.street1).",
-								},
-								"value": map[string]interface{}{
-									"type":        "array",
-									"description": "Field value, as a single-element array (e.g., [\"the_value\"] ). For enum-like sub-fields (e.g. phone.type), use TitleCase values (e.g. [\"Mobile\"]). For passkey.privateKey, value should be a JSON string representing the JsonWebKey. Example complex fields: bankAccount:[{accountType,routingNumber,accountNumber}], name:[{first,middle,last}], passkey:[{privateKey (JSON string),credentialId,signCount,userId,relyingParty,username,createdDate}], appFiller:[{applicationTitle,contentFilter,macroSequence}], script:[{command,fileRef,recordRef (comma-sep UIDs)}], pamResources:[{controllerUid,folderUid,resourceRef (comma-sep UIDs)}]",
-									"items":       map[string]interface{}{"type": "string"},
-									"minItems":    1,
-									"maxItems":    1,
-								},
-							},
-							"required": []string{"type", "value"},
-						},
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Fields to retrieve for each secret (default: all fields including passwords, login, URL, notes, custom fields)",
 					},
-					"notes": map[string]interface{}{
+					"output_format": map[string]interface{}{
 						"type":        "string",
-						"description": "(Optional) New notes for the secret. If provided, this will replace existing notes.",
+						"enum":        []string{"json", "ndjson"},
+						"description": "Result format. 'ndjson' (one record per line) needs a streaming transport; this server runs over stdio, so it returns a JSON array and sets output_format_note.",
+						"default":     "json",
 					},
 				},
-				"required": []string{"uid"},
 			},
 		},
 		{
-			Name:        "delete_secret",
-			Description: "Delete a secret (requires confirmation)",
+			Name:        "audit_passwords",
+			Description: "Check all passwords (optionally in one folder) for reuse across records and for failing the password policy. Returns only record titles, UIDs and issue types, never password values (requires confirmation).",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"uid": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "Secret UID to delete",
+						"description": "Optional: Only audit secrets in this folder",
 					},
 				},
-				"required": []string{"uid"},
 			},
 		},
 		{
-			Name:        "upload_file",
-			Description: "Upload a file to a secret (requires confirmation)",
+			Name:        "expiring_soon",
+			Description: "List records whose expiration date (expirationDate fields and payment card expiry) falls within the given number of days, including ones already expired. Only dates are returned; no secret values.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"uid": map[string]interface{}{
-						"type":        "string",
-						"description": "Secret UID",
-					},
-					"file_path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to file to upload",
+					"within_days": map[string]interface{}{
+						"type":        "integer",
+						"description": "Size of the window in days (default: 30)",
+						"minimum":     0,
 					},
-					"title": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "File title in KSM",
+						"description": "Optional: Only scan secrets in this folder",
 					},
 				},
-				"required": []string{"uid", "file_path", "title"},
 			},
 		},
 		{
-			Name:        "download_file",
-			Description: "Download a file from a secret",
+			Name:        "lint_vault",
+			Description: "Scan records for fields whose stored structure does not match their type (e.g. a paymentCard value that is not an array of objects). Such fields are silently skipped when secrets are read. Returns record UIDs and the structural problem only; no field values.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"uid": map[string]interface{}{
-						"type":        "string",
-						"description": "Secret UID",
-					},
-					"file_uid": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "File UID or name",
+						"description": "Optional: Only scan secrets in this folder",
 					},
-					"save_path": map[string]interface{}{
+				},
+			},
+		},
+		{
+			Name:        "missing_required_fields",
+			Description: "Check every record against the schema of its record type and report the records missing values for required fields, with the missing field names. Records of types without a schema are counted and skipped. Returns record metadata only; no field values.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "Path to save file",
+						"description": "Optional: Only check secrets in this folder",
 					},
 				},
-				"required": []string{"uid", "file_uid"},
 			},
 		},
 		{
-			Name:        "list_folders",
-			Description: "List all folders",
+			Name:        "find_duplicates",
+			Description: "Find duplicate records: groups of records with the same title (ignoring case) and type, and optionally the same values in key fields. Key field values are compared by hash on the server and never returned; comparing sensitive fields such as password requires confirmation. Returns record UIDs, titles and folders only.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
+				"properties": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only scan secrets in this folder",
+					},
+					"key_fields": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Field types (e.g. login, url) or custom field labels whose values must also match",
+					},
+				},
 			},
 		},
 		{
-			Name:        "create_folder",
-			Description: "Create a new folder (requires confirmation)",
+			Name:        "get_record_type_schema",
+			Description: "Get the schema for a specific KSM record type, detailing all its fields, sub-fields, types, and if they are required. Use this to understand how to structure a create_secret or update_secret call. For a custom record type without a template, the schema is derived from an existing record of that type.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{
+					"type": map[string]interface{}{
 						"type":        "string",
-						"description": "Folder name",
+						"description": "The KSM record type name (e.g., bankAccount, pamMachine, login).",
 					},
-					"parent_uid": map[string]interface{}{
+					"uid": map[string]interface{}{
 						"type":        "string",
-						"description": "Parent folder UID",
+						"description": "Optional record of this type to derive the schema from when the type has no template. Defaults to the first accessible record of the type.",
 					},
 				},
-				"required": []string{"name"},
+				"required": []string{"type"},
 			},
 		},
 		{
-			Name:        "health_check",
-			Description: "Check the health status of the MCP server",
+			Name:        "get_field_type_schema",
+			Description: "Get the definition of a single field type: its sub-fields for complex types (e.g. host, phone), its allowed values for enum fields (e.g. databaseType), and whether its values are masked. Use this when constructing one complex field.",
 			InputSchema: map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
+				"type": "object",
+				"properties": map[string]interface{}{
+					"field_type": map[string]interface{}{
+						"type":        "string",
+						"description": "The field type name (e.g., host, phone, paymentCard, databaseType).",
+					},
+				},
+				"required": []string{"field_type"},
 			},
 		},
 		{
-			Name:        "get_server_version",
-			Description: "Get the current version of the KSM MCP server",
-			InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
-		},
-		{
-			Name:        "delete_folder",
-			Description: "Delete a folder (requires confirmation). Optionally force delete if not empty.",
+			Name:        "preview_field_reconstruction",
+			Description: "Preview how create_secret will reconstruct a flattened fields array (e.g., bankAccount.accountType, name.first, securityQuestion[1].answer) into the structure sent to Keeper, with any warnings about dropped or adjusted values. Read-only: nothing is sent to the vault. Use it to debug a payload before creating a record.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"folder_uid": map[string]interface{}{
-						"type":        "string",
-						"description": "UID of the folder to delete",
-					},
-					"force": map[string]interface{}{
-						"type":        "boolean",
-						"description": "If true, delete the folder even if it is not empty. This is a destructive operation.",
-						"default":     false,
+					"fields": map[string]interface{}{
+						"type":        "array",
+						"description": "Fields in the flattened format accepted by create_secret, e.g., {type: \"login\", value: [\"user\"]}, {type: \"phone.type\", value: [\"Mobile\"]}.",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"type": map[string]interface{}{
+									"type":        "string",
+									"description": "Field type, using dot notation for sub-fields.",
+								},
+								"value": map[string]interface{}{
+									"type":        "array",
+									"description": "Field value, as an array.",
+								},
+							},
+							"required": []string{"type", "value"},
+						},
 					},
 				},
-				"required": []string{"folder_uid"},
+				"required": []string{"fields"},
 			},
 		},
-		// New tool for handling confirmed actions
 		{
-			Name:        "ksm_execute_confirmed_action",
-			Description: "Executes an action that has been previously confirmed by the user via a prompt.",
+			Name:        "create_from_template",
+			Description: "Build a create_secret payload for a record type with every schema field present as an empty placeholder, so fields can be filled in before creating the record. With create=true the placeholder record is created directly (requires confirmation) and can then be filled in with update_secret.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"original_tool_name": map[string]interface{}{
+					"type": map[string]interface{}{
 						"type":        "string",
-						"description": "The name of the original tool that required confirmation (e.g., 'create_secret').",
+						"description": "The KSM record type name (e.g., bankAccount, pamMachine, login).",
 					},
-					"original_tool_args_json": map[string]interface{}{
+					"title": map[string]interface{}{
 						"type":        "string",
-						"description": "The JSON string of arguments originally passed to the tool.",
+						"description": "Title of the record (required when create is true)",
 					},
-					"user_decision": map[string]interface{}{
-						"type":        "boolean",
-						"description": "User's decision: true if approved, false if denied.",
-					},
-					"confirmation_context": map[string]interface{}{
+					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "Optional context from the confirmation prompt.",
-						"nullable":    true,
+						"description": "Folder to create the record in",
+					},
+					"create": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Create the placeholder record instead of only returning the payload (default: false)",
+						"default":     false,
 					},
 				},
-				"required": []string{"original_tool_name", "original_tool_args_json", "user_decision"},
+				"required": []string{"type"},
 			},
 		},
 		{
-			Name:        "get_all_secrets_unmasked",
-			Description: "Get all secrets with complete unmasked data (passwords, custom fields, etc.) in a single operation (requires confirmation)",
+			Name:        "create_pam_resource",
+			Description: "Create a PAM resource record (pamMachine, pamDatabase or pamDirectory) from its logical pieces. The server assembles the pamHostname, pamResources and pamSettings fields, validates them against the record type schema and creates the record through create_secret (requires confirmation).",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
+					"type": map[string]interface{}{
+						"type":        "string",
+						"description": "PAM record type (default: pamMachine)",
+						"enum":        pamResourceTypes,
+						"default":     "pamMachine",
+					},
+					"title": map[string]interface{}{
+						"type":        "string",
+						"description": "Title of the record",
+					},
 					"folder_uid": map[string]interface{}{
 						"type":        "string",
-						"description": "Optional: Filter by folder UID",
+						"description": "Folder to create the record in",
 					},
-					"fields": map[string]interface{}{
+					"hostname": map[string]interface{}{
+						"type":        "string",
+						"description": "Host name or IP address of the resource",
+					},
+					"port": map[string]interface{}{
+						"type":        "string",
+						"description": "Port of the resource (required for pamMachine)",
+					},
+					"controller_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "UID of the gateway controller managing the resource",
+					},
+					"resource_folder_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Folder UID stored in pamResources (default: folder_uid)",
+					},
+					"resource_refs": map[string]interface{}{
 						"type":        "array",
 						"items":       map[string]interface{}{"type": "string"},
-						"description": "Fields to retrieve for each secret (default: all fields including passwords, login, URL, notes, custom fields)",
+						"description": "UIDs of related records, such as pamUser records, to link in pamResources (requires controller_uid)",
+					},
+					"protocol": map[string]interface{}{
+						"type":        "string",
+						"description": "Connection protocol stored in pamSettings",
+						"enum":        pamConnectionProtocols,
+					},
+					"notes": map[string]interface{}{
+						"type":        "string",
+						"description": "Notes for the record",
 					},
 				},
+				"required": []string{"title", "hostname"},
 			},
 		},
 		{
-			Name:        "get_record_type_schema",
-			Description: "Get the schema for a specific KSM record type, detailing all its fields, sub-fields, types, and if they are required. Use this to understand how to structure a create_secret or update_secret call.",
+			Name:        "find_by_field_value",
+			Description: "Find all records whose field of the given type matches a value, e.g. which records use a host, login or URL. Returns record metadata only, never field values. Sensitive field types such as password require confirmation.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"type": map[string]interface{}{
+					"field_type": map[string]interface{}{
 						"type":        "string",
-						"description": "The KSM record type name (e.g., bankAccount, pamMachine, login).",
+						"description": "Field type to compare (e.g. host, login, url, email)",
+					},
+					"value": map[string]interface{}{
+						"type":        "string",
+						"description": "Value to look for (case-insensitive; for complex fields such as host any part may match)",
+					},
+					"folder_uid": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only search secrets in this folder",
 					},
 				},
-				"required": []string{"type"},
+				"required": []string{"field_type", "value"},
 			},
 		},
 	}
 }
 
+// passwordGenerationProperties returns the input schema properties of the password
+// generation parameters shared by generate_password and rotate_passwords_matching
+func passwordGenerationProperties(defaultLength int) map[string]interface{} {
+	return map[string]interface{}{
+		"length": map[string]interface{}{
+			"type":        "integer",
+			"description": fmt.Sprintf("Password length (default: %d)", defaultLength),
+			"minimum":     ksm.MinPasswordLength,
+			"maximum":     ksm.MaxPasswordLength,
+		},
+		"lowercase": map[string]interface{}{
+			"type":        "integer",
+			"description": "Minimum lowercase characters",
+			"minimum":     0,
+		},
+		"uppercase": map[string]interface{}{
+			"type":        "integer",
+			"description": "Minimum uppercase characters",
+			"minimum":     0,
+		},
+		"digits": map[string]interface{}{
+			"type":        "integer",
+			"description": "Minimum digit characters",
+			"minimum":     0,
+		},
+		"special": map[string]interface{}{
+			"type":        "integer",
+			"description": "Minimum special characters",
+			"minimum":     0,
+		},
+		"special_set": map[string]interface{}{
+			"type":        "string",
+			"description": "Custom special character set",
+		},
+		"exclude_similar": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Leave out look-alike characters (i, l, 1, L, o, 0, O)",
+			"default":     false,
+		},
+		"exclude_ambiguous": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Leave out symbols that are hard to read aloud or type, such as brackets, quotes, slashes and punctuation",
+			"default":     false,
+		},
+	}
+}
+
+// withProperties returns properties with extra added
+func withProperties(properties, extra map[string]interface{}) map[string]interface{} {
+	for name, property := range extra {
+		properties[name] = property
+	}
+	return properties
+}
+
 // executeTool executes a tool with the given arguments
 func (s *Server) executeTool(toolName string, args json.RawMessage) (interface{}, error) {
 	// Get current client
@@ -430,6 +1211,10 @@ func (s *Server) executeTool(toolName string, args json.RawMessage) (interface{}
 		"profile": s.currentProfile,
 	})
 
+	if err := s.validateToolArgs(toolName, args); err != nil {
+		return nil, err
+	}
+
 	// Route to appropriate tool handler
 	switch toolName {
 	// Phase 1 Tools
@@ -437,28 +1222,74 @@ func (s *Server) executeTool(toolName string, args json.RawMessage) (interface{}
 		return s.executeListSecrets(client, args)
 	case "get_secret":
 		return s.executeGetSecret(client, args)
+	case "get_secret_safe":
+		return s.executeGetSecretSafe(client, args)
+	case "get_secrets":
+		return s.executeGetSecrets(client, args)
 	case "search_secrets":
 		return s.executeSearchSecrets(client, args)
+	case "find_secret_fuzzy":
+		return s.executeFindSecretFuzzy(client, args)
+	case "get_secret_path":
+		return s.executeGetSecretPath(client, args)
+	case "secret_fingerprint":
+		return s.executeSecretFingerprint(client, args)
 	case "get_field":
 		return s.executeGetField(client, args)
+	case "test_field":
+		return s.executeTestField(client, args)
+	case "list_custom_fields":
+		return s.executeListCustomFields(client, args)
+	case "get_by_external_id":
+		return s.executeGetByExternalID(client, args)
+	case "build_notation":
+		return s.executeBuildNotation(client, args)
 	case "generate_password":
 		return s.executeGeneratePassword(client, args)
 	case "get_totp_code":
 		return s.executeGetTOTPCode(client, args)
+	case "verify_totp":
+		return s.executeVerifyTOTP(client, args)
+	case "setup_totp":
+		return s.executeSetupTOTP(client, args)
+	case "clear_totp":
+		return s.executeClearTOTP(client, args)
 
 	// Phase 2 Tools
 	case "create_secret":
 		return s.executeCreateSecret(client, args)
 	case "update_secret":
 		return s.executeUpdateSecret(client, args)
+	case "update_secrets":
+		return s.executeUpdateSecrets(client, args)
+	case "annotate_records":
+		return s.executeAnnotateRecords(client, args)
+	case "rotate_passwords_matching":
+		return s.executeRotatePasswordsMatching(client, args)
+	case "rename_secret":
+		return s.executeRenameSecret(client, args)
+	case "copy_field":
+		return s.executeCopyField(client, args)
 	case "delete_secret":
 		return s.executeDeleteSecret(client, args)
 	case "upload_file":
 		return s.executeUploadFile(client, args)
+	case "upload_file_from_url":
+		return s.executeUploadFileFromURL(client, args)
 	case "download_file":
 		return s.executeDownloadFile(client, args)
+	case "download_all_files":
+		return s.executeDownloadAllFiles(client, args)
 	case "list_folders":
 		return s.executeListFolders(client, args)
+	case "writable_folders":
+		return s.executeWritableFolders(client, args)
+	case "list_shared_folders":
+		return s.executeListSharedFolders(client, args)
+	case "is_shared_folder":
+		return s.executeIsSharedFolder(client, args)
+	case "test_folder_access":
+		return s.executeTestFolderAccess(client, args)
 	case "create_folder":
 		return s.executeCreateFolder(client, args)
 	case "health_check":
@@ -472,14 +1303,44 @@ func (s *Server) executeTool(toolName string, args json.RawMessage) (interface{}
 		// We can pass nil as it won't be used.
 		client, _ := s.getCurrentClient() // Get client, ignore error for this specific case or handle if critical
 		return s.executeGetServerVersion(client, args)
+	case "validate_record":
+		return s.executeValidateRecord(client, args)
+	case "session_info":
+		return s.executeSessionInfo(client, args)
+	case "list_tools_detailed":
+		return s.executeListToolsDetailed(client, args)
 	case "delete_folder":
 		return s.executeDeleteFolder(client, args)
+	case "empty_folder":
+		return s.executeEmptyFolder(client, args)
 	case "ksm_execute_confirmed_action":
 		return s.executeKsmExecuteConfirmedAction(args)
+	case "cancel_confirmation":
+		return s.executeCancelConfirmation(args)
 	case "get_all_secrets_unmasked":
 		return s.executeGetAllSecretsUnmasked(client, args)
+	case "audit_passwords":
+		return s.executeAuditPasswords(client, args)
+	case "expiring_soon":
+		return s.executeExpiringSoon(client, args)
+	case "lint_vault":
+		return s.executeLintVault(client, args)
+	case "missing_required_fields":
+		return s.executeMissingRequiredFields(client, args)
+	case "find_duplicates":
+		return s.executeFindDuplicates(client, args)
 	case "get_record_type_schema":
 		return s.executeGetRecordTypeSchema(client, args)
+	case "get_field_type_schema":
+		return s.executeGetFieldTypeSchema(args)
+	case "preview_field_reconstruction":
+		return s.executePreviewFieldReconstruction(args)
+	case "create_from_template":
+		return s.executeCreateFromTemplate(client, args)
+	case "create_pam_resource":
+		return s.executeCreatePamResource(client, args)
+	case "find_by_field_value":
+		return s.executeFindByFieldValue(client, args)
 
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", toolName)
@@ -493,6 +1354,7 @@ func (s *Server) executeKsmExecuteConfirmedAction(args json.RawMessage) (interfa
 		OriginalToolArgsJSON string `json:"original_tool_args_json"`
 		UserDecision         bool   `json:"user_decision"`
 		ConfirmationContext  string `json:"confirmation_context,omitempty"`
+		ConfirmationID       string `json:"confirmation_id,omitempty"`
 	}
 
 	if err := json.Unmarshal(args, &params); err != nil {
@@ -505,6 +1367,22 @@ func (s *Server) executeKsmExecuteConfirmedAction(args json.RawMessage) (interfa
 		"profile":       s.currentProfile,
 	})
 
+	// A configured backend is the only one that may approve; the client's decision is not trusted
+	if s.options.ConfirmationBackend != nil {
+		s.logSystem(audit.EventAccessDenied, "ksm_execute_confirmed_action refused: confirmations are decided by the backend", map[string]interface{}{
+			"original_tool": params.OriginalToolName,
+			"profile":       s.currentProfile,
+		})
+		return nil, &ToolError{
+			Code:    ErrorCodeAccessDenied,
+			Message: fmt.Sprintf("confirmations are decided by the configured confirmation backend; call %s again to have it decide", params.OriginalToolName),
+		}
+	}
+
+	if err := s.consumeConfirmation(params.ConfirmationID, params.OriginalToolName, params.OriginalToolArgsJSON); err != nil {
+		return nil, err
+	}
+
 	if !params.UserDecision {
 		return map[string]interface{}{"status": "operation_denied", "message": "User denied the operation."}, nil
 	}
@@ -523,33 +1401,95 @@ func (s *Server) executeKsmExecuteConfirmedAction(args json.RawMessage) (interfa
 		originalToolArgs = json.RawMessage("{}") // Empty JSON object if no args
 	}
 
-	// IMPORTANT: The actual execution logic for each tool needs to be refactored
-	// so it can be called here *without* its own confirmation step.
-	// This is a placeholder for that dispatch logic.
-	switch params.OriginalToolName {
-	case "create_secret":
-		// Call a refactored version: e.g., s.executeCreateSecretInternal(client, originalToolArgs, true /*isConfirmed*/)
-		return s.executeCreateSecretConfirmed(client, originalToolArgs)
-	case "get_secret": // Assuming this is for unmasking
-		// Call a refactored version: e.g., s.executeGetSecretInternal(client, originalToolArgs, true /*isConfirmed*/)
-		return s.executeGetSecretConfirmed(client, originalToolArgs)
-	case "update_secret":
-		return s.executeUpdateSecretConfirmed(client, originalToolArgs)
-	case "delete_secret":
-		return s.executeDeleteSecretConfirmed(client, originalToolArgs)
-	case "upload_file":
-		return s.executeUploadFileConfirmed(client, originalToolArgs)
-	case "download_file":
-		return s.executeDownloadFileConfirmed(client, originalToolArgs)
-	case "create_folder":
-		return s.executeCreateFolderConfirmed(client, originalToolArgs)
-	case "delete_folder":
-		return s.executeDeleteFolderConfirmed(client, originalToolArgs)
-	case "get_all_secrets_unmasked":
-		return s.executeGetAllSecretsUnmaskedConfirmed(client, originalToolArgs)
-
-	// Add other sensitive tools here
-	default:
+	handler, ok := s.confirmedActionHandlers()[params.OriginalToolName]
+	if !ok {
 		return nil, fmt.Errorf("cannot execute unhandled confirmed original tool: %s", params.OriginalToolName)
 	}
+	// The arguments come back from the client, so they are checked like a direct call
+	if err := s.validateToolArgs(params.OriginalToolName, originalToolArgs); err != nil {
+		return nil, err
+	}
+	return handler(client, originalToolArgs)
+}
+
+// confirmedActionHandlers maps each tool that can require user confirmation to the
+// handler that performs it once approved. It is the single source of truth for
+// which tools go through ksm_confirm_action.
+func (s *Server) confirmedActionHandlers() map[string]func(KSMClient, json.RawMessage) (interface{}, error) {
+	return map[string]func(KSMClient, json.RawMessage) (interface{}, error){
+		"create_secret":             s.executeCreateSecretConfirmed,
+		"get_secret":                s.executeGetSecretConfirmed, // only when unmasking
+		"update_secret":             s.executeUpdateSecretConfirmed,
+		"update_secrets":            s.executeUpdateSecretsConfirmed,
+		"setup_totp":                s.executeSetupTOTPConfirmed,
+		"clear_totp":                s.executeClearTOTPConfirmed,
+		"rename_secret":             s.executeRenameSecretConfirmed,
+		"copy_field":                s.executeCopyFieldConfirmed,
+		"delete_secret":             s.executeDeleteSecretConfirmed,
+		"upload_file":               s.executeUploadFileConfirmed,
+		"upload_file_from_url":      s.executeUploadFileFromURLConfirmed,
+		"download_file":             s.executeDownloadFileConfirmed,
+		"download_all_files":        s.executeDownloadAllFilesConfirmed,
+		"create_folder":             s.executeCreateFolderConfirmed,
+		"delete_folder":             s.executeDeleteFolderConfirmed,
+		"empty_folder":              s.executeEmptyFolderConfirmed,
+		"get_all_secrets_unmasked":  s.executeGetAllSecretsUnmaskedConfirmed,
+		"audit_passwords":           s.executeAuditPasswordsConfirmed,
+		"find_by_field_value":       s.executeFindByFieldValueConfirmed,
+		"find_duplicates":           s.executeFindDuplicatesConfirmed,
+		"annotate_records":          s.executeAnnotateRecordsConfirmed,
+		"rotate_passwords_matching": s.executeRotatePasswordsMatchingConfirmed,
+	}
+}
+
+// mutatingTools lists the tools that change vault contents
+var mutatingTools = map[string]bool{
+	"generate_password":         true, // when save_to_secret is set
+	"setup_totp":                true,
+	"clear_totp":                true,
+	"create_secret":             true,
+	"create_from_template":      true, // when create is set
+	"create_pam_resource":       true,
+	"update_secret":             true,
+	"update_secrets":            true,
+	"annotate_records":          true,
+	"rotate_passwords_matching": true,
+	"rename_secret":             true,
+	"copy_field":                true,
+	"delete_secret":             true,
+	"upload_file":               true,
+	"upload_file_from_url":      true,
+	"create_folder":             true,
+	"delete_folder":             true,
+	"empty_folder":              true,
+}
+
+// executeListToolsDetailed handles the list_tools_detailed tool
+func (s *Server) executeListToolsDetailed(client KSMClient, args json.RawMessage) (interface{}, error) {
+	s.logSystem(audit.EventAccess, "Tool: list_tools_detailed", map[string]interface{}{
+		"profile": s.currentProfile,
+	})
+
+	confirmed := s.confirmedActionHandlers()
+	confirmationBypassed := s.options.BatchMode || s.options.AutoApprove
+
+	available := s.getAvailableTools()
+	tools := make([]map[string]interface{}, 0, len(available))
+	for _, tool := range available {
+		_, requiresConfirmation := confirmed[tool.Name]
+		tools = append(tools, map[string]interface{}{
+			"name":                  tool.Name,
+			"description":           tool.Description,
+			"input_schema":          tool.InputSchema,
+			"requires_confirmation": requiresConfirmation && !confirmationBypassed,
+			"mutating":              mutatingTools[tool.Name],
+			"read_only":             !mutatingTools[tool.Name],
+		})
+	}
+
+	return map[string]interface{}{
+		"tools":                 tools,
+		"count":                 len(tools),
+		"confirmation_bypassed": confirmationBypassed,
+	}, nil
 }
@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Confirmation warning verbosity levels accepted for mcp.confirmation_verbosity
+const (
+	VerbosityVerbose = "verbose" // full explanation of the action's consequences (default)
+	VerbosityConcise = "concise" // one short sentence, plus the AI exposure warning when it applies
+)
+
+// aiExposureWarning is kept in every confirmation that reveals values to the AI
+// model, whatever the configured verbosity
+const aiExposureWarning = "Values will be shown directly TO THE AI MODEL and its context."
+
+// confirmationWarning is the warning shown in a ksm_confirm_action prompt
+type confirmationWarning struct {
+	Verbose     string // full warning
+	Concise     string // short warning
+	ExposesToAI bool   // the action returns secret values to the AI model
+}
+
+// with appends a detail that matters in both verbosity levels, such as an
+// applied server default
+func (w confirmationWarning) with(detail string) confirmationWarning {
+	w.Verbose += " " + detail
+	w.Concise += " " + detail
+	return w
+}
+
+// confirmationWarnings holds the warnings of actions whose text does not depend on
+// their arguments, keyed by tool name
+var confirmationWarnings = map[string]confirmationWarning{
+	"create_secret": {
+		Verbose: "This will create a new entry in your Keeper vault.",
+		Concise: "Creates a record.",
+	},
+	"update_secret": {
+		Verbose: "This will modify an existing entry in your Keeper vault.",
+		Concise: "Modifies a record.",
+	},
+	"rename_secret": {
+		Verbose: "This will change the title of an existing entry in your Keeper vault. Notations that reference the secret by its current title will stop resolving.",
+		Concise: "Renames a record; title-based notations stop resolving.",
+	},
+	"copy_field": {
+		Verbose: "This will overwrite the current value of the target field in your Keeper vault. The copied value is read and written by the server and is never shown to the AI model.",
+		Concise: "Overwrites the target field.",
+	},
+	"delete_secret": {
+		Verbose: "This action CANNOT BE UNDONE. The secret will be permanently removed from your Keeper vault.",
+		Concise: "Permanent; CANNOT BE UNDONE.",
+	},
+	"upload_file": {
+		Verbose: "This will add a file attachment to an existing secret in your Keeper vault.",
+		Concise: "Adds an attachment.",
+	},
+	"upload_file_from_url": {
+		Verbose: "This will download a file from the given URL and add it as an attachment to an existing secret in your Keeper vault.",
+		Concise: "Downloads the URL and adds it as an attachment.",
+	},
+	"download_file": {
+		Verbose: "This will download a file from your Keeper vault to your local system.",
+		Concise: "Writes a file to the local system.",
+	},
+	"create_folder": {
+		Verbose: "This will create a new folder in your Keeper vault.",
+		Concise: "Creates a folder.",
+	},
+	"empty_folder": {
+		Verbose: "This action CANNOT BE UNDONE. Every secret and subfolder inside the folder will be permanently removed; the folder itself will be kept.",
+		Concise: "Permanently removes the folder's contents; CANNOT BE UNDONE.",
+	},
+	"audit_passwords": {
+		Verbose: "The server will read every password internally to compare them. No password values are returned to the AI model; only record titles, UIDs and issue types are reported.",
+		Concise: "Passwords are compared server-side; none are returned.",
+	},
+	"clear_totp": {
+		Verbose: "This will delete the record's one-time code seed. Codes can no longer be generated from Keeper, and the seed cannot be recovered unless the authenticator is re-enrolled.",
+		Concise: "Deletes the TOTP seed; it cannot be recovered.",
+	},
+	"find_by_field_value": {
+		Verbose: "The server will read this sensitive field from every record to compare it with the given value. No field values are returned, but a match confirms which records hold that value.",
+		Concise: "Sensitive values are compared server-side; matches reveal which records hold the value.",
+	},
+	"find_duplicates": {
+		Verbose: "The server will read the requested sensitive fields from every record and compare them by hash. No field values are returned, but each reported group reveals that its records share those values.",
+		Concise: "Sensitive values are compared server-side; groups reveal which records share them.",
+	},
+}
+
+// getSecretWarning warns about unmasking a secret, calling out long notes
+func getSecretWarning(notesLength int) confirmationWarning {
+	w := confirmationWarning{
+		Verbose:     "This will expose all requested fields of the secret, including the password if present, directly TO THE AI MODEL and its context. This information could be logged or stored by the AI service.",
+		Concise:     "Reveals the secret's values.",
+		ExposesToAI: true,
+	}
+	if notesLength > longNotesThreshold {
+		w = w.with(fmt.Sprintf("The record's notes (%d characters of free-form text) will also be exposed.", notesLength))
+	}
+	return w
+}
+
+// getAllSecretsUnmaskedWarning warns about unmasking every secret in bulk
+func getAllSecretsUnmaskedWarning(maxRecords int) confirmationWarning {
+	return confirmationWarning{
+		Verbose:     fmt.Sprintf("This will expose ALL PASSWORDS and sensitive data from your secrets directly TO THE AI MODEL. This is a bulk operation that could expose a large amount of sensitive information. At most %d records can be unmasked in one call.", maxRecords),
+		Concise:     fmt.Sprintf("Reveals ALL PASSWORDS in bulk (at most %d records).", maxRecords),
+		ExposesToAI: true,
+	}
+}
+
+// downloadAllFilesWarning warns about returning or saving every attachment
+func downloadAllFilesWarning(savePath string) confirmationWarning {
+	if savePath != "" {
+		return confirmationWarning{
+			Verbose: "This will write all attachments from your Keeper vault to your local system.",
+			Concise: "Writes all attachments to the local system.",
+		}
+	}
+	return confirmationWarning{
+		Verbose:     "This will return the contents of all attachments to the AI client.",
+		Concise:     "Returns all attachments.",
+		ExposesToAI: true,
+	}
+}
+
+// deleteFolderWarning warns about deleting a folder, noting when force was defaulted
+func deleteFolderWarning(force, forceDefaulted bool) confirmationWarning {
+	w := confirmationWarning{
+		Verbose: "This action CANNOT BE UNDONE. The folder must be empty to be deleted.",
+		Concise: "Permanent; the folder must be empty.",
+	}
+	if force {
+		w = confirmationWarning{
+			Verbose: "This action CANNOT BE UNDONE. The folder and ALL ITS CONTENTS (secrets and subfolders) will be permanently removed.",
+			Concise: "Permanently removes the folder and ALL ITS CONTENTS.",
+		}
+	}
+	if forceDefaulted {
+		w = w.with(fmt.Sprintf("'force' was not specified, so the server default (force: %t) applies.", force))
+	}
+	return w
+}
+
+// updateSecretsWarning warns about a bulk update of the given records
+func updateSecretsWarning(uids []string) confirmationWarning {
+	return confirmationWarning{
+		Verbose: fmt.Sprintf("This will modify %d existing entries in your Keeper vault (UIDs: %s). Each listed field replaces the current value.", len(uids), strings.Join(uids, ", ")),
+		Concise: fmt.Sprintf("Modifies %d records (UIDs: %s).", len(uids), strings.Join(uids, ", ")),
+	}
+}
+
+// annotateRecordsWarning warns about appending a note to the given records
+func annotateRecordsWarning(titles []string) confirmationWarning {
+	return confirmationWarning{
+		Verbose: fmt.Sprintf("This will append the rendered template to the notes of %d record(s): %s. Existing notes are kept.", len(titles), strings.Join(titles, ", ")),
+		Concise: fmt.Sprintf("Appends a note to %d record(s): %s.", len(titles), strings.Join(titles, ", ")),
+	}
+}
+
+// rotatePasswordsWarning warns about replacing the passwords of the given records
+func rotatePasswordsWarning(titles []string) confirmationWarning {
+	return confirmationWarning{
+		Verbose: fmt.Sprintf("This will replace the password of %d login record(s) with newly generated ones: %s. Systems still using the current passwords will stop working until they are updated. The new passwords are stored in Keeper and never shown to the AI model.", len(titles), strings.Join(titles, ", ")),
+		Concise: fmt.Sprintf("Replaces the password of %d record(s): %s.", len(titles), strings.Join(titles, ", ")),
+	}
+}
+
+// setupTOTPWarning warns about replacing a TOTP seed with a generated or provided one,
+// and about returning it when unmasking
+func setupTOTPWarning(unmask, provided bool) confirmationWarning {
+	w := confirmationWarning{
+		Verbose: "This will generate a new TOTP seed and store it in the record's one-time code field, replacing any existing one. Authenticators enrolled with the previous seed will stop matching.",
+		Concise: "Replaces the TOTP seed; enrolled authenticators stop matching.",
+	}
+	if provided {
+		w = confirmationWarning{
+			Verbose: "This will store the provided TOTP seed in the record's one-time code field, replacing any existing one. Authenticators enrolled with a different seed will stop matching.",
+			Concise: "Replaces the TOTP seed with the provided one.",
+		}
+	}
+	if unmask {
+		w.Verbose += " The provisioning URI, including the seed, will be returned directly TO THE AI MODEL and its context."
+		w.ExposesToAI = true
+	}
+	return w
+}
+
+// confirmationWarningText renders a warning at the configured verbosity. Concise
+// warnings always keep the AI exposure warning when the action reveals values.
+func (s *Server) confirmationWarningText(w confirmationWarning) string {
+	if s.options.ConfirmationVerbosity != VerbosityConcise {
+		return w.Verbose
+	}
+	if w.ExposesToAI {
+		return w.Concise + " " + aiExposureWarning
+	}
+	return w.Concise
+}
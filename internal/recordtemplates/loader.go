@@ -160,6 +160,59 @@ func GetSchema(recordTypeID string) (*types.RecordTypeSchema, error) {
 	return schema, nil
 }
 
+// SynthesizeSchema builds a minimal schema for a record type that has no template from
+// the field layout of one of its records. Fields of a type defined in fields.json get their usual sub-fields; other fields are
+// described by their stored type. A layout does not say which fields are required, so
+// no field is marked required.
+func SynthesizeSchema(layout *types.FullRecordTemplate) (*types.RecordTypeSchema, error) {
+	if loadedFields == nil || loadedFieldTypes == nil {
+		return nil, fmt.Errorf("record templates not loaded. Call LoadRecordTemplates first")
+	}
+	if layout == nil || layout.ID == "" {
+		return nil, fmt.Errorf("a record layout with a record type is required to synthesize a schema")
+	}
+
+	schema := &types.RecordTypeSchema{
+		RecordType:  layout.ID,
+		Description: fmt.Sprintf("Synthesized from an existing %s record; no template is available for this record type", layout.ID),
+		Fields:      make([]types.SchemaField, 0),
+		Notes:       "This schema lists the fields an existing record of this type stores. Which fields are required is unknown, so none are marked required. Fields should be provided in a flattened format (e.g., 'host.hostName').",
+	}
+	for _, field := range layout.Fields {
+		appendSynthesizedField(field, &schema.Fields, false)
+	}
+	for _, field := range layout.Custom {
+		appendSynthesizedField(field, &schema.Fields, true)
+	}
+	return schema, nil
+}
+
+// appendSynthesizedField adds a record's field to a synthesized schema. Field types the
+// loaded templates do not define are kept as single fields rather than reported as
+// template parse errors.
+func appendSynthesizedField(field types.RecordTemplateField, schemaFields *[]types.SchemaField, isCustom bool) {
+	if basicField, ok := loadedFields[field.Ref]; ok {
+		if _, ok := loadedFieldTypes[basicField.Type]; ok {
+			appendSchemaFields(field, schemaFields, isCustom, "synthesized")
+			return
+		}
+	}
+
+	name := field.Label
+	if name == "" {
+		name = field.Ref
+	}
+	if isCustom {
+		name = "custom." + name
+	}
+	*schemaFields = append(*schemaFields, types.SchemaField{
+		Name:        name,
+		Description: fmt.Sprintf("Field of type '%s', which the loaded templates do not define", field.Ref),
+		Type:        field.Ref,
+		Ref:         field.Ref,
+	})
+}
+
 // appendSchemaFields is a helper to recursively build the schema fields.
 // It now takes recordTypeID to help with context-specific decisions if needed.
 func appendSchemaFields(tplField types.RecordTemplateField, schemaFields *[]types.SchemaField, isCustom bool, recordTypeID string) {
@@ -202,7 +255,8 @@ func appendSchemaFields(tplField types.RecordTemplateField, schemaFields *[]type
 				Name:        finalNamePrefix + fieldLabelToUse + "." + elementName,
 				Description: fmt.Sprintf("%s - %s", fieldTypeDefinition.Description, elementName),
 				Type:        "string",
-				Required:    tplField.Required,
+				Required:    tplField.Required && !optionalElements[basicField.Type][elementName],
+				Ref:         tplField.Ref,
 			}
 			addExampleValuesToSubField(&sf, basicField.Type, elementName)
 			*schemaFields = append(*schemaFields, sf)
@@ -213,25 +267,104 @@ func appendSchemaFields(tplField types.RecordTemplateField, schemaFields *[]type
 			Description: fieldTypeDefinition.Description,
 			Type:        basicField.Type,
 			Required:    tplField.Required,
+			Ref:         tplField.Ref,
 		}
-		addExampleValuesToSimpleField(&sf, basicField.Type)
+		addExampleValuesToSimpleField(&sf, basicField)
 		*schemaFields = append(*schemaFields, sf)
 	}
 }
 
+// optionalElements lists the elements of complex field types that may stay empty
+// when the field itself is required
+var optionalElements = map[string]map[string]bool{
+	"name": {"middle": true},
+}
+
 // addExampleValuesToSimpleField adds example values for simple enum-like fields
-func addExampleValuesToSimpleField(schemaField *types.SchemaField, fieldTypeID string) {
-	// This is for simple fields that are enums directly, not sub-fields of complex types
-	// Example: if 'databaseType' was a simple field itself.
-	switch fieldTypeID {
-	case "databaseType":
-		schemaField.ExampleValues = []string{"PostgreSQL", "MySQL", "MariaDB", "MSSQL", "Oracle", "MongoDB"} // From vault's DatabaseType enum
-		schemaField.Description += " (e.g., PostgreSQL, MySQL)"
-	case "directoryType":
-		schemaField.ExampleValues = []string{"Active Directory", "OpenLDAP"} // From vault's DirectoryType enum
-		schemaField.Description += " (e.g., Active Directory)"
-		// Add more simple enum field types here if needed
+func addExampleValuesToSimpleField(schemaField *types.SchemaField, basicField types.TemplateBasicField) {
+	// Dropdown fields such as databaseType declare their allowed values in fields.json
+	if len(basicField.Enum) > 0 {
+		schemaField.ExampleValues = basicField.Enum
+		schemaField.Description += fmt.Sprintf(" (one of: %s)", strings.Join(basicField.Enum, ", "))
+	}
+}
+
+// GetFieldEnum returns the allowed values for an enum (dropdown) field such as
+// databaseType, and false if the field is not an enum or templates are not loaded.
+func GetFieldEnum(fieldID string) ([]string, bool) {
+	basicField, ok := loadedFields[fieldID]
+	if !ok || len(basicField.Enum) == 0 {
+		return nil, false
 	}
+	return basicField.Enum, true
+}
+
+// GetFieldTypeSchema returns the definition of a single field type: its sub-fields
+// for complex types and its allowed values for enum fields. The name may be a field
+// from fields.json (e.g. "wifiEncryption") or a type from field-types.json (e.g. "host").
+func GetFieldTypeSchema(name string) (*types.FieldTypeSchema, error) {
+	if loadedFields == nil || loadedFieldTypes == nil {
+		return nil, fmt.Errorf("record templates not loaded. Call LoadRecordTemplates first")
+	}
+
+	basicField, isField := loadedFields[name]
+	if !isField {
+		for id, bf := range loadedFields {
+			if strings.EqualFold(id, name) {
+				basicField, isField = bf, true
+				break
+			}
+		}
+	}
+
+	typeID := name
+	if isField {
+		typeID = basicField.Type
+	}
+	definition, ok := loadedFieldTypes[typeID]
+	if !ok {
+		for id, ftd := range loadedFieldTypes {
+			if strings.EqualFold(id, typeID) {
+				definition, ok = ftd, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("field type not found: %s", name)
+	}
+
+	fieldType := definition.ID
+	if isField {
+		fieldType = basicField.ID
+	}
+	schema := &types.FieldTypeSchema{
+		FieldType:   fieldType,
+		Type:        definition.ID,
+		Description: definition.Description,
+		Multiple:    basicField.Multiple,
+	}
+
+	for _, elementName := range definition.Elements {
+		sf := types.SchemaField{
+			Name:        fieldType + "." + elementName,
+			Description: fmt.Sprintf("%s - %s", definition.Description, elementName),
+			Type:        "string",
+		}
+		addExampleValuesToSubField(&sf, definition.ID, elementName)
+		schema.SubFields = append(schema.SubFields, sf)
+	}
+	if len(basicField.Enum) > 0 {
+		schema.IsEnum = true
+		schema.AllowedValues = basicField.Enum
+	}
+
+	if len(schema.SubFields) > 0 {
+		schema.Notes = "Provide each sub-field as its own flattened field (e.g., '" + schema.SubFields[0].Name + "') with a single-element string array value."
+	} else {
+		schema.Notes = "Provide the value as a single-element string array."
+	}
+	return schema, nil
 }
 
 func applyUITransformations(recordTypeID string, schema *types.RecordTypeSchema) {
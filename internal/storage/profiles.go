@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/keeper-security/ksm-mcp/internal/crypto"
@@ -189,15 +190,64 @@ func (ps *ProfileStore) ProfileExists(name string) bool {
 func (ps *ProfileStore) GetProfileMetadata() map[string]types.ProfileMetadata {
 	metadata := make(map[string]types.ProfileMetadata)
 	for name, profile := range ps.profiles {
-		metadata[name] = types.ProfileMetadata{
-			Name:      profile.Name,
-			CreatedAt: profile.CreatedAt,
-			UpdatedAt: profile.UpdatedAt,
+		metadata[name] = DescribeProfile(profile)
+	}
+	return metadata
+}
+
+// defaultKeeperHostname is the server the KSM SDK uses when a config has no hostname
+const defaultKeeperHostname = "keepersecurity.com"
+
+// keeperRegionHostnames maps the KSM SDK region codes to their hostnames
+var keeperRegionHostnames = map[string]string{
+	"US":  "keepersecurity.com",
+	"EU":  "keepersecurity.eu",
+	"AU":  "keepersecurity.com.au",
+	"GOV": "govcloud.keepersecurity.us",
+	"JP":  "keepersecurity.jp",
+	"CA":  "keepersecurity.ca",
+}
+
+// DescribeProfile returns the metadata that identifies which Keeper region and
+// application a profile targets. Only the hostname and a masked client ID are read
+// from the config; private keys and app keys are never included.
+func DescribeProfile(profile *types.Profile) types.ProfileMetadata {
+	metadata := types.ProfileMetadata{
+		Name:      profile.Name,
+		CreatedAt: profile.CreatedAt,
+		UpdatedAt: profile.UpdatedAt,
+	}
+
+	hostname := strings.TrimSpace(profile.Config["hostname"])
+	if hostname == "" {
+		hostname = defaultKeeperHostname
+	}
+	// The SDK also accepts a region code in place of the hostname
+	if regionHost, ok := keeperRegionHostnames[strings.ToUpper(hostname)]; ok {
+		hostname = regionHost
+	}
+	metadata.Hostname = hostname
+	for region, regionHost := range keeperRegionHostnames {
+		if strings.EqualFold(hostname, regionHost) {
+			metadata.Region = region
+			break
 		}
 	}
+
+	if clientID := profile.Config["clientId"]; clientID != "" {
+		metadata.ClientID = maskClientID(clientID)
+	}
 	return metadata
 }
 
+// maskClientID keeps just enough of a client ID to tell profiles apart
+func maskClientID(clientID string) string {
+	if len(clientID) <= 8 {
+		return "********"
+	}
+	return clientID[:4] + "..." + clientID[len(clientID)-4:]
+}
+
 // saveProfiles encrypts and saves all profiles to disk
 func (ps *ProfileStore) saveProfiles() error {
 	db := &ProfilesDatabase{
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -499,6 +501,69 @@ func TestProfileCopy(t *testing.T) {
 }
 
 // setupTestStore creates a test profile store with temporary directory
+func TestDescribeProfile(t *testing.T) {
+	const (
+		clientID   = "Q2xpZW50SWRWYWx1ZTEyMzQ1Njc4OTA="
+		privateKey = "MIGHAgEAMBMGByqGSM49AgEGCCqGSM49AwEHBG0wawIBAQQg"
+		appKey     = "YXBwLWtleS1tYXRlcmlhbC0xMjM0NTY3OA=="
+	)
+
+	tests := []struct {
+		name         string
+		hostname     string
+		wantHostname string
+		wantRegion   string
+	}{
+		{"explicit EU hostname", "keepersecurity.eu", "keepersecurity.eu", "EU"},
+		{"region code", "gov", "govcloud.keepersecurity.us", "GOV"},
+		{"default hostname", "", "keepersecurity.com", "US"},
+		{"custom hostname", "ksm.internal.example.com", "ksm.internal.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := setupTestStore(t)
+			defer store.Close()
+
+			config := map[string]string{
+				"clientId":   clientID,
+				"privateKey": privateKey,
+				"appKey":     appKey,
+			}
+			if tt.hostname != "" {
+				config["hostname"] = tt.hostname
+			}
+			if err := store.CreateProfile("regional", config); err != nil {
+				t.Fatalf("Failed to create profile: %v", err)
+			}
+
+			metadata, ok := store.GetProfileMetadata()["regional"]
+			if !ok {
+				t.Fatal("Profile metadata not found")
+			}
+			if metadata.Hostname != tt.wantHostname {
+				t.Errorf("Expected hostname '%s', got '%s'", tt.wantHostname, metadata.Hostname)
+			}
+			if metadata.Region != tt.wantRegion {
+				t.Errorf("Expected region '%s', got '%s'", tt.wantRegion, metadata.Region)
+			}
+			if metadata.ClientID != "Q2xp...OTA=" {
+				t.Errorf("Expected masked client ID, got '%s'", metadata.ClientID)
+			}
+
+			encoded, err := json.Marshal(metadata)
+			if err != nil {
+				t.Fatalf("Failed to marshal metadata: %v", err)
+			}
+			for _, secret := range []string{clientID, privateKey, appKey} {
+				if strings.Contains(string(encoded), secret) {
+					t.Errorf("Profile metadata exposes key material: %s", encoded)
+				}
+			}
+		})
+	}
+}
+
 func setupTestStore(t *testing.T) *ProfileStore {
 	tempDir := t.TempDir()
 	originalConfigDir := os.Getenv("KSM_MCP_CONFIG_DIR")
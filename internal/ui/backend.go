@@ -0,0 +1,173 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+)
+
+// Confirmation backend names accepted by NewBackend
+const (
+	BackendPrompt      = "prompt"      // the MCP client confirms through the ksm_confirm_action prompt
+	BackendInteractive = "interactive" // the Confirmer decides locally (approves only in batch/auto-approve mode)
+	BackendWebhook     = "webhook"     // an external approval service decides
+)
+
+// Backend decides whether an action that requires confirmation may proceed
+type Backend interface {
+	Confirm(ctx context.Context, message string) *ConfirmationResult
+	ConfirmOperation(ctx context.Context, operation, resource string, details map[string]interface{}) *ConfirmationResult
+}
+
+// Ensure the confirmation backends implement Backend
+var (
+	_ Backend = (*Confirmer)(nil)
+	_ Backend = (*WebhookBackend)(nil)
+)
+
+// NewBackend returns the confirmation backend with the given name. The prompt backend
+// is asynchronous, so it has no Backend value: NewBackend returns nil and the server
+// hands the confirmation to the MCP client instead.
+func NewBackend(name string, config types.Confirmation, webhook WebhookConfig) (Backend, error) {
+	switch name {
+	case "", BackendPrompt:
+		return nil, nil
+	case BackendInteractive:
+		return NewConfirmer(config), nil
+	case BackendWebhook:
+		if webhook.Timeout <= 0 {
+			webhook.Timeout = config.Timeout
+		}
+		backend, err := NewWebhookBackend(webhook)
+		if err != nil {
+			return nil, err
+		}
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unknown confirmation backend %q: expected %q, %q or %q", name, BackendPrompt, BackendInteractive, BackendWebhook)
+	}
+}
+
+// defaultWebhookTimeout bounds how long the webhook backend waits for a decision
+const defaultWebhookTimeout = 5 * time.Minute
+
+// WebhookConfig configures the webhook confirmation backend
+type WebhookConfig struct {
+	URL       string        // approval endpoint that receives a POST per action
+	AuthToken string        // sent as a bearer token when set
+	Timeout   time.Duration // how long to wait for a decision; 0 uses defaultWebhookTimeout
+}
+
+// WebhookRequest is the JSON body POSTed to the approval service
+type WebhookRequest struct {
+	Operation   string                 `json:"operation"`
+	Resource    string                 `json:"resource,omitempty"`
+	Message     string                 `json:"message"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+	RequestedAt time.Time              `json:"requested_at"`
+}
+
+// WebhookResponse is the decision the approval service returns
+type WebhookResponse struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// WebhookBackend routes confirmations to an external approval service. The service
+// answers each POST with a WebhookResponse once a decision is made; anything else,
+// including no answer before the timeout, denies the action.
+type WebhookBackend struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookBackend creates a webhook confirmation backend
+func NewWebhookBackend(config WebhookConfig) (*WebhookBackend, error) {
+	if config.URL == "" {
+		return nil, errors.New("webhook confirmation backend requires a URL")
+	}
+	if !strings.HasPrefix(config.URL, "https://") && !strings.HasPrefix(config.URL, "http://") {
+		return nil, fmt.Errorf("webhook confirmation URL must use http or https: %s", config.URL)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultWebhookTimeout
+	}
+	return &WebhookBackend{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// Confirm asks the approval service to approve the given message
+func (w *WebhookBackend) Confirm(ctx context.Context, message string) *ConfirmationResult {
+	return w.send(ctx, WebhookRequest{Operation: "confirm", Message: message})
+}
+
+// ConfirmOperation asks the approval service to approve an operation on a resource.
+// Sensitive detail values are masked before they leave the server.
+func (w *WebhookBackend) ConfirmOperation(ctx context.Context, operation, resource string, details map[string]interface{}) *ConfirmationResult {
+	var safeDetails map[string]interface{}
+	if len(details) > 0 {
+		safeDetails = make(map[string]interface{}, len(details))
+		for key, value := range details {
+			if isSensitiveKey(key) {
+				value = "[MASKED]"
+			}
+			safeDetails[key] = value
+		}
+	}
+	return w.send(ctx, WebhookRequest{
+		Operation: operation,
+		Resource:  resource,
+		Message:   fmt.Sprintf("Confirm: %s '%s'?", operation, resource),
+		Details:   safeDetails,
+	})
+}
+
+// send POSTs the request and waits for the approval service's decision
+func (w *WebhookBackend) send(ctx context.Context, request WebhookRequest) *ConfirmationResult {
+	request.RequestedAt = time.Now().UTC()
+	body, err := json.Marshal(request)
+	if err != nil {
+		return &ConfirmationResult{Error: fmt.Errorf("failed to encode confirmation request: %w", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return &ConfirmationResult{Error: fmt.Errorf("failed to build confirmation request: %w", err)}
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if w.config.AuthToken != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+w.config.AuthToken)
+	}
+
+	httpResponse, err := w.client.Do(httpRequest)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+			return &ConfirmationResult{TimedOut: true}
+		}
+		return &ConfirmationResult{Error: fmt.Errorf("confirmation webhook request failed: %w", err)}
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return &ConfirmationResult{Error: fmt.Errorf("confirmation webhook returned status %d", httpResponse.StatusCode)}
+	}
+
+	var decision WebhookResponse
+	if err := json.NewDecoder(io.LimitReader(httpResponse.Body, 1<<20)).Decode(&decision); err != nil {
+		return &ConfirmationResult{Error: fmt.Errorf("invalid confirmation webhook response: %w", err)}
+	}
+	return &ConfirmationResult{Approved: decision.Approved, Reason: decision.Reason}
+}
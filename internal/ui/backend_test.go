@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keeper-security/ksm-mcp/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBackend(t *testing.T) {
+	config := types.Confirmation{Timeout: 30 * time.Second}
+
+	backend, err := NewBackend("", config, WebhookConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, backend, "prompt flow has no synchronous backend")
+
+	backend, err = NewBackend(BackendPrompt, config, WebhookConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, backend)
+
+	backend, err = NewBackend(BackendInteractive, config, WebhookConfig{})
+	assert.NoError(t, err)
+	assert.IsType(t, &Confirmer{}, backend)
+
+	backend, err = NewBackend(BackendWebhook, config, WebhookConfig{URL: "https://approvals.example.com/hook"})
+	assert.NoError(t, err)
+	if assert.IsType(t, &WebhookBackend{}, backend) {
+		assert.Equal(t, 30*time.Second, backend.(*WebhookBackend).config.Timeout, "falls back to the confirmation timeout")
+	}
+
+	_, err = NewBackend(BackendWebhook, config, WebhookConfig{})
+	assert.EqualError(t, err, "webhook confirmation backend requires a URL")
+
+	_, err = NewBackend(BackendWebhook, config, WebhookConfig{URL: "ftp://approvals.example.com"})
+	assert.Error(t, err)
+
+	_, err = NewBackend("slack", config, WebhookConfig{})
+	assert.EqualError(t, err, `unknown confirmation backend "slack": expected "prompt", "interactive" or "webhook"`)
+}
+
+func TestWebhookBackendConfirmOperation(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantApproved bool
+		wantReason   string
+		wantError    string
+	}{
+		{
+			name:         "approved",
+			status:       http.StatusOK,
+			body:         `{"approved": true, "reason": "ticket CHG-42"}`,
+			wantApproved: true,
+			wantReason:   "ticket CHG-42",
+		},
+		{
+			name:       "denied",
+			status:     http.StatusOK,
+			body:       `{"approved": false, "reason": "outside change window"}`,
+			wantReason: "outside change window",
+		},
+		{
+			name:      "server error denies",
+			status:    http.StatusInternalServerError,
+			body:      `oops`,
+			wantError: "confirmation webhook returned status 500",
+		},
+		{
+			name:      "malformed response denies",
+			status:    http.StatusOK,
+			body:      `approved`,
+			wantError: "invalid confirmation webhook response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received WebhookRequest
+			var authHeader string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				authHeader = r.Header.Get("Authorization")
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			backend, err := NewWebhookBackend(WebhookConfig{URL: server.URL, AuthToken: "approver-token", Timeout: 5 * time.Second})
+			require.NoError(t, err)
+
+			result := backend.ConfirmOperation(context.Background(), "delete_secret", "Delete secret uid-1", map[string]interface{}{
+				"warning":  "This cannot be undone",
+				"password": "hunter2",
+			})
+
+			assert.Equal(t, "Bearer approver-token", authHeader)
+			assert.Equal(t, "delete_secret", received.Operation)
+			assert.Equal(t, "Delete secret uid-1", received.Resource)
+			assert.Equal(t, "This cannot be undone", received.Details["warning"])
+			assert.Equal(t, "[MASKED]", received.Details["password"])
+			assert.False(t, received.RequestedAt.IsZero())
+
+			assert.Equal(t, tt.wantApproved, result.Approved)
+			assert.Equal(t, tt.wantReason, result.Reason)
+			if tt.wantError != "" {
+				assert.ErrorContains(t, result.Error, tt.wantError)
+			} else {
+				assert.NoError(t, result.Error)
+			}
+		})
+	}
+}
+
+func TestWebhookBackendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	backend, err := NewWebhookBackend(WebhookConfig{URL: server.URL, Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	result := backend.Confirm(context.Background(), "Reveal unmasked field?")
+	assert.True(t, result.TimedOut)
+	assert.False(t, result.Approved)
+	assert.NoError(t, result.Error)
+}
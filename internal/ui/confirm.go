@@ -14,6 +14,7 @@ type ConfirmationResult struct {
 	Approved bool
 	TimedOut bool
 	Error    error
+	Reason   string // optional explanation from the backend that decided
 }
 
 // Confirmer handles user confirmation prompts
@@ -135,6 +136,11 @@ func (c *Confirmer) buildOperationMessage(operation, resource string, details ma
 
 // isSensitiveKey checks if a key contains sensitive information
 func (c *Confirmer) isSensitiveKey(key string) bool {
+	return isSensitiveKey(key)
+}
+
+// isSensitiveKey checks if a confirmation detail key names sensitive information
+func isSensitiveKey(key string) bool {
 	sensitiveKeys := []string{
 		"password", "secret", "key", "token", "auth", "credential",
 		"private", "passphrase", "pin", "code", "signature",
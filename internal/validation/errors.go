@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Inputs a validation error can be about
+var (
+	ErrInvalidUID         = errors.New("invalid UID")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidProfileName = errors.New("invalid profile name")
+	ErrInvalidFilePath    = errors.New("invalid file path")
+	ErrInvalidNotation    = errors.New("invalid notation")
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+	ErrInvalidFieldName   = errors.New("invalid field name")
+	ErrInvalidTitle       = errors.New("invalid title")
+	ErrInvalidNotes       = errors.New("invalid notes")
+	ErrInvalidURL         = errors.New("invalid URL")
+	ErrInvalidUsername    = errors.New("invalid username")
+	ErrInvalidPassword    = errors.New("invalid password")
+)
+
+// Reasons an input was rejected
+var (
+	ErrEmptyValue       = errors.New("value is empty")
+	ErrTooLong          = errors.New("value is too long")
+	ErrTooShort         = errors.New("value is too short")
+	ErrInvalidFormat    = errors.New("value has an invalid format")
+	ErrReservedName     = errors.New("name is reserved")
+	ErrCommandInjection = errors.New("value contains command injection characters")
+	ErrPathTraversal    = errors.New("value contains path traversal")
+	ErrInjectionPattern = errors.New("value contains injection patterns")
+	ErrHTMLContent      = errors.New("value contains HTML")
+	ErrInvalidUnicode   = errors.New("value contains invalid Unicode characters")
+	ErrDangerousURL     = errors.New("URL uses a dangerous protocol")
+	ErrPolicyViolation  = errors.New("value does not meet the policy")
+)
+
+// Error is a validation failure. Input says what was invalid (ErrInvalidUID,
+// ErrInvalidTitle, ...) and Reason why (ErrEmptyValue, ErrCommandInjection, ...);
+// errors.Is matches either, so callers can assert the specific failure while the
+// message stays human readable.
+type Error struct {
+	Input   error
+	Reason  error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Input, e.Reason}
+}
+
+// newError builds a validation Error with a formatted message
+func newError(input, reason error, format string, args ...interface{}) error {
+	return &Error{Input: input, Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
@@ -5,7 +5,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Validator provides input validation and sanitization
@@ -18,13 +20,58 @@ type Validator struct {
 	// Security patterns to detect injection attempts
 	commandInjectionPatterns []*regexp.Regexp
 	pathTraversalPatterns    []*regexp.Regexp
+
+	// maxNotesLength is the longest notes value accepted, in characters
+	maxNotesLength int
+}
+
+// DefaultMaxNotesLength is the notes length limit used unless one is configured
+const DefaultMaxNotesLength = 10000
+
+// UID length bounds used unless others are configured. Keeper UIDs are currently
+// 22 characters.
+const (
+	DefaultUIDMinLength = 16
+	DefaultUIDMaxLength = 32
+)
+
+// uidBounds holds the accepted UID lengths. UIDs are checked by validators created
+// throughout the server, so the bounds are process-wide rather than per validator.
+var uidBounds = struct {
+	sync.RWMutex
+	min, max int
+}{min: DefaultUIDMinLength, max: DefaultUIDMaxLength}
+
+// SetUIDLengthBounds changes the UID lengths accepted by every validator, so a new
+// Keeper UID format can be allowed without a release. A bound of 0 restores its default.
+func SetUIDLengthBounds(min, max int) error {
+	if min == 0 {
+		min = DefaultUIDMinLength
+	}
+	if max == 0 {
+		max = DefaultUIDMaxLength
+	}
+	if min < 1 || max < min {
+		return fmt.Errorf("invalid UID length bounds %d-%d: the minimum must be at least 1 and not above the maximum", min, max)
+	}
+	uidBounds.Lock()
+	uidBounds.min, uidBounds.max = min, max
+	uidBounds.Unlock()
+	return nil
+}
+
+// UIDLengthBounds returns the shortest and longest UID accepted
+func UIDLengthBounds() (int, int) {
+	uidBounds.RLock()
+	defer uidBounds.RUnlock()
+	return uidBounds.min, uidBounds.max
 }
 
 // NewValidator creates a new validator instance
 func NewValidator() *Validator {
 	return &Validator{
-		// Valid UID format: alphanumeric with underscores and hyphens, 16-32 characters
-		uidPattern: regexp.MustCompile(`^[a-zA-Z0-9_-]{16,32}$`),
+		// Valid UID format: base64url characters with optional padding; the length is checked separately
+		uidPattern: regexp.MustCompile(`^[a-zA-Z0-9_-]+={0,2}$`),
 
 		// Token format: US:TOKEN or EU:TOKEN format
 		tokenPattern: regexp.MustCompile(`^(US|EU|AU|JP|CA|GOV):[A-Za-z0-9+/=_-]+$`),
@@ -32,6 +79,8 @@ func NewValidator() *Validator {
 		// Profile name: alphanumeric with underscores, hyphens, dots (1-64 chars)
 		profileNamePattern: regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`),
 
+		maxNotesLength: DefaultMaxNotesLength,
+
 		// Command injection patterns
 		commandInjectionPatterns: []*regexp.Regexp{
 			regexp.MustCompile(`[;&|]`),     // Command separators
@@ -57,20 +106,21 @@ func NewValidator() *Validator {
 // ValidateUID validates a KSM record UID
 func (v *Validator) ValidateUID(uid string) error {
 	if uid == "" {
-		return fmt.Errorf("UID cannot be empty")
+		return newError(ErrInvalidUID, ErrEmptyValue, "UID cannot be empty")
 	}
 
-	if len(uid) < 16 || len(uid) > 32 {
-		return fmt.Errorf("UID must be between 16 and 32 characters")
+	minLength, maxLength := UIDLengthBounds()
+	if len(uid) < minLength || len(uid) > maxLength {
+		return newError(ErrInvalidUID, ErrInvalidFormat, "UID must be between %d and %d characters", minLength, maxLength)
 	}
 
 	if !v.uidPattern.MatchString(uid) {
-		return fmt.Errorf("invalid UID format: must contain only alphanumeric characters, underscores, and hyphens")
+		return newError(ErrInvalidUID, ErrInvalidFormat, "invalid UID format: must contain only base64url characters (letters, digits, underscores and hyphens), optionally padded with '='")
 	}
 
 	// Check for command injection attempts
 	if v.containsCommandInjection(uid) {
-		return fmt.Errorf("UID contains invalid characters")
+		return newError(ErrInvalidUID, ErrCommandInjection, "UID contains invalid characters")
 	}
 
 	return nil
@@ -79,22 +129,22 @@ func (v *Validator) ValidateUID(uid string) error {
 // ValidateToken validates a KSM one-time token
 func (v *Validator) ValidateToken(token string) error {
 	if token == "" {
-		return fmt.Errorf("token cannot be empty")
+		return newError(ErrInvalidToken, ErrEmptyValue, "token cannot be empty")
 	}
 
 	if !v.tokenPattern.MatchString(token) {
-		return fmt.Errorf("invalid token format: expected format REGION:TOKEN (e.g., US:TOKEN_HERE)")
+		return newError(ErrInvalidToken, ErrInvalidFormat, "invalid token format: expected format REGION:TOKEN (e.g., US:TOKEN_HERE)")
 	}
 
 	// Extract region and token parts
 	parts := strings.SplitN(token, ":", 2)
 	if len(parts) != 2 {
-		return fmt.Errorf("invalid token format: missing region prefix")
+		return newError(ErrInvalidToken, ErrInvalidFormat, "invalid token format: missing region prefix")
 	}
 
 	// Validate token length (minimum reasonable length)
 	if len(parts[1]) < 20 {
-		return fmt.Errorf("token appears to be too short")
+		return newError(ErrInvalidToken, ErrTooShort, "token appears to be too short")
 	}
 
 	return nil
@@ -103,15 +153,15 @@ func (v *Validator) ValidateToken(token string) error {
 // ValidateProfileName validates a profile name
 func (v *Validator) ValidateProfileName(name string) error {
 	if name == "" {
-		return fmt.Errorf("profile name cannot be empty")
+		return newError(ErrInvalidProfileName, ErrEmptyValue, "profile name cannot be empty")
 	}
 
 	if len(name) > 64 {
-		return fmt.Errorf("profile name too long: maximum 64 characters")
+		return newError(ErrInvalidProfileName, ErrTooLong, "profile name too long: maximum 64 characters")
 	}
 
 	if !v.profileNamePattern.MatchString(name) {
-		return fmt.Errorf("invalid profile name: must contain only alphanumeric characters, dots, underscores, and hyphens")
+		return newError(ErrInvalidProfileName, ErrInvalidFormat, "invalid profile name: must contain only alphanumeric characters, dots, underscores, and hyphens")
 	}
 
 	// Check for reserved names
@@ -119,7 +169,7 @@ func (v *Validator) ValidateProfileName(name string) error {
 	nameLower := strings.ToLower(name)
 	for _, reserved := range reservedNames {
 		if nameLower == reserved {
-			return fmt.Errorf("profile name '%s' is reserved", name)
+			return newError(ErrInvalidProfileName, ErrReservedName, "profile name '%s' is reserved", name)
 		}
 	}
 
@@ -129,18 +179,18 @@ func (v *Validator) ValidateProfileName(name string) error {
 // ValidateFilePath validates and sanitizes a file path
 func (v *Validator) ValidateFilePath(path string) error {
 	if path == "" {
-		return fmt.Errorf("file path cannot be empty")
+		return newError(ErrInvalidFilePath, ErrEmptyValue, "file path cannot be empty")
 	}
 
 	// Check for path traversal attempts
 	if v.containsPathTraversal(path) {
-		return fmt.Errorf("file path contains invalid characters or patterns")
+		return newError(ErrInvalidFilePath, ErrPathTraversal, "file path contains invalid characters or patterns")
 	}
 
 	// Check for command injection attempts in file paths
 	// But allow forward slashes which are valid in paths
 	if v.containsFilePathCommandInjection(path) {
-		return fmt.Errorf("file path contains invalid characters")
+		return newError(ErrInvalidFilePath, ErrCommandInjection, "file path contains invalid characters")
 	}
 
 	// Clean the path
@@ -148,12 +198,12 @@ func (v *Validator) ValidateFilePath(path string) error {
 
 	// Ensure it's not trying to access parent directories
 	if strings.HasPrefix(cleaned, "..") {
-		return fmt.Errorf("file path cannot traverse to parent directories")
+		return newError(ErrInvalidFilePath, ErrPathTraversal, "file path cannot traverse to parent directories")
 	}
 
 	// Check for null bytes
 	if strings.Contains(path, "\x00") {
-		return fmt.Errorf("file path contains null bytes")
+		return newError(ErrInvalidFilePath, ErrPathTraversal, "file path contains null bytes")
 	}
 
 	return nil
@@ -162,48 +212,89 @@ func (v *Validator) ValidateFilePath(path string) error {
 // ValidateKSMNotation validates KSM notation strings
 func (v *Validator) ValidateKSMNotation(notation string) error {
 	if notation == "" {
-		return fmt.Errorf("notation cannot be empty")
+		return newError(ErrInvalidNotation, ErrEmptyValue, "notation cannot be empty")
 	}
 
 	// Check for command injection
 	if v.containsCommandInjection(notation) {
-		return fmt.Errorf("notation contains invalid characters")
+		return newError(ErrInvalidNotation, ErrCommandInjection, "notation contains invalid characters")
 	}
 
 	// Basic notation format validation
 	// Formats: UID/field/name, Title/field/name, UID/file/filename
-	parts := strings.Split(notation, "/")
+	// A '/' inside a title, label or filename is escaped as '\/'
+	parts, err := SplitNotation(notation)
+	if err != nil {
+		return err
+	}
 	if len(parts) < 2 {
-		return fmt.Errorf("invalid notation format: expected at least 2 parts separated by '/'")
+		return newError(ErrInvalidNotation, ErrInvalidFormat, "invalid notation format: expected at least 2 parts separated by '/'")
 	}
 
 	// Validate each part doesn't contain injection attempts
 	for _, part := range parts {
 		if v.containsCommandInjection(part) {
-			return fmt.Errorf("notation part contains invalid characters")
+			return newError(ErrInvalidNotation, ErrCommandInjection, "notation part contains invalid characters")
 		}
 		// Check for path traversal patterns in each part
 		if strings.Contains(part, "..") {
-			return fmt.Errorf("notation contains path traversal patterns")
+			return newError(ErrInvalidNotation, ErrPathTraversal, "notation contains path traversal patterns")
 		}
 	}
 
 	return nil
 }
 
+// NotationSpecialChars are the characters escaped with a backslash inside a notation
+// part such as a title, field label or filename, matching the KSM SDK convention
+// (e.g. "prod\/db")
+const NotationSpecialChars = `/[]\`
+
+// SplitNotation splits a notation on unescaped '/' delimiters, rejecting escape
+// sequences of characters other than NotationSpecialChars. Escape sequences are kept
+// in the returned parts so field parts can still be matched against the array and
+// property patterns.
+func SplitNotation(notation string) ([]string, error) {
+	var parts []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range notation {
+		switch {
+		case escaped:
+			if !strings.ContainsRune(NotationSpecialChars, r) {
+				return nil, newError(ErrInvalidNotation, ErrInvalidFormat, "invalid escape sequence '\\%c' in notation", r)
+			}
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '/':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, newError(ErrInvalidNotation, ErrInvalidFormat, "notation cannot end with an incomplete escape sequence")
+	}
+	return append(parts, current.String()), nil
+}
+
 // ValidateSearchQuery validates a search query
 func (v *Validator) ValidateSearchQuery(query string) error {
 	if query == "" {
-		return fmt.Errorf("search query cannot be empty")
+		return newError(ErrInvalidSearchQuery, ErrEmptyValue, "search query cannot be empty")
 	}
 
 	if len(query) > 256 {
-		return fmt.Errorf("search query too long: maximum 256 characters")
+		return newError(ErrInvalidSearchQuery, ErrTooLong, "search query too long: maximum 256 characters")
 	}
 
 	// Check for injection attempts
 	if v.containsCommandInjection(query) {
-		return fmt.Errorf("search query contains invalid characters")
+		return newError(ErrInvalidSearchQuery, ErrCommandInjection, "search query contains invalid characters")
 	}
 
 	// Check for SQL injection patterns (even though we're not using SQL)
@@ -215,19 +306,19 @@ func (v *Validator) ValidateSearchQuery(query string) error {
 	queryLower := strings.ToLower(query)
 	for _, pattern := range sqlPatterns {
 		if strings.Contains(queryLower, pattern) {
-			return fmt.Errorf("search query contains suspicious patterns")
+			return newError(ErrInvalidSearchQuery, ErrInjectionPattern, "search query contains suspicious patterns")
 		}
 	}
 
 	// Check for LDAP injection
 	if strings.Contains(query, "*)(") || strings.Contains(query, ")(|") {
-		return fmt.Errorf("search query contains invalid characters")
+		return newError(ErrInvalidSearchQuery, ErrInjectionPattern, "search query contains invalid characters")
 	}
 
 	// Check for NoSQL injection
 	if strings.Contains(query, "$ne") || strings.Contains(query, "$regex") ||
 		strings.Contains(query, "$gt") || strings.Contains(query, "$lt") {
-		return fmt.Errorf("search query contains invalid characters")
+		return newError(ErrInvalidSearchQuery, ErrInjectionPattern, "search query contains invalid characters")
 	}
 
 	return nil
@@ -268,7 +359,7 @@ func (v *Validator) SanitizeForShell(input string) string {
 func (v *Validator) ValidateMapKeys(data map[string]interface{}) error {
 	for key := range data {
 		if v.containsCommandInjection(key) {
-			return fmt.Errorf("map key '%s' contains invalid characters", key)
+			return newError(ErrInvalidFieldName, ErrCommandInjection, "map key '%s' contains invalid characters", key)
 		}
 	}
 	return nil
@@ -277,22 +368,22 @@ func (v *Validator) ValidateMapKeys(data map[string]interface{}) error {
 // ValidateJSONField validates a field name for JSON usage
 func (v *Validator) ValidateJSONField(field string) error {
 	if field == "" {
-		return fmt.Errorf("field name cannot be empty")
+		return newError(ErrInvalidFieldName, ErrEmptyValue, "field name cannot be empty")
 	}
 
 	// Check for dots (property access)
 	if strings.Contains(field, ".") {
-		return fmt.Errorf("field name cannot contain dots")
+		return newError(ErrInvalidFieldName, ErrInvalidFormat, "field name cannot contain dots")
 	}
 
 	// Check for brackets (array access)
 	if strings.Contains(field, "[") || strings.Contains(field, "]") {
-		return fmt.Errorf("field name cannot contain brackets")
+		return newError(ErrInvalidFieldName, ErrInvalidFormat, "field name cannot contain brackets")
 	}
 
 	// Check for quotes
 	if strings.Contains(field, "\"") || strings.Contains(field, "'") {
-		return fmt.Errorf("field name cannot contain quotes")
+		return newError(ErrInvalidFieldName, ErrInvalidFormat, "field name cannot contain quotes")
 	}
 
 	return nil
@@ -301,48 +392,69 @@ func (v *Validator) ValidateJSONField(field string) error {
 // ValidateTitle validates a title field
 func (v *Validator) ValidateTitle(title string) error {
 	if title == "" {
-		return fmt.Errorf("title cannot be empty")
+		return newError(ErrInvalidTitle, ErrEmptyValue, "title cannot be empty")
 	}
 
 	if len(title) > 255 {
-		return fmt.Errorf("title cannot exceed 255 characters")
+		return newError(ErrInvalidTitle, ErrTooLong, "title cannot exceed 255 characters")
 	}
 
 	if v.containsCommandInjection(title) {
-		return fmt.Errorf("title contains invalid characters")
+		return newError(ErrInvalidTitle, ErrCommandInjection, "title contains invalid characters")
 	}
 
 	// Check for XSS attempts
 	if v.containsHTML(title) {
-		return fmt.Errorf("title cannot contain HTML")
+		return newError(ErrInvalidTitle, ErrHTMLContent, "title cannot contain HTML")
 	}
 
 	// Check for dangerous Unicode characters
 	if v.containsDangerousUnicode(title) {
-		return fmt.Errorf("title contains invalid Unicode characters")
+		return newError(ErrInvalidTitle, ErrInvalidUnicode, "title contains invalid Unicode characters")
 	}
 
 	return nil
 }
 
+// SetMaxNotesLength changes the notes length limit; a value of 0 or less restores
+// DefaultMaxNotesLength
+func (v *Validator) SetMaxNotesLength(limit int) {
+	if limit <= 0 {
+		limit = DefaultMaxNotesLength
+	}
+	v.maxNotesLength = limit
+}
+
+// ValidateNotesLength checks notes against the configured length limit only
+func (v *Validator) ValidateNotesLength(notes string) error {
+	limit := v.maxNotesLength
+	if limit <= 0 {
+		limit = DefaultMaxNotesLength
+	}
+	if utf8.RuneCountInString(notes) > limit {
+		return newError(ErrInvalidNotes, ErrTooLong, "notes cannot exceed %d characters", limit)
+	}
+	return nil
+}
+
 // ValidateNotes validates notes field
 func (v *Validator) ValidateNotes(notes string) error {
-	if len(notes) > 10000 {
-		return fmt.Errorf("notes cannot exceed 10000 characters")
+	if err := v.ValidateNotesLength(notes); err != nil {
+		return err
 	}
 
 	if v.containsCommandInjection(notes) {
-		return fmt.Errorf("notes contain invalid characters")
+		return newError(ErrInvalidNotes, ErrCommandInjection, "notes contain invalid characters")
 	}
 
 	// Check for XSS attempts
 	if v.containsHTML(notes) {
-		return fmt.Errorf("notes cannot contain HTML")
+		return newError(ErrInvalidNotes, ErrHTMLContent, "notes cannot contain HTML")
 	}
 
 	// Check for format string vulnerabilities
 	if strings.Contains(notes, "%n") {
-		return fmt.Errorf("notes contain invalid format specifiers")
+		return newError(ErrInvalidNotes, ErrInjectionPattern, "notes contain invalid format specifiers")
 	}
 
 	return nil
@@ -355,7 +467,7 @@ func (v *Validator) ValidateURL(url string) error {
 	}
 
 	if len(url) > 2048 {
-		return fmt.Errorf("URL cannot exceed 2048 characters")
+		return newError(ErrInvalidURL, ErrTooLong, "URL cannot exceed 2048 characters")
 	}
 
 	// Check for dangerous protocols
@@ -363,12 +475,12 @@ func (v *Validator) ValidateURL(url string) error {
 	dangerousProtocols := []string{"javascript:", "data:", "vbscript:", "file:"}
 	for _, proto := range dangerousProtocols {
 		if strings.HasPrefix(lowerURL, proto) {
-			return fmt.Errorf("URL contains dangerous protocol")
+			return newError(ErrInvalidURL, ErrDangerousURL, "URL contains dangerous protocol")
 		}
 	}
 
 	if v.containsCommandInjection(url) {
-		return fmt.Errorf("URL contains invalid characters")
+		return newError(ErrInvalidURL, ErrCommandInjection, "URL contains invalid characters")
 	}
 
 	return nil
@@ -381,18 +493,18 @@ func (v *Validator) ValidateUsername(username string) error {
 	}
 
 	if len(username) > 255 {
-		return fmt.Errorf("username cannot exceed 255 characters")
+		return newError(ErrInvalidUsername, ErrTooLong, "username cannot exceed 255 characters")
 	}
 
 	if v.containsCommandInjection(username) {
-		return fmt.Errorf("username contains invalid characters")
+		return newError(ErrInvalidUsername, ErrCommandInjection, "username contains invalid characters")
 	}
 
 	// Check for LDAP injection
 	ldapDangerous := []string{"*", "(", ")", "\\", "/", "\x00"}
 	for _, char := range ldapDangerous {
 		if strings.Contains(username, char) {
-			return fmt.Errorf("username contains invalid characters")
+			return newError(ErrInvalidUsername, ErrInjectionPattern, "username contains invalid characters")
 		}
 	}
 
@@ -495,8 +607,33 @@ func (v *Validator) IsAlphanumeric(s string) bool {
 
 // ValidatePasswordStrength validates password meets minimum requirements
 func (v *Validator) ValidatePasswordStrength(password string) error {
-	if len(password) < 12 {
-		return fmt.Errorf("password must be at least 12 characters long")
+	return v.ValidatePasswordPolicy(password, DefaultPasswordPolicy())
+}
+
+// PasswordPolicy describes the composition rules a password must satisfy
+type PasswordPolicy struct {
+	MinLength      int  `json:"min_length" mapstructure:"min_length"`
+	RequireUpper   bool `json:"require_upper" mapstructure:"require_upper"`
+	RequireLower   bool `json:"require_lower" mapstructure:"require_lower"`
+	RequireDigit   bool `json:"require_digit" mapstructure:"require_digit"`
+	RequireSpecial bool `json:"require_special" mapstructure:"require_special"`
+}
+
+// DefaultPasswordPolicy returns the policy enforced by ValidatePasswordStrength
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:      12,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+	}
+}
+
+// ValidatePasswordPolicy validates a password against the given policy
+func (v *Validator) ValidatePasswordPolicy(password string, policy PasswordPolicy) error {
+	if len(password) < policy.MinLength {
+		return newError(ErrInvalidPassword, ErrPolicyViolation, "password must be at least %d characters long", policy.MinLength)
 	}
 
 	var hasUpper, hasLower, hasDigit, hasSpecial bool
@@ -514,8 +651,33 @@ func (v *Validator) ValidatePasswordStrength(password string) error {
 		}
 	}
 
-	if !hasUpper || !hasLower || !hasDigit || !hasSpecial {
-		return fmt.Errorf("password must contain uppercase, lowercase, digits, and special characters")
+	var required []string
+	satisfied := true
+	for _, class := range []struct {
+		required bool
+		present  bool
+		name     string
+	}{
+		{policy.RequireUpper, hasUpper, "uppercase"},
+		{policy.RequireLower, hasLower, "lowercase"},
+		{policy.RequireDigit, hasDigit, "digits"},
+		{policy.RequireSpecial, hasSpecial, "special characters"},
+	} {
+		if class.required {
+			required = append(required, class.name)
+			satisfied = satisfied && class.present
+		}
+	}
+
+	if !satisfied {
+		if len(required) > 1 {
+			required[len(required)-1] = "and " + required[len(required)-1]
+		}
+		separator := ", "
+		if len(required) == 2 {
+			separator = " "
+		}
+		return newError(ErrInvalidPassword, ErrPolicyViolation, "password must contain %s", strings.Join(required, separator))
 	}
 
 	return nil
@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -39,6 +41,7 @@ func TestValidateUID(t *testing.T) {
 		{"valid uid 32 chars", "12345678901234567890123456789012", false},
 		{"valid with underscore", "NJ_xXSkk3xYI1h9ql5lAiQ", false},
 		{"valid with hyphen", "abc-def-123-456-789", false},
+		{"valid with base64url padding", "NJ_xXSkk3xYI1h9ql5lAiQ==", false},
 
 		// Invalid UIDs
 		{"empty", "", true},
@@ -51,6 +54,9 @@ func TestValidateUID(t *testing.T) {
 		{"command injection backtick", "valid123456789012`whoami`", true},
 		{"with newline", "valid123456789012\n", true},
 		{"with null byte", "valid123456789012\x00", true},
+		{"too much padding", "NJ_xXSkk3xYI1h9ql5lAiQ===", true},
+		{"padding inside", "NJ_xXSkk3x=YI1h9ql5lAiQ", true},
+		{"only padding", "================", true},
 	}
 
 	for _, tt := range tests {
@@ -63,6 +69,54 @@ func TestValidateUID(t *testing.T) {
 	}
 }
 
+func TestUIDLengthBounds(t *testing.T) {
+	defer func() {
+		if err := SetUIDLengthBounds(0, 0); err != nil {
+			t.Fatalf("restoring default bounds: %v", err)
+		}
+	}()
+
+	if min, max := UIDLengthBounds(); min != DefaultUIDMinLength || max != DefaultUIDMaxLength {
+		t.Fatalf("default bounds = %d-%d, want %d-%d", min, max, DefaultUIDMinLength, DefaultUIDMaxLength)
+	}
+
+	for _, bounds := range [][2]int{{-1, 32}, {16, -1}, {20, 10}} {
+		if err := SetUIDLengthBounds(bounds[0], bounds[1]); err == nil {
+			t.Errorf("SetUIDLengthBounds(%d, %d) should fail", bounds[0], bounds[1])
+		}
+	}
+
+	if err := SetUIDLengthBounds(8, 40); err != nil {
+		t.Fatalf("SetUIDLengthBounds(8, 40) error = %v", err)
+	}
+	v := NewValidator()
+	tests := []struct {
+		length  int
+		wantErr bool
+	}{
+		{7, true},
+		{8, false},
+		{16, false},
+		{33, false},
+		{40, false},
+		{41, true},
+	}
+	for _, tt := range tests {
+		uid := strings.Repeat("a", tt.length)
+		if err := v.ValidateUID(uid); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateUID(%d chars) error = %v, wantErr %v", tt.length, err, tt.wantErr)
+		}
+	}
+
+	// Only the unset bound falls back to its default
+	if err := SetUIDLengthBounds(20, 0); err != nil {
+		t.Fatalf("SetUIDLengthBounds(20, 0) error = %v", err)
+	}
+	if min, max := UIDLengthBounds(); min != 20 || max != DefaultUIDMaxLength {
+		t.Errorf("bounds = %d-%d, want 20-%d", min, max, DefaultUIDMaxLength)
+	}
+}
+
 func TestValidateToken(t *testing.T) {
 	v := NewValidator()
 
@@ -194,6 +248,8 @@ func TestValidateKSMNotation(t *testing.T) {
 		{"nested array", "UID123/custom_field/phone[0][number]", false},
 		{"file notation", "UID123/file/document.pdf", false},
 		{"title search", "MyTitle/field/password", false},
+		{"title with escaped slash", `prod\/db/field/password`, false},
+		{"escaped brackets in label", `prod\/db/custom_field/list\[1\]`, false},
 
 		// Invalid notations
 		{"empty", "", true},
@@ -202,6 +258,9 @@ func TestValidateKSMNotation(t *testing.T) {
 		{"pipe injection", "UID123/field/password|cat", true},
 		{"backtick injection", "UID123/field/`password`", true},
 		{"newline injection", "UID123/field/password\n", true},
+		{"invalid escape sequence", `prod\db/field/password`, true},
+		{"incomplete escape sequence", `UID123/field/password\`, true},
+		{"escaped traversal", `prod\/../field/password`, true},
 	}
 
 	for _, tt := range tests {
@@ -430,6 +489,123 @@ func TestValidatePasswordStrength(t *testing.T) {
 	}
 }
 
+func TestValidatePasswordPolicy(t *testing.T) {
+	v := NewValidator()
+
+	strict := PasswordPolicy{MinLength: 20, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSpecial: true}
+	digitsOnly := PasswordPolicy{MinLength: 6, RequireDigit: true}
+
+	tests := []struct {
+		name     string
+		password string
+		policy   PasswordPolicy
+		wantErr  string
+	}{
+		{"strict policy satisfied", "MyStr0ng!Pass123-Long", strict, ""},
+		{"strict policy too short", "MyStr0ng!Pass123", strict, "at least 20 characters"},
+		{"strict policy missing special", "MyStr0ngPass123456789", strict, "uppercase, lowercase, digits, and special characters"},
+		{"digits only satisfied", "123456", digitsOnly, ""},
+		{"digits only missing digit", "abcdef", digitsOnly, "must contain digits"},
+		{"two classes message", "abcdef", PasswordPolicy{RequireUpper: true, RequireDigit: true}, "must contain uppercase and digits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidatePasswordPolicy(tt.password, tt.policy)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePasswordPolicy() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePasswordPolicy() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNotesLength(t *testing.T) {
+	v := NewValidator()
+
+	// The default limit is 10000 characters
+	if err := v.ValidateNotes(strings.Repeat("a", DefaultMaxNotesLength)); err != nil {
+		t.Errorf("notes at the default limit rejected: %v", err)
+	}
+	err := v.ValidateNotes(strings.Repeat("a", DefaultMaxNotesLength+1))
+	if err == nil || !strings.Contains(err.Error(), "10000") {
+		t.Errorf("expected default limit error, got %v", err)
+	}
+
+	// A custom limit applies and is named in the error
+	v.SetMaxNotesLength(50000)
+	if err := v.ValidateNotes(strings.Repeat("a", 20000)); err != nil {
+		t.Errorf("notes under a raised limit rejected: %v", err)
+	}
+	v.SetMaxNotesLength(10)
+	err = v.ValidateNotesLength(strings.Repeat("a", 11))
+	if err == nil || !strings.Contains(err.Error(), "cannot exceed 10 characters") {
+		t.Errorf("expected custom limit error, got %v", err)
+	}
+
+	// The limit counts characters, not bytes
+	if err := v.ValidateNotesLength(strings.Repeat("世", 10)); err != nil {
+		t.Errorf("multi-byte notes within the limit rejected: %v", err)
+	}
+
+	// Zero restores the default
+	v.SetMaxNotesLength(0)
+	if err := v.ValidateNotesLength(strings.Repeat("a", 11)); err != nil {
+		t.Errorf("expected default limit after reset, got %v", err)
+	}
+}
+
+func TestValidationErrorKinds(t *testing.T) {
+	v := NewValidator()
+
+	tests := []struct {
+		name   string
+		err    error
+		input  error
+		reason error
+	}{
+		{"empty UID", v.ValidateUID(""), ErrInvalidUID, ErrEmptyValue},
+		{"short UID", v.ValidateUID("abc"), ErrInvalidUID, ErrInvalidFormat},
+		{"title injection", v.ValidateTitle("a; rm -rf /"), ErrInvalidTitle, ErrCommandInjection},
+		{"title HTML", v.ValidateTitle("javascript:alert(1)"), ErrInvalidTitle, ErrHTMLContent},
+		{"path traversal", v.ValidateFilePath("../../etc/passwd"), ErrInvalidFilePath, ErrPathTraversal},
+		{"notation traversal", v.ValidateKSMNotation("UID/file/../secret"), ErrInvalidNotation, ErrPathTraversal},
+		{"reserved profile", v.ValidateProfileName("admin"), ErrInvalidProfileName, ErrReservedName},
+		{"dangerous URL", v.ValidateURL("javascript:alert(1)"), ErrInvalidURL, ErrDangerousURL},
+		{"weak password", v.ValidatePasswordPolicy("short", DefaultPasswordPolicy()), ErrInvalidPassword, ErrPolicyViolation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("expected a validation error")
+			}
+			if !errors.Is(tt.err, tt.input) {
+				t.Errorf("error %q does not match input %v", tt.err, tt.input)
+			}
+			if !errors.Is(tt.err, tt.reason) {
+				t.Errorf("error %q does not match reason %v", tt.err, tt.reason)
+			}
+
+			// The kinds survive wrapping by callers
+			var validationErr *Error
+			if !errors.As(fmt.Errorf("invalid input: %w", tt.err), &validationErr) {
+				t.Errorf("wrapped error is not a *Error")
+			}
+		})
+	}
+
+	// Messages are unchanged
+	if err := v.ValidateUID(""); err.Error() != "UID cannot be empty" {
+		t.Errorf("unexpected message %q", err)
+	}
+}
+
 func TestTruncateString(t *testing.T) {
 	v := NewValidator()
 
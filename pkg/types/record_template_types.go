@@ -2,10 +2,11 @@ package types
 
 // Based on record-templates/fields.json
 type TemplateBasicField struct {
-	ID       string `json:"$id"`
-	Type     string `json:"type"`
-	Lookup   string `json:"lookup,omitempty"`
-	Multiple string `json:"multiple,omitempty"` // e.g., "optional", "default"
+	ID       string   `json:"$id"`
+	Type     string   `json:"type"`
+	Lookup   string   `json:"lookup,omitempty"`
+	Multiple string   `json:"multiple,omitempty"` // e.g., "optional", "default"
+	Enum     []string `json:"enum,omitempty"`     // Allowed values for dropdown fields
 }
 
 // Based on record-templates/field-types.json
@@ -48,6 +49,7 @@ type SchemaField struct {
 	Required      bool          `json:"required"`
 	ExampleValues []string      `json:"example_values,omitempty"`
 	SubFields     []SchemaField `json:"sub_fields,omitempty"` // For explicitly showing structure of complex types, if not fully flattened
+	Ref           string        `json:"ref,omitempty"`        // Field type ($ref) stored on the record, e.g. "text" for a labeled text field
 }
 
 // RecordTypeSchema is the structure returned by the get_record_type_schema tool
@@ -57,3 +59,16 @@ type RecordTypeSchema struct {
 	Fields      []SchemaField `json:"fields"`
 	Notes       string        `json:"notes,omitempty"` // General notes about creating this record type
 }
+
+// FieldTypeSchema is the structure returned by the get_field_type_schema tool
+type FieldTypeSchema struct {
+	FieldType     string        `json:"field_type"` // field name as used in create_secret, e.g. "host" or "wifiEncryption"
+	Type          string        `json:"type"`       // underlying type from field-types.json, e.g. "dropdown"
+	Description   string        `json:"description,omitempty"`
+	SubFields     []SchemaField `json:"sub_fields,omitempty"` // flattened sub-fields of complex types, e.g. "host.port"
+	IsEnum        bool          `json:"is_enum"`
+	AllowedValues []string      `json:"allowed_values,omitempty"` // allowed values of enum fields
+	Multiple      string        `json:"multiple,omitempty"`       // whether the field may hold several values, e.g. "optional"
+	Masked        bool          `json:"masked"`                   // whether values are masked unless unmasking is confirmed
+	Notes         string        `json:"notes,omitempty"`
+}
@@ -12,10 +12,24 @@ type Profile struct {
 
 // SecretMetadata represents basic information about a secret
 type SecretMetadata struct {
-	UID    string `json:"uid"`
-	Title  string `json:"title"`
-	Type   string `json:"type"`
-	Folder string `json:"folder,omitempty"`
+	UID     string `json:"uid"`
+	Title   string `json:"title"`
+	Type    string `json:"type"`
+	Folder  string `json:"folder,omitempty"`
+	HasTOTP *bool  `json:"has_totp,omitempty"`
+
+	// Set by list_secrets when last-accessed times are requested
+	LastAccessed *time.Time `json:"last_accessed,omitempty"` // last successful use through this server, per the audit log
+	AccessCount  *int       `json:"access_count,omitempty"`
+}
+
+// FuzzyMatch is a secret whose title approximately matches a query
+type FuzzyMatch struct {
+	UID    string  `json:"uid"`
+	Title  string  `json:"title"`
+	Type   string  `json:"type"`
+	Folder string  `json:"folder,omitempty"`
+	Score  float64 `json:"score"` // 0 to 1; 1 is an exact (case-insensitive) title match
 }
 
 // ListSecretsParams parameters for listing secrets
@@ -45,14 +59,26 @@ type GetFieldParams struct {
 
 // GeneratePasswordParams parameters for password generation
 type GeneratePasswordParams struct {
-	Length       int    `json:"length,omitempty"`
-	Lowercase    int    `json:"lowercase,omitempty"`
-	Uppercase    int    `json:"uppercase,omitempty"`
-	Digits       int    `json:"digits,omitempty"`
-	Special      int    `json:"special,omitempty"`
-	SpecialSet   string `json:"special_set,omitempty"`
-	SaveToSecret string `json:"save_to_secret,omitempty"` // Title of the secret to save to
-	FolderUID    string `json:"folder_uid,omitempty"`     // Optional: UID of the folder to save the secret in
+	Length           int    `json:"length,omitempty"`
+	Lowercase        int    `json:"lowercase,omitempty"`
+	Uppercase        int    `json:"uppercase,omitempty"`
+	Digits           int    `json:"digits,omitempty"`
+	Special          int    `json:"special,omitempty"`
+	SpecialSet       string `json:"special_set,omitempty"`
+	ExcludeSimilar   bool   `json:"exclude_similar,omitempty"`   // Leave out look-alike characters such as l, 1, O and 0
+	ExcludeAmbiguous bool   `json:"exclude_ambiguous,omitempty"` // Leave out brackets, quotes and other hard to read symbols
+	SaveToSecret     string `json:"save_to_secret,omitempty"`    // Title of the secret to save to
+	FolderUID        string `json:"folder_uid,omitempty"`        // Optional: UID of the folder to save the secret in
+}
+
+// PasswordComposition describes the character classes of a generated password
+type PasswordComposition struct {
+	Length      int     `json:"length"`
+	Lowercase   int     `json:"lowercase"`
+	Uppercase   int     `json:"uppercase"`
+	Digits      int     `json:"digits"`
+	Special     int     `json:"special"`
+	EntropyBits float64 `json:"entropy_bits"` // Estimate: length × log2 of the pool of the classes used
 }
 
 // GetTOTPParams parameters for getting TOTP code
@@ -66,6 +92,13 @@ type TOTPResponse struct {
 	TimeLeft int    `json:"time_left"` // Seconds until expiry
 }
 
+// TOTPVerification is the result of checking a TOTP code against a secret's seed
+type TOTPVerification struct {
+	UID   string `json:"uid"`
+	Valid bool   `json:"valid"`
+	Drift int    `json:"drift"` // periods between the matching window and now (-1, 0 or 1)
+}
+
 // SecretField represents a field in a secret
 type SecretField struct {
 	Type  string        `json:"type"`
@@ -83,10 +116,11 @@ type CreateSecretParams struct {
 
 // UpdateSecretParams parameters for updating a secret
 type UpdateSecretParams struct {
-	UID    string        `json:"uid"`
-	Title  string        `json:"title,omitempty"`
-	Fields []SecretField `json:"fields,omitempty"`
-	Notes  string        `json:"notes,omitempty"`
+	UID       string        `json:"uid"`
+	Title     string        `json:"title,omitempty"`
+	Fields    []SecretField `json:"fields,omitempty"`
+	Notes     string        `json:"notes,omitempty"`
+	ClearTOTP bool          `json:"clear_totp,omitempty"` // remove the record's oneTimeCode/otp fields
 }
 
 // DeleteSecretParams parameters for deleting a secret
@@ -102,6 +136,15 @@ type UploadFileParams struct {
 	Title    string `json:"title,omitempty"`
 }
 
+// UploadedFile describes a file attached to a record
+type UploadedFile struct {
+	FileUID  string `json:"file_uid"`
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"` // bytes
+}
+
 // DownloadFileParams parameters for downloading a file
 type DownloadFileParams struct {
 	UID      string `json:"uid"`      // Record UID
@@ -121,6 +164,106 @@ type ListFoldersResponse struct {
 	Folders []FolderInfo `json:"folders"`
 }
 
+// WritableFolder is a folder the application is expected to be able to create records in
+type WritableFolder struct {
+	UID             string `json:"uid"`
+	Name            string `json:"name"`
+	ParentUID       string `json:"parent_uid,omitempty"`
+	SharedFolderUID string `json:"shared_folder_uid"` // the shared folder the records end up in
+	IsSharedFolder  bool   `json:"is_shared_folder"`
+}
+
+// SharedFolderSummary is a shared folder with the number of records it holds
+type SharedFolderSummary struct {
+	UID         string `json:"uid"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	RecordCount int    `json:"record_count"`
+	Error       string `json:"error,omitempty"` // set when the records could not be counted
+}
+
+// BatchResult holds the secrets a batch read returned and the UIDs that failed
+type BatchResult struct {
+	Results []map[string]interface{} `json:"results"`
+	Errors  []BatchError             `json:"errors"`
+}
+
+// BatchError is a UID a batch operation could not process
+type BatchError struct {
+	UID   string `json:"uid"`
+	Error string `json:"error"`
+}
+
+// CustomFieldInfo describes a custom field of a record without its value
+type CustomFieldInfo struct {
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	Sensitive  bool   `json:"sensitive"`   // masked by get_secret and get_field unless unmasked
+	ValueCount int    `json:"value_count"` // number of values stored in the field
+	Notation   string `json:"notation"`    // get_field notation for the field
+}
+
+// FieldLintIssue describes a record field whose stored structure does not match its type
+type FieldLintIssue struct {
+	Section   string `json:"section"` // "fields" or "custom"
+	Index     int    `json:"index"`
+	FieldType string `json:"field_type,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Problem   string `json:"problem"`
+}
+
+// RecordLintReport lists the structural problems found in one record
+type RecordLintReport struct {
+	UID    string           `json:"uid"`
+	Title  string           `json:"title"`
+	Type   string           `json:"type"`
+	Issues []FieldLintIssue `json:"issues"`
+}
+
+// LintResult is the outcome of checking record field structures
+type LintResult struct {
+	RecordsChecked int                `json:"records_checked"`
+	Records        []RecordLintReport `json:"records"` // only records with problems
+}
+
+// MissingFieldsRecord is a record without values for fields its record type requires
+type MissingFieldsRecord struct {
+	UID     string   `json:"uid"`
+	Title   string   `json:"title"`
+	Type    string   `json:"type"`
+	Missing []string `json:"missing"` // schema field names, e.g. "login" or "pamHostname.port"
+}
+
+// MissingFieldsResult is the outcome of checking records for missing required fields
+type MissingFieldsResult struct {
+	RecordsChecked int                   `json:"records_checked"`
+	Records        []MissingFieldsRecord `json:"records"`                 // only records missing required fields
+	UnknownTypes   map[string]int        `json:"unknown_types,omitempty"` // record types without a schema, with the number of records skipped
+}
+
+// DuplicateRecord is a member of a group of duplicate records
+type DuplicateRecord struct {
+	UID       string `json:"uid"`
+	Title     string `json:"title"`
+	FolderUID string `json:"folder_uid,omitempty"`
+}
+
+// DuplicateGroup lists records that share a title, a type and the values of the
+// compared key fields
+type DuplicateGroup struct {
+	Title   string            `json:"title"`
+	Type    string            `json:"type"`
+	Count   int               `json:"count"`
+	Records []DuplicateRecord `json:"records"`
+}
+
+// DuplicatesResult is the outcome of searching for duplicate records
+type DuplicatesResult struct {
+	RecordsChecked int              `json:"records_checked"`
+	KeyFields      []string         `json:"key_fields,omitempty"` // fields whose values were compared
+	Groups         []DuplicateGroup `json:"groups"`               // only groups with more than one record
+}
+
 // CreateFolderParams parameters for creating a folder
 type CreateFolderParams struct {
 	Name      string `json:"name"`
@@ -185,6 +328,9 @@ type Confirmation struct {
 // ProfileMetadata represents metadata about a profile
 type ProfileMetadata struct {
 	Name      string    `json:"name"`
+	Hostname  string    `json:"hostname,omitempty"`  // KSM server the profile's application targets
+	Region    string    `json:"region,omitempty"`    // region code (US, EU, AU, GOV, JP, CA) when the hostname is a known Keeper region
+	ClientID  string    `json:"client_id,omitempty"` // masked client ID; never the full value
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }